@@ -17,6 +17,7 @@ import (
 	"github.com/nkhang/pluto/internal/dataset"
 	"github.com/nkhang/pluto/internal/image"
 	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/outbox"
 	"github.com/nkhang/pluto/internal/project"
 	"github.com/nkhang/pluto/internal/tool"
 	"github.com/nkhang/pluto/internal/tool/toolapi"
@@ -37,12 +38,15 @@ type params struct {
 	TaskService      pgin.StandaloneRouter `name:"TaskService"`
 	ImageService     pgin.StandaloneRouter `name:"ImageService"`
 	TaskServiceIns   *taskapi.Service      `name:"TaskService"`
+	OutboxService    pgin.StandaloneRouter `name:"OutboxService"`
+	StorageService   pgin.StandaloneRouter `name:"StorageService"`
 }
 
 func initializer(l fx.Lifecycle, p params) {
 	migrate(p.GormDB)
 	router := p.Router.Group("/pluto/api/v1")
 	p.ImageService.RegisterStandalone(router.Group("/images"))
+	p.StorageService.RegisterStandalone(router.Group("/storage"))
 	p.TaskServiceIns.RegisterInternal(router.Group(""))
 	if viper.GetBool("service.authen") {
 		router.Use(pgin.ApplyVerifyToken())
@@ -51,6 +55,7 @@ func initializer(l fx.Lifecycle, p params) {
 	p.ProjectService.RegisterStandalone(router.Group("/projects"))
 	p.WorkspaceService.RegisterStandalone(router.Group("/workspaces"))
 	p.TaskService.RegisterStandalone(router.Group("/tasks"))
+	p.OutboxService.RegisterStandalone(router.Group("/admin/outbox"))
 	l.Append(
 		fx.Hook{
 			OnStart: func(ctx context.Context) error {
@@ -75,18 +80,26 @@ func initializer(l fx.Lifecycle, p params) {
 
 func migrate(db *gorm.DB) {
 	db.AutoMigrate(&tool.Tool{})
-	db.FirstOrCreate(&tool.Tool{Name: "RECTANGLE"}, "name = ?", "RECTANGLE")
-	db.FirstOrCreate(&tool.Tool{Name: "POINT"}, "name = ?", "POINT")
-	db.FirstOrCreate(&tool.Tool{Name: "POLYLINE"}, "name = ?", "POLYLINE")
-	db.FirstOrCreate(&tool.Tool{Name: "POLYGON"}, "name = ?", "POLYGON")
+	for _, t := range tool.Builtins() {
+		db.Where("name = ?", t.Name).Attrs(t).FirstOrCreate(&tool.Tool{})
+		db.Model(&tool.Tool{}).Where("name = ? AND kind = ?", t.Name, "").
+			Updates(map[string]interface{}{"kind": t.Kind, "config": t.Config})
+	}
 	db.AutoMigrate(&dataset.Dataset{})
 	db.AutoMigrate(&label.Label{})
+	db.AutoMigrate(&label.Attribute{})
+	db.AutoMigrate(&label.Template{})
+	db.AutoMigrate(&label.TemplateLabel{})
 	db.AutoMigrate(&project.Project{})
 	db.AutoMigrate(&project.Permission{})
 	db.AutoMigrate(&workspace.Workspace{})
 	db.AutoMigrate(&workspace.Permission{})
 	db.AutoMigrate(&image.Image{})
+	db.AutoMigrate(&image.UploadJob{})
+	db.AutoMigrate(&image.UploadFile{})
 	db.AutoMigrate(&task.Task{})
+	db.AutoMigrate(&task.Group{})
+	db.AutoMigrate(&task.History{})
 	db.AutoMigrate(&task.Detail{})
 	db.AutoMigrate(&task.Detail{TaskID: 1})
 	db.AutoMigrate(&task.Detail{TaskID: 2})
@@ -97,4 +110,5 @@ func migrate(db *gorm.DB) {
 	db.AutoMigrate(&task.Detail{TaskID: 7})
 	db.AutoMigrate(&task.Detail{TaskID: 8})
 	db.AutoMigrate(&task.Detail{TaskID: 9})
+	db.AutoMigrate(&outbox.Event{})
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"github.com/nkhang/pluto/internal/fx/authzfx"
 	"github.com/nkhang/pluto/internal/fx/taskfx"
 	"github.com/nkhang/pluto/pkg/fx/annotationfx"
 	"go.uber.org/fx"
@@ -8,12 +9,14 @@ import (
 	"github.com/nkhang/pluto/internal/fx/datasetfx"
 	"github.com/nkhang/pluto/internal/fx/imagefx"
 	"github.com/nkhang/pluto/internal/fx/labelfx"
+	"github.com/nkhang/pluto/internal/fx/outboxfx"
 	"github.com/nkhang/pluto/internal/fx/projectfx"
 	"github.com/nkhang/pluto/internal/fx/toolfx"
 	"github.com/nkhang/pluto/internal/fx/workspacefx"
 	"github.com/nkhang/pluto/pkg/fx/configfx"
 	"github.com/nkhang/pluto/pkg/fx/dbfx"
 	"github.com/nkhang/pluto/pkg/fx/ginfx"
+	"github.com/nkhang/pluto/pkg/fx/natsfx"
 	"github.com/nkhang/pluto/pkg/fx/redisfx"
 	"github.com/nkhang/pluto/pkg/fx/storagefx"
 )
@@ -23,6 +26,7 @@ func main() {
 		configfx.Initialize("pluto"),
 		dbfx.Module,
 		redisfx.Module,
+		natsfx.Module,
 		toolfx.Module,
 		labelfx.Module,
 		taskfx.Module,
@@ -31,6 +35,8 @@ func main() {
 		projectfx.Module,
 		workspacefx.Module,
 		annotationfx.Module,
+		authzfx.Module,
+		outboxfx.Module,
 		storagefx.Module,
 		ginfx.Module,
 		fx.Invoke(initializer),
@@ -0,0 +1,66 @@
+// Command storagegc reports the stored originals and thumbnails that no
+// image refers to, and removes them when run with -delete.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/storagegc"
+	"github.com/nkhang/pluto/pkg/fx/configfx"
+	"github.com/nkhang/pluto/pkg/fx/dbfx"
+	"github.com/nkhang/pluto/pkg/fx/storagefx"
+	"github.com/nkhang/pluto/pkg/objectstorage"
+)
+
+var (
+	remove = flag.Bool("delete", false, "remove the orphaned objects instead of only reporting them")
+	grace  = flag.Duration("grace", 24*time.Hour, "leave out objects modified within this duration, they may belong to running uploads")
+)
+
+func main() {
+	flag.Parse()
+	app := fx.New(
+		fx.NopLogger,
+		configfx.Initialize("pluto"),
+		dbfx.Module,
+		storagefx.Module,
+		fx.Invoke(run),
+	)
+	if err := app.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(db *gorm.DB, s objectstorage.ObjectStorage) error {
+	defer db.Close()
+	collections := []string{
+		viper.GetString("minio.bucketname"),
+		viper.GetString("minio.thumbnailbucket"),
+	}
+	c := storagegc.NewCollector(image.NewDBRepository(db), s)
+	orphans, err := c.FindOrphans(collections, time.Now().Add(-*grace))
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, o := range orphans {
+		size += o.Size
+		fmt.Printf("%s/%s\t%d\t%s\n", o.Collection, o.Key, o.Size, o.LastModified.Format(time.RFC3339))
+	}
+	fmt.Printf("%d orphaned objects, %d bytes\n", len(orphans), size)
+	if !*remove {
+		return nil
+	}
+	removed, err := c.Remove(orphans)
+	fmt.Printf("%d orphaned objects removed\n", removed)
+	return err
+}
@@ -41,5 +41,6 @@ require (
 	golang.org/x/text v0.3.3 // indirect
 	golang.org/x/tools/gopls v0.4.1 // indirect
 	google.golang.org/genproto v0.0.0-20190819201941-24fa4b261c55
+	gopkg.in/yaml.v2 v2.2.8
 	moul.io/http2curl v1.0.0 // indirect
 )
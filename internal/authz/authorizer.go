@@ -0,0 +1,104 @@
+package authz
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/workspace"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/ginwrapper"
+	"github.com/nkhang/pluto/pkg/logger"
+	"github.com/nkhang/pluto/pkg/pgin"
+)
+
+type Authorizer interface {
+	Require(p Policy) gin.HandlerFunc
+	RequireSystemAdmin() gin.HandlerFunc
+	Authorize(userID, workspaceID, projectID uint64, p Policy) error
+}
+
+type authorizer struct {
+	workspaceRepo workspace.Repository
+	projectRepo   project.Repository
+	enabled       bool
+	admins        map[uint64]bool
+}
+
+// NewAuthorizer creates an authorizer. admins are the system administrators,
+// who may use the maintenance endpoints that do not belong to any workspace.
+func NewAuthorizer(w workspace.Repository, p project.Repository, enabled bool, admins []uint64) *authorizer {
+	var adminSet = make(map[uint64]bool, len(admins))
+	for _, id := range admins {
+		adminSet[id] = true
+	}
+	return &authorizer{
+		workspaceRepo: w,
+		projectRepo:   p,
+		enabled:       enabled,
+		admins:        adminSet,
+	}
+}
+
+// Require must be placed after the middlewares that put the workspace and
+// project IDs into the context, so the roles are resolved against the
+// entities the route works on.
+func (a *authorizer) Require(p Policy) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		userID := pgin.ExtractUserIDFromContext(c)
+		workspaceID := uint64(c.GetInt64(pgin.FieldWorkspaceID))
+		projectID := uint64(c.GetInt64(pgin.FieldProjectID))
+		if err := a.Authorize(userID, workspaceID, projectID, p); err != nil {
+			ginwrapper.Report(c, http.StatusOK, err, nil)
+			return
+		}
+		c.Next()
+	})
+}
+
+func (a *authorizer) RequireSystemAdmin() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		userID := pgin.ExtractUserIDFromContext(c)
+		if a.enabled && !a.admins[userID] {
+			err := errors.Forbidden.NewWithMessageF("user %d is not a system administrator", userID)
+			ginwrapper.Report(c, http.StatusOK, err, nil)
+			return
+		}
+		c.Next()
+	})
+}
+
+func (a *authorizer) Authorize(userID, workspaceID, projectID uint64, p Policy) error {
+	if !a.enabled {
+		return nil
+	}
+	if userID == 0 {
+		return errors.Unauthorized.NewWithMessage("user is not authenticated")
+	}
+	var workspaceRole workspace.Role
+	if workspaceID != 0 {
+		perm, err := a.workspaceRepo.GetUserPermission(workspaceID, userID)
+		switch {
+		case err == nil:
+			workspaceRole = perm.Role
+		case errors.Type(err) != errors.WorkspacePermissionNotFound:
+			return err
+		}
+	}
+	var projectRole project.Role
+	if projectID != 0 {
+		perm, err := a.projectRepo.GetPermission(userID, projectID)
+		switch {
+		case err == nil:
+			projectRole = perm.Role
+		case errors.Type(err) != errors.ProjectPermissionNotFound:
+			return err
+		}
+	}
+	if p.Allow(workspaceRole, projectRole) {
+		return nil
+	}
+	logger.Infof("[AUTHZ] - user %d denied. workspace %d role %d, project %d role %d", userID, workspaceID, workspaceRole, projectID, projectRole)
+	return errors.Forbidden.NewWithMessageF("user %d is not allowed to perform this action", userID)
+}
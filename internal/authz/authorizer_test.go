@@ -0,0 +1,117 @@
+package authz
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/workspace"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/logger"
+	"github.com/nkhang/pluto/pkg/pgin"
+)
+
+func TestMain(m *testing.M) {
+	logger.Initlialize(false)
+	os.Exit(m.Run())
+}
+
+type fakeWorkspaceRepo struct {
+	workspace.Repository
+	roles map[uint64]workspace.Role
+}
+
+func (r *fakeWorkspaceRepo) GetUserPermission(workspaceID, userID uint64) (workspace.Permission, error) {
+	role, ok := r.roles[userID]
+	if !ok {
+		return workspace.Permission{}, errors.WorkspacePermissionNotFound.NewWithMessage("permission not found")
+	}
+	return workspace.Permission{WorkspaceID: workspaceID, UserID: userID, Role: role}, nil
+}
+
+type fakeProjectRepo struct {
+	project.Repository
+	roles map[uint64]project.Role
+}
+
+func (r *fakeProjectRepo) GetPermission(userID, projectID uint64) (project.Permission, error) {
+	role, ok := r.roles[userID]
+	if !ok {
+		return project.Permission{}, errors.ProjectPermissionNotFound.NewWithMessage("permission not found")
+	}
+	return project.Permission{ProjectID: projectID, UserID: userID, Role: role}, nil
+}
+
+const (
+	workspaceAdminID uint64 = iota + 1
+	workspaceMemberID
+	projectManagerID
+	projectMemberID
+	outsiderID
+)
+
+func TestRequire(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAuthorizer(
+		&fakeWorkspaceRepo{roles: map[uint64]workspace.Role{
+			workspaceAdminID:  workspace.Admin,
+			workspaceMemberID: workspace.Member,
+			projectManagerID:  workspace.Member,
+			projectMemberID:   workspace.Member,
+		}},
+		&fakeProjectRepo{roles: map[uint64]project.Role{
+			projectManagerID: project.Manager,
+			projectMemberID:  project.Member,
+		}},
+		true,
+		nil,
+	)
+	tests := []struct {
+		name   string
+		policy Policy
+		userID uint64
+		status errors.ErrorType
+	}{
+		{"anonymous", ProjectMember, 0, errors.Unauthorized},
+		{"workspace admin deletes project", ProjectAdmin, workspaceAdminID, errors.Success},
+		{"project manager deletes project", ProjectAdmin, projectManagerID, errors.Forbidden},
+		{"project manager creates dataset", ProjectManager, projectManagerID, errors.Success},
+		{"project member creates dataset", ProjectManager, projectMemberID, errors.Forbidden},
+		{"project member reads project", ProjectMember, projectMemberID, errors.Success},
+		{"workspace member reads project", ProjectMember, workspaceMemberID, errors.Forbidden},
+		{"workspace member reads workspace", WorkspaceMember, workspaceMemberID, errors.Success},
+		{"outsider reads workspace", WorkspaceMember, outsiderID, errors.Forbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				if tt.userID != 0 {
+					c.Set(pgin.FieldUserID, int64(tt.userID))
+				}
+				c.Set(pgin.FieldWorkspaceID, int64(1))
+				c.Set(pgin.FieldProjectID, int64(1))
+			}, a.Require(tt.policy), func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"status": errors.Success})
+			})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			var body struct {
+				Status errors.ErrorType `json:"status"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.status, body.Status)
+		})
+	}
+}
+
+func TestAuthorizeDisabled(t *testing.T) {
+	a := NewAuthorizer(&fakeWorkspaceRepo{}, &fakeProjectRepo{}, false, nil)
+	assert.NoError(t, a.Authorize(outsiderID, 1, 1, ProjectAdmin))
+}
@@ -0,0 +1,39 @@
+package authz
+
+import (
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/workspace"
+)
+
+// Policy lists the roles allowed to access a route. A user passes when either
+// their workspace role or their project role is listed. Workspace admins
+// always pass.
+type Policy struct {
+	Workspace []workspace.Role
+	Project   []project.Role
+}
+
+var (
+	WorkspaceAdmin  = Policy{}
+	WorkspaceMember = Policy{Workspace: []workspace.Role{workspace.Member}}
+	ProjectAdmin    = Policy{Project: []project.Role{project.Admin}}
+	ProjectManager  = Policy{Project: []project.Role{project.Admin, project.Manager}}
+	ProjectMember   = Policy{Project: []project.Role{project.Admin, project.Manager, project.Member}}
+)
+
+func (p Policy) Allow(workspaceRole workspace.Role, projectRole project.Role) bool {
+	if workspaceRole == workspace.Admin {
+		return true
+	}
+	for _, r := range p.Workspace {
+		if r == workspaceRole {
+			return true
+		}
+	}
+	for _, r := range p.Project {
+		if r == projectRole {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/workspace"
+)
+
+func TestPolicyAllow(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        Policy
+		workspaceRole workspace.Role
+		projectRole   project.Role
+		allowed       bool
+	}{
+		{"workspace admin passes workspace admin policy", WorkspaceAdmin, workspace.Admin, project.Any, true},
+		{"workspace member fails workspace admin policy", WorkspaceAdmin, workspace.Member, project.Admin, false},
+		{"workspace member passes workspace member policy", WorkspaceMember, workspace.Member, project.Any, true},
+		{"outsider fails workspace member policy", WorkspaceMember, workspace.Any, project.Any, false},
+		{"workspace admin passes project admin policy", ProjectAdmin, workspace.Admin, project.Any, true},
+		{"project admin passes project admin policy", ProjectAdmin, workspace.Member, project.Admin, true},
+		{"project manager fails project admin policy", ProjectAdmin, workspace.Member, project.Manager, false},
+		{"project manager passes project manager policy", ProjectManager, workspace.Member, project.Manager, true},
+		{"project member fails project manager policy", ProjectManager, workspace.Member, project.Member, false},
+		{"project member passes project member policy", ProjectMember, workspace.Any, project.Member, true},
+		{"workspace member fails project member policy", ProjectMember, workspace.Member, project.Any, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, tt.policy.Allow(tt.workspaceRole, tt.projectRole))
+		})
+	}
+}
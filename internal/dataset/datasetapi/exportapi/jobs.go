@@ -0,0 +1,170 @@
+package exportapi
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/labelformat"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+type Config struct {
+	// Dir is where the archives of the jobs are written.
+	Dir string
+	// TTL is how long a finished job and its archive are kept.
+	TTL time.Duration
+	// Workers bounds how many jobs write archives at the same time.
+	Workers int
+	// SyncThreshold is the largest number of images exported within the
+	// request.
+	SyncThreshold int
+}
+
+type exportFunc func(projectID, datasetID uint64, format labelformat.Format, w io.Writer) error
+
+// jobStore keeps the export jobs in memory, so they are lost on restart and
+// only visible to the instance that runs them.
+type jobStore struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	dir     string
+	ttl     time.Duration
+	workers chan struct{}
+	export  exportFunc
+}
+
+func newJobStore(conf Config, export exportFunc) *jobStore {
+	if conf.Dir == "" {
+		conf.Dir = filepath.Join(os.TempDir(), "pluto-exports")
+	}
+	if conf.TTL <= 0 {
+		conf.TTL = time.Hour
+	}
+	if conf.Workers <= 0 {
+		conf.Workers = 2
+	}
+	return &jobStore{
+		jobs:    make(map[string]*Job),
+		dir:     conf.Dir,
+		ttl:     conf.TTL,
+		workers: make(chan struct{}, conf.Workers),
+		export:  export,
+	}
+}
+
+func (s *jobStore) start(projectID, datasetID uint64, format labelformat.Format, imageCount int) Job {
+	s.purge()
+	j := &Job{
+		ID:         uuid.NewV4().String(),
+		ProjectID:  projectID,
+		DatasetID:  datasetID,
+		Format:     format,
+		Status:     JobPending,
+		ImageCount: imageCount,
+		CreatedAt:  time.Now(),
+	}
+	j.path = filepath.Join(s.dir, j.ID+".zip")
+	s.mu.Lock()
+	s.jobs[j.ID] = j
+	created := *j
+	s.mu.Unlock()
+	go s.run(created)
+	logger.Infof("[EXPORT] - job %s started for dataset %d as %s", j.ID, datasetID, format)
+	return created
+}
+
+func (s *jobStore) run(j Job) {
+	s.workers <- struct{}{}
+	defer func() { <-s.workers }()
+	s.update(j.ID, func(j *Job) { j.Status = JobRunning })
+	err := s.write(j)
+	s.update(j.ID, func(j *Job) {
+		j.FinishedAt = time.Now()
+		if err != nil {
+			j.Status = JobFailed
+			j.Error = err.Error()
+			return
+		}
+		j.Status = JobDone
+	})
+	if err != nil {
+		logger.Errorf("[EXPORT] - job %s failed. err %v", j.ID, err)
+		return
+	}
+	logger.Infof("[EXPORT] - job %s done", j.ID)
+}
+
+func (s *jobStore) write(j Job) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return errors.ExportCannotWrite.Wrap(err, "cannot create export directory")
+	}
+	f, err := os.Create(j.path)
+	if err != nil {
+		return errors.ExportCannotWrite.Wrap(err, "cannot create export file")
+	}
+	err = s.export(j.ProjectID, j.DatasetID, j.Format, f)
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = errors.ExportCannotWrite.Wrap(cerr, "cannot close export file")
+	}
+	if err != nil {
+		os.Remove(j.path)
+	}
+	return err
+}
+
+func (s *jobStore) update(id string, fn func(j *Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j, ok := s.jobs[id]; ok {
+		fn(j)
+	}
+}
+
+func (s *jobStore) get(datasetID uint64, id string) (Job, error) {
+	s.purge()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok || j.DatasetID != datasetID {
+		return Job{}, errors.ExportJobNotFound.NewWithMessageF("export job %s not found", id)
+	}
+	return *j, nil
+}
+
+func (s *jobStore) open(datasetID uint64, id string) (io.ReadCloser, Job, error) {
+	j, err := s.get(datasetID, id)
+	if err != nil {
+		return nil, Job{}, err
+	}
+	if j.Status != JobDone {
+		return nil, Job{}, errors.ExportJobNotReady.NewWithMessageF("export job %s is %s", id, j.Status)
+	}
+	f, err := os.Open(j.path)
+	if err != nil {
+		return nil, Job{}, errors.ExportJobNotFound.Wrap(err, "cannot open export file")
+	}
+	return f, j, nil
+}
+
+// purge drops the jobs finished for longer than the TTL along with their
+// archives.
+func (s *jobStore) purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deadline := time.Now().Add(-s.ttl)
+	for id, j := range s.jobs {
+		if j.FinishedAt.IsZero() || j.FinishedAt.After(deadline) {
+			continue
+		}
+		if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+			logger.Errorf("[EXPORT] - cannot remove archive of job %s. err %v", id, err)
+		}
+		delete(s.jobs, id)
+	}
+}
@@ -0,0 +1,67 @@
+package exportapi
+
+import (
+	"time"
+
+	"github.com/nkhang/pluto/pkg/labelformat"
+	"github.com/nkhang/pluto/pkg/util/clock"
+)
+
+type JobStatus string
+
+const (
+	JobPending JobStatus = "pending"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+// ExportRequest asks for the export of a dataset. Small datasets are
+// streamed right away unless Async is set, large ones always go through a
+// background job.
+type ExportRequest struct {
+	Format labelformat.Format `form:"format" json:"format" binding:"required"`
+	Async  bool               `form:"async" json:"async"`
+}
+
+// Job is an export running in the background, the archive is kept in a local
+// file until the job expires.
+type Job struct {
+	ID         string
+	ProjectID  uint64
+	DatasetID  uint64
+	Format     labelformat.Format
+	Status     JobStatus
+	Error      string
+	ImageCount int
+	CreatedAt  time.Time
+	FinishedAt time.Time
+	path       string
+}
+
+type JobResponse struct {
+	ID         string             `json:"id"`
+	DatasetID  uint64             `json:"dataset_id"`
+	Format     labelformat.Format `json:"format"`
+	Status     JobStatus          `json:"status"`
+	Error      string             `json:"error,omitempty"`
+	ImageCount int                `json:"image_count"`
+	CreatedAt  int64              `json:"created_at"`
+	FinishedAt int64              `json:"finished_at,omitempty"`
+}
+
+func ToJobResponse(j Job) JobResponse {
+	resp := JobResponse{
+		ID:         j.ID,
+		DatasetID:  j.DatasetID,
+		Format:     j.Format,
+		Status:     j.Status,
+		Error:      j.Error,
+		ImageCount: j.ImageCount,
+		CreatedAt:  clock.UnixMillisecondFromTime(j.CreatedAt),
+	}
+	if !j.FinishedAt.IsZero() {
+		resp.FinishedAt = clock.UnixMillisecondFromTime(j.FinishedAt)
+	}
+	return resp
+}
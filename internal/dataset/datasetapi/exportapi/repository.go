@@ -0,0 +1,214 @@
+package exportapi
+
+import (
+	"io"
+
+	"github.com/nkhang/pluto/internal/dataset"
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/task"
+	"github.com/nkhang/pluto/pkg/annotation"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/labelformat"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+type Repository interface {
+	Build(projectID, datasetID uint64) (labelformat.Dataset, error)
+	Export(projectID, datasetID uint64, format labelformat.Format, w io.Writer) error
+	NeedsJob(datasetID uint64) (bool, error)
+	StartJob(projectID, datasetID uint64, format labelformat.Format) (Job, error)
+	GetJob(datasetID uint64, jobID string) (Job, error)
+	OpenJob(datasetID uint64, jobID string) (io.ReadCloser, Job, error)
+}
+
+type repository struct {
+	datasetRepo       dataset.Repository
+	imageRepo         image.Repository
+	labelRepo         label.Repository
+	projectRepo       project.Repository
+	taskRepo          task.Repository
+	annotationService annotation.Service
+	jobs              *jobStore
+	syncThreshold     int
+}
+
+func NewRepository(d dataset.Repository, i image.Repository, l label.Repository, p project.Repository, t task.Repository, s annotation.Service, conf Config) *repository {
+	if conf.SyncThreshold <= 0 {
+		conf.SyncThreshold = 500
+	}
+	r := &repository{
+		datasetRepo:       d,
+		imageRepo:         i,
+		labelRepo:         l,
+		projectRepo:       p,
+		taskRepo:          t,
+		annotationService: s,
+		syncThreshold:     conf.SyncThreshold,
+	}
+	r.jobs = newJobStore(conf, r.Export)
+	return r
+}
+
+// Build joins the images of a dataset, the labels of its project and the
+// shapes stored by the annotation server. In classification projects the
+// labels of each image are stored by pluto on the task details instead and
+// images have no shapes. Shapes and labels that no longer belong to the
+// project are left out.
+func (r *repository) Build(projectID, datasetID uint64) (labelformat.Dataset, error) {
+	d, err := r.datasetRepo.Get(datasetID)
+	if err != nil {
+		return labelformat.Dataset{}, err
+	}
+	images, err := r.imageRepo.GetAllImageByDataset(datasetID)
+	if err != nil {
+		return labelformat.Dataset{}, err
+	}
+	labels, err := r.labelRepo.GetByProjectId(projectID)
+	if err != nil {
+		return labelformat.Dataset{}, err
+	}
+	p, err := r.projectRepo.Get(projectID)
+	if err != nil {
+		return labelformat.Dataset{}, err
+	}
+	var result = labelformat.Dataset{
+		Name:       d.Title,
+		Categories: make([]labelformat.Category, len(labels)),
+		Images:     make([]labelformat.Image, len(images)),
+	}
+	var known = make(map[uint64]bool, len(labels))
+	for i, l := range labels {
+		known[l.ID] = true
+		result.Categories[i] = labelformat.Category{
+			ID:   l.ID,
+			Name: l.Name,
+			Tool: l.Tool.Name,
+		}
+	}
+	var (
+		shapes  map[uint64][]labelformat.Shape
+		classes map[uint64][]uint64
+	)
+	if p.Classifies() {
+		classes, err = r.classifications(projectID, datasetID, known)
+	} else {
+		shapes, err = r.shapes(projectID, datasetID, known)
+		classes = labelsOf(shapes)
+	}
+	if err != nil {
+		return labelformat.Dataset{}, err
+	}
+	for i, img := range images {
+		result.Images[i] = labelformat.Image{
+			ID:       img.ID,
+			FileName: img.Title,
+			URL:      img.URL,
+			Width:    img.Width,
+			Height:   img.Height,
+			Shapes:   shapes[img.ID],
+			Labels:   classes[img.ID],
+		}
+	}
+	return result, nil
+}
+
+func (r *repository) shapes(projectID, datasetID uint64, known map[uint64]bool) (map[uint64][]labelformat.Shape, error) {
+	annotations, err := r.annotationService.GetDatasetAnnotations(projectID, datasetID)
+	if err != nil {
+		return nil, errors.ExportCannotBuild.Wrap(err, "cannot get annotations of dataset")
+	}
+	var shapes = make(map[uint64][]labelformat.Shape)
+	for _, a := range annotations {
+		for _, s := range a.Annotations {
+			if !known[s.LabelID] {
+				logger.Infof("[EXPORT] - skip shape of unknown label %d on image %d", s.LabelID, a.ImageID)
+				continue
+			}
+			points := make([]labelformat.Point, len(s.Points))
+			for i, p := range s.Points {
+				points[i] = labelformat.Point{X: p.X, Y: p.Y}
+			}
+			shapes[a.ImageID] = append(shapes[a.ImageID], labelformat.Shape{
+				CategoryID: s.LabelID,
+				Points:     points,
+			})
+		}
+	}
+	return shapes, nil
+}
+
+func (r *repository) classifications(projectID, datasetID uint64, known map[uint64]bool) (map[uint64][]uint64, error) {
+	details, err := r.taskRepo.GetClassifiedDetails(projectID, datasetID)
+	if err != nil {
+		return nil, errors.ExportCannotBuild.Wrap(err, "cannot get labels of dataset")
+	}
+	var classes = make(map[uint64][]uint64)
+	for imageID, ids := range task.ImageLabels(details) {
+		for _, id := range ids {
+			if !known[id] {
+				logger.Infof("[EXPORT] - skip unknown label %d on image %d", id, imageID)
+				continue
+			}
+			classes[imageID] = append(classes[imageID], id)
+		}
+	}
+	return classes, nil
+}
+
+// labelsOf returns the labels of the shapes of each image, each once.
+func labelsOf(shapes map[uint64][]labelformat.Shape) map[uint64][]uint64 {
+	var classes = make(map[uint64][]uint64, len(shapes))
+	for imageID, ss := range shapes {
+		var seen = make(map[uint64]bool)
+		for _, s := range ss {
+			if !seen[s.CategoryID] {
+				seen[s.CategoryID] = true
+				classes[imageID] = append(classes[imageID], s.CategoryID)
+			}
+		}
+	}
+	return classes
+}
+
+func (r *repository) Export(projectID, datasetID uint64, format labelformat.Format, w io.Writer) error {
+	if !format.Valid() {
+		return errors.ExportFormatNotSupported.NewWithMessageF("format %s is not supported", format)
+	}
+	d, err := r.Build(projectID, datasetID)
+	if err != nil {
+		return err
+	}
+	logger.Infof("[EXPORT] - writing dataset %d as %s with %d images", datasetID, format, len(d.Images))
+	return labelformat.Write(w, format, d)
+}
+
+// NeedsJob reports whether a dataset is too large to be exported within the
+// request.
+func (r *repository) NeedsJob(datasetID uint64) (bool, error) {
+	images, err := r.imageRepo.GetAllImageByDataset(datasetID)
+	if err != nil {
+		return false, err
+	}
+	return len(images) > r.syncThreshold, nil
+}
+
+func (r *repository) StartJob(projectID, datasetID uint64, format labelformat.Format) (Job, error) {
+	if !format.Valid() {
+		return Job{}, errors.ExportFormatNotSupported.NewWithMessageF("format %s is not supported", format)
+	}
+	images, err := r.imageRepo.GetAllImageByDataset(datasetID)
+	if err != nil {
+		return Job{}, err
+	}
+	return r.jobs.start(projectID, datasetID, format, len(images)), nil
+}
+
+func (r *repository) GetJob(datasetID uint64, jobID string) (Job, error) {
+	return r.jobs.get(datasetID, jobID)
+}
+
+func (r *repository) OpenJob(datasetID uint64, jobID string) (io.ReadCloser, Job, error) {
+	return r.jobs.open(datasetID, jobID)
+}
@@ -0,0 +1,281 @@
+package exportapi
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/dataset"
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/task"
+	"github.com/nkhang/pluto/internal/tool"
+	"github.com/nkhang/pluto/pkg/annotation"
+	"github.com/nkhang/pluto/pkg/gorm"
+	"github.com/nkhang/pluto/pkg/labelformat"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+const (
+	testProjectID = 7
+	testDatasetID = 3
+)
+
+func TestMain(m *testing.M) {
+	logger.Initlialize(false)
+	os.Exit(m.Run())
+}
+
+type fakeDatasetRepo struct {
+	dataset.Repository
+}
+
+func (fakeDatasetRepo) Get(id uint64) (dataset.Dataset, error) {
+	return dataset.Dataset{Model: gorm.Model{ID: id}, Title: "streets", ProjectID: testProjectID}, nil
+}
+
+type fakeImageRepo struct {
+	image.Repository
+	images []image.Image
+}
+
+func (r fakeImageRepo) GetAllImageByDataset(uint64) ([]image.Image, error) {
+	return r.images, nil
+}
+
+type fakeLabelRepo struct {
+	label.Repository
+}
+
+func (fakeLabelRepo) GetByProjectId(uint64) ([]label.Label, error) {
+	return []label.Label{
+		{Model: gorm.Model{ID: 1}, Name: "car", Tool: tool.Tool{Name: labelformat.ToolRectangle}},
+		{Model: gorm.Model{ID: 2}, Name: "road", Tool: tool.Tool{Name: labelformat.ToolPolygon}},
+	}, nil
+}
+
+type fakeProjectRepo struct {
+	project.Repository
+	mode project.Mode
+}
+
+func (r fakeProjectRepo) Get(id uint64) (project.Project, error) {
+	return project.Project{Model: gorm.Model{ID: id}, Mode: r.mode}, nil
+}
+
+// fakeTaskRepo classifies image 10 as a car in a draft and as a road once
+// approved, and image 11 with label 9 which the project does not have.
+type fakeTaskRepo struct {
+	task.Repository
+}
+
+func (fakeTaskRepo) GetClassifiedDetails(projectID, datasetID uint64) ([]task.Detail, error) {
+	return []task.Detail{
+		{ImageID: 10, Status: task.Draft, Labels: "1"},
+		{ImageID: 10, Status: task.Approved, Labels: "2"},
+		{ImageID: 11, Status: task.Labeled, Labels: "9"},
+	}, nil
+}
+
+// newAnnotationServer fakes the annotation server: image 10 has a car and a
+// road, image 11 has a shape of label 9 which the project does not have.
+func newAnnotationServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/annotations", r.URL.Path)
+		assert.Equal(t, "7", r.URL.Query().Get("project_id"))
+		assert.Equal(t, "3", r.URL.Query().Get("dataset_id"))
+		json.NewEncoder(w).Encode(annotation.ImageAnnotationsResponse{
+			Status: 1,
+			Data: []annotation.ImageAnnotationsObject{
+				{
+					ImageID: 10,
+					Annotations: []annotation.ShapeObject{
+						{LabelID: 1, Points: []annotation.PointObject{{X: 10, Y: 20}, {X: 50, Y: 60}}},
+						{LabelID: 2, Points: []annotation.PointObject{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 50}}},
+					},
+				},
+				{
+					ImageID: 11,
+					Annotations: []annotation.ShapeObject{
+						{LabelID: 9, Points: []annotation.PointObject{{X: 1, Y: 1}, {X: 2, Y: 2}}},
+					},
+				},
+			},
+		})
+	}))
+}
+
+func newTestRepository(t *testing.T, conf Config) *repository {
+	return newModeRepository(t, conf, project.ModeAnnotation)
+}
+
+func newModeRepository(t *testing.T, conf Config, mode project.Mode) *repository {
+	srv := newAnnotationServer(t)
+	t.Cleanup(srv.Close)
+	viper.Set("annotation.baseurl", srv.URL)
+	images := fakeImageRepo{images: []image.Image{
+		{Model: gorm.Model{ID: 10}, Title: "a.jpg", URL: "http://s3/a.jpg", Width: 200, Height: 100},
+		{Model: gorm.Model{ID: 11}, Title: "b.png", URL: "http://s3/b.png", Width: 40, Height: 40},
+	}}
+	s := annotation.NewService(nil, nil, nil, nil, nil)
+	return NewRepository(fakeDatasetRepo{}, images, fakeLabelRepo{}, fakeProjectRepo{mode: mode}, fakeTaskRepo{}, s, conf)
+}
+
+func readZip(t *testing.T, b []byte) map[string]string {
+	z, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+	var files = make(map[string]string)
+	for _, f := range z.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestBuildJoinsAnnotations(t *testing.T) {
+	r := newTestRepository(t, Config{})
+	d, err := r.Build(testProjectID, testDatasetID)
+	require.NoError(t, err)
+	assert.Equal(t, "streets", d.Name)
+	assert.Len(t, d.Categories, 2)
+	require.Len(t, d.Images, 2)
+	assert.Len(t, d.Images[0].Shapes, 2)
+	assert.Empty(t, d.Images[1].Shapes, "shapes of unknown labels are dropped")
+}
+
+func TestExportCOCO(t *testing.T) {
+	r := newTestRepository(t, Config{})
+	var buf bytes.Buffer
+	require.NoError(t, r.Export(testProjectID, testDatasetID, labelformat.COCO, &buf))
+	files := readZip(t, buf.Bytes())
+	var coco struct {
+		Images      []map[string]interface{} `json:"images"`
+		Annotations []struct {
+			CategoryID   uint64      `json:"category_id"`
+			BBox         []float64   `json:"bbox"`
+			Area         float64     `json:"area"`
+			Segmentation [][]float64 `json:"segmentation"`
+		} `json:"annotations"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(files["annotations/instances.json"]), &coco))
+	assert.Len(t, coco.Images, 2)
+	require.Len(t, coco.Annotations, 2)
+	assert.Equal(t, []float64{10, 20, 40, 40}, coco.Annotations[0].BBox)
+	assert.Empty(t, coco.Annotations[0].Segmentation)
+	assert.Equal(t, float64(2500), coco.Annotations[1].Area)
+	assert.Equal(t, [][]float64{{0, 0, 100, 0, 100, 50}}, coco.Annotations[1].Segmentation)
+}
+
+func TestExportVOC(t *testing.T) {
+	r := newTestRepository(t, Config{})
+	var buf bytes.Buffer
+	require.NoError(t, r.Export(testProjectID, testDatasetID, labelformat.VOC, &buf))
+	files := readZip(t, buf.Bytes())
+	assert.Equal(t, "10_a\n11_b\n", files["ImageSets/Main/default.txt"])
+	var a struct {
+		FileName string `xml:"filename"`
+		Objects  []struct {
+			Name string `xml:"name"`
+			XMax int    `xml:"bndbox>xmax"`
+		} `xml:"object"`
+	}
+	require.NoError(t, xml.Unmarshal([]byte(files["Annotations/10_a.xml"]), &a))
+	assert.Equal(t, "10_a.jpg", a.FileName)
+	require.Len(t, a.Objects, 2)
+	assert.Equal(t, "car", a.Objects[0].Name)
+	assert.Equal(t, 50, a.Objects[0].XMax)
+}
+
+func TestExportYOLO(t *testing.T) {
+	r := newTestRepository(t, Config{})
+	var buf bytes.Buffer
+	require.NoError(t, r.Export(testProjectID, testDatasetID, labelformat.YOLO, &buf))
+	files := readZip(t, buf.Bytes())
+	assert.Equal(t, "car\nroad\n", files["classes.txt"])
+	lines := strings.Split(strings.TrimSpace(files["labels/10_a.txt"]), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, "0 0.150000 0.400000 0.200000 0.400000", lines[0])
+	assert.Equal(t, "", files["labels/11_b.txt"])
+}
+
+func TestExportCSV(t *testing.T) {
+	r := newTestRepository(t, Config{})
+	var buf bytes.Buffer
+	require.NoError(t, r.Export(testProjectID, testDatasetID, labelformat.CSV, &buf))
+	files := readZip(t, buf.Bytes())
+	assert.Equal(t, "image_id,file_name,url,labels\n10,10_a.jpg,http://s3/a.jpg,car;road\n11,11_b.png,http://s3/b.png,\n", files["labels.csv"])
+}
+
+func TestExportClassification(t *testing.T) {
+	r := newModeRepository(t, Config{}, project.ModeClassification)
+	d, err := r.Build(testProjectID, testDatasetID)
+	require.NoError(t, err)
+	require.Len(t, d.Images, 2)
+	assert.Empty(t, d.Images[0].Shapes, "the annotation server is not asked")
+	assert.Equal(t, []uint64{2}, d.Images[0].Labels, "the approved labels win")
+	assert.Empty(t, d.Images[1].Labels, "unknown labels are dropped")
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Export(testProjectID, testDatasetID, labelformat.JSON, &buf))
+	var file struct {
+		Categories []string `json:"categories"`
+		Images     []struct {
+			ID     uint64   `json:"id"`
+			Labels []string `json:"labels"`
+		} `json:"images"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(readZip(t, buf.Bytes())["labels.json"]), &file))
+	assert.Equal(t, []string{"car", "road"}, file.Categories)
+	require.Len(t, file.Images, 2)
+	assert.Equal(t, []string{"road"}, file.Images[0].Labels)
+	assert.Empty(t, file.Images[1].Labels)
+}
+
+func TestExportRejectsUnknownFormat(t *testing.T) {
+	r := newTestRepository(t, Config{})
+	var buf bytes.Buffer
+	assert.Error(t, r.Export(testProjectID, testDatasetID, labelformat.Format("xml"), &buf))
+}
+
+func TestExportJob(t *testing.T) {
+	r := newTestRepository(t, Config{Dir: t.TempDir(), SyncThreshold: 1})
+	needsJob, err := r.NeedsJob(testDatasetID)
+	require.NoError(t, err)
+	assert.True(t, needsJob)
+
+	job, err := r.StartJob(testProjectID, testDatasetID, labelformat.YOLO)
+	require.NoError(t, err)
+	assert.Equal(t, 2, job.ImageCount)
+
+	_, err = r.GetJob(testDatasetID+1, job.ID)
+	assert.Error(t, err, "jobs are only visible from their dataset")
+
+	require.Eventually(t, func() bool {
+		j, err := r.GetJob(testDatasetID, job.ID)
+		return err == nil && j.Status == JobDone
+	}, 5*time.Second, 10*time.Millisecond)
+
+	rc, _, err := r.OpenJob(testDatasetID, job.ID)
+	require.NoError(t, err)
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Contains(t, readZip(t, b), "classes.txt")
+}
@@ -0,0 +1,116 @@
+package exportapi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nkhang/pluto/internal/authz"
+	"github.com/nkhang/pluto/internal/dataset/datasetapi"
+	"github.com/nkhang/pluto/internal/project/projectapi"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/ginwrapper"
+	"github.com/nkhang/pluto/pkg/labelformat"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+const fieldJobID = "jobId"
+
+type service struct {
+	repository Repository
+	authorizer authz.Authorizer
+}
+
+func NewService(r Repository, authorizer authz.Authorizer) *service {
+	return &service{
+		repository: r,
+		authorizer: authorizer,
+	}
+}
+
+func (s *service) Register(router gin.IRouter) {
+	exportRouter := router.Group("/export", s.authorizer.Require(authz.ProjectManager))
+	{
+		exportRouter.GET("", s.export)
+		exportRouter.GET("/jobs/:"+fieldJobID, ginwrapper.Wrap(s.getJob))
+		exportRouter.GET("/jobs/:"+fieldJobID+"/download", s.download)
+	}
+}
+
+// export streams the archive of a small dataset, or starts a job and
+// returns it when the dataset is large or an async export is asked for.
+func (s *service) export(c *gin.Context) {
+	projectID := uint64(c.GetInt64(projectapi.FieldProjectID))
+	datasetID := uint64(c.GetInt64(datasetapi.FieldDatasetID))
+	var req ExportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ginwrapper.Report(c, http.StatusOK, errors.BadRequest.NewWithMessage("format is required"), nil)
+		return
+	}
+	if !req.Format.Valid() {
+		err := errors.ExportFormatNotSupported.NewWithMessageF("format %s is not supported", req.Format)
+		ginwrapper.Report(c, http.StatusOK, err, nil)
+		return
+	}
+	async := req.Async
+	if !async {
+		needsJob, err := s.repository.NeedsJob(datasetID)
+		if err != nil {
+			ginwrapper.Report(c, http.StatusOK, err, nil)
+			return
+		}
+		async = needsJob
+	}
+	if async {
+		job, err := s.repository.StartJob(projectID, datasetID, req.Format)
+		if err != nil {
+			ginwrapper.Report(c, http.StatusOK, err, nil)
+			return
+		}
+		ginwrapper.Report(c, http.StatusOK, errors.Success.NewWithMessage("export started"), ToJobResponse(job))
+		return
+	}
+	d, err := s.repository.Build(projectID, datasetID)
+	if err != nil {
+		ginwrapper.Report(c, http.StatusOK, err, nil)
+		return
+	}
+	setAttachment(c, datasetID, req.Format)
+	if err := labelformat.Write(c.Writer, req.Format, d); err != nil {
+		logger.Errorf("[EXPORT] - cannot stream dataset %d. err %v", datasetID, err)
+	}
+}
+
+func (s *service) getJob(c *gin.Context) ginwrapper.Response {
+	datasetID := uint64(c.GetInt64(datasetapi.FieldDatasetID))
+	job, err := s.repository.GetJob(datasetID, c.Param(fieldJobID))
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  ToJobResponse(job),
+	}
+}
+
+func (s *service) download(c *gin.Context) {
+	datasetID := uint64(c.GetInt64(datasetapi.FieldDatasetID))
+	f, job, err := s.repository.OpenJob(datasetID, c.Param(fieldJobID))
+	if err != nil {
+		ginwrapper.Report(c, http.StatusOK, err, nil)
+		return
+	}
+	defer f.Close()
+	setAttachment(c, datasetID, job.Format)
+	if _, err := io.Copy(c.Writer, f); err != nil {
+		logger.Errorf("[EXPORT] - cannot send archive of job %s. err %v", job.ID, err)
+	}
+}
+
+func setAttachment(c *gin.Context, datasetID uint64, format labelformat.Format) {
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"dataset-%d-%s.zip\"", datasetID, format))
+	c.Status(http.StatusOK)
+}
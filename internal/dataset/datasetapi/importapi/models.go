@@ -0,0 +1,32 @@
+package importapi
+
+import (
+	"mime/multipart"
+
+	"github.com/nkhang/pluto/internal/dataset/datasetapi"
+	"github.com/nkhang/pluto/pkg/labelformat"
+)
+
+// ImportRequest creates a dataset from File, a zip archive of images
+// annotated in Format.
+type ImportRequest struct {
+	Format      labelformat.Format    `form:"format" binding:"required"`
+	Title       string                `form:"title" binding:"required"`
+	Description string                `form:"description"`
+	File        *multipart.FileHeader `form:"file" binding:"required"`
+}
+
+type SkippedEntry struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+}
+
+// ImportResponse reports what an import created, along with the entries of
+// the archive that were left out.
+type ImportResponse struct {
+	Dataset       datasetapi.DatasetResponse `json:"dataset"`
+	Images        int                        `json:"images"`
+	Annotations   int                        `json:"annotations"`
+	CreatedLabels []string                   `json:"created_labels"`
+	Skipped       []SkippedEntry             `json:"skipped"`
+}
@@ -0,0 +1,215 @@
+package importapi
+
+import (
+	"archive/zip"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/nkhang/pluto/internal/dataset"
+	"github.com/nkhang/pluto/internal/dataset/datasetapi"
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/image/imageapi"
+	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/tool"
+	"github.com/nkhang/pluto/pkg/annotation"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/labelformat"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+// labelColors are given in turn to the labels created by an import.
+var labelColors = []string{
+	"#e6194b", "#3cb44b", "#ffe119", "#4363d8", "#f58231",
+	"#911eb4", "#46f0f0", "#f032e6", "#bcf60c", "#008080",
+}
+
+type Repository interface {
+	Import(projectID uint64, req ImportRequest) (ImportResponse, error)
+}
+
+type repository struct {
+	datasetRepo       dataset.Repository
+	datasetAPIRepo    datasetapi.Repository
+	imageAPIRepo      imageapi.Repository
+	labelRepo         label.Repository
+	toolRepo          tool.Repository
+	annotationService annotation.Service
+	limits            imageapi.ArchiveLimits
+}
+
+// NewRepository creates the import repository. The uploaded archives are
+// bounded by limits, the same as archives of images.
+func NewRepository(d dataset.Repository, dAPI datasetapi.Repository, i imageapi.Repository, l label.Repository, t tool.Repository, s annotation.Service, limits imageapi.ArchiveLimits) *repository {
+	return &repository{
+		datasetRepo:       d,
+		datasetAPIRepo:    dAPI,
+		imageAPIRepo:      i,
+		labelRepo:         l,
+		toolRepo:          t,
+		annotationService: s,
+		limits:            limits,
+	}
+}
+
+// Import creates a dataset from an annotated archive. The labels of the
+// archive are matched by name with those of the project and the missing
+// ones are created. When the annotations cannot be forwarded, the dataset
+// and its images are kept and the response is returned with the error.
+func (r *repository) Import(projectID uint64, req ImportRequest) (ImportResponse, error) {
+	if !req.Format.Readable() {
+		return ImportResponse{}, errors.ImportFormatNotSupported.NewWithMessageF("format %s is not supported", req.Format)
+	}
+	f, err := req.File.Open()
+	if err != nil {
+		return ImportResponse{}, errors.ImportCannotRead.Wrap(err, "cannot open uploaded archive")
+	}
+	defer f.Close()
+	z, err := imageapi.OpenZip(f, req.File.Size, r.limits)
+	if errors.Type(err) == errors.ImageArchiveCannotRead {
+		return ImportResponse{}, errors.ImportCannotRead.NewWithMessage("uploaded file is not a zip archive")
+	}
+	if err != nil {
+		return ImportResponse{}, err
+	}
+	parsed, problems, err := labelformat.Read(z, req.Format)
+	if err != nil {
+		return ImportResponse{}, err
+	}
+	var resp = ImportResponse{
+		CreatedLabels: make([]string, 0),
+		Skipped:       make([]SkippedEntry, 0),
+	}
+	for _, p := range problems {
+		resp.Skipped = append(resp.Skipped, SkippedEntry{File: p.File, Reason: p.Reason})
+	}
+	labels, err := r.resolveLabels(projectID, parsed.Categories, &resp)
+	if err != nil {
+		return ImportResponse{}, err
+	}
+	d, err := r.datasetRepo.CreateDataset(req.Title, req.Description, projectID)
+	if err != nil {
+		return ImportResponse{}, err
+	}
+	logger.Infof("[IMPORT] - importing %d images as %s into dataset %d", len(parsed.Images), req.Format, d.ID)
+	var files = make(map[string]*zip.File, len(z.File))
+	for _, zf := range z.File {
+		files[zf.Name] = zf
+	}
+	var (
+		titles  = make(map[string]bool, len(parsed.Images))
+		objects = make([]annotation.ImageAnnotationsObject, 0, len(parsed.Images))
+	)
+	for _, img := range parsed.Images {
+		created, err := r.createImage(d, files[img.FileName], titles)
+		if err != nil {
+			logger.Errorf("[IMPORT] - cannot create image %s. err %v", img.FileName, err)
+			resp.Skipped = append(resp.Skipped, SkippedEntry{File: img.FileName, Reason: errors.Message(err)})
+			continue
+		}
+		resp.Images++
+		obj := annotation.ImageAnnotationsObject{
+			ImageID:     created.ID,
+			Annotations: make([]annotation.ShapeObject, 0, len(img.Shapes)),
+		}
+		for _, s := range img.Shapes {
+			l, ok := labels[s.CategoryID]
+			if !ok {
+				continue
+			}
+			points := make([]annotation.PointObject, len(s.Points))
+			for i, p := range s.Points {
+				points[i] = annotation.PointObject{X: p.X, Y: p.Y}
+			}
+			obj.Annotations = append(obj.Annotations, annotation.ShapeObject{LabelID: l.ID, Points: points})
+		}
+		if len(obj.Annotations) == 0 {
+			continue
+		}
+		resp.Annotations += len(obj.Annotations)
+		objects = append(objects, obj)
+	}
+	resp.Dataset, err = r.datasetAPIRepo.GetByID(d.ID)
+	if err != nil {
+		return ImportResponse{}, err
+	}
+	if len(objects) == 0 {
+		return resp, nil
+	}
+	if err := r.annotationService.ImportAnnotations(projectID, d.ID, objects); err != nil {
+		logger.Errorf("[IMPORT] - cannot forward annotations of dataset %d. err %v", d.ID, err)
+		return resp, errors.ImportCannotForward.NewWithMessage("dataset is created but its annotations cannot be sent to the annotation server")
+	}
+	return resp, nil
+}
+
+// resolveLabels maps the categories of an archive to the labels of the
+// project, creating the missing ones with the tool of the category. A
+// category whose label exists with another tool is left out, along with its
+// shapes.
+func (r *repository) resolveLabels(projectID uint64, categories []labelformat.Category, resp *ImportResponse) (map[uint64]label.Label, error) {
+	existing, err := r.labelRepo.GetByProjectId(projectID)
+	if err != nil {
+		return nil, err
+	}
+	var byName = make(map[string]label.Label, len(existing))
+	for _, l := range existing {
+		byName[l.Name] = l
+	}
+	tools, err := r.toolRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	var result = make(map[uint64]label.Label, len(categories))
+	for _, c := range categories {
+		if l, ok := byName[c.Name]; ok {
+			if !strings.EqualFold(l.Tool.Name, c.Tool) {
+				resp.Skipped = append(resp.Skipped, SkippedEntry{
+					File:   c.Name,
+					Reason: fmt.Sprintf("label already exists with tool %s instead of %s, its shapes are skipped", l.Tool.Name, c.Tool),
+				})
+				continue
+			}
+			result[c.ID] = l
+			continue
+		}
+		t, ok := findTool(tools, c.Tool)
+		if !ok {
+			return nil, errors.ImportToolNotFound.NewWithMessageF("tool %s not found", c.Tool)
+		}
+		color := labelColors[(len(existing)+len(resp.CreatedLabels))%len(labelColors)]
+		l, err := r.labelRepo.CreateLabel(c.Name, color, projectID, 0, t.ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		l.Tool = t
+		result[c.ID] = l
+		resp.CreatedLabels = append(resp.CreatedLabels, c.Name)
+	}
+	return result, nil
+}
+
+func findTool(tools []tool.Tool, name string) (tool.Tool, bool) {
+	for _, t := range tools {
+		if strings.EqualFold(t.Name, name) {
+			return t, true
+		}
+	}
+	return tool.Tool{}, false
+}
+
+// createImage stores an image of the archive under its base name, or under
+// its path within the archive when another image already took that name.
+func (r *repository) createImage(d dataset.Dataset, f *zip.File, titles map[string]bool) (created image.Image, err error) {
+	title := path.Base(f.Name)
+	if titles[title] {
+		title = strings.ReplaceAll(f.Name, "/", "_")
+	}
+	titles[title] = true
+	rc, err := f.Open()
+	if err != nil {
+		return created, errors.ImportCannotRead.Wrap(err, "cannot read image from archive")
+	}
+	defer rc.Close()
+	return r.imageAPIRepo.CreateImageFromReader(d, title, rc, nil)
+}
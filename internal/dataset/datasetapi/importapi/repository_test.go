@@ -0,0 +1,234 @@
+package importapi
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/dataset"
+	"github.com/nkhang/pluto/internal/dataset/datasetapi"
+	pimage "github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/image/imageapi"
+	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/tool"
+	"github.com/nkhang/pluto/pkg/annotation"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/gorm"
+	"github.com/nkhang/pluto/pkg/labelformat"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Initlialize(false)
+	os.Exit(m.Run())
+}
+
+type fakeDatasetRepo struct {
+	dataset.Repository
+	created []string
+}
+
+func (r *fakeDatasetRepo) CreateDataset(title, description string, pID uint64) (dataset.Dataset, error) {
+	r.created = append(r.created, title)
+	return dataset.Dataset{Model: gorm.Model{ID: uint64(len(r.created))}, Title: title, ProjectID: pID}, nil
+}
+
+type fakeDatasetAPIRepo struct {
+	datasetapi.Repository
+}
+
+func (fakeDatasetAPIRepo) GetByID(dID uint64) (datasetapi.DatasetResponse, error) {
+	return datasetapi.DatasetResponse{ID: dID}, nil
+}
+
+type fakeImageAPIRepo struct {
+	imageapi.Repository
+	images map[string]int
+}
+
+func (r *fakeImageAPIRepo) CreateImageFromReader(d dataset.Dataset, filename string, reader io.Reader, tags []string) (pimage.Image, error) {
+	b, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return pimage.Image{}, err
+	}
+	r.images[filename] = len(b)
+	return pimage.Image{Model: gorm.Model{ID: uint64(len(r.images))}, Title: filename, DatasetID: d.ID}, nil
+}
+
+type fakeLabelRepo struct {
+	label.Repository
+	labels []label.Label
+}
+
+func (r *fakeLabelRepo) GetByProjectId(pID uint64) ([]label.Label, error) {
+	return r.labels, nil
+}
+
+func (r *fakeLabelRepo) CreateLabel(name, color string, projectID, parentID, toolID uint64, attributes []label.Attribute) (label.Label, error) {
+	l := label.Label{Model: gorm.Model{ID: uint64(len(r.labels) + 1)}, Name: name, ProjectID: projectID, ToolID: toolID}
+	r.labels = append(r.labels, l)
+	return l, nil
+}
+
+type fakeToolRepo struct {
+	tool.Repository
+}
+
+func (fakeToolRepo) GetAll() ([]tool.Tool, error) {
+	return []tool.Tool{
+		{Model: gorm.Model{ID: 1}, Name: labelformat.ToolRectangle},
+		{Model: gorm.Model{ID: 2}, Name: labelformat.ToolPolygon},
+	}, nil
+}
+
+type fakeAnnotationService struct {
+	annotation.Service
+	images []annotation.ImageAnnotationsObject
+}
+
+func (s *fakeAnnotationService) ImportAnnotations(projectID, datasetID uint64, images []annotation.ImageAnnotationsObject) error {
+	s.images = append(s.images, images...)
+	return nil
+}
+
+type fakes struct {
+	datasets    *fakeDatasetRepo
+	images      *fakeImageAPIRepo
+	labels      *fakeLabelRepo
+	annotations *fakeAnnotationService
+}
+
+func newRepository(limits imageapi.ArchiveLimits) (*repository, fakes) {
+	f := fakes{
+		datasets:    &fakeDatasetRepo{},
+		images:      &fakeImageAPIRepo{images: make(map[string]int)},
+		labels:      &fakeLabelRepo{},
+		annotations: &fakeAnnotationService{},
+	}
+	r := NewRepository(f.datasets, fakeDatasetAPIRepo{}, f.images, f.labels, fakeToolRepo{}, f.annotations, limits)
+	return r, f
+}
+
+func newPNG(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+// newFile uploads content as a multipart file.
+func newFile(t *testing.T, name string, content []byte) *multipart.FileHeader {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fw, err := w.CreateFormFile("file", name)
+	require.NoError(t, err)
+	_, err = fw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	form, err := multipart.NewReader(&buf, w.Boundary()).ReadForm(1 << 20)
+	require.NoError(t, err)
+	return form.File["file"][0]
+}
+
+func newZip(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := z.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, z.Close())
+	return buf.Bytes()
+}
+
+func yoloArchive(t *testing.T) []byte {
+	return newZip(t, map[string][]byte{
+		"classes.txt":  []byte("car\n"),
+		"images/a.png": newPNG(t, 200, 100),
+		"images/b.png": newPNG(t, 10, 10),
+		"labels/a.txt": []byte("0 0.5 0.5 0.2 0.2\n"),
+	})
+}
+
+func TestImport(t *testing.T) {
+	r, f := newRepository(imageapi.ArchiveLimits{})
+	resp, err := r.Import(3, ImportRequest{
+		Format: labelformat.YOLO,
+		Title:  "cars",
+		File:   newFile(t, "cars.zip", yoloArchive(t)),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cars"}, f.datasets.created)
+	assert.Equal(t, 2, resp.Images)
+	assert.Equal(t, 1, resp.Annotations)
+	assert.Equal(t, []string{"car"}, resp.CreatedLabels)
+	require.Len(t, f.labels.labels, 1)
+	assert.Equal(t, uint64(1), f.labels.labels[0].ToolID)
+	assert.Contains(t, f.images.images, "a.png")
+	assert.Contains(t, f.images.images, "b.png")
+	require.Len(t, f.annotations.images, 1)
+	assert.Equal(t, f.labels.labels[0].ID, f.annotations.images[0].Annotations[0].LabelID)
+}
+
+func TestImportLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits imageapi.ArchiveLimits
+	}{
+		{name: "too many files", limits: imageapi.ArchiveLimits{MaxFiles: 2}},
+		{name: "too large", limits: imageapi.ArchiveLimits{MaxSize: 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, f := newRepository(tt.limits)
+			_, err := r.Import(3, ImportRequest{
+				Format: labelformat.YOLO,
+				Title:  "cars",
+				File:   newFile(t, "cars.zip", yoloArchive(t)),
+			})
+			assert.Equal(t, errors.ImageArchiveTooLarge, errors.Type(err))
+			assert.Empty(t, f.datasets.created, "no dataset is created from an archive over the limits")
+			assert.Empty(t, f.images.images)
+		})
+	}
+}
+
+func TestImportBomb(t *testing.T) {
+	r, f := newRepository(imageapi.ArchiveLimits{MaxRatio: 100})
+	_, err := r.Import(3, ImportRequest{
+		Format: labelformat.YOLO,
+		Title:  "bomb",
+		File: newFile(t, "bomb.zip", newZip(t, map[string][]byte{
+			"classes.txt": bytes.Repeat([]byte("car\n"), 1<<20),
+		})),
+	})
+	assert.Equal(t, errors.ImageArchiveTooLarge, errors.Type(err))
+	assert.Empty(t, f.labels.labels)
+}
+
+func TestImportRejects(t *testing.T) {
+	r, f := newRepository(imageapi.ArchiveLimits{})
+	_, err := r.Import(3, ImportRequest{
+		Format: labelformat.YOLO,
+		Title:  "cars",
+		File:   newFile(t, "cars.zip", []byte("not a zip")),
+	})
+	assert.Equal(t, errors.ImportCannotRead, errors.Type(err))
+	_, err = r.Import(3, ImportRequest{
+		Format: labelformat.CSV,
+		Title:  "cars",
+		File:   newFile(t, "cars.zip", yoloArchive(t)),
+	})
+	assert.Equal(t, errors.ImportFormatNotSupported, errors.Type(err))
+	assert.Empty(t, f.datasets.created)
+}
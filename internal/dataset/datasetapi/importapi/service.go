@@ -0,0 +1,41 @@
+package importapi
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/nkhang/pluto/internal/project/projectapi"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/ginwrapper"
+)
+
+type service struct {
+	repository Repository
+}
+
+func NewService(r Repository) *service {
+	return &service{
+		repository: r,
+	}
+}
+
+// Import serves POST .../datasets/import, which datasetapi dispatches here.
+func (s *service) Import(c *gin.Context) ginwrapper.Response {
+	projectID := uint64(c.GetInt64(projectapi.FieldProjectID))
+	var req ImportRequest
+	if err := c.ShouldBind(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("format, title and file are required"),
+		}
+	}
+	resp, err := s.repository.Import(projectID, req)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+			Data:  resp,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
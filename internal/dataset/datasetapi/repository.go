@@ -4,8 +4,6 @@ import (
 	"net/url"
 	"strings"
 
-	"github.com/nkhang/pluto/pkg/annotation"
-
 	"github.com/nkhang/pluto/internal/project"
 
 	"github.com/nkhang/pluto/internal/dataset"
@@ -28,27 +26,25 @@ type Repository interface {
 }
 
 type repository struct {
-	repository        dataset.Repository
-	imgRepo           image.Repository
-	projectRepo       project.Repository
-	annotationService annotation.Service
-	baseURL           string
-	secret            []byte
+	repository  dataset.Repository
+	imgRepo     image.Repository
+	projectRepo project.Repository
+	baseURL     string
+	secret      []byte
 }
 
-func NewRepository(r dataset.Repository, imgRepo image.Repository, p project.Repository, a annotation.Service) *repository {
+func NewRepository(r dataset.Repository, imgRepo image.Repository, p project.Repository) *repository {
 	secret := viper.GetString("getlink.secret")
 	baseURL := viper.GetString("getlink.baseurl")
 	if secret == "" || baseURL == "" {
 		logger.Panic("secret empty")
 	}
 	return &repository{
-		repository:        r,
-		imgRepo:           imgRepo,
-		baseURL:           baseURL,
-		projectRepo:       p,
-		secret:            []byte(secret),
-		annotationService: a,
+		repository:  r,
+		imgRepo:     imgRepo,
+		baseURL:     baseURL,
+		projectRepo: p,
+		secret:      []byte(secret),
 	}
 }
 
@@ -77,10 +73,6 @@ func (r *repository) CreateDataset(title, description string, pID uint64) (Datas
 	if err != nil {
 		return DatasetResponse{}, err
 	}
-	err = r.annotationService.UpdateDataset(d.ID)
-	if err != nil {
-		logger.Errorf("[DATASET-API] - error pushing create dataset request to annotation server. err ", err)
-	}
 	return r.ToDatasetResponse(d), nil
 }
 
@@ -6,6 +6,7 @@ import (
 	"github.com/nkhang/pluto/pkg/pgin"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/internal/dataset"
 	"github.com/nkhang/pluto/internal/project/projectapi"
 	"github.com/nkhang/pluto/pkg/util/idextractor"
@@ -17,34 +18,49 @@ import (
 
 const (
 	FieldDatasetID = "datasetId"
+	pathImport     = "import"
 )
 
+// Importer creates a dataset from an annotated archive. It is served from
+// verifyDataset, like parse, since gin does not allow the import segment
+// next to the dataset ID.
+type Importer interface {
+	Import(c *gin.Context) ginwrapper.Response
+}
+
 type service struct {
-	repository  Repository
-	datasetRepo dataset.Repository
-	imageRouter pgin.Router
+	repository   Repository
+	datasetRepo  dataset.Repository
+	imageRouter  pgin.Router
+	exportRouter pgin.Router
+	importer     Importer
+	authorizer   authz.Authorizer
 }
 
-func NewService(r Repository, datasetRepo dataset.Repository, imageRouter pgin.Router) *service {
+func NewService(r Repository, datasetRepo dataset.Repository, imageRouter, exportRouter pgin.Router, importer Importer, authorizer authz.Authorizer) *service {
 	return &service{
-		repository:  r,
-		datasetRepo: datasetRepo,
-		imageRouter: imageRouter,
+		repository:   r,
+		datasetRepo:  datasetRepo,
+		imageRouter:  imageRouter,
+		exportRouter: exportRouter,
+		importer:     importer,
+		authorizer:   authorizer,
 	}
 }
 
 func (s *service) Register(router gin.IRouter) {
 	router.GET("", ginwrapper.Wrap(s.getByProjectID))
-	router.POST("", ginwrapper.Wrap(s.create))
+	router.POST("", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.create))
 	detailRouter := router.Group("/:"+FieldDatasetID, s.verifyDataset())
 	{
 		detailRouter.POST("")
 		detailRouter.GET("", ginwrapper.Wrap(s.getByID))
-		detailRouter.DELETE("", ginwrapper.Wrap(s.del))
-		detailRouter.GET("/link", ginwrapper.Wrap(s.getLink))
-		detailRouter.POST("/clone", ginwrapper.Wrap(s.clone))
+		detailRouter.DELETE("", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.del))
+		detailRouter.GET("/link", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.getLink))
+		detailRouter.POST("/clone", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.clone))
 	}
-	s.imageRouter.Register(detailRouter.Group("/images"))
+	s.imageRouter.Register(detailRouter)
+	s.exportRouter.Register(detailRouter)
 }
 
 func (s *service) getByID(c *gin.Context) ginwrapper.Response {
@@ -134,6 +150,10 @@ func (s *service) verifyDataset() gin.HandlerFunc {
 			ginwrapper.Wrap(s.parseLink)(c)
 			return
 		}
+		if param == pathImport && c.Request.Method == http.MethodPost {
+			ginwrapper.Wrap(s.importDataset)(c)
+			return
+		}
 		datasetID, err := idextractor.ExtractInt64Param(c, FieldDatasetID)
 		if err != nil {
 			err := errors.BadRequest.NewWithMessageF("dataset %d not found", datasetID)
@@ -160,6 +180,16 @@ func (s *service) verifyDataset() gin.HandlerFunc {
 	})
 }
 
+func (s *service) importDataset(c *gin.Context) ginwrapper.Response {
+	userID := pgin.ExtractUserIDFromContext(c)
+	workspaceID := uint64(c.GetInt64(pgin.FieldWorkspaceID))
+	projectID := uint64(c.GetInt64(projectapi.FieldProjectID))
+	if err := s.authorizer.Authorize(userID, workspaceID, projectID, authz.ProjectManager); err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return s.importer.Import(c)
+}
+
 func (s *service) getLink(c *gin.Context) ginwrapper.Response {
 	datasetID := uint64(c.GetInt64(FieldDatasetID))
 	url, err := s.repository.GetLink(datasetID)
@@ -5,6 +5,7 @@ import (
 
 	"github.com/jinzhu/gorm"
 
+	"github.com/nkhang/pluto/internal/outbox"
 	"github.com/nkhang/pluto/pkg/errors"
 )
 
@@ -53,11 +54,16 @@ func (r *dbRepository) CreateDataset(title, description string, pID uint64) (Dat
 	d := Dataset{
 		Title:       title,
 		Description: description,
-		Thumbnail:   defaultImage,
+		Thumbnail:   DefaultThumbnail,
 
 		ProjectID: pID,
 	}
-	err := r.db.Create(&d).Error
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&d).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, outbox.KindUpdateDataset, outbox.DatasetPayload{DatasetID: d.ID})
+	})
 	if err != nil {
 		return Dataset{}, errors.DatasetCannotCreate.Wrap(err, "cannot create dataset")
 	}
@@ -6,7 +6,8 @@ import (
 
 const (
 	fieldProjectID = "project_id"
-	defaultImage   = "http://annotation.ml:9000/plutos3/placeholder.png"
+	// DefaultThumbnail is the thumbnail of a dataset without images.
+	DefaultThumbnail = "http://annotation.ml:9000/plutos3/placeholder.png"
 )
 
 type Dataset struct {
@@ -10,17 +10,25 @@ import (
 
 type Repository DbRepository
 
+// ImageReleaser deletes the images of deleted datasets and the stored
+// objects no other image uses.
+type ImageReleaser interface {
+	ReleaseDatasets(dIDs []uint64) error
+}
+
 type repository struct {
 	dbRepo    DbRepository
 	taskRepo  task.Repository
 	cacheRepo cache.Cache
+	releaser  ImageReleaser
 }
 
-func NewRepository(d DbRepository, c cache.Cache, t task.Repository) *repository {
+func NewRepository(d DbRepository, c cache.Cache, t task.Repository, releaser ImageReleaser) *repository {
 	return &repository{
 		dbRepo:    d,
 		cacheRepo: c,
 		taskRepo:  t,
+		releaser:  releaser,
 	}
 }
 
@@ -112,9 +120,19 @@ func (r *repository) DeleteDataset(ID uint64) error {
 	if err != nil {
 		return err
 	}
+	go r.release([]uint64{ID})
 	return nil
 }
 
+func (r *repository) release(dIDs []uint64) {
+	if len(dIDs) == 0 {
+		return
+	}
+	if err := r.releaser.ReleaseDatasets(dIDs); err != nil {
+		logger.Errorf("[DATASET] - error releasing images of datasets %v. err %v", dIDs, err)
+	}
+}
+
 func (r *repository) invalidate(datasetID, projectID uint64) {
 	k := rediskey.DatasetByID(datasetID)
 	k2 := rediskey.DatasetByProject(projectID)
@@ -134,10 +152,19 @@ func (r *repository) Update(id uint64, changes map[string]interface{}) (Dataset,
 }
 
 func (r *repository) DeleteByProject(projectID uint64) error {
-	err := r.dbRepo.DeleteByProject(projectID)
+	ds, err := r.dbRepo.GetByProject(projectID)
+	if err != nil {
+		return err
+	}
+	err = r.dbRepo.DeleteByProject(projectID)
 	if err != nil {
 		return err
 	}
 	r.invalidate(0, projectID)
+	var ids = make([]uint64, len(ds))
+	for i := range ds {
+		ids[i] = ds[i].ID
+	}
+	go r.release(ids)
 	return nil
 }
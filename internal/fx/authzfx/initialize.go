@@ -0,0 +1,18 @@
+package authzfx
+
+import (
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+
+	"github.com/nkhang/pluto/internal/authz"
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/workspace"
+)
+
+func provideAuthorizer(w workspace.Repository, p project.Repository) authz.Authorizer {
+	var admins []uint64
+	for _, id := range cast.ToIntSlice(viper.Get("service.admins")) {
+		admins = append(admins, uint64(id))
+	}
+	return authz.NewAuthorizer(w, p, viper.GetBool("service.authen"), admins)
+}
@@ -0,0 +1,5 @@
+package authzfx
+
+import "go.uber.org/fx"
+
+var Module = fx.Provide(provideAuthorizer)
@@ -2,34 +2,70 @@ package datasetfx
 
 import (
 	"github.com/jinzhu/gorm"
+	"github.com/nkhang/pluto/internal/authz"
+	"github.com/nkhang/pluto/internal/label"
 	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/storagegc"
 	"github.com/nkhang/pluto/internal/task"
 	"github.com/nkhang/pluto/pkg/annotation"
+	"github.com/spf13/viper"
 	"go.uber.org/fx"
 
 	"github.com/nkhang/pluto/internal/dataset"
 	"github.com/nkhang/pluto/internal/dataset/datasetapi"
+	"github.com/nkhang/pluto/internal/dataset/datasetapi/exportapi"
+	"github.com/nkhang/pluto/internal/dataset/datasetapi/importapi"
 	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/image/imageapi"
+	"github.com/nkhang/pluto/internal/tool"
 	"github.com/nkhang/pluto/pkg/cache"
 	"github.com/nkhang/pluto/pkg/pgin"
 )
 
-func provideRepository(db *gorm.DB, c cache.Cache, t task.Repository) dataset.Repository {
+func provideRepository(db *gorm.DB, c cache.Cache, t task.Repository, gc *storagegc.Collector) dataset.Repository {
 	dbRepo := dataset.NewDbRepository(db)
-	return dataset.NewRepository(dbRepo, c, t)
+	return dataset.NewRepository(dbRepo, c, t, gc)
 }
 
-func provideAPIRepo(r dataset.Repository, imgRepo image.Repository, p project.Repository, a annotation.Service) datasetapi.Repository {
-	return datasetapi.NewRepository(r, imgRepo, p, a)
+func provideAPIRepo(r dataset.Repository, imgRepo image.Repository, p project.Repository) datasetapi.Repository {
+	return datasetapi.NewRepository(r, imgRepo, p)
+}
+
+func provideExportRepo(d dataset.Repository, i image.Repository, l label.Repository, p project.Repository, t task.Repository, s annotation.Service) exportapi.Repository {
+	conf := exportapi.Config{
+		Dir:           viper.GetString("export.dir"),
+		TTL:           viper.GetDuration("export.ttl"),
+		Workers:       viper.GetInt("export.workers"),
+		SyncThreshold: viper.GetInt("export.syncthreshold"),
+	}
+	return exportapi.NewRepository(d, i, l, p, t, s, conf)
+}
+
+func provideExportService(r exportapi.Repository, a authz.Authorizer) pgin.Router {
+	return exportapi.NewService(r, a)
+}
+
+func provideImportService(d dataset.Repository, dAPI datasetapi.Repository, i imageapi.Repository,
+	l label.Repository, t tool.Repository, s annotation.Service) datasetapi.Importer {
+	limits := imageapi.ArchiveLimits{
+		MaxSize:  viper.GetInt64("upload.archive.maxsize"),
+		MaxFiles: viper.GetInt("upload.archive.maxfiles"),
+		MaxRatio: viper.GetFloat64("upload.archive.maxratio"),
+	}
+	r := importapi.NewRepository(d, dAPI, i, l, t, s, limits)
+	return importapi.NewService(r)
 }
 
 type params struct {
 	fx.In
-	Repository  datasetapi.Repository
-	DatasetRepo dataset.Repository
-	ImageRouter pgin.Router `name:"ImageService"`
+	Repository   datasetapi.Repository
+	DatasetRepo  dataset.Repository
+	Authorizer   authz.Authorizer
+	ImageRouter  pgin.Router `name:"ImageService"`
+	ExportRouter pgin.Router `name:"ExportService"`
+	Importer     datasetapi.Importer
 }
 
 func provideService(p params) pgin.Router {
-	return datasetapi.NewService(p.Repository, p.DatasetRepo, p.ImageRouter)
+	return datasetapi.NewService(p.Repository, p.DatasetRepo, p.ImageRouter, p.ExportRouter, p.Importer, p.Authorizer)
 }
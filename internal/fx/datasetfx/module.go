@@ -5,6 +5,12 @@ import "go.uber.org/fx"
 var Module = fx.Provide(
 	provideRepository,
 	provideAPIRepo,
+	provideExportRepo,
+	provideImportService,
+	fx.Annotated{
+		Name:   "ExportService",
+		Target: provideExportService,
+	},
 	fx.Annotated{
 		Name:   "DatasetService",
 		Target: provideService,
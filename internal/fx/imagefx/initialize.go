@@ -1,8 +1,13 @@
 package imagefx
 
 import (
+	"context"
+
 	"github.com/jinzhu/gorm"
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/storagegc"
+	"github.com/nkhang/pluto/internal/task"
 
 	"github.com/nkhang/pluto/internal/dataset"
 	"github.com/nkhang/pluto/internal/image"
@@ -10,6 +15,8 @@ import (
 	"github.com/nkhang/pluto/pkg/cache"
 	"github.com/nkhang/pluto/pkg/objectstorage"
 	"github.com/nkhang/pluto/pkg/pgin"
+	"github.com/nkhang/pluto/pkg/util/clock"
+	"go.uber.org/fx"
 )
 
 func provideImageRepository(db *gorm.DB, cache cache.Cache) image.Repository {
@@ -17,9 +24,39 @@ func provideImageRepository(db *gorm.DB, cache cache.Cache) image.Repository {
 	return image.NewRepository(dbRepo, cache)
 }
 
-func provideService(r image.Repository, s objectstorage.ObjectStorage,
-	d dataset.Repository, p project.Repository) (pgin.Router, pgin.StandaloneRouter) {
-	repository := imageapi.NewRepository(r, s, d, p)
-	router := imageapi.NewService(repository)
+func provideCollector(r image.Repository, s objectstorage.ObjectStorage) *storagegc.Collector {
+	return storagegc.NewCollector(r, s)
+}
+
+func provideUploadRepository(db *gorm.DB) image.UploadRepository {
+	return image.NewUploadRepository(db)
+}
+
+func provideAPIRepository(r image.Repository, u image.UploadRepository, s objectstorage.ObjectStorage,
+	d dataset.Repository, p project.Repository, t task.Repository, gc *storagegc.Collector, c clock.Clock) (imageapi.Repository, imageapi.Uploader) {
+	repository := imageapi.NewRepository(r, u, s, d, p, t, gc, c)
+	return repository, repository
+}
+
+func provideService(repository imageapi.Repository, a authz.Authorizer) (pgin.Router, pgin.StandaloneRouter) {
+	router := imageapi.NewService(repository, a)
 	return router, router
 }
+
+func provideProjectService(repository imageapi.Repository) pgin.Router {
+	return imageapi.NewProjectService(repository)
+}
+
+func runUploader(l fx.Lifecycle, u imageapi.Uploader) {
+	ctx, cancel := context.WithCancel(context.Background())
+	l.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go u.RunUploads(ctx)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+}
@@ -2,10 +2,18 @@ package imagefx
 
 import "go.uber.org/fx"
 
-var Module = fx.Provide(
+var Module = fx.Options(fx.Provide(
 	provideImageRepository,
+	provideUploadRepository,
+	provideCollector,
+	provideAPIRepository,
 	fx.Annotated{
 		Name:   "ImageService",
 		Target: provideService,
 	},
+	fx.Annotated{
+		Name:   "ImageProjectService",
+		Target: provideProjectService,
+	}),
+	fx.Invoke(runUploader),
 )
@@ -2,9 +2,16 @@ package labelfx
 
 import (
 	"github.com/jinzhu/gorm"
+	"go.uber.org/fx"
 
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/internal/label"
 	"github.com/nkhang/pluto/internal/label/labelapi"
+	"github.com/nkhang/pluto/internal/label/templateapi"
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/task"
+	"github.com/nkhang/pluto/internal/tool"
+	"github.com/nkhang/pluto/pkg/annotation"
 	"github.com/nkhang/pluto/pkg/cache"
 	"github.com/nkhang/pluto/pkg/pgin"
 )
@@ -15,7 +22,30 @@ func provideRepository(db *gorm.DB, c cache.Cache) label.Repository {
 	return label.NewRepository(dbRepo, c)
 }
 
-func provideService(r label.Repository) pgin.Router {
-	repository := labelapi.NewRepository(r)
-	return labelapi.NewService(repository)
+func provideTemplateRepository(db *gorm.DB) label.TemplateRepository {
+	return label.NewTemplateRepository(db)
+}
+
+func provideTemplateAPIRepository(t label.TemplateRepository, l label.Repository, tools tool.Repository) templateapi.Repository {
+	return templateapi.NewRepository(t, l, tools)
+}
+
+type serviceParams struct {
+	fx.In
+
+	LabelRepo         label.Repository
+	AnnotationService annotation.Service
+	ProjectRepo       project.Repository
+	TaskRepo          task.Repository
+	TemplateRepo      templateapi.Repository
+	Authorizer        authz.Authorizer
+}
+
+func provideService(p serviceParams) pgin.Router {
+	repository := labelapi.NewRepository(p.LabelRepo, p.AnnotationService, p.ProjectRepo, p.TaskRepo)
+	return labelapi.NewService(repository, templateapi.NewProjectService(p.TemplateRepo, p.Authorizer), p.Authorizer)
+}
+
+func provideTemplateService(t templateapi.Repository, a authz.Authorizer) pgin.Router {
+	return templateapi.NewService(t, a)
 }
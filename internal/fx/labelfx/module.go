@@ -4,7 +4,13 @@ import "go.uber.org/fx"
 
 var Module = fx.Provide(
 	provideRepository,
+	provideTemplateRepository,
+	provideTemplateAPIRepository,
 	fx.Annotated{
 		Name:   "LabelService",
 		Target: provideService,
+	},
+	fx.Annotated{
+		Name:   "TemplateService",
+		Target: provideTemplateService,
 	})
@@ -0,0 +1,19 @@
+package outboxfx
+
+import (
+	"github.com/jinzhu/gorm"
+
+	"github.com/nkhang/pluto/internal/authz"
+	"github.com/nkhang/pluto/internal/outbox"
+	"github.com/nkhang/pluto/internal/outbox/outboxapi"
+	"github.com/nkhang/pluto/pkg/pgin"
+)
+
+func provideDBRepository(db *gorm.DB) outbox.DBRepository {
+	return outbox.NewDBRepository(db)
+}
+
+func provideService(r outbox.DBRepository, a authz.Authorizer) pgin.StandaloneRouter {
+	repository := outboxapi.NewRepository(r)
+	return outboxapi.NewService(repository, a)
+}
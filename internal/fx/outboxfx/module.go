@@ -0,0 +1,11 @@
+package outboxfx
+
+import "go.uber.org/fx"
+
+var Module = fx.Provide(
+	provideDBRepository,
+	fx.Annotated{
+		Name:   "OutboxService",
+		Target: provideService,
+	},
+)
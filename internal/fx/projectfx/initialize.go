@@ -2,13 +2,16 @@ package projectfx
 
 import (
 	"github.com/jinzhu/gorm"
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/internal/image"
 	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/label/templateapi"
 	"github.com/nkhang/pluto/internal/project/projectapi/permissionapi"
 	"github.com/nkhang/pluto/internal/project/projectapi/statsapi"
 	"github.com/nkhang/pluto/internal/task"
 	"github.com/nkhang/pluto/internal/workspace/workspaceapi"
 	"github.com/nkhang/pluto/pkg/annotation"
+	"github.com/nkhang/pluto/pkg/util/clock"
 	"go.uber.org/fx"
 
 	"github.com/nkhang/pluto/internal/dataset"
@@ -26,32 +29,33 @@ func provideRepository(r project.DBRepository, c cache.Cache, t task.Repository,
 	return project.NewRepository(r, c, t, d, i)
 }
 
-func provideAPIRepository(r project.Repository, dr dataset.Repository, wr workspaceapi.Repository, ann annotation.Service) projectapi.Repository {
-	return projectapi.NewRepository(r, dr, wr, ann)
+func provideAPIRepository(r project.Repository, dr dataset.Repository, wr workspaceapi.Repository, tr templateapi.Repository) projectapi.Repository {
+	return projectapi.NewRepository(r, dr, wr, tr)
 }
 
-func provideStatsAPIRepo(d dataset.Repository, t task.Repository, i image.Repository, s annotation.Service, l label.Repository) statsapi.Repository {
-	return statsapi.NewRepository(d, t, i, s, l)
+func provideStatsAPIRepo(d dataset.Repository, t task.Repository, i image.Repository, s annotation.Service, l label.Repository, p project.Repository, c clock.Clock) statsapi.Repository {
+	return statsapi.NewRepository(d, t, i, s, l, p, c)
 }
 
 type params struct {
 	fx.In
 
-	Repository        projectapi.Repository
-	StatAPIRepo       statsapi.Repository
-	ProjectRepo       project.Repository
-	ProjectAPI        projectapi.Repository
-	AnnotationService annotation.Service
-	DatasetRouter     pgin.Router `name:"DatasetService"`
-	TaskRouter        pgin.Router `name:"TaskService"`
-	LabelRouter       pgin.Router `name:"LabelService"`
+	Repository    projectapi.Repository
+	StatAPIRepo   statsapi.Repository
+	ProjectRepo   project.Repository
+	ProjectAPI    projectapi.Repository
+	Authorizer    authz.Authorizer
+	DatasetRouter pgin.Router `name:"DatasetService"`
+	TaskRouter    pgin.Router `name:"TaskService"`
+	LabelRouter   pgin.Router `name:"LabelService"`
+	ImageRouter   pgin.Router `name:"ImageProjectService"`
 }
 
 func provideService(p params) (pgin.Router, pgin.StandaloneRouter) {
-	permRepo := permissionapi.NewProjectPermissionAPIRepository(p.ProjectRepo, p.ProjectAPI, p.AnnotationService)
-	permService := permissionapi.NewService(permRepo, p.ProjectRepo)
+	permRepo := permissionapi.NewProjectPermissionAPIRepository(p.ProjectRepo, p.ProjectAPI)
+	permService := permissionapi.NewService(permRepo, p.ProjectRepo, p.Authorizer)
 	statService := statsapi.NewService(p.StatAPIRepo)
 	service := projectapi.NewService(p.Repository, p.ProjectRepo,
-		permService, p.TaskRouter, p.DatasetRouter, p.LabelRouter, statService)
+		permService, p.TaskRouter, p.DatasetRouter, p.LabelRouter, statService, p.ImageRouter, p.Authorizer)
 	return service, service
 }
@@ -1,16 +1,23 @@
 package taskfx
 
 import (
+	"context"
+
 	"github.com/jinzhu/gorm"
+	"github.com/nats-io/nats.go"
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/internal/dataset/datasetapi"
 	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/label"
 	"github.com/nkhang/pluto/internal/project/projectapi"
 	"github.com/nkhang/pluto/internal/task"
 	"github.com/nkhang/pluto/internal/task/taskapi"
 	"github.com/nkhang/pluto/internal/task/taskapi/statsapi"
-	"github.com/nkhang/pluto/pkg/annotation"
 	"github.com/nkhang/pluto/pkg/cache"
+	"github.com/nkhang/pluto/pkg/logger"
 	pgin "github.com/nkhang/pluto/pkg/pgin"
+	"github.com/nkhang/pluto/pkg/util/clock"
+	"github.com/spf13/viper"
 	"go.uber.org/fx"
 )
 
@@ -18,8 +25,8 @@ func provideTaskDBRepo(db *gorm.DB) task.DBRepository {
 	return task.NewDBRepository(db)
 }
 
-func provideTaskRepo(dbRepo task.DBRepository, cacheRepo cache.Cache) task.Repository {
-	return task.NewRepository(dbRepo, cacheRepo)
+func provideTaskRepo(dbRepo task.DBRepository, cacheRepo cache.Cache, c clock.Clock) task.Repository {
+	return task.NewRepository(dbRepo, cacheRepo, c)
 }
 
 func provideTaskStatsRepo(r task.Repository) statsapi.Repository {
@@ -30,18 +37,54 @@ func provideTaskStatsService(r statsapi.Repository) pgin.Router {
 	return statsapi.NewService(r)
 }
 
-func provideAPIRepo(r task.Repository, ir image.Repository, datasetRepo datasetapi.Repository, projectRepo projectapi.Repository, annotationService annotation.Service) taskapi.Repository {
-	return taskapi.NewRepository(r, ir, datasetRepo, projectRepo, annotationService)
+func provideAPIRepo(r task.Repository, ir image.Repository, datasetRepo datasetapi.Repository, projectRepo projectapi.Repository, labelRepo label.Repository, c clock.Clock) taskapi.Repository {
+	return taskapi.NewRepository(r, ir, datasetRepo, projectRepo, labelRepo, c, viper.GetDuration("workqueue.lease"))
 }
 
 type params struct {
 	fx.In
 	Repo        task.Repository
 	APIRepo     taskapi.Repository
+	Authorizer  authz.Authorizer
 	StatsRouter pgin.Router `name:"TaskStatsService"`
 }
 
 func provideService(p params) (pgin.Router, pgin.StandaloneRouter, *taskapi.Service) {
-	service := taskapi.NewService(p.APIRepo, p.Repo, p.StatsRouter)
+	service := taskapi.NewService(p.APIRepo, p.Repo, p.StatsRouter, p.Authorizer)
 	return service, service, service
 }
+
+type sweeperParams struct {
+	fx.In
+	DBRepo   task.DBRepository
+	Clock    clock.Clock
+	NATSConn *nats.EncodedConn `optional:"true"`
+}
+
+func runSweeper(l fx.Lifecycle, p sweeperParams) {
+	if !viper.GetBool("deadline.sweeper") {
+		logger.Info("[TASK] - overdue sweeper is disabled")
+		return
+	}
+	if p.NATSConn == nil {
+		logger.Error("[TASK] - overdue sweeper needs a NATS connection, set nats.enabled")
+		return
+	}
+	conf := task.SweeperConfig{
+		Interval:  viper.GetDuration("deadline.interval"),
+		BatchSize: viper.GetInt("deadline.batchsize"),
+		Subject:   viper.GetString("deadline.overdue"),
+	}
+	sweeper := task.NewSweeper(p.DBRepo, p.NATSConn, p.Clock, conf)
+	ctx, cancel := context.WithCancel(context.Background())
+	l.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go sweeper.Run(ctx)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+}
@@ -1,8 +1,12 @@
 package taskfx
 
-import "go.uber.org/fx"
+import (
+	"github.com/nkhang/pluto/pkg/util/clock"
+	"go.uber.org/fx"
+)
 
-var Module = fx.Provide(
+var Module = fx.Options(fx.Provide(
+	clock.New,
 	provideTaskDBRepo,
 	provideTaskRepo,
 	provideAPIRepo,
@@ -14,4 +18,6 @@ var Module = fx.Provide(
 	fx.Annotated{
 		Name:   "TaskStatsService",
 		Target: provideTaskStatsService,
-	})
+	}),
+	fx.Invoke(runSweeper),
+)
@@ -2,6 +2,7 @@ package toolfx
 
 import (
 	"github.com/jinzhu/gorm"
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/internal/tool"
 	"github.com/nkhang/pluto/internal/tool/toolapi"
 	"github.com/nkhang/pluto/pkg/cache"
@@ -18,6 +19,6 @@ func provideToolAPI(r tool.Repository) toolapi.Repository {
 	return toolapi.NewRepository(r)
 }
 
-func provideToolService(r toolapi.Repository) pgin.StandaloneRouter {
-	return toolapi.NewService(r)
+func provideToolService(r toolapi.Repository, a authz.Authorizer) pgin.StandaloneRouter {
+	return toolapi.NewService(r, a)
 }
@@ -2,6 +2,7 @@ package workspacefx
 
 import (
 	"github.com/jinzhu/gorm"
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/internal/project"
 	"github.com/nkhang/pluto/internal/workspace/workspaceapi/permissionapi"
 	"go.uber.org/fx"
@@ -26,13 +27,15 @@ func provideWorkspaceAPIRepository(workspaceRepo workspace.Repository, projectRe
 
 type params struct {
 	fx.In
-	Repository    workspaceapi.Repository
-	Wr            workspace.Repository
-	ProjectRouter pgin.Router `name:"ProjectService"`
+	Repository     workspaceapi.Repository
+	Wr             workspace.Repository
+	Authorizer     authz.Authorizer
+	ProjectRouter  pgin.Router `name:"ProjectService"`
+	TemplateRouter pgin.Router `name:"TemplateService"`
 }
 
 func provideWorkspaceService(p params) pgin.StandaloneRouter {
 	permRepo := permissionapi.NewRepository(p.Wr)
-	permRouter := permissionapi.NewService(permRepo)
-	return workspaceapi.NewService(p.Repository, p.Wr, p.ProjectRouter, permRouter)
+	permRouter := permissionapi.NewService(permRepo, p.Authorizer)
+	return workspaceapi.NewService(p.Repository, p.Wr, p.ProjectRouter, permRouter, p.TemplateRouter, p.Authorizer)
 }
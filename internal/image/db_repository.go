@@ -10,8 +10,17 @@ import (
 type DBRepository interface {
 	Get(id uint64) (Image, error)
 	GetByDataset(dID uint64, offset, limit int) (imgs []Image, err error)
-	CreateImage(title, url, thumbnail string, w, h int, size int64, dataset_id uint64) (Image, error)
+	CreateImage(title, url, thumbnail string, w, h int, size int64, dataset_id uint64, tags []string, contentHash string, perceptualHash uint64) (Image, error)
 	GetAllByDataset(dID uint64) (images []Image, err error)
+	GetByContentHash(pID uint64, hash string) ([]Image, error)
+	GetByURL(dID uint64, url string) ([]Image, error)
+	GetHashedByProject(pID uint64) ([]Image, error)
+	Update(id uint64, changes map[string]interface{}) (Image, error)
+	Delete(id uint64, within func(tx *gorm.DB) error) error
+	Move(id, dID uint64, url, thumbnail string, within func(tx *gorm.DB) error) (Image, error)
+	DeleteByDatasets(dIDs []uint64) ([]Image, error)
+	CountReferences(urls []string) (int, error)
+	GetAllURLs() ([]string, error)
 	BulkInsert(images []Image, dID uint64) error
 	Incr(id uint64) error
 }
@@ -60,7 +69,154 @@ func (r *dbRepository) GetAllByDataset(dID uint64) (images []Image, err error) {
 	return
 }
 
-func (r *dbRepository) CreateImage(title, url, thumbnail string, w, h int, size int64, dID uint64) (Image, error) {
+// GetByContentHash returns the images of the datasets of project pID whose
+// file has the given hash.
+func (r *dbRepository) GetByContentHash(pID uint64, hash string) (images []Image, err error) {
+	err = r.db.Joins("JOIN datasets ON datasets.id = images.dataset_id AND datasets.deleted_at IS NULL").
+		Where("datasets.project_id = ? AND images.content_hash = ?", pID, hash).
+		Order("images.id").
+		Find(&images).Error
+	if err != nil {
+		err = errors.ImageQueryError.Wrap(err, "images query error")
+	}
+	return
+}
+
+// GetByURL returns the images of dataset dID whose file is at url.
+func (r *dbRepository) GetByURL(dID uint64, url string) (images []Image, err error) {
+	err = r.db.Where("dataset_id = ? AND url = ?", dID, url).
+		Order("id").
+		Find(&images).Error
+	if err != nil {
+		err = errors.ImageQueryError.Wrap(err, "images query error")
+	}
+	return
+}
+
+// GetHashedByProject returns the images of the datasets of project pID that
+// have their hashes.
+func (r *dbRepository) GetHashedByProject(pID uint64) (images []Image, err error) {
+	err = r.db.Joins("JOIN datasets ON datasets.id = images.dataset_id AND datasets.deleted_at IS NULL").
+		Where("datasets.project_id = ? AND images.content_hash <> ''", pID).
+		Order("images.id").
+		Find(&images).Error
+	if err != nil {
+		err = errors.ImageQueryError.Wrap(err, "images query error")
+	}
+	return
+}
+
+func (r *dbRepository) Update(id uint64, changes map[string]interface{}) (Image, error) {
+	var img Image
+	img.ID = id
+	err := r.db.Model(&img).Updates(changes).First(&img, id).Error
+	if err != nil {
+		return Image{}, errors.ImageCannotUpdate.Wrap(err, "cannot update image")
+	}
+	return img, nil
+}
+
+// Delete deletes an image. within runs first in the same transaction, so
+// that what refers to the image goes along with it.
+func (r *dbRepository) Delete(id uint64, within func(tx *gorm.DB) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if within != nil {
+			if err := within(tx); err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&Image{}, id).Error; err != nil {
+			return errors.ImageCannotDelete.WrapF(err, "cannot delete image %d", id)
+		}
+		return nil
+	})
+}
+
+// Move puts an image into dataset dID with its new file and thumbnail.
+// within runs first in the same transaction, as in Delete.
+func (r *dbRepository) Move(id, dID uint64, url, thumbnail string, within func(tx *gorm.DB) error) (Image, error) {
+	var img Image
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if within != nil {
+			if err := within(tx); err != nil {
+				return err
+			}
+		}
+		img.ID = id
+		err := tx.Model(&img).Updates(map[string]interface{}{
+			"dataset_id": dID,
+			"url":        url,
+			"thumbnail":  thumbnail,
+		}).First(&img, id).Error
+		if err != nil {
+			return errors.ImageCannotUpdate.Wrap(err, "cannot move image")
+		}
+		return nil
+	})
+	if err != nil {
+		return Image{}, err
+	}
+	return img, nil
+}
+
+// DeleteByDatasets deletes the images of the datasets dIDs and returns them.
+func (r *dbRepository) DeleteByDatasets(dIDs []uint64) ([]Image, error) {
+	var images = make([]Image, 0)
+	if len(dIDs) == 0 {
+		return images, nil
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("dataset_id IN (?)", dIDs).Find(&images).Error; err != nil {
+			return err
+		}
+		return tx.Where("dataset_id IN (?)", dIDs).Delete(&Image{}).Error
+	})
+	if err != nil {
+		return nil, errors.ImageCannotDelete.Wrap(err, "cannot delete images of datasets")
+	}
+	return images, nil
+}
+
+// CountReferences counts the images of live datasets whose file or
+// thumbnail is at any of urls, the forms under which an object may be
+// stored. Clones of a dataset share the URLs of its images.
+func (r *dbRepository) CountReferences(urls []string) (count int, err error) {
+	err = r.db.Model(&Image{}).
+		Joins("JOIN datasets ON datasets.id = images.dataset_id AND datasets.deleted_at IS NULL").
+		Where("images.url IN (?) OR images.thumbnail IN (?)", urls, urls).
+		Count(&count).Error
+	if err != nil {
+		err = errors.ImageQueryError.Wrap(err, "image references query error")
+	}
+	return
+}
+
+// GetAllURLs returns the URLs of the files and thumbnails of the images of
+// live datasets.
+func (r *dbRepository) GetAllURLs() ([]string, error) {
+	rows, err := r.db.Model(&Image{}).
+		Joins("JOIN datasets ON datasets.id = images.dataset_id AND datasets.deleted_at IS NULL").
+		Select("images.url, images.thumbnail").
+		Rows()
+	if err != nil {
+		return nil, errors.ImageQueryError.Wrap(err, "image urls query error")
+	}
+	defer rows.Close()
+	var urls = make([]string, 0)
+	for rows.Next() {
+		var u, thumbnail string
+		if err := rows.Scan(&u, &thumbnail); err != nil {
+			return nil, errors.ImageQueryError.Wrap(err, "image urls query error")
+		}
+		urls = append(urls, u, thumbnail)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.ImageQueryError.Wrap(err, "image urls query error")
+	}
+	return urls, nil
+}
+
+func (r *dbRepository) CreateImage(title, url, thumbnail string, w, h int, size int64, dID uint64, tags []string, contentHash string, perceptualHash uint64) (Image, error) {
 	img := Image{
 		URL:       url,
 		Thumbnail: thumbnail,
@@ -69,6 +225,10 @@ func (r *dbRepository) CreateImage(title, url, thumbnail string, w, h int, size
 		Height:    h,
 		Size:      size,
 		DatasetID: dID,
+		Tags:      JoinTags(tags),
+
+		ContentHash:    contentHash,
+		PerceptualHash: perceptualHash,
 	}
 	err := r.db.Save(&img).Error
 	if err != nil {
@@ -88,6 +248,10 @@ func (r *dbRepository) BulkInsert(images []Image, dID uint64) error {
 			Height:    images[i].Height,
 			Size:      images[i].Size,
 			DatasetID: dID,
+			Tags:      images[i].Tags,
+
+			ContentHash:    images[i].ContentHash,
+			PerceptualHash: images[i].PerceptualHash,
 		}
 		clone = append(clone, img)
 	}
@@ -0,0 +1,205 @@
+package imageapi
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+// minRatioCheckSize is the uncompressed size from which the compression
+// ratio is checked, small files of any kind may compress well.
+const minRatioCheckSize = 1 << 20
+
+// ArchiveLimits bounds what is unpacked from an uploaded archive.
+type ArchiveLimits struct {
+	// MaxSize is the total uncompressed size of the archive in bytes.
+	MaxSize int64
+	// MaxFiles is the number of files in the archive.
+	MaxFiles int
+	// MaxRatio is the largest ratio of uncompressed to compressed size.
+	MaxRatio float64
+}
+
+type archiveKind int
+
+const (
+	notArchive archiveKind = iota
+	zipArchive
+	tarGzArchive
+)
+
+func archiveKindOf(filename string) archiveKind {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return zipArchive
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return tarGzArchive
+	}
+	return notArchive
+}
+
+// entryFunc gets each file of an archive with its path within the archive.
+// The reader is only valid until entryFunc returns.
+type entryFunc func(name string, r io.Reader)
+
+// skipEntry reports whether an entry holds the metadata added by archivers,
+// such as __MACOSX and dot files, which are left out silently.
+func skipEntry(name string) bool {
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".")
+}
+
+// OpenZip opens a zip archive and checks limits against the sizes declared
+// by the archive before any file is read. archive/zip fails the read of a
+// file that goes past its declared size, so the files of the returned
+// archive stay within limits.
+func OpenZip(r io.ReaderAt, size int64, limits ArchiveLimits) (*zip.Reader, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.ImageArchiveCannotRead.Wrap(err, "cannot open zip archive")
+	}
+	var (
+		count int
+		total uint64
+	)
+	for _, f := range z.File {
+		if f.FileInfo().IsDir() || skipEntry(f.Name) {
+			continue
+		}
+		count++
+		total += f.UncompressedSize64
+		if err := limits.check(count, total); err != nil {
+			return nil, err
+		}
+		if f.UncompressedSize64 >= minRatioCheckSize && limits.MaxRatio > 0 &&
+			float64(f.UncompressedSize64) > limits.MaxRatio*float64(f.CompressedSize64) {
+			return nil, errors.ImageArchiveTooLarge.NewWithMessageF("file %s of archive is compressed too much", f.Name)
+		}
+	}
+	return z, nil
+}
+
+// unpackZip passes the files of a zip archive opened with OpenZip to fn one
+// at a time.
+func unpackZip(r io.ReaderAt, size int64, limits ArchiveLimits, fn entryFunc) error {
+	z, err := OpenZip(r, size, limits)
+	if err != nil {
+		return err
+	}
+	for _, f := range z.File {
+		if f.FileInfo().IsDir() || skipEntry(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return errors.ImageArchiveCannotRead.WrapF(err, "cannot open %s in archive", f.Name)
+		}
+		fn(f.Name, rc)
+		rc.Close()
+	}
+	return nil
+}
+
+// unpackTarGz streams the files of a gzipped tar archive to fn. Since the
+// sizes are only known as the archive is read, the limits are checked as
+// each header comes and the compression ratio as the bytes are read. The
+// files passed before a limit is hit are kept.
+func unpackTarGz(r io.Reader, limits ArchiveLimits, fn entryFunc) error {
+	compressed := &countingReader{r: r}
+	gz, err := gzip.NewReader(compressed)
+	if err != nil {
+		return errors.ImageArchiveCannotRead.Wrap(err, "cannot open gzip archive")
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	uncompressed := &ratioReader{r: tr, compressed: compressed, maxRatio: limits.MaxRatio}
+	var (
+		count int
+		total uint64
+	)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.ImageArchiveCannotRead.Wrap(err, "cannot read tar archive")
+		}
+		if h.Typeflag != tar.TypeReg || skipEntry(h.Name) {
+			continue
+		}
+		count++
+		total += uint64(h.Size)
+		if err := limits.check(count, total); err != nil {
+			return err
+		}
+		fn(h.Name, io.LimitReader(uncompressed, h.Size))
+		if uncompressed.err != nil {
+			return uncompressed.err
+		}
+	}
+}
+
+func (l ArchiveLimits) check(count int, total uint64) error {
+	if l.MaxFiles > 0 && count > l.MaxFiles {
+		return errors.ImageArchiveTooLarge.NewWithMessageF("archive has more than %d files", l.MaxFiles)
+	}
+	if l.MaxSize > 0 && total > uint64(l.MaxSize) {
+		return errors.ImageArchiveTooLarge.NewWithMessageF("archive is larger than %d bytes uncompressed", l.MaxSize)
+	}
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ratioReader fails, and keeps failing, once more than maxRatio times the
+// bytes read from compressed have come out of r.
+type ratioReader struct {
+	r          io.Reader
+	compressed *countingReader
+	maxRatio   float64
+	n          int64
+	err        error
+}
+
+func (c *ratioReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.n >= minRatioCheckSize && c.maxRatio > 0 && float64(c.n) > c.maxRatio*float64(c.compressed.n) {
+		c.err = errors.ImageArchiveTooLarge.NewWithMessage("archive is compressed too much")
+		return n, c.err
+	}
+	return n, err
+}
+
+// folderTags turns the folders of name within an archive into tags.
+func folderTags(name string) []string {
+	dir := path.Dir(name)
+	if dir == "." || dir == "/" {
+		return nil
+	}
+	var tags []string
+	for _, t := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if t != "" && t != "." {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
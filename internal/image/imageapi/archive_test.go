@@ -0,0 +1,119 @@
+package imageapi
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+type archiveFile struct {
+	name    string
+	content []byte
+}
+
+func newZipArchive(t *testing.T, files ...archiveFile) []byte {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := z.Create(f.name)
+		require.NoError(t, err)
+		_, err = w.Write(f.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, z.Close())
+	return buf.Bytes()
+}
+
+func newTarGzArchive(t *testing.T, files ...archiveFile) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     f.name,
+			Mode:     0644,
+			Size:     int64(len(f.content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write(f.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// collect reads every entry passed to it, so the ratio of tar.gz archives is
+// checked on the whole content.
+func collect(got map[string]string) entryFunc {
+	return func(name string, r io.Reader) {
+		b, _ := ioutil.ReadAll(r)
+		got[name] = string(b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	files := []archiveFile{
+		{name: "cats/indoor/a.jpg", content: []byte("a")},
+		{name: "b.png", content: []byte("b")},
+		{name: "__MACOSX/cats/._a.jpg", content: []byte("x")},
+		{name: ".DS_Store", content: []byte("x")},
+	}
+	want := map[string]string{"cats/indoor/a.jpg": "a", "b.png": "b"}
+
+	z := newZipArchive(t, files...)
+	got := make(map[string]string)
+	require.NoError(t, unpackZip(bytes.NewReader(z), int64(len(z)), ArchiveLimits{}, collect(got)))
+	assert.Equal(t, want, got)
+
+	got = make(map[string]string)
+	require.NoError(t, unpackTarGz(bytes.NewReader(newTarGzArchive(t, files...)), ArchiveLimits{}, collect(got)))
+	assert.Equal(t, want, got)
+}
+
+func TestUnpackLimits(t *testing.T) {
+	small := []archiveFile{{name: "a.jpg", content: []byte("aaaa")}, {name: "b.jpg", content: []byte("bbbb")}}
+	bomb := []archiveFile{{name: "bomb.jpg", content: make([]byte, 4<<20)}}
+	tests := []struct {
+		name   string
+		files  []archiveFile
+		limits ArchiveLimits
+	}{
+		{name: "too many files", files: small, limits: ArchiveLimits{MaxFiles: 1}},
+		{name: "too large", files: small, limits: ArchiveLimits{MaxSize: 6}},
+		{name: "compressed too much", files: bomb, limits: ArchiveLimits{MaxRatio: 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			z := newZipArchive(t, tt.files...)
+			err := unpackZip(bytes.NewReader(z), int64(len(z)), tt.limits, collect(make(map[string]string)))
+			assert.Equal(t, errors.ImageArchiveTooLarge, errors.Type(err), "zip")
+
+			tgz := newTarGzArchive(t, tt.files...)
+			err = unpackTarGz(bytes.NewReader(tgz), tt.limits, collect(make(map[string]string)))
+			assert.Equal(t, errors.ImageArchiveTooLarge, errors.Type(err), "tar.gz")
+		})
+	}
+}
+
+func TestUnpackRejectsOtherFiles(t *testing.T) {
+	err := unpackZip(bytes.NewReader([]byte("not a zip")), 9, ArchiveLimits{}, collect(make(map[string]string)))
+	assert.Equal(t, errors.ImageArchiveCannotRead, errors.Type(err))
+	err = unpackTarGz(bytes.NewReader([]byte("not a tar")), ArchiveLimits{}, collect(make(map[string]string)))
+	assert.Equal(t, errors.ImageArchiveCannotRead, errors.Type(err))
+}
+
+func TestFolderTags(t *testing.T) {
+	assert.Equal(t, []string{"cats", "indoor"}, folderTags("cats/indoor/a.jpg"))
+	assert.Empty(t, folderTags("a.jpg"))
+	assert.Equal(t, []string{"dogs"}, folderTags("./dogs/b.jpg"))
+}
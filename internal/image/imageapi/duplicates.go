@@ -0,0 +1,51 @@
+package imageapi
+
+import (
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/pkg/imagehash"
+)
+
+// DefaultDuplicateThreshold is the Hamming distance under which two images
+// are near duplicates when the request sets none.
+const DefaultDuplicateThreshold = 10
+
+func (r *repository) GetDuplicatesByDataset(dID uint64, threshold int) (DuplicatesResponse, error) {
+	images, err := r.repo.GetAllImageByDataset(dID)
+	if err != nil {
+		return DuplicatesResponse{}, err
+	}
+	var hashed = make([]image.Image, 0, len(images))
+	for _, img := range images {
+		if img.ContentHash != "" {
+			hashed = append(hashed, img)
+		}
+	}
+	return clusterDuplicates(hashed, threshold), nil
+}
+
+func (r *repository) GetDuplicatesByProject(pID uint64, threshold int) (DuplicatesResponse, error) {
+	images, err := r.repo.GetHashedByProject(pID)
+	if err != nil {
+		return DuplicatesResponse{}, err
+	}
+	return clusterDuplicates(images, threshold), nil
+}
+
+func clusterDuplicates(images []image.Image, threshold int) DuplicatesResponse {
+	hashes := make([]uint64, len(images))
+	for i, img := range images {
+		hashes[i] = img.PerceptualHash
+	}
+	resp := DuplicatesResponse{
+		Threshold: threshold,
+		Clusters:  make([]DuplicateCluster, 0),
+	}
+	for _, group := range imagehash.Cluster(hashes, threshold) {
+		cluster := DuplicateCluster{Images: make([]ImageResponse, len(group))}
+		for i, idx := range group {
+			cluster.Images[i] = ToImageResponse(images[idx])
+		}
+		resp.Clusters = append(resp.Clusters, cluster)
+	}
+	return resp
+}
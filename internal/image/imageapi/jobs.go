@@ -0,0 +1,193 @@
+package imageapi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+// Uploader turns the spooled files of upload jobs into images.
+type Uploader interface {
+	// RunUploads resumes the unfinished jobs of this instance and runs the
+	// workers until ctx is done.
+	RunUploads(ctx context.Context)
+}
+
+// StartUpload spools the uploaded files and archives to the local disk and
+// returns the upload job right away, its images are created by the workers.
+func (r *repository) StartUpload(dID uint64, req UploadRequest) (UploadJobResponse, error) {
+	if _, err := r.datasetRepo.Get(dID); err != nil {
+		return UploadJobResponse{}, err
+	}
+	job, err := r.uploadRepo.CreateJob(dID, r.conf.Upload.Instance)
+	if err != nil {
+		return UploadJobResponse{}, err
+	}
+	dir := r.jobDir(job.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logger.Errorf("[IMAGE-API] - cannot create spool directory %s. err %v", dir, err)
+		if err := r.uploadRepo.SetJobStatus(job.ID, image.UploadFailed); err != nil {
+			logger.Errorf("[IMAGE-API] - cannot fail upload job %d. err %v", job.ID, err)
+		}
+		return UploadJobResponse{}, errors.ImageUploadJobCannotCreate.Wrap(err, "cannot spool uploaded files")
+	}
+	s := newSpool(dir, r.conf.Archive, req.FolderTags)
+	for _, header := range req.FileHeader {
+		s.file(header)
+	}
+	job, err = r.uploadRepo.AddFiles(job.ID, s.files)
+	if err != nil {
+		os.RemoveAll(dir)
+		return UploadJobResponse{}, err
+	}
+	logger.Infof("[IMAGE-API] - upload job %d started with %d files", job.ID, job.Total)
+	if job.Status == image.UploadDone {
+		os.RemoveAll(dir)
+	} else {
+		r.enqueue(job.ID)
+	}
+	return r.toUploadJobResponse(job)
+}
+
+func (r *repository) GetUploadJob(dID, jobID uint64) (UploadJobResponse, error) {
+	job, err := r.uploadRepo.GetJob(jobID)
+	if err != nil {
+		return UploadJobResponse{}, err
+	}
+	if job.DatasetID != dID {
+		return UploadJobResponse{}, errors.ImageUploadJobNotFound.NewWithMessageF("upload job %d not found", jobID)
+	}
+	return r.toUploadJobResponse(job)
+}
+
+func (r *repository) toUploadJobResponse(job image.UploadJob) (UploadJobResponse, error) {
+	failed, err := r.uploadRepo.GetFiles(job.ID, image.UploadFailed)
+	if err != nil {
+		return UploadJobResponse{}, err
+	}
+	return ToUploadJobResponse(job, failed), nil
+}
+
+func (r *repository) RunUploads(ctx context.Context) {
+	r.resume()
+	var wg sync.WaitGroup
+	for i := 0; i < r.conf.Upload.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case f := <-r.uploads:
+					r.process(f)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// resume queues again the pending files of the running jobs of this
+// instance. A job that was still spooling cannot be resumed, its upload
+// request never got an answer.
+func (r *repository) resume() {
+	jobs, err := r.uploadRepo.GetUnfinishedJobs(r.conf.Upload.Instance)
+	if err != nil {
+		logger.Errorf("[IMAGE-API] - cannot get unfinished upload jobs. err %v", err)
+		return
+	}
+	for _, job := range jobs {
+		if job.Status == image.UploadPending {
+			if err := r.uploadRepo.SetJobStatus(job.ID, image.UploadFailed); err != nil {
+				logger.Errorf("[IMAGE-API] - cannot fail upload job %d. err %v", job.ID, err)
+			}
+			os.RemoveAll(r.jobDir(job.ID))
+			continue
+		}
+		logger.Infof("[IMAGE-API] - resuming upload job %d", job.ID)
+		r.enqueue(job.ID)
+	}
+}
+
+// enqueue hands the pending files of a job to the workers without blocking
+// the caller.
+func (r *repository) enqueue(jobID uint64) {
+	files, err := r.uploadRepo.GetFiles(jobID, image.UploadPending)
+	if err != nil {
+		logger.Errorf("[IMAGE-API] - cannot get files of upload job %d. err %v", jobID, err)
+		return
+	}
+	go func() {
+		for _, f := range files {
+			r.uploads <- f
+		}
+	}()
+}
+
+func (r *repository) process(f image.UploadFile) {
+	var (
+		status = image.UploadDone
+		msg    string
+	)
+	imageID, err := r.createUploadedImage(f)
+	switch {
+	case errors.Type(err) == errors.ImageDuplicateSkipped:
+		status, msg = image.UploadSkipped, errors.Message(err)
+	case err != nil:
+		logger.Errorf("[IMAGE-API] - cannot create image %s of upload job %d. err %v", f.Name, f.JobID, err)
+		status, msg = image.UploadFailed, errors.Message(err)
+	}
+	last, err := r.uploadRepo.FinishFile(f, status, imageID, msg)
+	if err != nil {
+		logger.Errorf("[IMAGE-API] - cannot record file %s of upload job %d. err %v", f.Name, f.JobID, err)
+		return
+	}
+	if !last {
+		return
+	}
+	os.RemoveAll(r.jobDir(f.JobID))
+	job, err := r.uploadRepo.GetJob(f.JobID)
+	if err != nil {
+		return
+	}
+	logger.Infof("[IMAGE-API] - upload job %d done, %d of %d files failed, %d skipped", job.ID, job.Failed, job.Total, job.Skipped)
+	imgs, err := r.repo.GetAllImageByDataset(job.DatasetID)
+	if err != nil {
+		logger.Errorf("[IMAGE-API] - cannot get images of dataset %d. err %v", job.DatasetID, err)
+		return
+	}
+	r.syncThumbnail(job.DatasetID, imgs)
+}
+
+func (r *repository) createUploadedImage(f image.UploadFile) (uint64, error) {
+	defer os.Remove(f.Path)
+	job, err := r.uploadRepo.GetJob(f.JobID)
+	if err != nil {
+		return 0, err
+	}
+	d, err := r.datasetRepo.Get(job.DatasetID)
+	if err != nil {
+		return 0, err
+	}
+	file, err := os.Open(f.Path)
+	if os.IsNotExist(err) {
+		return 0, errors.ImageErrorCreating.NewWithMessage("file lost before it was processed")
+	}
+	if err != nil {
+		return 0, errors.ImageErrorCreating.Wrap(err, "cannot open spooled file")
+	}
+	defer file.Close()
+	img, err := r.CreateImageFromReader(d, f.Title, file, f.TagList())
+	return img.ID, err
+}
+
+func (r *repository) jobDir(jobID uint64) string {
+	return filepath.Join(r.conf.Upload.Dir, strconv.FormatUint(jobID, 10))
+}
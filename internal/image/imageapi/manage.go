@@ -0,0 +1,104 @@
+package imageapi
+
+import (
+	"strings"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/nkhang/pluto/internal/dataset"
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+// UpdateImage changes the title and tags of an image of dataset dID.
+func (r *repository) UpdateImage(dID, imageID uint64, req UpdateImageRequest) (ImageResponse, error) {
+	img, err := r.imageOf(dID, imageID)
+	if err != nil {
+		return ImageResponse{}, err
+	}
+	var changes = make(map[string]interface{})
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			return ImageResponse{}, errors.BadRequest.NewWithMessage("title must not be empty")
+		}
+		changes["title"] = title
+	}
+	if req.Tags != nil {
+		changes["tags"] = image.JoinTags(*req.Tags)
+	}
+	if len(changes) == 0 {
+		return ToImageResponse(img), nil
+	}
+	img, err = r.repo.Update(imageID, changes)
+	if err != nil {
+		return ImageResponse{}, err
+	}
+	return ToImageResponse(img), nil
+}
+
+// DeleteImage deletes an image of dataset dID and takes it out of the tasks
+// still labeling or reviewing it in the same transaction. An image approved
+// in a task cannot be deleted. Its stored objects are released and the thumbnails of the
+// dataset and its project picked again in the background.
+func (r *repository) DeleteImage(dID, imageID uint64) error {
+	img, err := r.imageOf(dID, imageID)
+	if err != nil {
+		return err
+	}
+	var taskIDs []uint64
+	err = r.repo.Delete(imageID, func(tx *gorm.DB) error {
+		var err error
+		taskIDs, err = r.taskRepo.RemoveImage(tx, dID, imageID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	r.taskRepo.RefreshTasks(taskIDs)
+	logger.Infof("[IMAGE-API] - image %d of dataset %d deleted", imageID, dID)
+	go func() {
+		if err := r.releaser.Release([]image.Image{img}); err != nil {
+			logger.Errorf("[IMAGE-API] - cannot release objects of image %d. err %v", imageID, err)
+		}
+		r.pickThumbnails(dID)
+	}()
+	return nil
+}
+
+func (r *repository) imageOf(dID, imageID uint64) (image.Image, error) {
+	img, err := r.repo.Get(imageID)
+	if err != nil {
+		return image.Image{}, err
+	}
+	if img.DatasetID != dID {
+		return image.Image{}, errors.ImageNotFound.NewWithMessage("image not found")
+	}
+	return img, nil
+}
+
+// pickThumbnails sets the thumbnail of the dataset to that of its first
+// image, or to the default one when it has none left, and lets the project
+// pick its thumbnail again.
+func (r *repository) pickThumbnails(dID uint64) {
+	imgs, err := r.repo.GetAllImageByDataset(dID)
+	if err != nil {
+		logger.Errorf("[IMAGE-API] - cannot get images of dataset %d. err %v", dID, err)
+		return
+	}
+	thumbnail := dataset.DefaultThumbnail
+	if len(imgs) != 0 {
+		thumbnail = imgs[0].Thumbnail
+	}
+	d, err := r.datasetRepo.Update(dID, map[string]interface{}{
+		"thumbnail": thumbnail,
+	})
+	if err != nil {
+		logger.Errorf("[IMAGE-API] - cannot update dataset %d thumbnail. err %v", dID, err)
+		return
+	}
+	if err := r.projectRepo.PickThumbnail(d.ProjectID); err != nil {
+		logger.Errorf("[IMAGE-API] - cannot pick thumbnail of project %d. err %v", d.ProjectID, err)
+	}
+}
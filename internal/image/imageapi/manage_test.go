@@ -0,0 +1,137 @@
+package imageapi
+
+import (
+	"testing"
+	"time"
+
+	jgorm "github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/task"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/gorm"
+	"github.com/nkhang/pluto/pkg/util/clock"
+)
+
+func (r *fakeImageRepo) Get(id uint64) (image.Image, error) {
+	for _, img := range r.images {
+		if img.ID == id {
+			return img, nil
+		}
+	}
+	return image.Image{}, errors.ImageNotFound.NewWithMessage("image not found")
+}
+
+func (r *fakeImageRepo) Update(id uint64, changes map[string]interface{}) (image.Image, error) {
+	for i := range r.images {
+		if r.images[i].ID != id {
+			continue
+		}
+		if title, ok := changes["title"]; ok {
+			r.images[i].Title = title.(string)
+		}
+		if tags, ok := changes["tags"]; ok {
+			r.images[i].Tags = tags.(string)
+		}
+		return r.images[i], nil
+	}
+	return image.Image{}, errors.ImageNotFound.NewWithMessage("image not found")
+}
+
+// Delete runs within in place of the transaction deleting the image.
+func (r *fakeImageRepo) Delete(id uint64, within func(tx *jgorm.DB) error) error {
+	if within != nil {
+		if err := within(nil); err != nil {
+			return err
+		}
+	}
+	for i := range r.images {
+		if r.images[i].ID == id {
+			r.images = append(r.images[:i], r.images[i+1:]...)
+			return nil
+		}
+	}
+	return errors.ImageNotFound.NewWithMessage("image not found")
+}
+
+func (fakeProjectRepo) PickThumbnail(uint64) error {
+	return nil
+}
+
+// fakeTaskRepo puts every image in task 5.
+type fakeTaskRepo struct {
+	task.Repository
+	approved  map[uint64]bool
+	removed   []uint64
+	refreshed []uint64
+}
+
+func (r *fakeTaskRepo) RemoveImage(tx *jgorm.DB, dID, imageID uint64) ([]uint64, error) {
+	if r.approved[imageID] {
+		return nil, errors.TaskImageApproved.NewWithMessageF("image %d is already approved", imageID)
+	}
+	r.removed = append(r.removed, imageID)
+	return []uint64{5}, nil
+}
+
+func (r *fakeTaskRepo) RefreshTasks(taskIDs []uint64) {
+	r.refreshed = append(r.refreshed, taskIDs...)
+}
+
+type chanReleaser chan []image.Image
+
+func (c chanReleaser) Release(images []image.Image) error {
+	c <- images
+	return nil
+}
+
+func newManageTestRepository() (*repository, *fakeImageRepo, *fakeTaskRepo, chanReleaser) {
+	images := &fakeImageRepo{images: []image.Image{
+		{Model: gorm.Model{ID: 1}, DatasetID: 3, Title: "a.png", URL: "memory://plutos3/a.png"},
+		{Model: gorm.Model{ID: 2}, DatasetID: 3, Title: "b.png", URL: "memory://plutos3/b.png"},
+	}}
+	tasks := &fakeTaskRepo{approved: map[uint64]bool{2: true}}
+	releaser := make(chanReleaser, 1)
+	r := NewRepository(images, nil, nil, fakeDatasetRepo{}, fakeProjectRepo{}, tasks, releaser, clock.New())
+	return r, images, tasks, releaser
+}
+
+func TestUpdateImage(t *testing.T) {
+	r, _, _, _ := newManageTestRepository()
+	title, tags := " cat.png ", []string{"cat", "pet"}
+
+	resp, err := r.UpdateImage(3, 1, UpdateImageRequest{Title: &title, Tags: &tags})
+	require.NoError(t, err)
+	assert.Equal(t, "cat.png", resp.Title)
+	assert.Equal(t, tags, resp.Tags)
+
+	empty := ""
+	_, err = r.UpdateImage(3, 1, UpdateImageRequest{Title: &empty})
+	assert.Equal(t, errors.BadRequest, errors.Type(err))
+	_, err = r.UpdateImage(4, 1, UpdateImageRequest{Title: &title})
+	assert.Equal(t, errors.ImageNotFound, errors.Type(err), "the image belongs to another dataset")
+}
+
+func TestDeleteImage(t *testing.T) {
+	r, images, tasks, releaser := newManageTestRepository()
+
+	err := r.DeleteImage(3, 2)
+	assert.Equal(t, errors.TaskImageApproved, errors.Type(err))
+	assert.Len(t, images.images, 2, "an approved image is kept")
+	assert.Empty(t, tasks.refreshed)
+
+	require.NoError(t, r.DeleteImage(3, 1))
+	assert.Equal(t, []uint64{1}, tasks.removed)
+	assert.Equal(t, []uint64{5}, tasks.refreshed, "tasks are refreshed once the image is gone")
+	require.Len(t, images.images, 1)
+	assert.Equal(t, uint64(2), images.images[0].ID)
+	select {
+	case released := <-releaser:
+		require.Len(t, released, 1)
+		assert.Equal(t, "memory://plutos3/a.png", released[0].URL)
+	case <-time.After(time.Second):
+		t.Fatal("objects of the deleted image are not released")
+	}
+}
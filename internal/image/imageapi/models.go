@@ -2,6 +2,7 @@ package imageapi
 
 import (
 	"mime/multipart"
+	"time"
 
 	"github.com/nkhang/pluto/internal/image"
 	"github.com/nkhang/pluto/pkg/util/clock"
@@ -12,8 +13,124 @@ type ImageRequestQuery struct {
 	Limit  int `form:"limit"`
 }
 
+// UploadRequest holds images as well as zip and tar.gz archives of images.
+// With FolderTags, the folders of an image within its archive become its
+// tags.
 type UploadRequest struct {
 	FileHeader []*multipart.FileHeader `form:"file"`
+	FolderTags bool                    `form:"folder_tags"`
+}
+
+// FileError is a file of an upload job that cannot be created. The File of
+// an image within an archive is prefixed with the name of the archive.
+type FileError struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+type UploadJobResponse struct {
+	ID        uint64             `json:"id"`
+	DatasetID uint64             `json:"dataset_id"`
+	Status    image.UploadStatus `json:"status"`
+	Total     int                `json:"total"`
+	Processed int                `json:"processed"`
+	Failed    int                `json:"failed"`
+	Skipped   int                `json:"skipped"`
+	Errors    []FileError        `json:"errors"`
+	CreatedAt int64              `json:"created_at"`
+	UpdatedAt int64              `json:"updated_at"`
+}
+
+type PresignRequest struct {
+	Filenames []string `json:"filenames" binding:"required"`
+}
+
+type PresignedUpload struct {
+	Filename  string `json:"filename"`
+	Key       string `json:"key"`
+	URL       string `json:"url"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+type PresignResponse struct {
+	Uploads []PresignedUpload `json:"uploads"`
+}
+
+// FinalizeRequest holds the keys of the presigned uploads whose files were
+// put to storage, their images are created with Tags.
+type FinalizeRequest struct {
+	Keys []string `json:"keys" binding:"required"`
+	Tags []string `json:"tags"`
+}
+
+type FinalizeResult struct {
+	Key   string         `json:"key"`
+	Image *ImageResponse `json:"image,omitempty"`
+	Error string         `json:"error,omitempty"`
+}
+
+type FinalizeResponse struct {
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Skipped   int              `json:"skipped"`
+	Results   []FinalizeResult `json:"results"`
+}
+
+type DuplicatesRequest struct {
+	Threshold *int `form:"threshold"`
+}
+
+// DuplicateCluster holds images whose perceptual hashes are within the
+// threshold of each other, directly or through other images of the cluster.
+type DuplicateCluster struct {
+	Images []ImageResponse `json:"images"`
+}
+
+type DuplicatesResponse struct {
+	Threshold int                `json:"threshold"`
+	Clusters  []DuplicateCluster `json:"clusters"`
+}
+
+type TransferMode string
+
+const (
+	TransferMove TransferMode = "move"
+	TransferCopy TransferMode = "copy"
+)
+
+// StorageMode tells whether the images copied or moved to another project
+// share the stored files of the originals or get copies of them under the
+// directory of the target project.
+type StorageMode string
+
+const (
+	StorageShare StorageMode = "share"
+	StorageCopy  StorageMode = "copy"
+)
+
+type TransferRequest struct {
+	TargetDatasetID uint64       `json:"target_dataset_id" binding:"required"`
+	ImageIDs        []uint64     `json:"image_ids" binding:"required"`
+	Mode            TransferMode `json:"mode" binding:"required"`
+	Storage         StorageMode  `json:"storage"`
+}
+
+type TransferResult struct {
+	ImageID uint64         `json:"image_id"`
+	Image   *ImageResponse `json:"image,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+type TransferResponse struct {
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Skipped   int              `json:"skipped"`
+	Results   []TransferResult `json:"results"`
+}
+
+type UpdateImageRequest struct {
+	Title *string   `json:"title"`
+	Tags  *[]string `json:"tags"`
 }
 
 type GetImageRequest struct {
@@ -21,23 +138,41 @@ type GetImageRequest struct {
 }
 
 type ImageResponse struct {
-	ID        uint64 `json:"id"`
-	DatasetID uint64 `json:"dataset_id"`
-	CreatedAt int64  `json:"created_at"`
-	Title     string `json:"title"`
-	URL       string `json:"url"`
-	Thumbnail string `json:"thumbnail"`
-	Width     int    `json:"width"`
-	Height    int    `json:"height"`
-	Size      int64  `json:"size"`
+	ID        uint64   `json:"id"`
+	DatasetID uint64   `json:"dataset_id"`
+	CreatedAt int64    `json:"created_at"`
+	Title     string   `json:"title"`
+	URL       string   `json:"url"`
+	Thumbnail string   `json:"thumbnail"`
+	Width     int      `json:"width"`
+	Height    int      `json:"height"`
+	Size      int64    `json:"size"`
+	Tags      []string `json:"tags"`
 }
 
 type Config struct {
-	Scheme          string
-	Endpoint        string
 	BucketName      string
 	ThumbnailBucket string
-	BasePath        string
+	Archive         ArchiveLimits
+	Upload          UploadConfig
+	Presign         PresignConfig
+}
+
+// PresignConfig bounds the uploads made to presigned URLs. MaxSize is the
+// largest file accepted by finalize, as a presigned PUT cannot limit it.
+type PresignConfig struct {
+	Expiry   time.Duration
+	MaxFiles int
+	MaxSize  int64
+}
+
+// UploadConfig sets where the files of upload jobs are spooled and how many
+// of them are processed at once. Instance names this server, it resumes the
+// jobs it spooled after a restart.
+type UploadConfig struct {
+	Dir      string
+	Workers  int
+	Instance string
 }
 
 func ToImageResponse(i image.Image) ImageResponse {
@@ -51,5 +186,25 @@ func ToImageResponse(i image.Image) ImageResponse {
 		Width:     i.Width,
 		Height:    i.Height,
 		Size:      i.Size,
+		Tags:      i.TagList(),
+	}
+}
+
+func ToUploadJobResponse(job image.UploadJob, failed []image.UploadFile) UploadJobResponse {
+	errs := make([]FileError, len(failed))
+	for i, f := range failed {
+		errs[i] = FileError{File: f.Name, Error: f.Error}
+	}
+	return UploadJobResponse{
+		ID:        job.ID,
+		DatasetID: job.DatasetID,
+		Status:    job.Status,
+		Total:     job.Total,
+		Processed: job.Processed,
+		Failed:    job.Failed,
+		Skipped:   job.Skipped,
+		Errors:    errs,
+		CreatedAt: clock.UnixMillisecondFromTime(job.CreatedAt),
+		UpdatedAt: clock.UnixMillisecondFromTime(job.UpdatedAt),
 	}
 }
@@ -0,0 +1,152 @@
+package imageapi
+
+import (
+	"io/ioutil"
+	"path"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/nkhang/pluto/internal/dataset"
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/logger"
+	"github.com/nkhang/pluto/pkg/util/clock"
+)
+
+// PresignUploads returns a presigned PUT URL for each filename. Each file
+// goes under its own key so that files with the same name do not overwrite
+// each other, the keys are given back to FinalizeUploads.
+func (r *repository) PresignUploads(dID uint64, req PresignRequest) (PresignResponse, error) {
+	if r.conf.Presign.MaxFiles > 0 && len(req.Filenames) > r.conf.Presign.MaxFiles {
+		return PresignResponse{}, errors.BadRequest.NewWithMessageF("at most %d files can be presigned at once", r.conf.Presign.MaxFiles)
+	}
+	d, err := r.datasetRepo.Get(dID)
+	if err != nil {
+		return PresignResponse{}, err
+	}
+	prj, err := r.projectRepo.Get(d.ProjectID)
+	if err != nil {
+		return PresignResponse{}, err
+	}
+	var (
+		expiresAt = clock.UnixMillisecondFromTime(r.clock.Now().Add(r.conf.Presign.Expiry))
+		resp      = PresignResponse{Uploads: make([]PresignedUpload, len(req.Filenames))}
+	)
+	for i, filename := range req.Filenames {
+		name := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+		if name == "." || name == "/" {
+			return PresignResponse{}, errors.BadRequest.NewWithMessageF("invalid filename %q", filename)
+		}
+		key := uuid.NewV4().String() + "/" + name
+		u, err := r.storage.PresignPut(r.conf.BucketName, r.objectPath(prj, d, key), r.conf.Presign.Expiry)
+		if err != nil {
+			return PresignResponse{}, err
+		}
+		resp.Uploads[i] = PresignedUpload{
+			Filename:  filename,
+			Key:       key,
+			URL:       u,
+			ExpiresAt: expiresAt,
+		}
+	}
+	return resp, nil
+}
+
+// FinalizeUploads creates the images of the files uploaded to presigned
+// URLs. The outcome of each key is reported, a key whose file is missing or
+// cannot be decoded fails alone and its file is deleted. A key already
+// finalized gives back its image, so that a client can retry.
+func (r *repository) FinalizeUploads(dID uint64, req FinalizeRequest) (FinalizeResponse, error) {
+	d, err := r.datasetRepo.Get(dID)
+	if err != nil {
+		return FinalizeResponse{}, err
+	}
+	prj, err := r.projectRepo.Get(d.ProjectID)
+	if err != nil {
+		return FinalizeResponse{}, err
+	}
+	resp := FinalizeResponse{Results: make([]FinalizeResult, len(req.Keys))}
+	for i, key := range req.Keys {
+		resp.Results[i].Key = key
+		img, err := r.finalize(prj, d, key, req.Tags)
+		switch {
+		case errors.Type(err) == errors.ImageDuplicateSkipped:
+			resp.Skipped++
+			resp.Results[i].Error = errors.Message(err)
+		case err != nil:
+			logger.Errorf("[IMAGE-API] - cannot finalize upload %s of dataset %d. err %v", key, d.ID, err)
+			resp.Failed++
+			resp.Results[i].Error = errors.Message(err)
+		default:
+			resp.Succeeded++
+			created := ToImageResponse(img)
+			resp.Results[i].Image = &created
+		}
+	}
+	if resp.Succeeded == 0 {
+		return resp, nil
+	}
+	imgs, err := r.repo.GetAllImageByDataset(d.ID)
+	if err != nil {
+		return resp, err
+	}
+	r.syncThumbnail(d.ID, imgs)
+	return resp, nil
+}
+
+// finalize creates the image of the file uploaded under key. The file is
+// deleted when no image can be made of it, so that it does not stay in the
+// bucket unreferenced.
+func (r *repository) finalize(prj project.Project, d dataset.Dataset, key string, tags []string) (image.Image, error) {
+	if !validUploadKey(key) {
+		return image.Image{}, errors.ImageUploadKeyInvalid.NewWithMessage("key was not given by presign")
+	}
+	p := r.objectPath(prj, d, key)
+	existing, err := r.repo.GetByURL(d.ID, r.storage.URL(r.conf.BucketName, p))
+	if err != nil {
+		return image.Image{}, err
+	}
+	if len(existing) != 0 {
+		return existing[0], nil
+	}
+	img, err := r.finalizeStored(prj, d, key, p, tags)
+	if err != nil {
+		if err := r.storage.Delete(r.conf.BucketName, p); err != nil {
+			logger.Errorf("[IMAGE-API] - cannot delete upload %s of dataset %d. err %v", key, d.ID, err)
+		}
+	}
+	return img, err
+}
+
+func (r *repository) finalizeStored(prj project.Project, d dataset.Dataset, key, p string, tags []string) (image.Image, error) {
+	info, err := r.storage.Stat(r.conf.BucketName, p)
+	if err != nil {
+		return image.Image{}, err
+	}
+	if r.conf.Presign.MaxSize > 0 && info.Size > r.conf.Presign.MaxSize {
+		return image.Image{}, errors.ImageUploadKeyInvalid.NewWithMessageF("file is larger than %d bytes", r.conf.Presign.MaxSize)
+	}
+	rc, err := r.storage.Get(r.conf.BucketName, p)
+	if err != nil {
+		return image.Image{}, err
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return image.Image{}, errors.StorageCannotGet.Wrap(err, "cannot read uploaded file")
+	}
+	return r.createImage(prj, d, path.Base(key), key, b, tags, true)
+}
+
+// validUploadKey reports whether key has the form of the keys returned by
+// PresignUploads, a UUID followed by a file name.
+func validUploadKey(key string) bool {
+	parts := strings.Split(key, "/")
+	if len(parts) != 2 || parts[1] == "" || parts[1] == "." || parts[1] == ".." {
+		return false
+	}
+	_, err := uuid.FromString(parts[0])
+	return err == nil
+}
@@ -0,0 +1,206 @@
+package imageapi
+
+import (
+	"bytes"
+	"fmt"
+	gimage "image"
+	"image/png"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/dataset"
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/pkg/gorm"
+	"github.com/nkhang/pluto/pkg/objectstorage"
+	"github.com/nkhang/pluto/pkg/util/clock"
+)
+
+// countingStorage counts the images put to an in-memory storage.
+type countingStorage struct {
+	objectstorage.ObjectStorage
+	puts int
+}
+
+func (s *countingStorage) PutImage(collection, filename string, reader io.Reader, size int64) (int64, error) {
+	s.puts++
+	return s.ObjectStorage.PutImage(collection, filename, reader, size)
+}
+
+// fakeDatasetRepo puts every dataset into project 7 unless projects says
+// otherwise.
+type fakeDatasetRepo struct {
+	dataset.Repository
+	projects map[uint64]uint64
+}
+
+func (r fakeDatasetRepo) Get(id uint64) (dataset.Dataset, error) {
+	projectID, ok := r.projects[id]
+	if !ok {
+		projectID = 7
+	}
+	return dataset.Dataset{Model: gorm.Model{ID: id}, ProjectID: projectID}, nil
+}
+
+func (r fakeDatasetRepo) Update(id uint64, changes map[string]interface{}) (dataset.Dataset, error) {
+	return r.Get(id)
+}
+
+type fakeProjectRepo struct {
+	project.Repository
+}
+
+func (fakeProjectRepo) Get(id uint64) (project.Project, error) {
+	dir := "prj"
+	if id != 7 {
+		dir = fmt.Sprintf("prj%d", id)
+	}
+	return project.Project{Model: gorm.Model{ID: id}, Dir: dir}, nil
+}
+
+func (r fakeProjectRepo) UpdateProject(id uint64, changes map[string]interface{}) (project.Project, error) {
+	return r.Get(id)
+}
+
+type fakeImageRepo struct {
+	image.Repository
+	images []image.Image
+}
+
+func (r *fakeImageRepo) CreateImage(title, url, thumbnail string, w, h int, size int64, dID uint64, tags []string, contentHash string, perceptualHash uint64) (image.Image, error) {
+	img := image.Image{
+		Model:       gorm.Model{ID: uint64(len(r.images) + 1)},
+		Title:       title,
+		URL:         url,
+		Thumbnail:   thumbnail,
+		Width:       w,
+		Height:      h,
+		Size:        size,
+		DatasetID:   dID,
+		Tags:        image.JoinTags(tags),
+		ContentHash: contentHash,
+	}
+	r.images = append(r.images, img)
+	return img, nil
+}
+
+func (r *fakeImageRepo) GetByURL(dID uint64, url string) ([]image.Image, error) {
+	var images []image.Image
+	for _, img := range r.images {
+		if img.DatasetID == dID && img.URL == url {
+			images = append(images, img)
+		}
+	}
+	return images, nil
+}
+
+func (r *fakeImageRepo) GetByContentHash(pID uint64, hash string) ([]image.Image, error) {
+	var images []image.Image
+	for _, img := range r.images {
+		if img.ContentHash == hash {
+			images = append(images, img)
+		}
+	}
+	return images, nil
+}
+
+func (r *fakeImageRepo) GetAllImageByDataset(uint64) ([]image.Image, error) {
+	return r.images, nil
+}
+
+func newTestPNG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, gimage.NewGray(gimage.Rect(0, 0, 40, 30))))
+	return buf.Bytes()
+}
+
+func TestPresignAndFinalize(t *testing.T) {
+	storage := &countingStorage{ObjectStorage: objectstorage.NewMemory()}
+	images := &fakeImageRepo{}
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRepository(images, nil, storage, fakeDatasetRepo{}, fakeProjectRepo{}, nil, nil, clock.NewMock(now))
+	r.conf.BucketName, r.conf.ThumbnailBucket = "plutos3", "thumbnails"
+	r.conf.Presign.MaxSize = 1 << 20
+
+	presigned, err := r.PresignUploads(3, PresignRequest{Filenames: []string{"a.png", "dir/b.png", "a.png", "c.png"}})
+	require.NoError(t, err)
+	require.Len(t, presigned.Uploads, 4)
+	assert.NotEqual(t, presigned.Uploads[0].Key, presigned.Uploads[2].Key, "files with the same name get their own key")
+	assert.True(t, strings.HasSuffix(presigned.Uploads[1].Key, "/b.png"))
+	assert.Equal(t, clock.UnixMillisecondFromTime(now.Add(r.conf.Presign.Expiry)), presigned.Uploads[0].ExpiresAt)
+
+	// The client puts a and b, the third upload never happens and the
+	// fourth is not an image.
+	for _, u := range presigned.Uploads[:2] {
+		_, err := storage.Put("plutos3", "prj/3/"+u.Key, bytes.NewReader(newTestPNG(t)), 0, "image/png")
+		require.NoError(t, err)
+	}
+	_, err = storage.Put("plutos3", "prj/3/"+presigned.Uploads[3].Key, strings.NewReader("not an image"), 0, "image/png")
+	require.NoError(t, err)
+	storage.puts = 0
+	keys := []string{presigned.Uploads[0].Key, presigned.Uploads[1].Key, presigned.Uploads[2].Key, presigned.Uploads[3].Key, "../../other/c.png"}
+	resp, err := r.FinalizeUploads(3, FinalizeRequest{Keys: keys, Tags: []string{"street"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, resp.Succeeded)
+	assert.Equal(t, 3, resp.Failed)
+	require.NotNil(t, resp.Results[0].Image)
+	assert.Equal(t, "a.png", resp.Results[0].Image.Title)
+	assert.Equal(t, 40, resp.Results[0].Image.Width)
+	assert.Equal(t, []string{"street"}, resp.Results[0].Image.Tags)
+	assert.NotEmpty(t, resp.Results[2].Error, "missing file")
+	assert.NotEmpty(t, resp.Results[3].Error, "not an image")
+	assert.NotEmpty(t, resp.Results[4].Error, "key not given by presign")
+	_, err = storage.Stat("plutos3", "prj/3/"+keys[3])
+	assert.Error(t, err, "a file that cannot be finalized is deleted")
+	assert.Equal(t, 2, storage.puts, "only thumbnails are put, the originals are already stored")
+	_, err = storage.Stat("thumbnails", "prj/3/"+keys[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "memory://plutos3/"+url.PathEscape("prj/3/"+keys[0]), resp.Results[0].Image.URL)
+
+	resp, err = r.FinalizeUploads(3, FinalizeRequest{Keys: keys[:2]})
+	require.NoError(t, err)
+	assert.Equal(t, 2, resp.Succeeded, "finalizing again gives back the images")
+	assert.Len(t, images.images, 2, "no image is created twice")
+	assert.Equal(t, images.images[1].ID, resp.Results[1].Image.ID)
+	_, err = storage.Stat("plutos3", "prj/3/"+keys[0])
+	assert.NoError(t, err, "the file of a finalized image is kept")
+}
+
+// rejectingProjectRepo rejects the files already in the project.
+type rejectingProjectRepo struct {
+	fakeProjectRepo
+}
+
+func (r rejectingProjectRepo) Get(id uint64) (project.Project, error) {
+	p, err := r.fakeProjectRepo.Get(id)
+	p.DuplicatePolicy = project.DuplicateReject
+	return p, err
+}
+
+func TestFinalizeDeletesDuplicate(t *testing.T) {
+	storage := objectstorage.NewMemory()
+	images := &fakeImageRepo{}
+	r := NewRepository(images, nil, storage, fakeDatasetRepo{}, rejectingProjectRepo{}, nil, nil, clock.New())
+	r.conf.BucketName, r.conf.ThumbnailBucket = "plutos3", "thumbnails"
+
+	presigned, err := r.PresignUploads(3, PresignRequest{Filenames: []string{"a.png", "b.png"}})
+	require.NoError(t, err)
+	for _, u := range presigned.Uploads {
+		_, err := storage.Put("plutos3", "prj/3/"+u.Key, bytes.NewReader(newTestPNG(t)), 0, "image/png")
+		require.NoError(t, err)
+	}
+	keys := []string{presigned.Uploads[0].Key, presigned.Uploads[1].Key}
+	resp, err := r.FinalizeUploads(3, FinalizeRequest{Keys: keys})
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Succeeded)
+	assert.Equal(t, 1, resp.Failed, "b has the content of a")
+	_, err = storage.Stat("plutos3", "prj/3/"+keys[1])
+	assert.Error(t, err, "the file of a duplicate is deleted")
+}
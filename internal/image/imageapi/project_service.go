@@ -0,0 +1,42 @@
+package imageapi
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/nkhang/pluto/internal/project/projectapi"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/ginwrapper"
+)
+
+// projectService serves the images of a project across its datasets.
+type projectService struct {
+	repository Repository
+}
+
+func NewProjectService(r Repository) *projectService {
+	return &projectService{repository: r}
+}
+
+func (s *projectService) Register(router gin.IRouter) {
+	router.GET("/duplicates", ginwrapper.Wrap(s.getDuplicates))
+}
+
+func (s *projectService) getDuplicates(c *gin.Context) ginwrapper.Response {
+	projectID := uint64(c.GetInt64(projectapi.FieldProjectID))
+	threshold, err := bindThreshold(c)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	resp, err := s.repository.GetDuplicatesByProject(projectID, threshold)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
@@ -10,23 +10,22 @@ import (
 	_ "image/png"
 	"io"
 	"io/ioutil"
-	"mime/multipart"
-	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/nfnt/resize"
 
 	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/imagehash"
 
 	"golang.org/x/image/bmp"
 
 	"golang.org/x/image/webp"
 
-	"github.com/nkhang/pluto/internal/dataset/datasetapi"
-	"github.com/nkhang/pluto/pkg/util/clock"
-
 	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/task"
 
 	"github.com/spf13/viper"
 
@@ -34,36 +33,87 @@ import (
 	"github.com/nkhang/pluto/internal/image"
 	"github.com/nkhang/pluto/pkg/logger"
 	"github.com/nkhang/pluto/pkg/objectstorage"
+	"github.com/nkhang/pluto/pkg/util/clock"
 )
 
 type Repository interface {
 	GetImage(request GetImageRequest) (ImageResponse, error)
 	GetByDatasetID(dID uint64, offset, limit int) ([]ImageResponse, error)
-	UploadRequest(dID uint64, headers []*multipart.FileHeader) (datasetapi.DatasetResponse, []error)
+	StartUpload(dID uint64, req UploadRequest) (UploadJobResponse, error)
+	GetUploadJob(dID, jobID uint64) (UploadJobResponse, error)
+	PresignUploads(dID uint64, req PresignRequest) (PresignResponse, error)
+	FinalizeUploads(dID uint64, req FinalizeRequest) (FinalizeResponse, error)
+	GetDuplicatesByDataset(dID uint64, threshold int) (DuplicatesResponse, error)
+	GetDuplicatesByProject(pID uint64, threshold int) (DuplicatesResponse, error)
+	UpdateImage(dID, imageID uint64, req UpdateImageRequest) (ImageResponse, error)
+	DeleteImage(dID, imageID uint64) error
+	TransferImages(dID uint64, req TransferRequest, canManage func(prj project.Project) error) (TransferResponse, error)
+	CreateImageFromReader(d dataset.Dataset, filename string, reader io.Reader, tags []string) (image.Image, error)
+}
+
+// Releaser deletes the stored objects of deleted images that no other
+// image uses.
+type Releaser interface {
+	Release(images []image.Image) error
 }
 
 type repository struct {
 	repo        image.Repository
 	datasetRepo dataset.Repository
 	projectRepo project.Repository
+	taskRepo    task.Repository
+	uploadRepo  image.UploadRepository
 	storage     objectstorage.ObjectStorage
+	releaser    Releaser
 	conf        Config
+	clock       clock.Clock
+	uploads     chan image.UploadFile
 }
 
-func NewRepository(r image.Repository, s objectstorage.ObjectStorage, d dataset.Repository, p project.Repository) *repository {
+func NewRepository(r image.Repository, u image.UploadRepository, s objectstorage.ObjectStorage, d dataset.Repository,
+	p project.Repository, t task.Repository, releaser Releaser, c clock.Clock) *repository {
 	var conf = Config{
-		Scheme:          viper.GetString("minio.scheme"),
-		Endpoint:        viper.GetString("minio.endpoint"),
 		BucketName:      viper.GetString("minio.bucketname"),
 		ThumbnailBucket: viper.GetString("minio.thumbnailbucket"),
-		BasePath:        viper.GetString("minio.basepath"),
+		Archive: ArchiveLimits{
+			MaxSize:  viper.GetInt64("upload.archive.maxsize"),
+			MaxFiles: viper.GetInt("upload.archive.maxfiles"),
+			MaxRatio: viper.GetFloat64("upload.archive.maxratio"),
+		},
+		Presign: PresignConfig{
+			Expiry:   viper.GetDuration("upload.presign.expiry"),
+			MaxFiles: viper.GetInt("upload.presign.maxfiles"),
+			MaxSize:  viper.GetInt64("upload.presign.maxsize"),
+		},
+		Upload: UploadConfig{
+			Dir:      viper.GetString("upload.dir"),
+			Workers:  viper.GetInt("upload.workers"),
+			Instance: viper.GetString("upload.instance"),
+		},
+	}
+	if conf.Upload.Dir == "" {
+		conf.Upload.Dir = filepath.Join(os.TempDir(), "pluto-uploads")
+	}
+	if conf.Presign.Expiry <= 0 {
+		conf.Presign.Expiry = 15 * time.Minute
+	}
+	if conf.Upload.Workers <= 0 {
+		conf.Upload.Workers = 1
+	}
+	if conf.Upload.Instance == "" {
+		conf.Upload.Instance, _ = os.Hostname()
 	}
 	return &repository{
 		repo:        r,
+		uploadRepo:  u,
 		storage:     s,
 		datasetRepo: d,
 		projectRepo: p,
+		taskRepo:    t,
+		releaser:    releaser,
 		conf:        conf,
+		clock:       c,
+		uploads:     make(chan image.UploadFile),
 	}
 }
 
@@ -87,40 +137,6 @@ func (r *repository) GetByDatasetID(dID uint64, offset, limit int) ([]ImageRespo
 	return responses, nil
 }
 
-func (r *repository) UploadRequest(dID uint64, headers []*multipart.FileHeader) (resp datasetapi.DatasetResponse, errs []error) {
-	d, err := r.datasetRepo.Get(dID)
-	if err != nil {
-		return datasetapi.DatasetResponse{}, append(errs, err)
-	}
-	for _, header := range headers {
-		err := r.createImage(d, header)
-		if err != nil {
-			errs = append(errs, err)
-		}
-	}
-	resp = datasetapi.DatasetResponse{
-		ID:          d.ID,
-		Title:       d.Title,
-		Description: d.Description,
-		ProjectID:   d.ProjectID,
-		UpdatedAt:   clock.UnixMillisecondFromTime(d.UpdatedAt),
-	}
-	imgs, err := r.repo.GetAllImageByDataset(dID)
-	if err != nil {
-		errs = append(errs, err)
-		logger.Error("cannot get image to set to dataset")
-		return
-	}
-	resp.ImageCount = len(imgs)
-	d, err = r.syncThumbnail(d.ID, imgs)
-	if err != nil {
-		errs = append(errs, err)
-		return
-	}
-	resp.Thumbnail = d.Thumbnail
-	return
-}
-
 func (r *repository) syncThumbnail(datasetID uint64, images []image.Image) (d dataset.Dataset, err error) {
 	d, err = r.datasetRepo.Get(datasetID)
 	if err != nil {
@@ -155,52 +171,75 @@ func (r *repository) syncThumbnail(datasetID uint64, images []image.Image) (d da
 	return
 }
 
-func (r *repository) getImageURL(collection, title string) string {
-	return fmt.Sprintf("%s://%s/%s/%s", r.conf.Scheme, r.conf.BasePath, collection, url.PathEscape(title))
-}
-
-func (r *repository) createImage(d dataset.Dataset, h *multipart.FileHeader) error {
-	file, err := h.Open()
-	if err != nil {
-		return err
-	}
-	defer func() {
-		err := file.Close()
-		if err != nil {
-			logger.Error("error closing file", err)
-		}
-	}()
-
-	var buf bytes.Buffer
-	reader := io.TeeReader(file, &buf)
-
-	img, err := tryDecode(reader)
+// CreateImageFromReader stores the image read from reader under filename in
+// dataset d, along with its thumbnail, and creates its record with tags.
+// When the file is already in the project, the duplicate policy of the
+// project applies: reject fails with ImageDuplicate and skip returns the
+// image already stored with ImageDuplicateSkipped.
+func (r *repository) CreateImageFromReader(d dataset.Dataset, filename string, reader io.Reader, tags []string) (image.Image, error) {
+	b, err := ioutil.ReadAll(reader)
 	if err != nil {
-		logger.Errorf("[IMAGE-API] - error decode image %v. t", err)
-		return nil
+		return image.Image{}, errors.ImageErrorCreating.Wrap(err, "cannot read image")
 	}
 	prj, err := r.projectRepo.Get(d.ProjectID)
 	if err != nil {
-		return err
+		return image.Image{}, err
 	}
-	path := fmt.Sprintf("%s/%d/%s", prj.Dir, d.ID, h.Filename)
-	n, err := r.storage.PutImage(r.conf.BucketName, path, &buf, h.Size)
+	return r.createImage(prj, d, filename, filename, b, tags, false)
+}
+
+// createImage creates the image titled title from its bytes b. name is the
+// path of the file within the directory of dataset d, where the file is put
+// unless it is already stored there.
+func (r *repository) createImage(prj project.Project, d dataset.Dataset, title, name string, b []byte, tags []string, stored bool) (image.Image, error) {
+	img, err := tryDecode(bytes.NewReader(b))
 	if err != nil {
-		logger.Error("error putting to object storage", err)
-		return err
+		return image.Image{}, err
+	}
+	contentHash := imagehash.Content(b)
+	if existing, err := r.checkDuplicate(prj, contentHash); err != nil {
+		return existing, err
+	}
+	path := r.objectPath(prj, d, name)
+	size := int64(len(b))
+	if !stored {
+		n, err := r.storage.PutImage(r.conf.BucketName, path, bytes.NewReader(b), size)
+		if err != nil {
+			logger.Error("error putting to object storage", err)
+			return image.Image{}, err
+		}
+		logger.Infof("put image to object storage with %d bytes", n)
 	}
-	logger.Infof("put image to object storage with %d bytes", n)
 
 	width := img.Bounds().Max.X
 	height := img.Bounds().Max.Y
-	size := h.Size
-	u := r.getImageURL(r.conf.BucketName, path)
-	thumbnail, err := r.createThumbnail(img, prj, d, h.Filename)
+	u := r.storage.URL(r.conf.BucketName, path)
+	thumbnail, err := r.createThumbnail(img, prj, d, name)
 	if err != nil {
 		thumbnail = u
 	}
-	_, err = r.repo.CreateImage(h.Filename, u, thumbnail, width, height, size, d.ID)
-	return err
+	return r.repo.CreateImage(title, u, thumbnail, width, height, size, d.ID, tags, contentHash, imagehash.DHash(img))
+}
+
+func (r *repository) objectPath(prj project.Project, d dataset.Dataset, name string) string {
+	return fmt.Sprintf("%s/%d/%s", prj.Dir, d.ID, name)
+}
+
+func (r *repository) checkDuplicate(prj project.Project, contentHash string) (image.Image, error) {
+	if prj.DuplicatePolicy != project.DuplicateSkip && prj.DuplicatePolicy != project.DuplicateReject {
+		return image.Image{}, nil
+	}
+	existing, err := r.repo.GetByContentHash(prj.ID, contentHash)
+	if err != nil {
+		return image.Image{}, err
+	}
+	if len(existing) == 0 {
+		return image.Image{}, nil
+	}
+	if prj.DuplicatePolicy == project.DuplicateSkip {
+		return existing[0], errors.ImageDuplicateSkipped.NewWithMessageF("same file as image %d, skipped", existing[0].ID)
+	}
+	return image.Image{}, errors.ImageDuplicate.NewWithMessageF("same file as image %d of dataset %d", existing[0].ID, existing[0].DatasetID)
 }
 
 func tryDecode(r io.Reader) (gimage.Image, error) {
@@ -234,11 +273,11 @@ func (r *repository) createThumbnail(i gimage.Image, project project.Project, da
 	ext := filepath.Ext(filename)
 	filename = strings.TrimSuffix(filename, ext) + ".png"
 	logger.Infof("[THUMBNAIL-API] - thumbnail filename %s", filename)
-	path := fmt.Sprintf("%s/%d/%s", project.Dir, dataset.ID, filename)
+	path := r.objectPath(project, dataset, filename)
 	n, err := r.storage.PutImage(r.conf.ThumbnailBucket, path, buffer, int64(buffer.Len()))
 	if err != nil {
 		return
 	}
 	logger.Infof("[IMAGE-API] - put thumbnail %s to minio with %d bytes", n)
-	return r.getImageURL(r.conf.ThumbnailBucket, path), nil
+	return r.storage.URL(r.conf.ThumbnailBucket, path), nil
 }
@@ -2,26 +2,47 @@ package imageapi
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/internal/dataset/datasetapi"
+	"github.com/nkhang/pluto/internal/project"
 	"github.com/nkhang/pluto/pkg/errors"
 	"github.com/nkhang/pluto/pkg/ginwrapper"
+	"github.com/nkhang/pluto/pkg/imagehash"
+	"github.com/nkhang/pluto/pkg/pgin"
 	"github.com/spf13/cast"
 )
 
 type service struct {
 	repository Repository
+	authorizer authz.Authorizer
 }
 
-func NewService(r Repository) *service {
-	return &service{repository: r}
+func NewService(r Repository, authorizer authz.Authorizer) *service {
+	return &service{
+		repository: r,
+		authorizer: authorizer,
+	}
 }
 
-const fieldImageID = "imageId"
+const (
+	fieldImageID = "imageId"
+	fieldJobID   = "jobId"
+)
 
 func (s *service) Register(router gin.IRouter) {
-	router.GET("", ginwrapper.Wrap(s.getByDataset))
-	router.POST("", ginwrapper.Wrap(s.uploadByDataset))
-	router.GET("/:"+fieldImageID, ginwrapper.Wrap(s.get))
+	imageRouter := router.Group("/images")
+	{
+		imageRouter.GET("", ginwrapper.Wrap(s.getByDataset))
+		imageRouter.POST("", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.uploadByDataset))
+		imageRouter.POST("/presign", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.presign))
+		imageRouter.POST("/finalize", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.finalize))
+		imageRouter.POST("/transfer", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.transfer))
+		imageRouter.GET("/:"+fieldImageID, ginwrapper.Wrap(s.get))
+		imageRouter.PATCH("/:"+fieldImageID, s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.update))
+		imageRouter.DELETE("/:"+fieldImageID, s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.delete))
+	}
+	router.GET("/uploads/jobs/:"+fieldJobID, ginwrapper.Wrap(s.getUploadJob))
+	router.GET("/duplicates", ginwrapper.Wrap(s.getDuplicatesByDataset))
 }
 
 func (s *service) RegisterStandalone(router gin.IRouter) {
@@ -49,6 +70,50 @@ func (s *service) get(c *gin.Context) ginwrapper.Response {
 	}
 }
 
+func (s *service) update(c *gin.Context) ginwrapper.Response {
+	datasetID := uint64(c.GetInt64(datasetapi.FieldDatasetID))
+	imageID, err := cast.ToUint64E(c.Param(fieldImageID))
+	if err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.Wrap(err, "error binding params"),
+		}
+	}
+	var req UpdateImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.Wrap(err, "error binding request"),
+		}
+	}
+	response, err := s.repository.UpdateImage(datasetID, imageID, req)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  response,
+	}
+}
+
+func (s *service) delete(c *gin.Context) ginwrapper.Response {
+	datasetID := uint64(c.GetInt64(datasetapi.FieldDatasetID))
+	imageID, err := cast.ToUint64E(c.Param(fieldImageID))
+	if err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.Wrap(err, "error binding params"),
+		}
+	}
+	if err := s.repository.DeleteImage(datasetID, imageID); err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+	}
+}
+
 func (s *service) getByDataset(c *gin.Context) ginwrapper.Response {
 	datasetID := uint64(c.GetInt64(datasetapi.FieldDatasetID))
 	var q ImageRequestQuery
@@ -79,15 +144,145 @@ func (s *service) uploadByDataset(c *gin.Context) ginwrapper.Response {
 			Error: errors.BadRequest.NewWithMessage("error binding request"),
 		}
 	}
-	d, errs := s.repository.UploadRequest(datasetID, req.FileHeader)
-	if len(errs) != 0 {
+	job, err := s.repository.StartUpload(datasetID, req)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("upload started"),
+		Data:  job,
+	}
+}
+
+func (s *service) presign(c *gin.Context) ginwrapper.Response {
+	datasetID := uint64(c.GetInt64(datasetapi.FieldDatasetID))
+	var req PresignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.Wrap(err, "error binding request"),
+		}
+	}
+	resp, err := s.repository.PresignUploads(datasetID, req)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+func (s *service) finalize(c *gin.Context) ginwrapper.Response {
+	datasetID := uint64(c.GetInt64(datasetapi.FieldDatasetID))
+	var req FinalizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.Wrap(err, "error binding request"),
+		}
+	}
+	resp, err := s.repository.FinalizeUploads(datasetID, req)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+			Data:  resp,
+		}
+	}
+	if resp.Failed != 0 {
+		return ginwrapper.Response{
+			Error: errors.ImageErrorCreating.NewWithMessageF("%d of the uploaded files cannot be created", resp.Failed),
+			Data:  resp,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+func (s *service) transfer(c *gin.Context) ginwrapper.Response {
+	datasetID := uint64(c.GetInt64(datasetapi.FieldDatasetID))
+	userID := pgin.ExtractUserIDFromContext(c)
+	var req TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.Wrap(err, "error binding request"),
+		}
+	}
+	canManage := func(prj project.Project) error {
+		return s.authorizer.Authorize(userID, prj.WorkspaceID, prj.ID, authz.ProjectManager)
+	}
+	resp, err := s.repository.TransferImages(datasetID, req, canManage)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	if resp.Failed != 0 {
 		return ginwrapper.Response{
-			Error: errors.ImageErrorCreating.Wrap(err, "error reading file"),
-			Data:  errs,
+			Error: errors.ImageCannotTransfer.NewWithMessageF("%d of the images cannot be transferred", resp.Failed),
+			Data:  resp,
 		}
 	}
 	return ginwrapper.Response{
 		Error: errors.Success.NewWithMessage("success"),
-		Data:  d,
+		Data:  resp,
+	}
+}
+
+func (s *service) getUploadJob(c *gin.Context) ginwrapper.Response {
+	datasetID := uint64(c.GetInt64(datasetapi.FieldDatasetID))
+	jobID, err := cast.ToUint64E(c.Param(fieldJobID))
+	if err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.Wrap(err, "error binding params"),
+		}
+	}
+	job, err := s.repository.GetUploadJob(datasetID, jobID)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  job,
+	}
+}
+
+func (s *service) getDuplicatesByDataset(c *gin.Context) ginwrapper.Response {
+	datasetID := uint64(c.GetInt64(datasetapi.FieldDatasetID))
+	threshold, err := bindThreshold(c)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	resp, err := s.repository.GetDuplicatesByDataset(datasetID, threshold)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+func bindThreshold(c *gin.Context) (int, error) {
+	var req DuplicatesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		return 0, errors.BadRequest.Wrap(err, "fail to bind request")
+	}
+	if req.Threshold == nil {
+		return DefaultDuplicateThreshold, nil
+	}
+	if *req.Threshold < 0 || *req.Threshold > imagehash.MaxDistance {
+		return 0, errors.BadRequest.NewWithMessageF("threshold must be between 0 and %d", imagehash.MaxDistance)
 	}
+	return *req.Threshold, nil
 }
@@ -0,0 +1,179 @@
+package imageapi
+
+import (
+	"path"
+
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/nkhang/pluto/internal/dataset"
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+// transfer holds what is known of a transfer before its images are handled
+// one by one.
+type transfer struct {
+	source    dataset.Dataset
+	target    dataset.Dataset
+	targetPrj project.Project
+	mode      TransferMode
+	// copyFiles is set when the images get their own files in the target
+	// project, which only happens across projects.
+	copyFiles bool
+}
+
+// TransferImages moves or copies images of dataset dID to another dataset.
+// When the target dataset is in another project, canManage must accept the
+// caller for that project. The outcome of each image is reported, an image
+// that cannot be transferred fails alone.
+func (r *repository) TransferImages(dID uint64, req TransferRequest, canManage func(prj project.Project) error) (TransferResponse, error) {
+	if req.Mode != TransferMove && req.Mode != TransferCopy {
+		return TransferResponse{}, errors.BadRequest.NewWithMessageF("mode must be %s or %s", TransferMove, TransferCopy)
+	}
+	if req.Storage == "" {
+		req.Storage = StorageShare
+	}
+	if req.Storage != StorageShare && req.Storage != StorageCopy {
+		return TransferResponse{}, errors.BadRequest.NewWithMessageF("storage must be %s or %s", StorageShare, StorageCopy)
+	}
+	if req.TargetDatasetID == dID {
+		return TransferResponse{}, errors.BadRequest.NewWithMessage("target dataset must differ from the source")
+	}
+	source, err := r.datasetRepo.Get(dID)
+	if err != nil {
+		return TransferResponse{}, err
+	}
+	target, err := r.datasetRepo.Get(req.TargetDatasetID)
+	if err != nil {
+		return TransferResponse{}, err
+	}
+	targetPrj, err := r.projectRepo.Get(target.ProjectID)
+	if err != nil {
+		return TransferResponse{}, err
+	}
+	if target.ProjectID != source.ProjectID {
+		if err := canManage(targetPrj); err != nil {
+			return TransferResponse{}, err
+		}
+	}
+	t := transfer{
+		source:    source,
+		target:    target,
+		targetPrj: targetPrj,
+		mode:      req.Mode,
+		copyFiles: target.ProjectID != source.ProjectID && req.Storage == StorageCopy,
+	}
+	resp := TransferResponse{Results: make([]TransferResult, len(req.ImageIDs))}
+	for i, id := range req.ImageIDs {
+		resp.Results[i].ImageID = id
+		img, err := r.transferImage(t, id)
+		switch {
+		case errors.Type(err) == errors.ImageDuplicateSkipped:
+			resp.Skipped++
+			resp.Results[i].Error = errors.Message(err)
+		case err != nil:
+			logger.Errorf("[IMAGE-API] - cannot %s image %d to dataset %d. err %v", t.mode, id, target.ID, err)
+			resp.Failed++
+			resp.Results[i].Error = errors.Message(err)
+		default:
+			resp.Succeeded++
+			transferred := ToImageResponse(img)
+			resp.Results[i].Image = &transferred
+		}
+	}
+	if resp.Succeeded == 0 {
+		return resp, nil
+	}
+	if t.mode == TransferMove {
+		r.pickThumbnails(source.ID)
+	}
+	r.pickThumbnails(target.ID)
+	return resp, nil
+}
+
+func (r *repository) transferImage(t transfer, id uint64) (image.Image, error) {
+	img, err := r.imageOf(t.source.ID, id)
+	if err != nil {
+		return image.Image{}, err
+	}
+	// The duplicate policy of the target project applies to the images it
+	// gains, a move within the project adds nothing.
+	if t.mode == TransferCopy || t.target.ProjectID != t.source.ProjectID {
+		if existing, err := r.checkDuplicate(t.targetPrj, img.ContentHash); err != nil {
+			return existing, err
+		}
+	}
+	u, thumbnail := img.URL, img.Thumbnail
+	if t.copyFiles {
+		if u, thumbnail, err = r.copyFiles(t, img); err != nil {
+			return image.Image{}, err
+		}
+	}
+	if t.mode == TransferCopy {
+		return r.repo.CreateImage(img.Title, u, thumbnail, img.Width, img.Height, img.Size, t.target.ID,
+			img.TagList(), img.ContentHash, img.PerceptualHash)
+	}
+	var taskIDs []uint64
+	moved, err := r.repo.Move(id, t.target.ID, u, thumbnail, func(tx *gorm.DB) error {
+		var err error
+		taskIDs, err = r.taskRepo.RemoveImage(tx, t.source.ID, id)
+		return err
+	})
+	if err != nil {
+		if t.copyFiles {
+			r.releaser.Release([]image.Image{{URL: u, Thumbnail: thumbnail}})
+		}
+		return image.Image{}, err
+	}
+	r.taskRepo.RefreshTasks(taskIDs)
+	if t.copyFiles {
+		go func() {
+			if err := r.releaser.Release([]image.Image{img}); err != nil {
+				logger.Errorf("[IMAGE-API] - cannot release objects of moved image %d. err %v", id, err)
+			}
+		}()
+	}
+	return moved, nil
+}
+
+// copyFiles copies the file and thumbnail of img under the directory of the
+// target dataset and returns their URLs. A file that is not in our storage
+// stays shared.
+func (r *repository) copyFiles(t transfer, img image.Image) (string, string, error) {
+	name := uuid.NewV4().String() + "/" + path.Base(img.Title)
+	u, err := r.copyObject(img.URL, r.conf.BucketName, r.objectPath(t.targetPrj, t.target, name))
+	if err != nil {
+		return "", "", err
+	}
+	if img.Thumbnail == img.URL {
+		return u, u, nil
+	}
+	thumbnail, err := r.copyObject(img.Thumbnail, r.conf.ThumbnailBucket, r.objectPath(t.targetPrj, t.target, name))
+	if err != nil {
+		return "", "", err
+	}
+	return u, thumbnail, nil
+}
+
+func (r *repository) copyObject(u, collection, key string) (string, error) {
+	srcCollection, srcKey, ok := r.storage.Locate(u)
+	if !ok {
+		return u, nil
+	}
+	info, err := r.storage.Stat(srcCollection, srcKey)
+	if err != nil {
+		return "", err
+	}
+	rc, err := r.storage.Get(srcCollection, srcKey)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	if _, err := r.storage.Put(collection, key, rc, info.Size, info.ContentType); err != nil {
+		return "", err
+	}
+	return r.storage.URL(collection, key), nil
+}
@@ -0,0 +1,117 @@
+package imageapi
+
+import (
+	"strings"
+	"testing"
+
+	jgorm "github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/gorm"
+	"github.com/nkhang/pluto/pkg/objectstorage"
+	"github.com/nkhang/pluto/pkg/util/clock"
+)
+
+// Move runs within in place of the transaction moving the image.
+func (r *fakeImageRepo) Move(id, dID uint64, url, thumbnail string, within func(tx *jgorm.DB) error) (image.Image, error) {
+	if within != nil {
+		if err := within(nil); err != nil {
+			return image.Image{}, err
+		}
+	}
+	for i := range r.images {
+		if r.images[i].ID == id {
+			r.images[i].DatasetID, r.images[i].URL, r.images[i].Thumbnail = dID, url, thumbnail
+			return r.images[i], nil
+		}
+	}
+	return image.Image{}, errors.ImageNotFound.NewWithMessage("image not found")
+}
+
+func newTransferTestRepository(t *testing.T) (*repository, *fakeImageRepo, objectstorage.ObjectStorage, chanReleaser) {
+	storage := objectstorage.NewMemory()
+	for _, o := range []struct{ collection, key string }{
+		{"plutos3", "prj/3/a.png"},
+		{"thumbnails", "prj/3/a.png"},
+	} {
+		_, err := storage.Put(o.collection, o.key, strings.NewReader(o.key), int64(len(o.key)), "image/png")
+		require.NoError(t, err)
+	}
+	images := &fakeImageRepo{images: []image.Image{{
+		Model:     gorm.Model{ID: 1},
+		DatasetID: 3,
+		Title:     "a.png",
+		URL:       storage.URL("plutos3", "prj/3/a.png"),
+		Thumbnail: storage.URL("thumbnails", "prj/3/a.png"),
+	}}}
+	releaser := make(chanReleaser, 1)
+	datasets := fakeDatasetRepo{projects: map[uint64]uint64{9: 8}}
+	r := NewRepository(images, nil, storage, datasets, fakeProjectRepo{}, &fakeTaskRepo{}, releaser, clock.New())
+	r.conf.BucketName, r.conf.ThumbnailBucket = "plutos3", "thumbnails"
+	return r, images, storage, releaser
+}
+
+func allow(project.Project) error { return nil }
+
+func TestTransferImages_CopySharesFiles(t *testing.T) {
+	r, images, _, _ := newTransferTestRepository(t)
+
+	resp, err := r.TransferImages(3, TransferRequest{TargetDatasetID: 4, ImageIDs: []uint64{1, 5}, Mode: TransferCopy}, allow)
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Succeeded)
+	assert.Equal(t, 1, resp.Failed, "image 5 is not in the dataset")
+	require.Len(t, images.images, 2)
+	assert.Equal(t, uint64(4), images.images[1].DatasetID)
+	assert.Equal(t, images.images[0].URL, images.images[1].URL)
+}
+
+func TestTransferImages_MoveCopiesFilesAcrossProjects(t *testing.T) {
+	r, images, storage, releaser := newTransferTestRepository(t)
+
+	resp, err := r.TransferImages(3, TransferRequest{TargetDatasetID: 9, ImageIDs: []uint64{1}, Mode: TransferMove, Storage: StorageCopy}, allow)
+	require.NoError(t, err)
+	require.Equal(t, 1, resp.Succeeded)
+	require.Len(t, images.images, 1)
+	moved := images.images[0]
+	assert.Equal(t, uint64(9), moved.DatasetID)
+	collection, key, ok := storage.Locate(moved.URL)
+	require.True(t, ok)
+	assert.Equal(t, "plutos3", collection)
+	assert.True(t, strings.HasPrefix(key, "prj8/9/"), "the file is copied under the target project")
+	_, err = storage.Stat(collection, key)
+	assert.NoError(t, err)
+	released := <-releaser
+	assert.Equal(t, "memory://plutos3/prj%2F3%2Fa.png", released[0].URL, "the files of the source are released")
+}
+
+func TestTransferImages_MoveApprovedImage(t *testing.T) {
+	r, images, storage, releaser := newTransferTestRepository(t)
+	tasks := &fakeTaskRepo{approved: map[uint64]bool{1: true}}
+	r.taskRepo = tasks
+
+	resp, err := r.TransferImages(3, TransferRequest{TargetDatasetID: 9, ImageIDs: []uint64{1}, Mode: TransferMove, Storage: StorageCopy}, allow)
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Failed)
+	assert.Equal(t, uint64(3), images.images[0].DatasetID, "an approved image stays")
+	assert.Empty(t, tasks.refreshed)
+	released := <-releaser
+	_, key, ok := storage.Locate(released[0].URL)
+	require.True(t, ok)
+	assert.True(t, strings.HasPrefix(key, "prj8/9/"), "the copied files are released")
+}
+
+func TestTransferImages_TargetProjectForbidden(t *testing.T) {
+	r, images, _, _ := newTransferTestRepository(t)
+	forbid := func(project.Project) error { return errors.Forbidden.NewWithMessage("forbidden") }
+
+	_, err := r.TransferImages(3, TransferRequest{TargetDatasetID: 9, ImageIDs: []uint64{1}, Mode: TransferMove}, forbid)
+	assert.Equal(t, errors.Forbidden, errors.Type(err))
+	assert.Equal(t, uint64(3), images.images[0].DatasetID)
+
+	_, err = r.TransferImages(3, TransferRequest{TargetDatasetID: 4, ImageIDs: []uint64{1}, Mode: TransferMove}, forbid)
+	assert.NoError(t, err, "the caller already manages the project of the source")
+}
@@ -0,0 +1,126 @@
+package imageapi
+
+import (
+	"io"
+	"mime/multipart"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/labelformat"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+// spool writes the files of an upload request to dir, unpacking archives,
+// and keeps a record of each of them for the upload job. Files that cannot
+// be spooled are recorded as failed.
+type spool struct {
+	dir        string
+	limits     ArchiveLimits
+	folderTags bool
+	titles     map[string]bool
+	files      []image.UploadFile
+}
+
+func newSpool(dir string, limits ArchiveLimits, folderTags bool) *spool {
+	return &spool{
+		dir:        dir,
+		limits:     limits,
+		folderTags: folderTags,
+		titles:     make(map[string]bool),
+		files:      make([]image.UploadFile, 0),
+	}
+}
+
+func (s *spool) file(h *multipart.FileHeader) {
+	f, err := h.Open()
+	if err != nil {
+		s.fail(h.Filename, errors.ImageErrorCreating.Wrap(err, "cannot open uploaded file"))
+		return
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			logger.Error("error closing file", err)
+		}
+	}()
+	switch archiveKindOf(h.Filename) {
+	case zipArchive:
+		err = unpackZip(f, h.Size, s.limits, s.entry(h.Filename))
+	case tarGzArchive:
+		err = unpackTarGz(f, s.limits, s.entry(h.Filename))
+	default:
+		s.titles[h.Filename] = true
+		s.save(h.Filename, h.Filename, f, nil)
+		return
+	}
+	if err != nil {
+		logger.Errorf("[IMAGE-API] - cannot unpack archive %s. err %v", h.Filename, err)
+		s.fail(h.Filename, err)
+	}
+}
+
+// entry spools the images of an archive. An image is stored under its base
+// name, or under its path within the archive when another image of the
+// upload already took that name.
+func (s *spool) entry(archive string) entryFunc {
+	return func(name string, r io.Reader) {
+		file := archive + "/" + name
+		if !labelformat.IsImage(name) {
+			s.fail(file, errors.ImageCannotDecode.NewWithMessage("not an image"))
+			return
+		}
+		title := path.Base(name)
+		if s.titles[title] {
+			title = strings.ReplaceAll(name, "/", "_")
+		}
+		s.titles[title] = true
+		var tags []string
+		if s.folderTags {
+			tags = folderTags(name)
+		}
+		s.save(file, title, r, tags)
+	}
+}
+
+func (s *spool) save(file, title string, r io.Reader, tags []string) {
+	p := filepath.Join(s.dir, strconv.Itoa(len(s.files)))
+	if err := writeFile(p, r); err != nil {
+		logger.Errorf("[IMAGE-API] - cannot spool file %s. err %v", file, err)
+		os.Remove(p)
+		s.fail(file, err)
+		return
+	}
+	s.files = append(s.files, image.UploadFile{
+		Name:  file,
+		Title: title,
+		Path:  p,
+		Tags:  image.JoinTags(tags),
+	})
+}
+
+func (s *spool) fail(file string, err error) {
+	s.files = append(s.files, image.UploadFile{
+		Name:   file,
+		Status: image.UploadFailed,
+		Error:  errors.Message(err),
+	})
+}
+
+func writeFile(p string, r io.Reader) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return errors.ImageErrorCreating.Wrap(err, "cannot create spooled file")
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return errors.ImageErrorCreating.Wrap(err, "cannot write spooled file")
+	}
+	if err := f.Close(); err != nil {
+		return errors.ImageErrorCreating.Wrap(err, "cannot write spooled file")
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package imageapi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Initlialize(false)
+	os.Exit(m.Run())
+}
+
+func newFileHeaders(t *testing.T, files ...archiveFile) []*multipart.FileHeader {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, f := range files {
+		fw, err := w.CreateFormFile("file", f.name)
+		require.NoError(t, err)
+		_, err = fw.Write(f.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	form, err := multipart.NewReader(&buf, w.Boundary()).ReadForm(1 << 20)
+	require.NoError(t, err)
+	return form.File["file"]
+}
+
+func TestSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	s := newSpool(dir, ArchiveLimits{}, true)
+	archive := newZipArchive(t,
+		archiveFile{name: "cats/a.jpg", content: []byte("cat")},
+		archiveFile{name: "dogs/a.jpg", content: []byte("dog")},
+		archiveFile{name: "notes.txt", content: []byte("x")},
+	)
+	for _, h := range newFileHeaders(t,
+		archiveFile{name: "a.jpg", content: []byte("single")},
+		archiveFile{name: "images.zip", content: archive},
+		archiveFile{name: "broken.zip", content: []byte("not a zip")},
+	) {
+		s.file(h)
+	}
+
+	require.Len(t, s.files, 5)
+	var spooled []string
+	for _, f := range s.files[:3] {
+		assert.Empty(t, f.Status)
+		b, err := ioutil.ReadFile(f.Path)
+		require.NoError(t, err)
+		spooled = append(spooled, string(b))
+	}
+	assert.Equal(t, []string{"single", "cat", "dog"}, spooled)
+	assert.Equal(t, []string{"a.jpg", "cats_a.jpg", "dogs_a.jpg"},
+		[]string{s.files[0].Title, s.files[1].Title, s.files[2].Title}, "titles are unique within an upload")
+	assert.Equal(t, "images.zip/cats/a.jpg", s.files[1].Name)
+	assert.Equal(t, []string{"cats"}, s.files[1].TagList())
+
+	assert.Equal(t, image.UploadFailed, s.files[3].Status)
+	assert.Equal(t, "images.zip/notes.txt", s.files[3].Name)
+	assert.Equal(t, image.UploadFailed, s.files[4].Status)
+	assert.Equal(t, "broken.zip", s.files[4].Name)
+	assert.NotEmpty(t, s.files[4].Error)
+}
@@ -1,6 +1,12 @@
 package image
 
-import "github.com/nkhang/pluto/pkg/gorm"
+import (
+	"strings"
+
+	"github.com/nkhang/pluto/pkg/gorm"
+)
+
+const tagSeparator = ","
 
 type Image struct {
 	gorm.Model
@@ -12,4 +18,35 @@ type Image struct {
 	Height    int
 	Size      int64
 	DatasetID uint64
+	// Tags are joined with tagSeparator.
+	Tags string `gorm:"type:varchar(1024)"`
+	// ContentHash is the SHA-256 of the image file and PerceptualHash its
+	// dHash. Images created before they were computed have no ContentHash.
+	ContentHash    string `gorm:"type:char(64);index"`
+	PerceptualHash uint64
+}
+
+func (i Image) TagList() []string {
+	return SplitTags(i.Tags)
+}
+
+// SplitTags splits tags joined by JoinTags.
+func SplitTags(tags string) []string {
+	if tags == "" {
+		return []string{}
+	}
+	return strings.Split(tags, tagSeparator)
+}
+
+// JoinTags joins tags to be stored in Image.Tags, the separator is dropped
+// from each tag.
+func JoinTags(tags []string) string {
+	var cleaned = make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.TrimSpace(strings.ReplaceAll(t, tagSeparator, " "))
+		if t != "" {
+			cleaned = append(cleaned, t)
+		}
+	}
+	return strings.Join(cleaned, tagSeparator)
 }
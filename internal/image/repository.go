@@ -1,6 +1,8 @@
 package image
 
 import (
+	"github.com/jinzhu/gorm"
+
 	"github.com/nkhang/pluto/internal/rediskey"
 	"github.com/nkhang/pluto/pkg/cache"
 	"github.com/nkhang/pluto/pkg/errors"
@@ -11,7 +13,16 @@ type Repository interface {
 	Get(id uint64) (Image, error)
 	GetByDataset(dID uint64, offset, limit int) (imgs []Image, err error)
 	GetAllImageByDataset(dID uint64) ([]Image, error)
-	CreateImage(title, url, thumbnail string, w, h int, size int64, dataset_id uint64) (Image, error)
+	GetByContentHash(pID uint64, hash string) ([]Image, error)
+	GetByURL(dID uint64, url string) ([]Image, error)
+	GetHashedByProject(pID uint64) ([]Image, error)
+	Update(id uint64, changes map[string]interface{}) (Image, error)
+	Delete(id uint64, within func(tx *gorm.DB) error) error
+	Move(id, dID uint64, url, thumbnail string, within func(tx *gorm.DB) error) (Image, error)
+	DeleteByDatasets(dIDs []uint64) ([]Image, error)
+	CountReferences(urls []string) (int, error)
+	GetAllURLs() ([]string, error)
+	CreateImage(title, url, thumbnail string, w, h int, size int64, dataset_id uint64, tags []string, contentHash string, perceptualHash uint64) (Image, error)
 	Incr(id uint64) error
 	BulkInsert(images []Image, dID uint64) error
 }
@@ -78,9 +89,78 @@ func (r *repository) GetByDataset(dID uint64, offset, limit int) (images []Image
 	return
 }
 
-func (r *repository) CreateImage(title, url, thumbnail string, w, h int, size int64, datasetId uint64) (Image, error) {
+func (r *repository) CreateImage(title, url, thumbnail string, w, h int, size int64, datasetId uint64, tags []string, contentHash string, perceptualHash uint64) (Image, error) {
 	r.InvalidateDatasetImage(datasetId)
-	return r.dbRepo.CreateImage(title, url, thumbnail, w, h, size, datasetId)
+	return r.dbRepo.CreateImage(title, url, thumbnail, w, h, size, datasetId, tags, contentHash, perceptualHash)
+}
+
+func (r *repository) GetByContentHash(pID uint64, hash string) ([]Image, error) {
+	return r.dbRepo.GetByContentHash(pID, hash)
+}
+
+func (r *repository) GetByURL(dID uint64, url string) ([]Image, error) {
+	return r.dbRepo.GetByURL(dID, url)
+}
+
+func (r *repository) GetHashedByProject(pID uint64) ([]Image, error) {
+	return r.dbRepo.GetHashedByProject(pID)
+}
+
+func (r *repository) Update(id uint64, changes map[string]interface{}) (Image, error) {
+	img, err := r.dbRepo.Update(id, changes)
+	if err != nil {
+		return Image{}, err
+	}
+	r.invalidate(img)
+	return img, nil
+}
+
+func (r *repository) Delete(id uint64, within func(tx *gorm.DB) error) error {
+	img, err := r.dbRepo.Get(id)
+	if err != nil {
+		return err
+	}
+	if err := r.dbRepo.Delete(id, within); err != nil {
+		return err
+	}
+	r.invalidate(img)
+	return nil
+}
+
+func (r *repository) Move(id, dID uint64, url, thumbnail string, within func(tx *gorm.DB) error) (Image, error) {
+	old, err := r.dbRepo.Get(id)
+	if err != nil {
+		return Image{}, err
+	}
+	img, err := r.dbRepo.Move(id, dID, url, thumbnail, within)
+	if err != nil {
+		return Image{}, err
+	}
+	r.InvalidateDatasetImage(old.DatasetID)
+	r.invalidate(img)
+	return img, nil
+}
+
+func (r *repository) invalidate(img Image) {
+	if err := r.cacheRepo.Del(rediskey.ImageByID(img.ID)); err != nil {
+		logger.Errorf("[IMAGE] - error deleting image %d from cache. err %v", img.ID, err)
+	}
+	r.InvalidateDatasetImage(img.DatasetID)
+}
+
+func (r *repository) DeleteByDatasets(dIDs []uint64) ([]Image, error) {
+	for _, id := range dIDs {
+		r.InvalidateDatasetImage(id)
+	}
+	return r.dbRepo.DeleteByDatasets(dIDs)
+}
+
+func (r *repository) CountReferences(urls []string) (int, error) {
+	return r.dbRepo.CountReferences(urls)
+}
+
+func (r *repository) GetAllURLs() ([]string, error) {
+	return r.dbRepo.GetAllURLs()
 }
 
 func (r *repository) InvalidateDatasetImage(dID uint64) {
@@ -0,0 +1,49 @@
+package image
+
+import "github.com/nkhang/pluto/pkg/gorm"
+
+type UploadStatus string
+
+const (
+	UploadPending UploadStatus = "pending"
+	UploadRunning UploadStatus = "running"
+	UploadDone    UploadStatus = "done"
+	UploadFailed  UploadStatus = "failed"
+	// UploadSkipped is a file already in the project of a job, under the
+	// skip duplicate policy.
+	UploadSkipped UploadStatus = "skipped"
+)
+
+// UploadJob is an upload whose files are turned into images in the
+// background. Processed counts the files that are done, failed or skipped. The
+// files of a job are spooled to the local disk of Instance, which is the
+// only one to resume it after a restart.
+type UploadJob struct {
+	gorm.Model
+	DatasetID uint64 `gorm:"index"`
+	Instance  string
+	Status    UploadStatus
+	Total     int
+	Processed int
+	Failed    int
+	Skipped   int
+}
+
+// UploadFile is a file of an upload job, an uploaded image or an image of
+// an uploaded archive. Name is what the user knows the file by, Title the
+// name of the image to create and Path where the file is spooled.
+type UploadFile struct {
+	gorm.Model
+	JobID   uint64 `gorm:"index"`
+	Name    string `gorm:"type:varchar(1024)"`
+	Title   string
+	Path    string `gorm:"type:varchar(1024)"`
+	Tags    string `gorm:"type:varchar(1024)"`
+	Status  UploadStatus
+	Error   string `gorm:"type:varchar(1024)"`
+	ImageID uint64
+}
+
+func (f UploadFile) TagList() []string {
+	return SplitTags(f.Tags)
+}
@@ -0,0 +1,182 @@
+package image
+
+import (
+	"github.com/jinzhu/gorm"
+	gormbulk "github.com/t-tiger/gorm-bulk-insert/v2"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+type UploadRepository interface {
+	CreateJob(datasetID uint64, instance string) (UploadJob, error)
+	AddFiles(jobID uint64, files []UploadFile) (UploadJob, error)
+	GetJob(id uint64) (UploadJob, error)
+	GetFiles(jobID uint64, status UploadStatus) ([]UploadFile, error)
+	FinishFile(f UploadFile, status UploadStatus, imageID uint64, errMsg string) (bool, error)
+	GetUnfinishedJobs(instance string) ([]UploadJob, error)
+	SetJobStatus(id uint64, status UploadStatus) error
+}
+
+type uploadRepository struct {
+	db *gorm.DB
+}
+
+func NewUploadRepository(db *gorm.DB) *uploadRepository {
+	return &uploadRepository{db: db}
+}
+
+func (r *uploadRepository) CreateJob(datasetID uint64, instance string) (UploadJob, error) {
+	job := UploadJob{
+		DatasetID: datasetID,
+		Instance:  instance,
+		Status:    UploadPending,
+	}
+	if err := r.db.Create(&job).Error; err != nil {
+		return UploadJob{}, errors.ImageUploadJobCannotCreate.Wrap(err, "cannot create upload job")
+	}
+	return job, nil
+}
+
+// AddFiles adds the files of a job and starts it. Files that already failed,
+// such as those of an archive that cannot be unpacked, count as processed.
+func (r *uploadRepository) AddFiles(jobID uint64, files []UploadFile) (UploadJob, error) {
+	var (
+		records = make([]interface{}, len(files))
+		failed  int
+	)
+	for i, f := range files {
+		f.JobID = jobID
+		if f.Status == "" {
+			f.Status = UploadPending
+		}
+		if f.Status == UploadFailed {
+			failed++
+		}
+		records[i] = f
+	}
+	status := UploadRunning
+	if failed == len(files) {
+		status = UploadDone
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if len(records) != 0 {
+			if err := gormbulk.BulkInsert(tx, records, 1000); err != nil {
+				return errors.ImageUploadJobCannotCreate.Wrap(err, "cannot create upload files")
+			}
+		}
+		err := tx.Model(&UploadJob{}).
+			Where("id = ?", jobID).
+			Updates(map[string]interface{}{
+				"total":     len(files),
+				"processed": failed,
+				"failed":    failed,
+				"status":    status,
+			}).Error
+		if err != nil {
+			return errors.ImageUploadJobCannotUpdate.Wrap(err, "cannot start upload job")
+		}
+		return nil
+	})
+	if err != nil {
+		return UploadJob{}, err
+	}
+	return r.GetJob(jobID)
+}
+
+func (r *uploadRepository) GetJob(id uint64) (job UploadJob, err error) {
+	result := r.db.First(&job, id)
+	if result.RecordNotFound() {
+		err = errors.ImageUploadJobNotFound.NewWithMessageF("upload job %d not found", id)
+		return
+	}
+	if err = result.Error; err != nil {
+		err = errors.ImageQueryError.Wrap(err, "upload job query error")
+	}
+	return
+}
+
+func (r *uploadRepository) GetFiles(jobID uint64, status UploadStatus) ([]UploadFile, error) {
+	var files = make([]UploadFile, 0)
+	err := r.db.Where("job_id = ? AND status = ?", jobID, status).
+		Order("id").
+		Find(&files).Error
+	if err != nil {
+		return nil, errors.ImageQueryError.Wrap(err, "upload files query error")
+	}
+	return files, nil
+}
+
+// FinishFile records the outcome of a pending file and reports whether it
+// was the last file of its job. A file that is no longer pending is left as
+// is.
+func (r *uploadRepository) FinishFile(f UploadFile, status UploadStatus, imageID uint64, errMsg string) (bool, error) {
+	var (
+		failed  int
+		skipped int
+		last    bool
+	)
+	switch status {
+	case UploadFailed:
+		failed = 1
+	case UploadSkipped:
+		skipped = 1
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		db := tx.Model(&UploadFile{}).
+			Where("id = ? AND status = ?", f.ID, UploadPending).
+			Updates(map[string]interface{}{
+				"status":   status,
+				"error":    errMsg,
+				"image_id": imageID,
+			})
+		if err := db.Error; err != nil {
+			return errors.ImageUploadJobCannotUpdate.Wrap(err, "cannot update upload file")
+		}
+		if db.RowsAffected == 0 {
+			return nil
+		}
+		err := tx.Model(&UploadJob{}).
+			Where("id = ?", f.JobID).
+			Updates(map[string]interface{}{
+				"processed": gorm.Expr("processed + ?", 1),
+				"failed":    gorm.Expr("failed + ?", failed),
+				"skipped":   gorm.Expr("skipped + ?", skipped),
+			}).Error
+		if err != nil {
+			return errors.ImageUploadJobCannotUpdate.Wrap(err, "cannot update upload job")
+		}
+		db = tx.Model(&UploadJob{}).
+			Where("id = ? AND status = ? AND processed >= total", f.JobID, UploadRunning).
+			Update("status", UploadDone)
+		if err := db.Error; err != nil {
+			return errors.ImageUploadJobCannotUpdate.Wrap(err, "cannot finish upload job")
+		}
+		last = db.RowsAffected == 1
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return last, nil
+}
+
+func (r *uploadRepository) GetUnfinishedJobs(instance string) ([]UploadJob, error) {
+	var jobs = make([]UploadJob, 0)
+	err := r.db.Where("instance = ? AND status IN (?)", instance, []UploadStatus{UploadPending, UploadRunning}).
+		Order("id").
+		Find(&jobs).Error
+	if err != nil {
+		return nil, errors.ImageQueryError.Wrap(err, "upload jobs query error")
+	}
+	return jobs, nil
+}
+
+func (r *uploadRepository) SetJobStatus(id uint64, status UploadStatus) error {
+	err := r.db.Model(&UploadJob{}).
+		Where("id = ?", id).
+		Update("status", status).Error
+	if err != nil {
+		return errors.ImageUploadJobCannotUpdate.Wrap(err, "cannot update upload job")
+	}
+	return nil
+}
@@ -4,13 +4,20 @@ import (
 	"fmt"
 
 	"github.com/jinzhu/gorm"
+	gormbulk "github.com/t-tiger/gorm-bulk-insert/v2"
 
+	"github.com/nkhang/pluto/internal/outbox"
 	"github.com/nkhang/pluto/pkg/errors"
 )
 
 type DBRepository interface {
+	Get(id uint64) (Label, error)
 	GetByProjectID(projectID uint64) ([]Label, error)
-	CreateLabel(name, color string, projectID, toolID uint64) error
+	CreateLabel(name, color string, projectID, parentID, toolID uint64, attributes []Attribute) (Label, error)
+	BulkCreate(projectID uint64, labels []Label) ([]Label, error)
+	Update(id uint64, changes map[string]interface{}, attributes *[]Attribute) (Label, error)
+	Delete(l Label, remapTo uint64, remap func(tx *gorm.DB) error) error
+	Reorder(projectID uint64, ids []uint64) error
 }
 
 type dbRepository struct {
@@ -21,26 +28,210 @@ func NewDiskRepository(db *gorm.DB) *dbRepository {
 	return &dbRepository{db: db}
 }
 
+func (d *dbRepository) Get(id uint64) (Label, error) {
+	var l Label
+	result := d.db.Preload("Tool").
+		Preload("Attributes", withPosition).
+		First(&l, id)
+	if result.RecordNotFound() {
+		return Label{}, errors.LabelRecordNotFound.NewWithMessageF("label %d not found", id)
+	}
+	if err := result.Error; err != nil {
+		return Label{}, errors.LabelQueryError.Wrap(err, "label query error")
+	}
+	return l, nil
+}
+
 func (d *dbRepository) GetByProjectID(projectID uint64) ([]Label, error) {
 	l := make([]Label, 0)
 	query := fmt.Sprint(fieldProjectID, " = ?")
-	err := d.db.Preload("Tool").Where(query, projectID).Find(&l).Error
+	err := d.db.Preload("Tool").
+		Preload("Attributes", withPosition).
+		Where(query, projectID).
+		Order("position").
+		Order("id").
+		Find(&l).Error
 	if err != nil {
 		return nil, errors.LabelQueryError.NewWithMessage("label query error")
 	}
 	return l, nil
 }
 
-func (d *dbRepository) CreateLabel(name, color string, projectID, toolID uint64) error {
+func withPosition(db *gorm.DB) *gorm.DB {
+	return db.Order("position").Order("id")
+}
+
+// CreateLabel adds a label after the labels already in the project.
+func (d *dbRepository) CreateLabel(name, color string, projectID, parentID, toolID uint64, attributes []Attribute) (Label, error) {
 	l := Label{
 		Name:      name,
 		Color:     color,
 		ProjectID: projectID,
+		ParentID:  parentID,
 		ToolID:    toolID,
 	}
-	err := d.db.Create(&l).Error
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		var count int
+		if err := tx.Model(&Label{}).Where(fieldProjectID+" = ?", projectID).Count(&count).Error; err != nil {
+			return err
+		}
+		l.Position = count
+		if err := tx.Create(&l).Error; err != nil {
+			return err
+		}
+		if err := createAttributes(tx, l.ID, attributes); err != nil {
+			return err
+		}
+		return enqueueUpdate(tx, projectID)
+	})
+	if err != nil {
+		return Label{}, errors.LabelCannotCreate.Wrap(err, "cannot create label")
+	}
+	return d.Get(l.ID)
+}
+
+// BulkCreate adds labels after the labels already in the project, in one
+// transaction that tells the annotation server once. Attributes of the labels
+// are not created.
+func (d *dbRepository) BulkCreate(projectID uint64, labels []Label) ([]Label, error) {
+	created := make([]Label, 0, len(labels))
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		var count int
+		if err := tx.Model(&Label{}).Where(fieldProjectID+" = ?", projectID).Count(&count).Error; err != nil {
+			return err
+		}
+		var last struct{ ID uint64 }
+		if err := tx.Unscoped().Model(&Label{}).Select("COALESCE(MAX(id), 0) AS id").Scan(&last).Error; err != nil {
+			return err
+		}
+		records := make([]interface{}, len(labels))
+		for i, l := range labels {
+			records[i] = Label{
+				Name:      l.Name,
+				Color:     l.Color,
+				ProjectID: projectID,
+				ParentID:  l.ParentID,
+				ToolID:    l.ToolID,
+				Position:  count + i,
+			}
+		}
+		if err := gormbulk.BulkInsert(tx, records, 1000); err != nil {
+			return err
+		}
+		err := tx.Where(fieldProjectID+" = ? AND id > ?", projectID, last.ID).
+			Order("id").
+			Find(&created).Error
+		if err != nil {
+			return err
+		}
+		return enqueueUpdate(tx, projectID)
+	})
+	if err != nil {
+		return nil, errors.LabelCannotCreate.Wrap(err, "cannot create labels")
+	}
+	return created, nil
+}
+
+// Update changes the fields of a label. The attributes are replaced when
+// attributes is not nil.
+func (d *dbRepository) Update(id uint64, changes map[string]interface{}, attributes *[]Attribute) (Label, error) {
+	l, err := d.Get(id)
+	if err != nil {
+		return Label{}, err
+	}
+	err = d.db.Transaction(func(tx *gorm.DB) error {
+		if len(changes) != 0 {
+			if err := tx.Model(&Label{}).Where("id = ?", id).Updates(changes).Error; err != nil {
+				return err
+			}
+		}
+		if attributes != nil {
+			if err := tx.Where(fieldLabelID+" = ?", id).Delete(&Attribute{}).Error; err != nil {
+				return err
+			}
+			if err := createAttributes(tx, id, *attributes); err != nil {
+				return err
+			}
+		}
+		return enqueueUpdate(tx, l.ProjectID)
+	})
+	if err != nil {
+		return Label{}, errors.LabelCannotUpdate.Wrap(err, "cannot update label")
+	}
+	return d.Get(id)
+}
+
+// Delete deletes a label with its attributes, its children move up to its
+// parent. When remapTo is set, the objects of the label move to remapTo:
+// remap moves them in the same transaction when pluto stores them, otherwise
+// the annotation server is told to.
+func (d *dbRepository) Delete(l Label, remapTo uint64, remap func(tx *gorm.DB) error) error {
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(fieldLabelID+" = ?", l.ID).Delete(&Attribute{}).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&Label{}).
+			Where(fieldParentID+" = ?", l.ID).
+			Update(fieldParentID, l.ParentID).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&Label{}, l.ID).Error; err != nil {
+			return err
+		}
+		if remapTo != 0 && remap != nil {
+			if err := remap(tx); err != nil {
+				return err
+			}
+		} else if remapTo != 0 {
+			err := outbox.Enqueue(tx, outbox.KindRemapLabel, outbox.RemapLabelPayload{
+				ProjectID: l.ProjectID,
+				From:      l.ID,
+				To:        remapTo,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return enqueueUpdate(tx, l.ProjectID)
+	})
+	if err != nil {
+		return errors.LabelCannotDelete.WrapF(err, "cannot delete label %d", l.ID)
+	}
+	return nil
+}
+
+// Reorder gives the labels of a project the positions of their IDs in ids.
+func (d *dbRepository) Reorder(projectID uint64, ids []uint64) error {
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		for i, id := range ids {
+			err := tx.Model(&Label{}).
+				Where("id = ? AND "+fieldProjectID+" = ?", id, projectID).
+				Update("position", i).Error
+			if err != nil {
+				return err
+			}
+		}
+		return enqueueUpdate(tx, projectID)
+	})
 	if err != nil {
-		return errors.LabelCannotCreate.Wrap(err, "cannot create label")
+		return errors.LabelCannotUpdate.Wrap(err, "cannot reorder labels")
+	}
+	return nil
+}
+
+func createAttributes(tx *gorm.DB, labelID uint64, attributes []Attribute) error {
+	for i, a := range attributes {
+		a.ID = 0
+		a.LabelID = labelID
+		a.Position = i
+		if err := tx.Create(&a).Error; err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+func enqueueUpdate(tx *gorm.DB, projectID uint64) error {
+	return outbox.Enqueue(tx, outbox.KindUpdateLabels, outbox.ProjectPayload{ProjectID: projectID})
+}
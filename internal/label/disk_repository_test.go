@@ -0,0 +1,51 @@
+package label
+
+import (
+	"database/sql"
+	"testing"
+
+	gomocket "github.com/Selvatico/go-mocket"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockDB(t *testing.T) *gorm.DB {
+	gomocket.Catcher.Register()
+	conn, err := sql.Open(gomocket.DriverName, "connection_string")
+	require.NoError(t, err)
+	db, err := gorm.Open("mysql", conn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+		gomocket.Catcher.Reset()
+	})
+	return db
+}
+
+func TestBulkCreate(t *testing.T) {
+	db := newMockDB(t)
+	gomocket.Catcher.NewMock().
+		WithQuery("SELECT count(*)").
+		WithReply([]map[string]interface{}{{"count(*)": 2}})
+	gomocket.Catcher.NewMock().
+		WithQuery("MAX(id)").
+		WithReply([]map[string]interface{}{{"id": 10}})
+	inserted := gomocket.Catcher.NewMock().
+		WithQuery("INSERT INTO `labels` (`color`, `created_at`, `deleted_at`, `name`, `parent_id`, `position`, `project_id`, `tool_id`, `updated_at`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?), (?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	gomocket.Catcher.NewMock().
+		WithQuery("project_id = 5 AND id > 10").
+		WithReply([]map[string]interface{}{
+			{"id": 11, "name": "car", "project_id": 5, "position": 2},
+			{"id": 12, "name": "bus", "project_id": 5, "position": 3},
+		})
+	enqueued := gomocket.Catcher.NewMock().WithQuery("INSERT INTO `outbox_events`")
+
+	labels, err := NewDiskRepository(db).BulkCreate(5, []Label{{Name: "car", ToolID: 1}, {Name: "bus", ToolID: 1}})
+	require.NoError(t, err)
+	assert.True(t, inserted.Triggered, "labels are inserted in one statement")
+	assert.True(t, enqueued.Triggered)
+	require.Len(t, labels, 2)
+	assert.Equal(t, uint64(11), labels[0].ID)
+	assert.Equal(t, 3, labels[1].Position)
+}
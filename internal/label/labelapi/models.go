@@ -9,28 +9,121 @@ type LabelRequest struct {
 	ProjectID uint64 `form:"project_id" binding:"required"`
 }
 
+// GetLabelsRequest asks for the labels as a list instead of a tree.
+type GetLabelsRequest struct {
+	Flat bool `form:"flat"`
+}
+
 type CreateLabelRequest struct {
 	Labels []CreateLabelObject `json:"labels" form:"labels" binding:"required"`
 }
 
 type CreateLabelObject struct {
-	Name   string `json:"name" form:"name" binding:"required"`
-	Color  string `json:"color" form:"color" binding:"required"`
-	ToolID uint64 `json:"tool" form:"tool" binding:"required"`
+	Name       string            `json:"name" form:"name" binding:"required"`
+	Color      string            `json:"color" form:"color" binding:"required"`
+	ParentID   uint64            `json:"parent" form:"parent"`
+	ToolID     uint64            `json:"tool" form:"tool" binding:"required"`
+	Attributes []AttributeObject `json:"attributes" form:"attributes"`
+}
+
+type AttributeObject struct {
+	Name     string              `json:"name" binding:"required"`
+	Kind     label.AttributeKind `json:"kind" binding:"required"`
+	Options  []string            `json:"options"`
+	Required bool                `json:"required"`
+}
+
+// UpdateLabelRequest changes the fields that are set. Attributes, when set,
+// replace all the attributes of the label. A parent of 0 moves the label to
+// the top of the tree.
+type UpdateLabelRequest struct {
+	Name       *string            `json:"name"`
+	Color      *string            `json:"color"`
+	ParentID   *uint64            `json:"parent"`
+	ToolID     *uint64            `json:"tool"`
+	Attributes *[]AttributeObject `json:"attributes"`
+}
+
+// DeleteLabelRequest names the label that takes over the objects of the
+// deleted label, required when the label already has objects. Both labels are
+// drawn with the same tool.
+type DeleteLabelRequest struct {
+	RemapTo uint64 `form:"remap_to"`
+}
+
+type ReorderLabelsRequest struct {
+	LabelIDs []uint64 `json:"label_ids" binding:"required"`
+}
+
+type AttributeResponse struct {
+	ID       uint64              `json:"id"`
+	Name     string              `json:"name"`
+	Kind     label.AttributeKind `json:"kind"`
+	Options  []string            `json:"options"`
+	Required bool                `json:"required"`
 }
 
 type LabelResponse struct {
-	ID    uint64               `json:"id"`
-	Name  string               `json:"name"`
-	Color string               `json:"color"`
-	Tool  toolapi.ToolResponse `json:"tool"`
+	ID         uint64               `json:"id"`
+	Name       string               `json:"name"`
+	Color      string               `json:"color"`
+	Tool       toolapi.ToolResponse `json:"tool"`
+	ParentID   uint64               `json:"parent_id"`
+	Position   int                  `json:"position"`
+	Attributes []AttributeResponse  `json:"attributes"`
+	Children   []LabelResponse      `json:"children,omitempty"`
 }
 
 func ToLabelResponse(l label.Label) LabelResponse {
+	attributes := make([]AttributeResponse, len(l.Attributes))
+	for i, a := range l.Attributes {
+		attributes[i] = AttributeResponse{
+			ID:       a.ID,
+			Name:     a.Name,
+			Kind:     a.Kind,
+			Options:  a.OptionList(),
+			Required: a.Required,
+		}
+	}
 	return LabelResponse{
-		ID:    l.ID,
-		Name:  l.Name,
-		Color: l.Color,
-		Tool:  toolapi.ToToolResponse(l.Tool),
+		ID:         l.ID,
+		Name:       l.Name,
+		Color:      l.Color,
+		Tool:       toolapi.ToToolResponse(l.Tool),
+		ParentID:   l.ParentID,
+		Position:   l.Position,
+		Attributes: attributes,
+	}
+}
+
+// ToLabelTree nests each label under its parent, keeping the order of
+// labels among siblings. Labels whose parent is not in labels are roots.
+func ToLabelTree(labels []label.Label) []LabelResponse {
+	var (
+		known    = make(map[uint64]bool, len(labels))
+		children = make(map[uint64][]label.Label)
+		roots    []label.Label
+	)
+	for _, l := range labels {
+		known[l.ID] = true
+	}
+	for _, l := range labels {
+		if l.ParentID == 0 || !known[l.ParentID] {
+			roots = append(roots, l)
+			continue
+		}
+		children[l.ParentID] = append(children[l.ParentID], l)
+	}
+	var build func(labels []label.Label) []LabelResponse
+	build = func(labels []label.Label) []LabelResponse {
+		responses := make([]LabelResponse, len(labels))
+		for i, l := range labels {
+			responses[i] = ToLabelResponse(l)
+			if c := children[l.ID]; len(c) != 0 {
+				responses[i].Children = build(c)
+			}
+		}
+		return responses
 	}
+	return build(roots)
 }
@@ -1,22 +1,39 @@
 package labelapi
 
 import (
+	"strings"
+
+	"github.com/jinzhu/gorm"
+
 	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/task"
+	"github.com/nkhang/pluto/pkg/annotation"
 	"github.com/nkhang/pluto/pkg/errors"
 )
 
 type Repository interface {
 	GetByProject(pID uint64) ([]LabelResponse, error)
+	GetTree(pID uint64) ([]LabelResponse, error)
 	CreateLabel(projectID uint64, r CreateLabelRequest) error
+	UpdateLabel(projectID, labelID uint64, r UpdateLabelRequest) (LabelResponse, error)
+	DeleteLabel(projectID, labelID uint64, r DeleteLabelRequest) error
+	ReorderLabels(projectID uint64, r ReorderLabelsRequest) ([]LabelResponse, error)
 }
 
 type repository struct {
-	repository label.Repository
+	repository        label.Repository
+	annotationService annotation.Service
+	projectRepo       project.Repository
+	taskRepo          task.Repository
 }
 
-func NewRepository(r label.Repository) *repository {
+func NewRepository(r label.Repository, s annotation.Service, p project.Repository, t task.Repository) *repository {
 	return &repository{
-		repository: r,
+		repository:        r,
+		annotationService: s,
+		projectRepo:       p,
+		taskRepo:          t,
 	}
 }
 
@@ -32,10 +49,33 @@ func (r *repository) GetByProject(pID uint64) ([]LabelResponse, error) {
 	return responses, nil
 }
 
+// GetTree returns the labels at the top of the project tree, each with the
+// labels below it.
+func (r *repository) GetTree(pID uint64) ([]LabelResponse, error) {
+	labels, err := r.repository.GetByProjectId(pID)
+	if err != nil {
+		return nil, err
+	}
+	return ToLabelTree(labels), nil
+}
+
 func (r *repository) CreateLabel(projectID uint64, request CreateLabelRequest) error {
+	attributes := make([][]label.Attribute, len(request.Labels))
+	for i, req := range request.Labels {
+		a, err := toAttributes(req.Attributes)
+		if err != nil {
+			return err
+		}
+		attributes[i] = a
+		if req.ParentID != 0 {
+			if _, err := r.labelOf(projectID, req.ParentID); err != nil {
+				return errors.LabelParentInvalid.NewWithMessageF("parent %d of label %s not found", req.ParentID, req.Name)
+			}
+		}
+	}
 	errs := make([]error, 0)
-	for _, req := range request.Labels {
-		err := r.repository.CreateLabel(req.Name, req.Color, projectID, req.ToolID)
+	for i, req := range request.Labels {
+		_, err := r.repository.CreateLabel(req.Name, req.Color, projectID, req.ParentID, req.ToolID, attributes[i])
 		if err != nil {
 			errs = append(errs, err)
 		}
@@ -45,3 +85,223 @@ func (r *repository) CreateLabel(projectID uint64, request CreateLabelRequest) e
 	}
 	return nil
 }
+
+// UpdateLabel updates a label. The tool of a label whose objects are already
+// drawn cannot change, since they were drawn with it.
+func (r *repository) UpdateLabel(projectID, labelID uint64, request UpdateLabelRequest) (LabelResponse, error) {
+	l, err := r.labelOf(projectID, labelID)
+	if err != nil {
+		return LabelResponse{}, err
+	}
+	var changes = make(map[string]interface{})
+	if request.Name != nil {
+		name := strings.TrimSpace(*request.Name)
+		if name == "" {
+			return LabelResponse{}, errors.BadRequest.NewWithMessage("name must not be empty")
+		}
+		changes["name"] = name
+	}
+	if request.Color != nil {
+		if *request.Color == "" {
+			return LabelResponse{}, errors.BadRequest.NewWithMessage("color must not be empty")
+		}
+		changes["color"] = *request.Color
+	}
+	if request.ToolID != nil {
+		if *request.ToolID == 0 {
+			return LabelResponse{}, errors.BadRequest.NewWithMessage("tool must not be empty")
+		}
+		if *request.ToolID != l.ToolID {
+			objects, err := r.countObjects(projectID, labelID)
+			if err != nil {
+				return LabelResponse{}, err
+			}
+			if objects != 0 {
+				return LabelResponse{}, errors.LabelHasAnnotations.NewWithMessageF("label has %d objects, its tool cannot change", objects)
+			}
+		}
+		changes["tool_id"] = *request.ToolID
+	}
+	if request.ParentID != nil {
+		if err := r.checkParent(projectID, labelID, *request.ParentID); err != nil {
+			return LabelResponse{}, err
+		}
+		changes["parent_id"] = *request.ParentID
+	}
+	var attributes *[]label.Attribute
+	if request.Attributes != nil {
+		a, err := toAttributes(*request.Attributes)
+		if err != nil {
+			return LabelResponse{}, err
+		}
+		attributes = &a
+	}
+	l, err = r.repository.Update(labelID, changes, attributes)
+	if err != nil {
+		return LabelResponse{}, err
+	}
+	return ToLabelResponse(l), nil
+}
+
+// checkParent checks that parentID, when set, is a label of the project
+// that is not below the label labelID.
+func (r *repository) checkParent(projectID, labelID, parentID uint64) error {
+	if parentID == 0 {
+		return nil
+	}
+	if _, err := r.labelOf(projectID, parentID); err != nil {
+		return errors.LabelParentInvalid.NewWithMessageF("parent %d not found", parentID)
+	}
+	labels, err := r.repository.GetByProjectId(projectID)
+	if err != nil {
+		return err
+	}
+	if label.CreatesCycle(labels, labelID, parentID) {
+		return errors.LabelParentInvalid.NewWithMessageF("label %d is below label %d", parentID, labelID)
+	}
+	return nil
+}
+
+// DeleteLabel deletes a label, the labels below it move up to its parent.
+// A label whose objects are already drawn can only be deleted when another
+// label of the project takes them over. In classification projects pluto
+// remaps the details along with the deletion.
+func (r *repository) DeleteLabel(projectID, labelID uint64, request DeleteLabelRequest) error {
+	l, err := r.labelOf(projectID, labelID)
+	if err != nil {
+		return err
+	}
+	if request.RemapTo != 0 {
+		if request.RemapTo == labelID {
+			return errors.BadRequest.NewWithMessage("a label cannot be remapped to itself")
+		}
+		target, err := r.labelOf(projectID, request.RemapTo)
+		if err != nil {
+			return err
+		}
+		if target.ToolID != l.ToolID {
+			return errors.LabelHasAnnotations.NewWithMessageF("objects cannot be remapped to label %d drawn with another tool", target.ID)
+		}
+		p, err := r.projectRepo.Get(projectID)
+		if err != nil {
+			return err
+		}
+		if !p.Classifies() {
+			return r.repository.Delete(l, request.RemapTo, nil)
+		}
+		return r.repository.Delete(l, request.RemapTo, func(tx *gorm.DB) error {
+			return r.taskRepo.RemapLabel(tx, projectID, labelID, request.RemapTo)
+		})
+	}
+	objects, err := r.countObjects(projectID, labelID)
+	if err != nil {
+		return err
+	}
+	if objects != 0 {
+		return errors.LabelHasAnnotations.NewWithMessageF("label has %d objects, choose a label to remap them to", objects)
+	}
+	return r.repository.Delete(l, 0, nil)
+}
+
+// countObjects counts the objects of a label. In classification projects
+// these are the images the label is set on, which pluto stores itself.
+func (r *repository) countObjects(projectID, labelID uint64) (int, error) {
+	p, err := r.projectRepo.Get(projectID)
+	if err != nil {
+		return 0, err
+	}
+	if !p.Classifies() {
+		stats, err := r.annotationService.GetLabelCount(projectID, labelID)
+		if err != nil {
+			return 0, err
+		}
+		return stats.TotalObject, nil
+	}
+	details, err := r.taskRepo.GetClassifiedDetails(projectID, 0)
+	if err != nil {
+		return 0, err
+	}
+	var objects int
+	for _, d := range details {
+		for _, id := range d.LabelIDs() {
+			if id == labelID {
+				objects++
+			}
+		}
+	}
+	return objects, nil
+}
+
+// ReorderLabels orders the labels of a project as in request, which must
+// list each of them once.
+func (r *repository) ReorderLabels(projectID uint64, request ReorderLabelsRequest) ([]LabelResponse, error) {
+	labels, err := r.repository.GetByProjectId(projectID)
+	if err != nil {
+		return nil, err
+	}
+	var listed = make(map[uint64]bool, len(request.LabelIDs))
+	for _, id := range request.LabelIDs {
+		listed[id] = true
+	}
+	if len(listed) != len(request.LabelIDs) || len(listed) != len(labels) {
+		return nil, errors.LabelOrderInvalid.NewWithMessage("every label of the project must be listed once")
+	}
+	for _, l := range labels {
+		if !listed[l.ID] {
+			return nil, errors.LabelOrderInvalid.NewWithMessageF("label %d is not listed", l.ID)
+		}
+	}
+	if err := r.repository.Reorder(projectID, request.LabelIDs); err != nil {
+		return nil, err
+	}
+	return r.GetTree(projectID)
+}
+
+func (r *repository) labelOf(projectID, labelID uint64) (label.Label, error) {
+	l, err := r.repository.Get(labelID)
+	if err != nil {
+		return label.Label{}, err
+	}
+	if l.ProjectID != projectID {
+		return label.Label{}, errors.LabelRecordNotFound.NewWithMessageF("label %d not found", labelID)
+	}
+	return l, nil
+}
+
+// toAttributes validates the attributes of a label: names are unique, enum
+// attributes have options and the other kinds have none.
+func toAttributes(objects []AttributeObject) ([]label.Attribute, error) {
+	var (
+		attributes = make([]label.Attribute, len(objects))
+		names      = make(map[string]bool, len(objects))
+	)
+	for i, o := range objects {
+		name := strings.TrimSpace(o.Name)
+		if name == "" {
+			return nil, errors.LabelAttributeInvalid.NewWithMessage("attribute name must not be empty")
+		}
+		if names[name] {
+			return nil, errors.LabelAttributeInvalid.NewWithMessageF("attribute %s is given twice", name)
+		}
+		names[name] = true
+		if !o.Kind.Valid() {
+			return nil, errors.LabelAttributeInvalid.NewWithMessageF("kind of attribute %s must be %s, %s or %s",
+				name, label.AttributeEnum, label.AttributeBoolean, label.AttributeText)
+		}
+		if o.Kind != label.AttributeEnum && len(o.Options) != 0 {
+			return nil, errors.LabelAttributeInvalid.NewWithMessageF("attribute %s of kind %s takes no options", name, o.Kind)
+		}
+		if o.Kind == label.AttributeEnum && len(o.Options) == 0 {
+			return nil, errors.LabelAttributeInvalid.NewWithMessageF("enum attribute %s needs options", name)
+		}
+		var options = make(map[string]bool, len(o.Options))
+		for _, option := range o.Options {
+			if !label.ValidOption(option) || options[option] {
+				return nil, errors.LabelAttributeInvalid.NewWithMessageF("invalid option %q of attribute %s", option, name)
+			}
+			options[option] = true
+		}
+		attributes[i] = label.NewAttribute(name, o.Kind, o.Options, o.Required)
+	}
+	return attributes, nil
+}
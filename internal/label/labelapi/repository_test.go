@@ -0,0 +1,249 @@
+package labelapi
+
+import (
+	"testing"
+
+	jgorm "github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/task"
+	"github.com/nkhang/pluto/pkg/annotation"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/gorm"
+)
+
+type fakeLabelRepo struct {
+	label.Repository
+	labels  []label.Label
+	deleted map[uint64]uint64
+	remaps  int
+	order   []uint64
+}
+
+func (r *fakeLabelRepo) Get(id uint64) (label.Label, error) {
+	for _, l := range r.labels {
+		if l.ID == id {
+			return l, nil
+		}
+	}
+	return label.Label{}, errors.LabelRecordNotFound.NewWithMessage("label not found")
+}
+
+func (r *fakeLabelRepo) GetByProjectId(pID uint64) ([]label.Label, error) {
+	var labels []label.Label
+	for _, l := range r.labels {
+		if l.ProjectID == pID {
+			labels = append(labels, l)
+		}
+	}
+	return labels, nil
+}
+
+// Delete runs remap in place of the transaction deleting the label.
+func (r *fakeLabelRepo) Delete(l label.Label, remapTo uint64, remap func(tx *jgorm.DB) error) error {
+	if remap != nil {
+		r.remaps++
+		if err := remap(nil); err != nil {
+			return err
+		}
+	}
+	r.deleted[l.ID] = remapTo
+	return nil
+}
+
+func (r *fakeLabelRepo) Update(id uint64, changes map[string]interface{}, attributes *[]label.Attribute) (label.Label, error) {
+	for i, l := range r.labels {
+		if l.ID != id {
+			continue
+		}
+		if parentID, ok := changes["parent_id"]; ok {
+			r.labels[i].ParentID = parentID.(uint64)
+		}
+		if toolID, ok := changes["tool_id"]; ok {
+			r.labels[i].ToolID = toolID.(uint64)
+		}
+		return r.labels[i], nil
+	}
+	return label.Label{}, errors.LabelRecordNotFound.NewWithMessage("label not found")
+}
+
+func (r *fakeLabelRepo) Reorder(projectID uint64, ids []uint64) error {
+	r.order = ids
+	return nil
+}
+
+type fakeAnnotationService struct {
+	annotation.Service
+	objects map[uint64]int
+}
+
+func (s fakeAnnotationService) GetLabelCount(projectID, labelID uint64) (annotation.LabelStatsObject, error) {
+	return annotation.LabelStatsObject{TotalObject: s.objects[labelID]}, nil
+}
+
+type fakeProjectRepo struct {
+	project.Repository
+}
+
+// Get puts project 6 in classification mode.
+func (fakeProjectRepo) Get(id uint64) (project.Project, error) {
+	if id == 6 {
+		return project.Project{Mode: project.ModeClassification}, nil
+	}
+	return project.Project{Mode: project.ModeAnnotation}, nil
+}
+
+type fakeTaskRepo struct {
+	task.Repository
+	details  []task.Detail
+	remapped [][2]uint64
+}
+
+func (r *fakeTaskRepo) GetClassifiedDetails(projectID, datasetID uint64) ([]task.Detail, error) {
+	return r.details, nil
+}
+
+func (r *fakeTaskRepo) RemapLabel(tx *jgorm.DB, projectID, from, to uint64) error {
+	r.remapped = append(r.remapped, [2]uint64{from, to})
+	return nil
+}
+
+func newTestRepository() (*repository, *fakeLabelRepo) {
+	r, labels, _ := newTestRepositoryWithTasks()
+	return r, labels
+}
+
+func newTestRepositoryWithTasks() (*repository, *fakeLabelRepo, *fakeTaskRepo) {
+	labels := &fakeLabelRepo{
+		labels: []label.Label{
+			{Model: gorm.Model{ID: 1}, ProjectID: 5, Name: "car", ToolID: 1},
+			{Model: gorm.Model{ID: 2}, ProjectID: 5, Name: "truck", ToolID: 1},
+			{Model: gorm.Model{ID: 3}, ProjectID: 5, Name: "bus"},
+			{Model: gorm.Model{ID: 4}, ProjectID: 6, Name: "cat"},
+		},
+		deleted: make(map[uint64]uint64),
+	}
+	tasks := &fakeTaskRepo{details: []task.Detail{{ImageID: 1, Labels: "4"}, {ImageID: 2, Labels: "7,4"}}}
+	return NewRepository(labels, fakeAnnotationService{objects: map[uint64]int{1: 12}}, fakeProjectRepo{}, tasks), labels, tasks
+}
+
+func TestDeleteLabel(t *testing.T) {
+	r, labels := newTestRepository()
+
+	err := r.DeleteLabel(5, 1, DeleteLabelRequest{})
+	assert.Equal(t, errors.LabelHasAnnotations, errors.Type(err))
+	err = r.DeleteLabel(5, 1, DeleteLabelRequest{RemapTo: 4})
+	assert.Equal(t, errors.LabelRecordNotFound, errors.Type(err), "labels of other projects cannot take the objects")
+	err = r.DeleteLabel(5, 1, DeleteLabelRequest{RemapTo: 1})
+	assert.Equal(t, errors.BadRequest, errors.Type(err))
+	err = r.DeleteLabel(5, 1, DeleteLabelRequest{RemapTo: 3})
+	assert.Equal(t, errors.LabelHasAnnotations, errors.Type(err), "objects keep the tool they were drawn with")
+	assert.Empty(t, labels.deleted)
+
+	require.NoError(t, r.DeleteLabel(5, 1, DeleteLabelRequest{RemapTo: 2}))
+	require.NoError(t, r.DeleteLabel(5, 3, DeleteLabelRequest{}))
+	assert.Equal(t, map[uint64]uint64{1: 2, 3: 0}, labels.deleted)
+	assert.Zero(t, labels.remaps, "the annotation server remaps the objects of annotation projects")
+}
+
+func TestDeleteClassificationLabel(t *testing.T) {
+	r, labels, tasks := newTestRepositoryWithTasks()
+
+	err := r.DeleteLabel(6, 4, DeleteLabelRequest{})
+	assert.Equal(t, errors.LabelHasAnnotations, errors.Type(err), "the label is set on two images")
+	labels.labels = append(labels.labels,
+		label.Label{Model: gorm.Model{ID: 8}, ProjectID: 6, Name: "dog"},
+		label.Label{Model: gorm.Model{ID: 9}, ProjectID: 6, Name: "bird"},
+	)
+	require.NoError(t, r.DeleteLabel(6, 8, DeleteLabelRequest{}))
+	require.NoError(t, r.DeleteLabel(6, 4, DeleteLabelRequest{RemapTo: 9}))
+	assert.Equal(t, map[uint64]uint64{8: 0, 4: 9}, labels.deleted)
+	assert.Equal(t, [][2]uint64{{4, 9}}, tasks.remapped, "pluto remaps the details along with the deletion")
+}
+
+func TestReorderLabels(t *testing.T) {
+	r, labels := newTestRepository()
+
+	for _, ids := range [][]uint64{{3, 1}, {3, 1, 1}, {3, 1, 4}} {
+		_, err := r.ReorderLabels(5, ReorderLabelsRequest{LabelIDs: ids})
+		assert.Equal(t, errors.LabelOrderInvalid, errors.Type(err), "ids %v", ids)
+	}
+	_, err := r.ReorderLabels(5, ReorderLabelsRequest{LabelIDs: []uint64{3, 1, 2}})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3, 1, 2}, labels.order)
+}
+
+func TestUpdateLabelParent(t *testing.T) {
+	r, labels := newTestRepository()
+	parent := func(id uint64) *uint64 { return &id }
+
+	_, err := r.UpdateLabel(5, 2, UpdateLabelRequest{ParentID: parent(1)})
+	require.NoError(t, err)
+	_, err = r.UpdateLabel(5, 3, UpdateLabelRequest{ParentID: parent(2)})
+	require.NoError(t, err)
+
+	for _, p := range []uint64{1, 3, 4, 9} {
+		_, err = r.UpdateLabel(5, 1, UpdateLabelRequest{ParentID: parent(p)})
+		assert.Equal(t, errors.LabelParentInvalid, errors.Type(err), "parent %d", p)
+	}
+
+	tree, err := r.GetTree(5)
+	require.NoError(t, err)
+	require.Len(t, tree, 1)
+	require.Len(t, tree[0].Children, 1)
+	assert.Equal(t, uint64(2), tree[0].Children[0].ID)
+	require.Len(t, tree[0].Children[0].Children, 1)
+	assert.Equal(t, uint64(3), tree[0].Children[0].Children[0].ID)
+
+	_, err = r.UpdateLabel(5, 3, UpdateLabelRequest{ParentID: parent(0)})
+	require.NoError(t, err)
+	assert.Zero(t, labels.labels[2].ParentID)
+	tree, err = r.GetTree(5)
+	require.NoError(t, err)
+	assert.Len(t, tree, 2)
+}
+
+func TestUpdateLabelTool(t *testing.T) {
+	r, labels := newTestRepository()
+	tool := func(id uint64) *uint64 { return &id }
+
+	_, err := r.UpdateLabel(5, 1, UpdateLabelRequest{ToolID: tool(2)})
+	assert.Equal(t, errors.LabelHasAnnotations, errors.Type(err), "the label has objects drawn with its tool")
+	_, err = r.UpdateLabel(6, 4, UpdateLabelRequest{ToolID: tool(2)})
+	assert.Equal(t, errors.LabelHasAnnotations, errors.Type(err), "the label is set on two images")
+	assert.Equal(t, uint64(1), labels.labels[0].ToolID)
+
+	_, err = r.UpdateLabel(5, 1, UpdateLabelRequest{ToolID: tool(1)})
+	require.NoError(t, err, "the tool is unchanged")
+	_, err = r.UpdateLabel(5, 3, UpdateLabelRequest{ToolID: tool(2)})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), labels.labels[2].ToolID)
+}
+
+func TestToAttributes(t *testing.T) {
+	attributes, err := toAttributes([]AttributeObject{
+		{Name: "occluded", Kind: label.AttributeBoolean},
+		{Name: "vehicle type", Kind: label.AttributeEnum, Options: []string{"sedan", "suv"}, Required: true},
+		{Name: "plate", Kind: label.AttributeText},
+	})
+	require.NoError(t, err)
+	require.Len(t, attributes, 3)
+	assert.Equal(t, []string{"sedan", "suv"}, attributes[1].OptionList())
+	assert.True(t, attributes[1].Required)
+
+	for _, invalid := range [][]AttributeObject{
+		{{Name: " ", Kind: label.AttributeText}},
+		{{Name: "a", Kind: "number"}},
+		{{Name: "a", Kind: label.AttributeEnum}},
+		{{Name: "a", Kind: label.AttributeEnum, Options: []string{"x,y"}}},
+		{{Name: "a", Kind: label.AttributeEnum, Options: []string{"x", "x"}}},
+		{{Name: "a", Kind: label.AttributeBoolean, Options: []string{"x"}}},
+		{{Name: "a", Kind: label.AttributeText}, {Name: "a", Kind: label.AttributeBoolean}},
+	} {
+		_, err := toAttributes(invalid)
+		assert.Equal(t, errors.LabelAttributeInvalid, errors.Type(err), "attributes %v", invalid)
+	}
+}
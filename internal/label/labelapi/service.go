@@ -2,31 +2,54 @@ package labelapi
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/internal/project/projectapi"
 
 	"github.com/nkhang/pluto/pkg/errors"
 	"github.com/nkhang/pluto/pkg/ginwrapper"
 	"github.com/nkhang/pluto/pkg/logger"
+	"github.com/nkhang/pluto/pkg/pgin"
+	"github.com/spf13/cast"
 )
 
 type service struct {
-	repository Repository
+	repository     Repository
+	templateRouter pgin.Router
+	authorizer     authz.Authorizer
 }
 
-func NewService(r Repository) *service {
+func NewService(r Repository, templateRouter pgin.Router, authorizer authz.Authorizer) *service {
 	return &service{
-		repository: r,
+		repository:     r,
+		templateRouter: templateRouter,
+		authorizer:     authorizer,
 	}
 }
 
+const fieldLabelID = "labelId"
+
 func (s *service) Register(router gin.IRouter) {
 	router.GET("", ginwrapper.Wrap(s.getByProjectID))
-	router.POST("", ginwrapper.Wrap(s.create))
+	router.POST("", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.create))
+	router.PUT("/order", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.reorder))
+	router.PATCH("/:"+fieldLabelID, s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.update))
+	router.DELETE("/:"+fieldLabelID, s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.delete))
+	s.templateRouter.Register(router)
 }
 
 func (s *service) getByProjectID(c *gin.Context) ginwrapper.Response {
 	projectID := uint64(c.GetInt64(projectapi.FieldProjectID))
-	responses, err := s.repository.GetByProject(projectID)
+	var req GetLabelsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessageF("error binding request. error %v", err),
+		}
+	}
+	get := s.repository.GetTree
+	if req.Flat {
+		get = s.repository.GetByProject
+	}
+	responses, err := get(projectID)
 	if err != nil {
 		return ginwrapper.Response{
 			Error: err,
@@ -56,3 +79,73 @@ func (s *service) create(c *gin.Context) ginwrapper.Response {
 		Error: errors.Success.NewWithMessage("success"),
 	}
 }
+
+func (s *service) update(c *gin.Context) ginwrapper.Response {
+	projectID := uint64(c.GetInt64(projectapi.FieldProjectID))
+	labelID, err := cast.ToUint64E(c.Param(fieldLabelID))
+	if err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.Wrap(err, "error binding params"),
+		}
+	}
+	var req UpdateLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessageF("error binding request. error %v", err),
+		}
+	}
+	response, err := s.repository.UpdateLabel(projectID, labelID, req)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Data:  response,
+		Error: errors.Success.NewWithMessage("success"),
+	}
+}
+
+func (s *service) delete(c *gin.Context) ginwrapper.Response {
+	projectID := uint64(c.GetInt64(projectapi.FieldProjectID))
+	labelID, err := cast.ToUint64E(c.Param(fieldLabelID))
+	if err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.Wrap(err, "error binding params"),
+		}
+	}
+	var req DeleteLabelRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessageF("error binding request. error %v", err),
+		}
+	}
+	if err := s.repository.DeleteLabel(projectID, labelID, req); err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+	}
+}
+
+func (s *service) reorder(c *gin.Context) ginwrapper.Response {
+	projectID := uint64(c.GetInt64(projectapi.FieldProjectID))
+	var req ReorderLabelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessageF("error binding request. error %v", err),
+		}
+	}
+	responses, err := s.repository.ReorderLabels(projectID, req)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Data:  responses,
+		Error: errors.Success.NewWithMessage("success"),
+	}
+}
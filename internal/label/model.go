@@ -1,19 +1,86 @@
 package label
 
 import (
+	"strings"
+
 	"github.com/nkhang/pluto/internal/tool"
 	"github.com/nkhang/pluto/pkg/gorm"
 )
 
 const (
 	fieldProjectID = "project_id"
+	fieldLabelID   = "label_id"
+	fieldParentID  = "parent_id"
+	// optionSeparator joins the options of an enum attribute.
+	optionSeparator = ","
 )
 
+// Label is a class of objects. Labels form a tree within their project,
+// ParentID is 0 for the labels at the top.
 type Label struct {
 	gorm.Model
-	Name      string
-	Color     string
-	ProjectID uint64
-	ToolID    uint64
-	Tool      tool.Tool
+	Name       string
+	Color      string
+	ProjectID  uint64
+	ParentID   uint64 `gorm:"index"`
+	ToolID     uint64
+	Tool       tool.Tool
+	Position   int
+	Attributes []Attribute `gorm:"foreignkey:LabelID"`
+}
+
+type AttributeKind string
+
+const (
+	AttributeEnum    AttributeKind = "enum"
+	AttributeBoolean AttributeKind = "boolean"
+	AttributeText    AttributeKind = "text"
+)
+
+func (k AttributeKind) Valid() bool {
+	switch k {
+	case AttributeEnum, AttributeBoolean, AttributeText:
+		return true
+	}
+	return false
+}
+
+// Attribute is a field filled in for every object of its label, such as
+// whether a vehicle is occluded. An enum attribute takes one of its
+// options, which are joined with optionSeparator.
+type Attribute struct {
+	gorm.Model
+	LabelID  uint64 `gorm:"index"`
+	Name     string
+	Kind     AttributeKind `gorm:"type:varchar(16)"`
+	Options  string        `gorm:"type:varchar(1024)"`
+	Required bool
+	Position int
+}
+
+func (Attribute) TableName() string {
+	return "label_attributes"
+}
+
+func (a Attribute) OptionList() []string {
+	if a.Options == "" {
+		return []string{}
+	}
+	return strings.Split(a.Options, optionSeparator)
+}
+
+// NewAttribute creates an attribute, options must not contain the
+// separator.
+func NewAttribute(name string, kind AttributeKind, options []string, required bool) Attribute {
+	return Attribute{
+		Name:     name,
+		Kind:     kind,
+		Options:  strings.Join(options, optionSeparator),
+		Required: required,
+	}
+}
+
+// ValidOption reports whether option can be stored in an enum attribute.
+func ValidOption(option string) bool {
+	return strings.TrimSpace(option) != "" && !strings.Contains(option, optionSeparator)
 }
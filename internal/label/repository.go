@@ -1,6 +1,8 @@
 package label
 
 import (
+	"github.com/jinzhu/gorm"
+
 	"github.com/nkhang/pluto/internal/rediskey"
 	"github.com/nkhang/pluto/pkg/cache"
 	"github.com/nkhang/pluto/pkg/errors"
@@ -8,8 +10,13 @@ import (
 )
 
 type Repository interface {
+	Get(id uint64) (Label, error)
 	GetByProjectId(pID uint64) ([]Label, error)
-	CreateLabel(name, color string, projectID, toolID uint64) error
+	CreateLabel(name, color string, projectID, parentID, toolID uint64, attributes []Attribute) (Label, error)
+	ApplyTemplate(projectID uint64, t Template) ([]Label, error)
+	Update(id uint64, changes map[string]interface{}, attributes *[]Attribute) (Label, error)
+	Delete(l Label, remapTo uint64, remap func(tx *gorm.DB) error) error
+	Reorder(projectID uint64, ids []uint64) error
 }
 
 type repository struct {
@@ -24,6 +31,10 @@ func NewRepository(d DBRepository, c cache.Cache) *repository {
 	}
 }
 
+func (r *repository) Get(id uint64) (Label, error) {
+	return r.dbRepo.Get(id)
+}
+
 func (r *repository) GetByProjectId(pID uint64) ([]Label, error) {
 	var labels = make([]Label, 0)
 	k := rediskey.LabelsByProject(pID)
@@ -49,12 +60,79 @@ func (r *repository) GetByProjectId(pID uint64) ([]Label, error) {
 	}()
 	return labels, nil
 }
-func (r *repository) CreateLabel(name, color string, projectID, toolID uint64) error {
+func (r *repository) CreateLabel(name, color string, projectID, parentID, toolID uint64, attributes []Attribute) (Label, error) {
 	k := rediskey.LabelsByProject(projectID)
 	go func() {
 		if err := r.cacheRepo.Del(k); err != nil {
 			logger.Errorf("cannot invalidate all tools for project %d, error %s", projectID, err.Error())
 		}
 	}()
-	return r.dbRepo.CreateLabel(name, color, projectID, toolID)
+	return r.dbRepo.CreateLabel(name, color, projectID, parentID, toolID, attributes)
+}
+
+// ApplyTemplate creates the labels of template t in a project, leaving out
+// those whose name the project already uses, and returns the labels created.
+func (r *repository) ApplyTemplate(projectID uint64, t Template) ([]Label, error) {
+	existing, err := r.dbRepo.GetByProjectID(projectID)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		used   = make(map[string]bool, len(existing))
+		labels = make([]Label, 0, len(t.Labels))
+	)
+	for _, l := range existing {
+		used[l.Name] = true
+	}
+	for _, l := range t.Labels {
+		if used[l.Name] {
+			continue
+		}
+		used[l.Name] = true
+		labels = append(labels, Label{
+			Name:   l.Name,
+			Color:  l.Color,
+			ToolID: l.ToolID,
+		})
+	}
+	if len(labels) == 0 {
+		return labels, nil
+	}
+	created, err := r.dbRepo.BulkCreate(projectID, labels)
+	if err != nil {
+		return nil, err
+	}
+	r.invalidate(projectID)
+	return created, nil
+}
+
+func (r *repository) Update(id uint64, changes map[string]interface{}, attributes *[]Attribute) (Label, error) {
+	l, err := r.dbRepo.Update(id, changes, attributes)
+	if err != nil {
+		return Label{}, err
+	}
+	r.invalidate(l.ProjectID)
+	return l, nil
+}
+
+func (r *repository) Delete(l Label, remapTo uint64, remap func(tx *gorm.DB) error) error {
+	if err := r.dbRepo.Delete(l, remapTo, remap); err != nil {
+		return err
+	}
+	r.invalidate(l.ProjectID)
+	return nil
+}
+
+func (r *repository) Reorder(projectID uint64, ids []uint64) error {
+	if err := r.dbRepo.Reorder(projectID, ids); err != nil {
+		return err
+	}
+	r.invalidate(projectID)
+	return nil
+}
+
+func (r *repository) invalidate(projectID uint64) {
+	if err := r.cacheRepo.Del(rediskey.LabelsByProject(projectID)); err != nil {
+		logger.Errorf("[LABEL] - cannot invalidate labels of project %d. err %v", projectID, err)
+	}
 }
@@ -0,0 +1,62 @@
+package label
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/rediskey"
+	"github.com/nkhang/pluto/pkg/cache"
+	"github.com/nkhang/pluto/pkg/gorm"
+)
+
+type fakeDBRepo struct {
+	DBRepository
+	labels  []Label
+	created [][]Label
+}
+
+func (r *fakeDBRepo) GetByProjectID(projectID uint64) ([]Label, error) {
+	return r.labels, nil
+}
+
+func (r *fakeDBRepo) BulkCreate(projectID uint64, labels []Label) ([]Label, error) {
+	r.created = append(r.created, labels)
+	return labels, nil
+}
+
+type fakeCache struct {
+	cache.Cache
+	deleted []string
+}
+
+func (c *fakeCache) Del(keys ...string) error {
+	c.deleted = append(c.deleted, keys...)
+	return nil
+}
+
+func TestApplyTemplate(t *testing.T) {
+	db := &fakeDBRepo{labels: []Label{{Model: gorm.Model{ID: 1}, Name: "car"}}}
+	c := &fakeCache{}
+	r := NewRepository(db, c)
+	tmpl := Template{Labels: []TemplateLabel{
+		{Name: "car", Color: "#f00", ToolID: 1},
+		{Name: "truck", Color: "#0f0", ToolID: 1},
+		{Name: "person", Color: "#00f", ToolID: 2},
+	}}
+
+	created, err := r.ApplyTemplate(3, tmpl)
+	require.NoError(t, err)
+	require.Len(t, created, 2, "car is already in the project")
+	assert.Equal(t, "truck", created[0].Name)
+	assert.Equal(t, uint64(2), created[1].ToolID)
+	assert.Len(t, db.created, 1, "labels are created in one batch")
+	assert.Equal(t, []string{rediskey.LabelsByProject(3)}, c.deleted)
+
+	db.labels = append(db.labels, created...)
+	created, err = r.ApplyTemplate(3, tmpl)
+	require.NoError(t, err)
+	assert.Empty(t, created)
+	assert.Len(t, db.created, 1, "nothing left to create")
+}
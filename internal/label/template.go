@@ -0,0 +1,38 @@
+package label
+
+import (
+	"github.com/nkhang/pluto/internal/tool"
+	"github.com/nkhang/pluto/pkg/gorm"
+)
+
+const (
+	fieldWorkspaceID = "workspace_id"
+	fieldTemplateID  = "template_id"
+)
+
+// Template is a named set of labels of a workspace, from which the labels of
+// its projects are created.
+type Template struct {
+	gorm.Model
+	WorkspaceID uint64 `gorm:"index"`
+	Name        string
+	Labels      []TemplateLabel `gorm:"foreignkey:TemplateID"`
+}
+
+func (Template) TableName() string {
+	return "label_templates"
+}
+
+type TemplateLabel struct {
+	gorm.Model
+	TemplateID uint64 `gorm:"index"`
+	Name       string
+	Color      string
+	ToolID     uint64
+	Tool       tool.Tool
+	Position   int
+}
+
+func (TemplateLabel) TableName() string {
+	return "label_template_labels"
+}
@@ -0,0 +1,117 @@
+package label
+
+import (
+	"github.com/jinzhu/gorm"
+
+	"github.com/nkhang/pluto/internal/tool"
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+type TemplateRepository interface {
+	GetTemplate(id uint64) (Template, error)
+	GetTemplatesByWorkspace(workspaceID uint64) ([]Template, error)
+	CreateTemplate(workspaceID uint64, name string, labels []TemplateLabel) (Template, error)
+	UpdateTemplate(id uint64, name string, labels *[]TemplateLabel) (Template, error)
+	DeleteTemplate(id uint64) error
+}
+
+type templateRepository struct {
+	db *gorm.DB
+}
+
+func NewTemplateRepository(db *gorm.DB) *templateRepository {
+	return &templateRepository{db: db}
+}
+
+func (r *templateRepository) GetTemplate(id uint64) (Template, error) {
+	var t Template
+	result := r.db.Preload("Labels", withPosition).
+		Preload("Labels.Tool").
+		First(&t, id)
+	if result.RecordNotFound() {
+		return Template{}, errors.LabelTemplateNotFound.NewWithMessageF("template %d not found", id)
+	}
+	if err := result.Error; err != nil {
+		return Template{}, errors.LabelQueryError.Wrap(err, "template query error")
+	}
+	return t, nil
+}
+
+func (r *templateRepository) GetTemplatesByWorkspace(workspaceID uint64) ([]Template, error) {
+	templates := make([]Template, 0)
+	err := r.db.Preload("Labels", withPosition).
+		Preload("Labels.Tool").
+		Where(fieldWorkspaceID+" = ?", workspaceID).
+		Order("name").
+		Find(&templates).Error
+	if err != nil {
+		return nil, errors.LabelQueryError.Wrap(err, "template query error")
+	}
+	return templates, nil
+}
+
+func (r *templateRepository) CreateTemplate(workspaceID uint64, name string, labels []TemplateLabel) (Template, error) {
+	t := Template{
+		WorkspaceID: workspaceID,
+		Name:        name,
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&t).Error; err != nil {
+			return err
+		}
+		return createTemplateLabels(tx, t.ID, labels)
+	})
+	if err != nil {
+		return Template{}, errors.LabelTemplateCannotSave.Wrap(err, "cannot create template")
+	}
+	return r.GetTemplate(t.ID)
+}
+
+// UpdateTemplate renames a template when name is set, and replaces its
+// labels when labels is not nil.
+func (r *templateRepository) UpdateTemplate(id uint64, name string, labels *[]TemplateLabel) (Template, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if name != "" {
+			if err := tx.Model(&Template{}).Where("id = ?", id).Update("name", name).Error; err != nil {
+				return err
+			}
+		}
+		if labels == nil {
+			return nil
+		}
+		if err := tx.Where(fieldTemplateID+" = ?", id).Delete(&TemplateLabel{}).Error; err != nil {
+			return err
+		}
+		return createTemplateLabels(tx, id, *labels)
+	})
+	if err != nil {
+		return Template{}, errors.LabelTemplateCannotSave.Wrap(err, "cannot update template")
+	}
+	return r.GetTemplate(id)
+}
+
+func (r *templateRepository) DeleteTemplate(id uint64) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(fieldTemplateID+" = ?", id).Delete(&TemplateLabel{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Template{}, id).Error
+	})
+	if err != nil {
+		return errors.LabelTemplateCannotSave.WrapF(err, "cannot delete template %d", id)
+	}
+	return nil
+}
+
+func createTemplateLabels(tx *gorm.DB, templateID uint64, labels []TemplateLabel) error {
+	for i, l := range labels {
+		l.ID = 0
+		l.TemplateID = templateID
+		l.Position = i
+		l.Tool = tool.Tool{}
+		if err := tx.Create(&l).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
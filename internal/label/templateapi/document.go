@@ -0,0 +1,69 @@
+package templateapi
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+func (f Format) Valid() bool {
+	return f == FormatJSON || f == FormatYAML
+}
+
+// formatOf guesses the format of a file from its extension.
+func formatOf(filename string) Format {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	return FormatJSON
+}
+
+// Document is a template as exported. Tools are named instead of numbered
+// so that the document can be imported in another deployment.
+type Document struct {
+	Name   string          `json:"name" yaml:"name"`
+	Labels []DocumentLabel `json:"labels" yaml:"labels"`
+}
+
+type DocumentLabel struct {
+	Name  string `json:"name" yaml:"name"`
+	Color string `json:"color" yaml:"color"`
+	Tool  string `json:"tool" yaml:"tool"`
+}
+
+// Marshal writes d in format f.
+func Marshal(d Document, f Format) ([]byte, error) {
+	if f == FormatYAML {
+		return yaml.Marshal(d)
+	}
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// Unmarshal reads a document written in format f.
+func Unmarshal(b []byte, f Format) (Document, error) {
+	var (
+		d   Document
+		err error
+	)
+	if f == FormatYAML {
+		err = yaml.Unmarshal(b, &d)
+	} else {
+		err = json.Unmarshal(b, &d)
+	}
+	if err != nil {
+		return Document{}, errors.LabelTemplateInvalid.WrapF(err, "cannot read %s template", f)
+	}
+	return d, nil
+}
@@ -0,0 +1,82 @@
+package templateapi
+
+import (
+	"mime/multipart"
+
+	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/tool/toolapi"
+)
+
+type TemplateLabelObject struct {
+	Name   string `json:"name" binding:"required"`
+	Color  string `json:"color" binding:"required"`
+	ToolID uint64 `json:"tool" binding:"required"`
+}
+
+type CreateTemplateRequest struct {
+	Name   string                `json:"name" binding:"required"`
+	Labels []TemplateLabelObject `json:"labels" binding:"required"`
+}
+
+// UpdateTemplateRequest changes the fields that are set. Labels, when set,
+// replace all the labels of the template.
+type UpdateTemplateRequest struct {
+	Name   *string                `json:"name"`
+	Labels *[]TemplateLabelObject `json:"labels"`
+}
+
+type ExportRequest struct {
+	Format Format `form:"format"`
+}
+
+// ImportRequest takes a file written by export. Without a format, it is
+// guessed from the file extension.
+type ImportRequest struct {
+	Format Format                `form:"format"`
+	File   *multipart.FileHeader `form:"file" binding:"required"`
+}
+
+type ApplyTemplateRequest struct {
+	TemplateID uint64 `json:"template_id" form:"template_id" binding:"required"`
+}
+
+// ApplyTemplateResponse counts the labels created in the project and those
+// left out because the project already had a label of the same name.
+type ApplyTemplateResponse struct {
+	Created int `json:"created"`
+	Skipped int `json:"skipped"`
+}
+
+type TemplateLabelResponse struct {
+	ID       uint64               `json:"id"`
+	Name     string               `json:"name"`
+	Color    string               `json:"color"`
+	Tool     toolapi.ToolResponse `json:"tool"`
+	Position int                  `json:"position"`
+}
+
+type TemplateResponse struct {
+	ID          uint64                  `json:"id"`
+	WorkspaceID uint64                  `json:"workspace_id"`
+	Name        string                  `json:"name"`
+	Labels      []TemplateLabelResponse `json:"labels"`
+}
+
+func ToTemplateResponse(t label.Template) TemplateResponse {
+	labels := make([]TemplateLabelResponse, len(t.Labels))
+	for i, l := range t.Labels {
+		labels[i] = TemplateLabelResponse{
+			ID:       l.ID,
+			Name:     l.Name,
+			Color:    l.Color,
+			Tool:     toolapi.ToToolResponse(l.Tool),
+			Position: l.Position,
+		}
+	}
+	return TemplateResponse{
+		ID:          t.ID,
+		WorkspaceID: t.WorkspaceID,
+		Name:        t.Name,
+		Labels:      labels,
+	}
+}
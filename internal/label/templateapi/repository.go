@@ -0,0 +1,224 @@
+package templateapi
+
+import (
+	"strings"
+
+	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/tool"
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+type Repository interface {
+	GetByWorkspace(workspaceID uint64) ([]TemplateResponse, error)
+	Get(workspaceID, id uint64) (TemplateResponse, error)
+	Create(workspaceID uint64, r CreateTemplateRequest) (TemplateResponse, error)
+	Update(workspaceID, id uint64, r UpdateTemplateRequest) (TemplateResponse, error)
+	Delete(workspaceID, id uint64) error
+	Export(workspaceID, id uint64) (Document, error)
+	Import(workspaceID uint64, d Document) (TemplateResponse, error)
+	Apply(workspaceID, projectID, id uint64) (ApplyTemplateResponse, error)
+}
+
+type repository struct {
+	templateRepo label.TemplateRepository
+	labelRepo    label.Repository
+	toolRepo     tool.Repository
+}
+
+func NewRepository(t label.TemplateRepository, l label.Repository, tools tool.Repository) *repository {
+	return &repository{
+		templateRepo: t,
+		labelRepo:    l,
+		toolRepo:     tools,
+	}
+}
+
+func (r *repository) GetByWorkspace(workspaceID uint64) ([]TemplateResponse, error) {
+	templates, err := r.templateRepo.GetTemplatesByWorkspace(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]TemplateResponse, len(templates))
+	for i := range templates {
+		responses[i] = ToTemplateResponse(templates[i])
+	}
+	return responses, nil
+}
+
+func (r *repository) Get(workspaceID, id uint64) (TemplateResponse, error) {
+	t, err := r.templateOf(workspaceID, id)
+	if err != nil {
+		return TemplateResponse{}, err
+	}
+	return ToTemplateResponse(t), nil
+}
+
+func (r *repository) Create(workspaceID uint64, request CreateTemplateRequest) (TemplateResponse, error) {
+	name, err := templateName(request.Name)
+	if err != nil {
+		return TemplateResponse{}, err
+	}
+	labels, err := r.toTemplateLabels(request.Labels)
+	if err != nil {
+		return TemplateResponse{}, err
+	}
+	t, err := r.templateRepo.CreateTemplate(workspaceID, name, labels)
+	if err != nil {
+		return TemplateResponse{}, err
+	}
+	return ToTemplateResponse(t), nil
+}
+
+func (r *repository) Update(workspaceID, id uint64, request UpdateTemplateRequest) (TemplateResponse, error) {
+	if _, err := r.templateOf(workspaceID, id); err != nil {
+		return TemplateResponse{}, err
+	}
+	var name string
+	if request.Name != nil {
+		n, err := templateName(*request.Name)
+		if err != nil {
+			return TemplateResponse{}, err
+		}
+		name = n
+	}
+	var labels *[]label.TemplateLabel
+	if request.Labels != nil {
+		l, err := r.toTemplateLabels(*request.Labels)
+		if err != nil {
+			return TemplateResponse{}, err
+		}
+		labels = &l
+	}
+	t, err := r.templateRepo.UpdateTemplate(id, name, labels)
+	if err != nil {
+		return TemplateResponse{}, err
+	}
+	return ToTemplateResponse(t), nil
+}
+
+func (r *repository) Delete(workspaceID, id uint64) error {
+	if _, err := r.templateOf(workspaceID, id); err != nil {
+		return err
+	}
+	return r.templateRepo.DeleteTemplate(id)
+}
+
+func (r *repository) Export(workspaceID, id uint64) (Document, error) {
+	t, err := r.templateOf(workspaceID, id)
+	if err != nil {
+		return Document{}, err
+	}
+	d := Document{
+		Name:   t.Name,
+		Labels: make([]DocumentLabel, len(t.Labels)),
+	}
+	for i, l := range t.Labels {
+		d.Labels[i] = DocumentLabel{
+			Name:  l.Name,
+			Color: l.Color,
+			Tool:  l.Tool.Name,
+		}
+	}
+	return d, nil
+}
+
+// Import creates a template from an exported document, finding its tools
+// by name.
+func (r *repository) Import(workspaceID uint64, d Document) (TemplateResponse, error) {
+	tools, err := r.toolRepo.GetAll()
+	if err != nil {
+		return TemplateResponse{}, err
+	}
+	var byName = make(map[string]uint64, len(tools))
+	for _, t := range tools {
+		byName[strings.ToLower(t.Name)] = t.ID
+	}
+	objects := make([]TemplateLabelObject, len(d.Labels))
+	for i, l := range d.Labels {
+		id, ok := byName[strings.ToLower(strings.TrimSpace(l.Tool))]
+		if !ok {
+			return TemplateResponse{}, errors.LabelTemplateInvalid.NewWithMessageF("tool %q of label %s does not exist", l.Tool, l.Name)
+		}
+		objects[i] = TemplateLabelObject{
+			Name:   l.Name,
+			Color:  l.Color,
+			ToolID: id,
+		}
+	}
+	return r.Create(workspaceID, CreateTemplateRequest{Name: d.Name, Labels: objects})
+}
+
+// Apply creates the labels of a template in a project of the same
+// workspace. Labels whose name the project already uses are skipped.
+func (r *repository) Apply(workspaceID, projectID, id uint64) (ApplyTemplateResponse, error) {
+	t, err := r.templateOf(workspaceID, id)
+	if err != nil {
+		return ApplyTemplateResponse{}, err
+	}
+	created, err := r.labelRepo.ApplyTemplate(projectID, t)
+	if err != nil {
+		return ApplyTemplateResponse{}, err
+	}
+	return ApplyTemplateResponse{
+		Created: len(created),
+		Skipped: len(t.Labels) - len(created),
+	}, nil
+}
+
+func (r *repository) templateOf(workspaceID, id uint64) (label.Template, error) {
+	t, err := r.templateRepo.GetTemplate(id)
+	if err != nil {
+		return label.Template{}, err
+	}
+	if t.WorkspaceID != workspaceID {
+		return label.Template{}, errors.LabelTemplateNotFound.NewWithMessageF("template %d not found", id)
+	}
+	return t, nil
+}
+
+func templateName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.LabelTemplateInvalid.NewWithMessage("template name must not be empty")
+	}
+	return name, nil
+}
+
+// toTemplateLabels checks that the labels have unique names, a color and a
+// tool that exists.
+func (r *repository) toTemplateLabels(objects []TemplateLabelObject) ([]label.TemplateLabel, error) {
+	tools, err := r.toolRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	var known = make(map[uint64]bool, len(tools))
+	for _, t := range tools {
+		known[t.ID] = true
+	}
+	var (
+		labels = make([]label.TemplateLabel, len(objects))
+		names  = make(map[string]bool, len(objects))
+	)
+	for i, o := range objects {
+		name := strings.TrimSpace(o.Name)
+		if name == "" {
+			return nil, errors.LabelTemplateInvalid.NewWithMessage("label name must not be empty")
+		}
+		if names[name] {
+			return nil, errors.LabelTemplateInvalid.NewWithMessageF("label %s is given twice", name)
+		}
+		names[name] = true
+		if o.Color == "" {
+			return nil, errors.LabelTemplateInvalid.NewWithMessageF("label %s has no color", name)
+		}
+		if !known[o.ToolID] {
+			return nil, errors.LabelTemplateInvalid.NewWithMessageF("tool %d of label %s does not exist", o.ToolID, name)
+		}
+		labels[i] = label.TemplateLabel{
+			Name:   name,
+			Color:  o.Color,
+			ToolID: o.ToolID,
+		}
+	}
+	return labels, nil
+}
@@ -0,0 +1,141 @@
+package templateapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/tool"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/gorm"
+)
+
+type fakeTemplateRepo struct {
+	label.TemplateRepository
+	templates map[uint64]label.Template
+}
+
+func (r *fakeTemplateRepo) GetTemplate(id uint64) (label.Template, error) {
+	t, ok := r.templates[id]
+	if !ok {
+		return label.Template{}, errors.LabelTemplateNotFound.NewWithMessage("template not found")
+	}
+	return t, nil
+}
+
+func (r *fakeTemplateRepo) CreateTemplate(workspaceID uint64, name string, labels []label.TemplateLabel) (label.Template, error) {
+	t := label.Template{
+		Model:       gorm.Model{ID: uint64(len(r.templates) + 1)},
+		WorkspaceID: workspaceID,
+		Name:        name,
+		Labels:      labels,
+	}
+	for i := range t.Labels {
+		t.Labels[i].Tool = tool.Tool{Model: gorm.Model{ID: labels[i].ToolID}, Name: toolNames[labels[i].ToolID]}
+	}
+	r.templates[t.ID] = t
+	return t, nil
+}
+
+var toolNames = map[uint64]string{1: "Rectangle", 2: "Polygon"}
+
+type fakeToolRepo struct {
+	tool.Repository
+}
+
+func (fakeToolRepo) GetAll() ([]tool.Tool, error) {
+	var tools []tool.Tool
+	for id, name := range toolNames {
+		tools = append(tools, tool.Tool{Model: gorm.Model{ID: id}, Name: name})
+	}
+	return tools, nil
+}
+
+type fakeLabelRepo struct {
+	label.Repository
+	applied map[uint64]label.Template
+}
+
+func (r *fakeLabelRepo) ApplyTemplate(projectID uint64, t label.Template) ([]label.Label, error) {
+	r.applied[projectID] = t
+	return make([]label.Label, len(t.Labels)-1), nil
+}
+
+func newTestRepository() (*repository, *fakeLabelRepo) {
+	labels := &fakeLabelRepo{applied: make(map[uint64]label.Template)}
+	templates := &fakeTemplateRepo{templates: make(map[uint64]label.Template)}
+	return NewRepository(templates, labels, fakeToolRepo{}), labels
+}
+
+func TestCreateTemplate(t *testing.T) {
+	r, _ := newTestRepository()
+
+	for _, req := range []CreateTemplateRequest{
+		{Name: " ", Labels: []TemplateLabelObject{{Name: "car", Color: "#f00", ToolID: 1}}},
+		{Name: "traffic", Labels: []TemplateLabelObject{{Name: "", Color: "#f00", ToolID: 1}}},
+		{Name: "traffic", Labels: []TemplateLabelObject{{Name: "car", ToolID: 1}}},
+		{Name: "traffic", Labels: []TemplateLabelObject{{Name: "car", Color: "#f00", ToolID: 9}}},
+		{Name: "traffic", Labels: []TemplateLabelObject{{Name: "car", Color: "#f00", ToolID: 1}, {Name: "car ", Color: "#0f0", ToolID: 2}}},
+	} {
+		_, err := r.Create(4, req)
+		assert.Equal(t, errors.LabelTemplateInvalid, errors.Type(err), "request %v", req)
+	}
+
+	resp, err := r.Create(4, CreateTemplateRequest{Name: " traffic ", Labels: []TemplateLabelObject{{Name: "car", Color: "#f00", ToolID: 1}}})
+	require.NoError(t, err)
+	assert.Equal(t, "traffic", resp.Name)
+	_, err = r.Get(5, resp.ID)
+	assert.Equal(t, errors.LabelTemplateNotFound, errors.Type(err), "templates of other workspaces are hidden")
+}
+
+func TestExportImport(t *testing.T) {
+	r, _ := newTestRepository()
+	created, err := r.Create(4, CreateTemplateRequest{Name: "traffic", Labels: []TemplateLabelObject{
+		{Name: "car", Color: "#f00", ToolID: 1},
+		{Name: "road", Color: "#888", ToolID: 2},
+	}})
+	require.NoError(t, err)
+
+	for _, f := range []Format{FormatJSON, FormatYAML} {
+		d, err := r.Export(4, created.ID)
+		require.NoError(t, err)
+		b, err := Marshal(d, f)
+		require.NoError(t, err)
+		read, err := Unmarshal(b, f)
+		require.NoError(t, err)
+		assert.Equal(t, d, read, "format %s", f)
+
+		imported, err := r.Import(7, read)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(7), imported.WorkspaceID)
+		require.Len(t, imported.Labels, 2)
+		assert.Equal(t, "Polygon", imported.Labels[1].Tool.Name)
+	}
+
+	_, err = r.Import(7, Document{Name: "x", Labels: []DocumentLabel{{Name: "car", Color: "#f00", Tool: "brush"}}})
+	assert.Equal(t, errors.LabelTemplateInvalid, errors.Type(err))
+	_, err = Unmarshal([]byte("name: [x"), FormatYAML)
+	assert.Equal(t, errors.LabelTemplateInvalid, errors.Type(err))
+	assert.Equal(t, FormatYAML, formatOf("labels.YML"))
+	assert.Equal(t, FormatJSON, formatOf("labels.json"))
+}
+
+func TestApply(t *testing.T) {
+	r, labels := newTestRepository()
+	created, err := r.Create(4, CreateTemplateRequest{Name: "traffic", Labels: []TemplateLabelObject{
+		{Name: "car", Color: "#f00", ToolID: 1},
+		{Name: "road", Color: "#888", ToolID: 2},
+	}})
+	require.NoError(t, err)
+
+	_, err = r.Apply(5, 11, created.ID)
+	assert.Equal(t, errors.LabelTemplateNotFound, errors.Type(err))
+	assert.Empty(t, labels.applied)
+
+	resp, err := r.Apply(4, 11, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ApplyTemplateResponse{Created: 1, Skipped: 1}, resp)
+	assert.Equal(t, "traffic", labels.applied[11].Name)
+}
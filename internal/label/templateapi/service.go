@@ -0,0 +1,246 @@
+package templateapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+
+	"github.com/nkhang/pluto/internal/authz"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/ginwrapper"
+	"github.com/nkhang/pluto/pkg/logger"
+	"github.com/nkhang/pluto/pkg/pgin"
+)
+
+const fieldTemplateID = "templateId"
+
+type service struct {
+	repository Repository
+	authorizer authz.Authorizer
+}
+
+// NewService serves the templates of a workspace.
+func NewService(r Repository, authorizer authz.Authorizer) *service {
+	return &service{
+		repository: r,
+		authorizer: authorizer,
+	}
+}
+
+func (s *service) Register(router gin.IRouter) {
+	router.GET("", ginwrapper.Wrap(s.getByWorkspace))
+	router.POST("", s.authorizer.Require(authz.WorkspaceAdmin), ginwrapper.Wrap(s.create))
+	router.POST("/import", s.authorizer.Require(authz.WorkspaceAdmin), ginwrapper.Wrap(s.importTemplate))
+	router.GET("/:"+fieldTemplateID, ginwrapper.Wrap(s.get))
+	router.GET("/:"+fieldTemplateID+"/export", s.export)
+	router.PUT("/:"+fieldTemplateID, s.authorizer.Require(authz.WorkspaceAdmin), ginwrapper.Wrap(s.update))
+	router.DELETE("/:"+fieldTemplateID, s.authorizer.Require(authz.WorkspaceAdmin), ginwrapper.Wrap(s.delete))
+}
+
+func (s *service) getByWorkspace(c *gin.Context) ginwrapper.Response {
+	workspaceID := uint64(c.GetInt64(pgin.FieldWorkspaceID))
+	responses, err := s.repository.GetByWorkspace(workspaceID)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  responses,
+	}
+}
+
+func (s *service) get(c *gin.Context) ginwrapper.Response {
+	workspaceID := uint64(c.GetInt64(pgin.FieldWorkspaceID))
+	id, err := templateIDOf(c)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	resp, err := s.repository.Get(workspaceID, id)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+func (s *service) create(c *gin.Context) ginwrapper.Response {
+	workspaceID := uint64(c.GetInt64(pgin.FieldWorkspaceID))
+	var req CreateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessageF("error binding request. error %v", err),
+		}
+	}
+	resp, err := s.repository.Create(workspaceID, req)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+func (s *service) update(c *gin.Context) ginwrapper.Response {
+	workspaceID := uint64(c.GetInt64(pgin.FieldWorkspaceID))
+	id, err := templateIDOf(c)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	var req UpdateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessageF("error binding request. error %v", err),
+		}
+	}
+	resp, err := s.repository.Update(workspaceID, id, req)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+func (s *service) delete(c *gin.Context) ginwrapper.Response {
+	workspaceID := uint64(c.GetInt64(pgin.FieldWorkspaceID))
+	id, err := templateIDOf(c)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	if err := s.repository.Delete(workspaceID, id); err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+	}
+}
+
+// export sends the template as a JSON or YAML file.
+func (s *service) export(c *gin.Context) {
+	workspaceID := uint64(c.GetInt64(pgin.FieldWorkspaceID))
+	id, err := templateIDOf(c)
+	if err != nil {
+		ginwrapper.Report(c, http.StatusOK, err, nil)
+		return
+	}
+	var req ExportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ginwrapper.Report(c, http.StatusOK, errors.BadRequest.NewWithMessage("cannot bind export request"), nil)
+		return
+	}
+	if req.Format == "" {
+		req.Format = FormatJSON
+	}
+	if !req.Format.Valid() {
+		err := errors.BadRequest.NewWithMessageF("format must be %s or %s", FormatJSON, FormatYAML)
+		ginwrapper.Report(c, http.StatusOK, err, nil)
+		return
+	}
+	d, err := s.repository.Export(workspaceID, id)
+	if err != nil {
+		ginwrapper.Report(c, http.StatusOK, err, nil)
+		return
+	}
+	b, err := Marshal(d, req.Format)
+	if err != nil {
+		logger.Errorf("[TEMPLATE] - cannot write template %d as %s. err %v", id, req.Format, err)
+		ginwrapper.Report(c, http.StatusOK, errors.LabelTemplateInvalid.Wrap(err, "cannot write template"), nil)
+		return
+	}
+	contentType := "application/json"
+	if req.Format == FormatYAML {
+		contentType = "application/x-yaml"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"template-%d.%s\"", id, req.Format))
+	c.Data(http.StatusOK, contentType, b)
+}
+
+func (s *service) importTemplate(c *gin.Context) ginwrapper.Response {
+	workspaceID := uint64(c.GetInt64(pgin.FieldWorkspaceID))
+	var req ImportRequest
+	if err := c.ShouldBind(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("file is required"),
+		}
+	}
+	if req.Format == "" {
+		req.Format = formatOf(req.File.Filename)
+	}
+	if !req.Format.Valid() {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessageF("format must be %s or %s", FormatJSON, FormatYAML),
+		}
+	}
+	f, err := req.File.Open()
+	if err != nil {
+		return ginwrapper.Response{Error: errors.BadRequest.Wrap(err, "cannot open file")}
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return ginwrapper.Response{Error: errors.BadRequest.Wrap(err, "cannot read file")}
+	}
+	d, err := Unmarshal(b, req.Format)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	resp, err := s.repository.Import(workspaceID, d)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+func templateIDOf(c *gin.Context) (uint64, error) {
+	id, err := cast.ToUint64E(c.Param(fieldTemplateID))
+	if err != nil || id == 0 {
+		return 0, errors.BadRequest.NewWithMessageF("invalid template id %s", c.Param(fieldTemplateID))
+	}
+	return id, nil
+}
+
+type projectService struct {
+	repository Repository
+	authorizer authz.Authorizer
+}
+
+// NewProjectService serves applying templates to the labels of a project.
+func NewProjectService(r Repository, authorizer authz.Authorizer) *projectService {
+	return &projectService{
+		repository: r,
+		authorizer: authorizer,
+	}
+}
+
+func (s *projectService) Register(router gin.IRouter) {
+	router.POST("/apply", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.apply))
+}
+
+func (s *projectService) apply(c *gin.Context) ginwrapper.Response {
+	workspaceID := uint64(c.GetInt64(pgin.FieldWorkspaceID))
+	projectID := uint64(c.GetInt64(pgin.FieldProjectID))
+	var req ApplyTemplateRequest
+	if err := c.ShouldBind(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("template_id is required"),
+		}
+	}
+	resp, err := s.repository.Apply(workspaceID, projectID, req.TemplateID)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
@@ -0,0 +1,48 @@
+package label
+
+// Subtree returns the IDs of the label id and of all the labels below it.
+func Subtree(labels []Label, id uint64) []uint64 {
+	children := childrenOf(labels)
+	var (
+		ids   = []uint64{id}
+		seen  = map[uint64]bool{id: true}
+		queue = []uint64{id}
+	)
+	for len(queue) != 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, child := range children[parent] {
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			ids = append(ids, child)
+			queue = append(queue, child)
+		}
+	}
+	return ids
+}
+
+// CreatesCycle reports whether making parentID the parent of the label id
+// would put the label below itself.
+func CreatesCycle(labels []Label, id, parentID uint64) bool {
+	if parentID == 0 {
+		return false
+	}
+	for _, below := range Subtree(labels, id) {
+		if below == parentID {
+			return true
+		}
+	}
+	return false
+}
+
+func childrenOf(labels []Label) map[uint64][]uint64 {
+	var children = make(map[uint64][]uint64)
+	for _, l := range labels {
+		if l.ParentID != 0 {
+			children[l.ParentID] = append(children[l.ParentID], l.ID)
+		}
+	}
+	return children
+}
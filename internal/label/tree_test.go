@@ -0,0 +1,32 @@
+package label
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nkhang/pluto/pkg/gorm"
+)
+
+func newLabel(id, parentID uint64) Label {
+	return Label{Model: gorm.Model{ID: id}, ParentID: parentID}
+}
+
+func TestSubtree(t *testing.T) {
+	// vehicle(1) -> car(2) -> sedan(3), vehicle(1) -> truck(4), animal(5)
+	labels := []Label{newLabel(1, 0), newLabel(2, 1), newLabel(3, 2), newLabel(4, 1), newLabel(5, 0)}
+
+	assert.ElementsMatch(t, []uint64{1, 2, 3, 4}, Subtree(labels, 1))
+	assert.Equal(t, []uint64{3}, Subtree(labels, 3))
+	assert.Equal(t, []uint64{5}, Subtree(labels, 5))
+}
+
+func TestCreatesCycle(t *testing.T) {
+	labels := []Label{newLabel(1, 0), newLabel(2, 1), newLabel(3, 2), newLabel(5, 0)}
+
+	assert.True(t, CreatesCycle(labels, 1, 3), "a label cannot go below its descendant")
+	assert.True(t, CreatesCycle(labels, 2, 2), "a label cannot be its own parent")
+	assert.False(t, CreatesCycle(labels, 3, 1))
+	assert.False(t, CreatesCycle(labels, 1, 5))
+	assert.False(t, CreatesCycle(labels, 1, 0))
+}
@@ -0,0 +1,86 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+type DBRepository interface {
+	Get(id uint64) (Event, error)
+	Claim(now time.Time, lease time.Duration, limit int) ([]Event, error)
+	GetByStatus(status Status, offset, limit int) ([]Event, int, error)
+	Update(id uint64, changes map[string]interface{}) (Event, error)
+}
+
+type dbRepository struct {
+	db *gorm.DB
+}
+
+func NewDBRepository(db *gorm.DB) *dbRepository {
+	return &dbRepository{db: db}
+}
+
+func (r *dbRepository) Get(id uint64) (e Event, err error) {
+	db := r.db.First(&e, id)
+	if db.RecordNotFound() {
+		return Event{}, errors.OutboxEventNotFound.NewWithMessageF("outbox event %d not found", id)
+	}
+	if err = db.Error; err != nil {
+		return Event{}, errors.OutboxCannotGet.Wrap(err, "cannot get outbox event")
+	}
+	return
+}
+
+// Claim returns up to limit events due at now, oldest first, and holds them
+// for lease by moving their next attempt past it. The rows are locked while
+// they are claimed and the rows locked by another claim are skipped, so
+// dispatchers running side by side never get the same event. An event whose
+// dispatcher dies is claimed again once its lease is over.
+func (r *dbRepository) Claim(now time.Time, lease time.Duration, limit int) (events []Event, err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+			Where("status = ? AND next_attempt_at <= ?", Pending, now).
+			Order("id").
+			Limit(limit).
+			Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+		ids := make([]uint64, len(events))
+		for i := range events {
+			ids[i] = events[i].ID
+		}
+		return tx.Model(&Event{}).
+			Where("id IN (?)", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, errors.OutboxCannotGet.Wrap(err, "cannot claim due outbox events")
+	}
+	return
+}
+
+func (r *dbRepository) GetByStatus(status Status, offset, limit int) (events []Event, total int, err error) {
+	db := r.db.Model(&Event{}).Where("status = ?", status).Count(&total)
+	if offset != 0 || limit != 0 {
+		db = db.Offset(offset).Limit(limit)
+	}
+	err = db.Order("id desc").Find(&events).Error
+	if err != nil {
+		return nil, 0, errors.OutboxCannotGet.Wrap(err, "cannot get outbox events")
+	}
+	return
+}
+
+func (r *dbRepository) Update(id uint64, changes map[string]interface{}) (Event, error) {
+	var e Event
+	e.ID = id
+	err := r.db.Model(&e).Update(changes).First(&e, id).Error
+	if err != nil {
+		return Event{}, errors.OutboxCannotUpdate.Wrap(err, "cannot update outbox event")
+	}
+	return e, nil
+}
@@ -0,0 +1,54 @@
+package outbox
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	gomocket "github.com/Selvatico/go-mocket"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockDB(t *testing.T) *gorm.DB {
+	gomocket.Catcher.Register()
+	conn, err := sql.Open(gomocket.DriverName, "connection_string")
+	require.NoError(t, err)
+	db, err := gorm.Open("mysql", conn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+		gomocket.Catcher.Reset()
+	})
+	return db
+}
+
+func TestClaim(t *testing.T) {
+	db := newMockDB(t)
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	selected := gomocket.Catcher.NewMock().
+		WithQuery("LIMIT 2 FOR UPDATE SKIP LOCKED").
+		WithReply([]map[string]interface{}{
+			{"id": 4, "kind": string(KindUpdateProject), "status": int(Pending)},
+			{"id": 7, "kind": string(KindUpdateTask), "status": int(Pending)},
+		})
+	leased := gomocket.Catcher.NewMock().
+		WithQuery("UPDATE `outbox_events` SET `next_attempt_at` = ?")
+	events, err := NewDBRepository(db).Claim(now, time.Minute, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, uint64(4), events[0].ID)
+	assert.Equal(t, KindUpdateTask, events[1].Kind)
+	assert.True(t, selected.Triggered, "due events are locked while claimed")
+	assert.True(t, leased.Triggered, "claimed events are leased")
+}
+
+func TestClaimNothingDue(t *testing.T) {
+	db := newMockDB(t)
+	leased := gomocket.Catcher.NewMock().WithQuery("UPDATE `outbox_events`")
+	events, err := NewDBRepository(db).Claim(time.Now(), time.Minute, 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	assert.False(t, leased.Triggered)
+}
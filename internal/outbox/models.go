@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/nkhang/pluto/pkg/gorm"
+)
+
+type Status int32
+type Kind string
+
+const (
+	Pending Status = iota
+	Delivered
+	Dead
+)
+
+const (
+	KindCreateTask    Kind = "task.create"
+	KindUpdateTask    Kind = "task.update"
+	KindDeleteTask    Kind = "task.delete"
+	KindUpdateProject Kind = "project.update"
+	KindUpdateDataset Kind = "dataset.update"
+	KindUpdateLabels  Kind = "labels.update"
+	KindRemapLabel    Kind = "label.remap"
+)
+
+// Event is a message to the annotation server. It is written in the same
+// transaction as the change it describes and delivered later by the
+// dispatcher.
+type Event struct {
+	gorm.Model
+	Kind          Kind
+	Payload       string `gorm:"type:text"`
+	Status        Status
+	Attempts      int
+	NextAttemptAt time.Time `sql:"index"`
+	LastError     string    `gorm:"type:text"`
+}
+
+func (Event) TableName() string {
+	return "outbox_events"
+}
+
+type TaskPayload struct {
+	ProjectID uint64   `json:"project_id"`
+	DatasetID uint64   `json:"dataset_id"`
+	TaskIDs   []uint64 `json:"task_ids"`
+}
+
+// DeleteTaskPayload removes tasks from the annotation server. Tasks merged
+// into task MergedInto handed their details over to it, DetailIDs maps the
+// details that got a new ID on the way to that ID.
+type DeleteTaskPayload struct {
+	ProjectID  uint64            `json:"project_id"`
+	TaskIDs    []uint64          `json:"task_ids"`
+	MergedInto uint64            `json:"merged_into,omitempty"`
+	DetailIDs  map[uint64]uint64 `json:"detail_ids,omitempty"`
+}
+
+type ProjectPayload struct {
+	ProjectID uint64 `json:"project_id"`
+}
+
+type DatasetPayload struct {
+	DatasetID uint64 `json:"dataset_id"`
+}
+
+// RemapLabelPayload moves the objects of label From to label To.
+type RemapLabelPayload struct {
+	ProjectID uint64 `json:"project_id"`
+	From      uint64 `json:"from"`
+	To        uint64 `json:"to"`
+}
@@ -0,0 +1,29 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+// Enqueue writes an event with tx, so it is committed or rolled back
+// together with the change it describes.
+func Enqueue(tx *gorm.DB, kind Kind, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return errors.OutboxCannotCreate.Wrap(err, "cannot marshal outbox payload")
+	}
+	e := Event{
+		Kind:          kind,
+		Payload:       string(b),
+		Status:        Pending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := tx.Create(&e).Error; err != nil {
+		return errors.OutboxCannotCreate.Wrap(err, "cannot create outbox event")
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package outboxapi
+
+import (
+	"encoding/json"
+
+	"github.com/nkhang/pluto/internal/outbox"
+	"github.com/nkhang/pluto/pkg/util/clock"
+)
+
+const (
+	statusPending   = "pending"
+	statusDelivered = "delivered"
+	statusDead      = "dead"
+)
+
+var statusMap = map[string]outbox.Status{
+	statusPending:   outbox.Pending,
+	statusDelivered: outbox.Delivered,
+	statusDead:      outbox.Dead,
+}
+
+type GetEventsRequest struct {
+	Status   string `json:"status" form:"status"`
+	Page     int    `json:"page" form:"page"`
+	PageSize int    `json:"page_size" form:"page_size"`
+}
+
+type GetEventsResponse struct {
+	Total  int             `json:"total"`
+	Events []EventResponse `json:"events"`
+}
+
+type EventResponse struct {
+	ID            uint64          `json:"id"`
+	Kind          outbox.Kind     `json:"kind"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        outbox.Status   `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt int64           `json:"next_attempt_at"`
+	LastError     string          `json:"last_error"`
+	CreatedAt     int64           `json:"created_at"`
+}
+
+func ToEventResponse(e outbox.Event) EventResponse {
+	return EventResponse{
+		ID:            e.ID,
+		Kind:          e.Kind,
+		Payload:       json.RawMessage(e.Payload),
+		Status:        e.Status,
+		Attempts:      e.Attempts,
+		NextAttemptAt: clock.UnixMillisecondFromTime(e.NextAttemptAt),
+		LastError:     e.LastError,
+		CreatedAt:     clock.UnixMillisecondFromTime(e.CreatedAt),
+	}
+}
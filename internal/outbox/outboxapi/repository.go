@@ -0,0 +1,70 @@
+package outboxapi
+
+import (
+	"time"
+
+	"github.com/nkhang/pluto/internal/outbox"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/logger"
+	"github.com/nkhang/pluto/pkg/util/paging"
+)
+
+type Repository interface {
+	GetEvents(request GetEventsRequest) (GetEventsResponse, error)
+	Replay(id uint64) (EventResponse, error)
+}
+
+type repository struct {
+	repository outbox.DBRepository
+}
+
+func NewRepository(r outbox.DBRepository) *repository {
+	return &repository{repository: r}
+}
+
+// GetEvents lists the events of the requested status, dead ones by default.
+func (r *repository) GetEvents(request GetEventsRequest) (GetEventsResponse, error) {
+	if request.Status == "" {
+		request.Status = statusDead
+	}
+	status, ok := statusMap[request.Status]
+	if !ok {
+		return GetEventsResponse{}, errors.BadRequest.NewWithMessageF("status %s is not supported", request.Status)
+	}
+	offset, limit := paging.Parse(request.Page, request.PageSize)
+	events, total, err := r.repository.GetByStatus(status, offset, limit)
+	if err != nil {
+		return GetEventsResponse{}, err
+	}
+	responses := make([]EventResponse, len(events))
+	for i := range events {
+		responses[i] = ToEventResponse(events[i])
+	}
+	return GetEventsResponse{
+		Total:  total,
+		Events: responses,
+	}, nil
+}
+
+// Replay puts an undelivered event back in the queue with a fresh retry
+// budget.
+func (r *repository) Replay(id uint64) (EventResponse, error) {
+	e, err := r.repository.Get(id)
+	if err != nil {
+		return EventResponse{}, err
+	}
+	if e.Status == outbox.Delivered {
+		return EventResponse{}, errors.OutboxCannotUpdate.NewWithMessageF("event %d is already delivered", id)
+	}
+	e, err = r.repository.Update(id, map[string]interface{}{
+		"status":          outbox.Pending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+		"last_error":      "",
+	})
+	if err != nil {
+		return EventResponse{}, err
+	}
+	logger.Infof("[OUTBOX] - event %d (%s) queued for replay", e.ID, e.Kind)
+	return ToEventResponse(e), nil
+}
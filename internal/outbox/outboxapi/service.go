@@ -0,0 +1,64 @@
+package outboxapi
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/nkhang/pluto/internal/authz"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/ginwrapper"
+	"github.com/nkhang/pluto/pkg/util/idextractor"
+)
+
+const fieldEventID = "eventId"
+
+type service struct {
+	repository Repository
+	authorizer authz.Authorizer
+}
+
+func NewService(r Repository, authorizer authz.Authorizer) *service {
+	return &service{
+		repository: r,
+		authorizer: authorizer,
+	}
+}
+
+func (s *service) RegisterStandalone(router gin.IRouter) {
+	adminRouter := router.Group("", s.authorizer.RequireSystemAdmin())
+	{
+		adminRouter.GET("", ginwrapper.Wrap(s.getEvents))
+		adminRouter.POST("/:"+fieldEventID+"/replay", ginwrapper.Wrap(s.replay))
+	}
+}
+
+func (s *service) getEvents(c *gin.Context) ginwrapper.Response {
+	var req GetEventsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("error binding get events request"),
+		}
+	}
+	resp, err := s.repository.GetEvents(req)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+func (s *service) replay(c *gin.Context) ginwrapper.Response {
+	id, err := idextractor.ExtractUint64Param(c, fieldEventID)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	resp, err := s.repository.Replay(id)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
@@ -2,6 +2,7 @@ package project
 
 import (
 	"github.com/jinzhu/gorm"
+	"github.com/nkhang/pluto/internal/outbox"
 	"github.com/nkhang/pluto/pkg/logger"
 
 	"github.com/nkhang/pluto/pkg/errors"
@@ -13,8 +14,9 @@ type DBRepository interface {
 	GetProjectPermissions(pID uint64, role Role, offset, limit int) (perms []Permission, total int, err error)
 	GetUserPermissions(userID uint64, role Role, offset, limit int) ([]Permission, int, error)
 	GetPermission(userID, projectID uint64) (Permission, error)
-	CreateProject(wID uint64, title, desc, color, uid string) (Project, error)
+	CreateProject(wID uint64, title, desc, color, uid string, mode Mode) (Project, error)
 	CreatePermission(projectID, userID uint64, role Role) (Permission, error)
+	CreatePermissions(projectID uint64, perms []Permission) ([]Permission, error)
 	UpdatePermission(projectID, userID uint64, role Role) (Permission, error)
 	UpdateProject(ProjectID uint64, changes map[string]interface{}) (Project, error)
 	Delete(id uint64) error
@@ -52,7 +54,7 @@ func (r *dbRepository) GetByWorkspaceID(wID uint64) ([]Project, error) {
 	return projects, nil
 }
 
-func (r *dbRepository) CreateProject(wID uint64, title, desc, color, uid string) (Project, error) {
+func (r *dbRepository) CreateProject(wID uint64, title, desc, color, uid string, mode Mode) (Project, error) {
 	var p = Project{
 		WorkspaceID: wID,
 		Title:       title,
@@ -60,6 +62,7 @@ func (r *dbRepository) CreateProject(wID uint64, title, desc, color, uid string)
 		Dir:         uid,
 		Thumbnail:   defaultImage,
 		Color:       color,
+		Mode:        mode,
 	}
 	err := r.db.Create(&p).Error
 	if err != nil {
@@ -102,16 +105,29 @@ func (r *dbRepository) GetUserPermissions(userID uint64, role Role, offset, limi
 }
 
 func (r *dbRepository) CreatePermission(projectID, userID uint64, role Role) (Permission, error) {
-	perm := Permission{
-		ProjectID: projectID,
-		UserID:    userID,
-		Role:      role,
+	perms, err := r.CreatePermissions(projectID, []Permission{{UserID: userID, Role: role}})
+	if err != nil {
+		return Permission{}, err
 	}
-	err := r.db.Create(&perm).Error
+	return perms[0], nil
+}
+
+// CreatePermissions adds members to a project at once, so the annotation
+// server is told about the project a single time.
+func (r *dbRepository) CreatePermissions(projectID uint64, perms []Permission) ([]Permission, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range perms {
+			perms[i].ProjectID = projectID
+			if err := tx.Create(&perms[i]).Error; err != nil {
+				return err
+			}
+		}
+		return outbox.Enqueue(tx, outbox.KindUpdateProject, outbox.ProjectPayload{ProjectID: projectID})
+	})
 	if err != nil {
-		return perm, errors.ProjectPermissionCreatingError.Wrap(err, "cannot create project permission")
+		return nil, errors.ProjectPermissionCreatingError.Wrap(err, "cannot create project permission")
 	}
-	return perm, nil
+	return perms, nil
 }
 
 func (r *dbRepository) UpdatePermission(projectID, userID uint64, role Role) (Permission, error) {
@@ -148,7 +164,12 @@ func (r *dbRepository) GetPermission(userID, projectID uint64) (Permission, erro
 func (r *dbRepository) UpdateProject(ProjectID uint64, changes map[string]interface{}) (Project, error) {
 	var project Project
 	project.ID = ProjectID
-	err := r.db.Model(&project).Update(changes).First(&project, ProjectID).Error
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&project).Update(changes).First(&project, ProjectID).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, outbox.KindUpdateProject, outbox.ProjectPayload{ProjectID: ProjectID})
+	})
 	if err != nil {
 		return Project{}, errors.ProjectCannotUpdate.Wrap(err, "cannot update project detail")
 	}
@@ -178,10 +199,14 @@ func (r *dbRepository) Delete(id uint64) error {
 }
 
 func (r *dbRepository) DeletePermission(userID, projectID uint64) error {
+	return deletePermission(r.db, userID, projectID)
+}
+
+func deletePermission(db *gorm.DB, userID, projectID uint64) error {
 	var perm Permission
 	perm.ProjectID = projectID
 	perm.UserID = userID
-	err := r.db.Model(&perm).Where(&perm).Delete(&perm).Error
+	err := db.Model(&perm).Where(&perm).Delete(&perm).Error
 	if err != nil {
 		return errors.ProjectPermissionCannotDelete.NewWithMessageF("cannot delete permission for user %d, project %d", userID, projectID)
 	}
@@ -18,6 +18,38 @@ const (
 	Admin
 )
 
+// DuplicatePolicy tells what happens to an image whose file is already in
+// a dataset of the project.
+type DuplicatePolicy string
+
+const (
+	DuplicateAllow  DuplicatePolicy = "allow"
+	DuplicateSkip   DuplicatePolicy = "skip"
+	DuplicateReject DuplicatePolicy = "reject"
+)
+
+func (p DuplicatePolicy) Valid() bool {
+	switch p {
+	case DuplicateAllow, DuplicateSkip, DuplicateReject:
+		return true
+	}
+	return false
+}
+
+// Mode tells how the images of a project are labeled. Annotation projects
+// draw shapes on the annotation server, classification projects only put
+// labels on whole images, which pluto stores itself.
+type Mode string
+
+const (
+	ModeAnnotation     Mode = "annotation"
+	ModeClassification Mode = "classification"
+)
+
+func (m Mode) Valid() bool {
+	return m == ModeAnnotation || m == ModeClassification
+}
+
 var defaultImage = "http://annotation.ml:9000/plutos3/placeholder.png"
 
 type Project struct {
@@ -29,6 +61,15 @@ type Project struct {
 	Color       string
 	Dir         string
 	Labels      []label.Label
+
+	DuplicatePolicy DuplicatePolicy `gorm:"type:varchar(16);default:'allow'"`
+	Mode            Mode            `gorm:"type:varchar(16);default:'annotation'"`
+}
+
+// Classifies reports whether the labels of p are put on whole images by
+// pluto instead of drawn on the annotation server.
+func (p Project) Classifies() bool {
+	return p.Mode == ModeClassification
 }
 
 type Permission struct {
@@ -1,6 +1,7 @@
 package projectapi
 
 import (
+	"github.com/nkhang/pluto/internal/project"
 	"github.com/nkhang/pluto/internal/workspace/workspaceapi"
 )
 
@@ -16,10 +17,15 @@ type GetProjectRequest struct {
 	Source   int `form:"src" binding:"required"`
 }
 
+// CreateProjectRequest creates a project, with the labels of a template of
+// the workspace when TemplateID is set. Mode defaults to annotation and
+// cannot change afterwards.
 type CreateProjectRequest struct {
-	Title       string `form:"title" json:"title"`
-	Description string `form:"description" json:"description"`
-	Color       string `form:"color" json:"color"`
+	Title       string       `form:"title" json:"title"`
+	Description string       `form:"description" json:"description"`
+	Color       string       `form:"color" json:"color"`
+	TemplateID  uint64       `form:"template_id" json:"template_id"`
+	Mode        project.Mode `form:"mode" json:"mode"`
 }
 
 type ProjectResponse struct {
@@ -29,6 +35,8 @@ type ProjectResponse struct {
 	Admin           uint64                               `json:"admin"`
 	ProjectManagers []uint64                             `json:"project_managers"`
 	Workspace       workspaceapi.WorkspaceDetailResponse `json:"workspace"`
+	DuplicatePolicy project.DuplicatePolicy              `json:"duplicate_policy"`
+	Mode            project.Mode                         `json:"mode"`
 }
 
 type ProjectBaseResponse struct {
@@ -45,6 +53,7 @@ type GetProjectResponse struct {
 }
 
 type UpdateProjectRequest struct {
-	Title       string `form:"title" json:"title,omitempty"`
-	Description string `form:"description" json:"description,omitempty"`
+	Title           string                  `form:"title" json:"title,omitempty"`
+	Description     string                  `form:"description" json:"description,omitempty"`
+	DuplicatePolicy project.DuplicatePolicy `form:"duplicate_policy" json:"duplicate_policy,omitempty"`
 }
@@ -22,6 +22,12 @@ type PermissionObject struct {
 	Role      project.Role `json:"role"`
 }
 
+// DeletePermissionRequest optionally names the member who takes over the
+// tasks of the removed user. The tasks are deleted when it is empty.
+type DeletePermissionRequest struct {
+	ReassignTo uint64 `form:"reassign_to" json:"reassign_to"`
+}
+
 type UpdatePermissionRequest struct {
 	UserID uint64       `form:"user_id" json:"user_id" binding:"required"`
 	Role   project.Role `form:"role" json:"role" binding:"required"`
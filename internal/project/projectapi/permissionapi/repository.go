@@ -3,9 +3,7 @@ package permissionapi
 import (
 	"github.com/nkhang/pluto/internal/project"
 	"github.com/nkhang/pluto/internal/project/projectapi"
-	"github.com/nkhang/pluto/pkg/annotation"
 	"github.com/nkhang/pluto/pkg/errors"
-	"github.com/nkhang/pluto/pkg/logger"
 	"github.com/nkhang/pluto/pkg/util/clock"
 )
 
@@ -13,25 +11,23 @@ type Repository interface {
 	Create(projectID uint64, req CreatePermRequest) (projectapi.ProjectResponse, error)
 	GetList(projectID uint64) (PermissionResponse, error)
 	Update(projectID uint64, req UpdatePermissionRequest) (PermissionObject, error)
-	Delete(projectID, userID uint64) error
+	Delete(projectID, userID uint64, req DeletePermissionRequest) error
 }
 
 type repository struct {
-	repository        project.Repository
-	projectRepo       projectapi.Repository
-	annotationService annotation.Service
+	repository  project.Repository
+	projectRepo projectapi.Repository
 }
 
-func NewProjectPermissionAPIRepository(r project.Repository, p projectapi.Repository, ann annotation.Service) *repository {
+func NewProjectPermissionAPIRepository(r project.Repository, p projectapi.Repository) *repository {
 	return &repository{
-		repository:        r,
-		projectRepo:       p,
-		annotationService: ann,
+		repository:  r,
+		projectRepo: p,
 	}
 }
 
 func (r *repository) Create(projectID uint64, req CreatePermRequest) (resp projectapi.ProjectResponse, err error) {
-	var errs = make([]error, 0)
+	var perms = make([]project.Permission, 0, len(req.Members))
 	for _, p := range req.Members {
 		_, err := r.repository.GetPermission(p.UserID, projectID)
 		if err == nil {
@@ -40,18 +36,12 @@ func (r *repository) Create(projectID uint64, req CreatePermRequest) (resp proje
 		if p.Role == project.Admin { //role Admin
 			continue
 		}
-		_, err = r.repository.CreatePermission(projectID, p.UserID, p.Role)
-		if err != nil {
-			errs = append(errs, err)
-		}
-	}
-	if len(errs) != 0 {
-		err = errors.ProjectPermissionCreatingError.NewWithMessageF("error creating %d permissions", len(errs))
-		return
+		perms = append(perms, project.Permission{UserID: p.UserID, Role: p.Role})
 	}
-	err = r.annotationService.UpdateProject(projectID)
-	if err != nil {
-		logger.Errorf("from project: push project to annotation server failed %v", err)
+	if len(perms) != 0 {
+		if _, err = r.repository.CreatePermissions(projectID, perms); err != nil {
+			return
+		}
 	}
 	resp, err = r.projectRepo.GetByID(projectID)
 	return
@@ -91,6 +81,9 @@ func convertPermissionObject(perm project.Permission) PermissionObject {
 	}
 }
 
-func (r *repository) Delete(projectID, userID uint64) error {
+func (r *repository) Delete(projectID, userID uint64, req DeletePermissionRequest) error {
+	if req.ReassignTo != 0 {
+		return r.repository.DeletePermissionAndReassign(userID, projectID, req.ReassignTo)
+	}
 	return r.repository.DeletePermission(userID, projectID)
 }
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/internal/project"
 	"github.com/nkhang/pluto/internal/project/projectapi"
 	"github.com/nkhang/pluto/pkg/errors"
@@ -14,24 +15,26 @@ import (
 type service struct {
 	repository  Repository
 	projectRepo project.Repository
+	authorizer  authz.Authorizer
 }
 
 const (
 	FieldUserID = "userId"
 )
 
-func NewService(r Repository, pr project.Repository) *service {
+func NewService(r Repository, pr project.Repository, authorizer authz.Authorizer) *service {
 	return &service{
 		repository:  r,
 		projectRepo: pr,
+		authorizer:  authorizer,
 	}
 }
 
 func (s *service) Register(router gin.IRouter) {
-	router.POST("", ginwrapper.Wrap(s.createPerm))
+	router.POST("", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.createPerm))
 	router.GET("", ginwrapper.Wrap(s.getPermissions))
-	router.PUT("", ginwrapper.Wrap(s.updatePermission))
-	router.DELETE("/:"+FieldUserID, s.verifyPermission(), ginwrapper.Wrap(s.delete))
+	router.PUT("", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.updatePermission))
+	router.DELETE("/:"+FieldUserID, s.authorizer.Require(authz.ProjectManager), s.verifyPermission(), ginwrapper.Wrap(s.delete))
 }
 
 func (s *service) getPermissions(c *gin.Context) ginwrapper.Response {
@@ -96,7 +99,13 @@ func (s *service) delete(c *gin.Context) ginwrapper.Response {
 			Error: err,
 		}
 	}
-	err = s.repository.Delete(projectID, userID)
+	var req DeletePermissionRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("cannot bind params"),
+		}
+	}
+	err = s.repository.Delete(projectID, userID, req)
 	if err != nil {
 		return ginwrapper.Response{
 			Error: err,
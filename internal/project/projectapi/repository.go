@@ -3,8 +3,7 @@ package projectapi
 import (
 	"encoding/json"
 
-	"github.com/nkhang/pluto/pkg/annotation"
-
+	"github.com/nkhang/pluto/internal/label/templateapi"
 	"github.com/nkhang/pluto/internal/workspace/workspaceapi"
 
 	"github.com/nkhang/pluto/internal/dataset"
@@ -25,18 +24,18 @@ type Repository interface {
 }
 
 type repository struct {
-	repository        project.Repository
-	datasetRepo       dataset.Repository
-	workspaceRepo     workspaceapi.Repository
-	annotationService annotation.Service
+	repository    project.Repository
+	datasetRepo   dataset.Repository
+	workspaceRepo workspaceapi.Repository
+	templateRepo  templateapi.Repository
 }
 
-func NewRepository(r project.Repository, dr dataset.Repository, wr workspaceapi.Repository, ann annotation.Service) *repository {
+func NewRepository(r project.Repository, dr dataset.Repository, wr workspaceapi.Repository, tr templateapi.Repository) *repository {
 	return &repository{
-		repository:        r,
-		datasetRepo:       dr,
-		workspaceRepo:     wr,
-		annotationService: ann,
+		repository:    r,
+		datasetRepo:   dr,
+		workspaceRepo: wr,
+		templateRepo:  tr,
 	}
 }
 
@@ -100,11 +99,32 @@ func (r *repository) GetForWorkspace(workspaceID uint64, userID uint64, paging p
 	}, nil
 }
 
+// Create creates a project with its creator as admin. When a template is
+// given, the project is deleted again if its labels cannot be created.
 func (r *repository) Create(workspaceID, creator uint64, p CreateProjectRequest) (ProjectResponse, error) {
-	prj, err := r.repository.CreateProject(workspaceID, p.Title, p.Description, p.Color)
+	if p.Mode == "" {
+		p.Mode = project.ModeAnnotation
+	}
+	if !p.Mode.Valid() {
+		return ProjectResponse{}, errors.ProjectModeInvalid.NewWithMessageF("mode %s is not one of %s and %s", p.Mode, project.ModeAnnotation, project.ModeClassification)
+	}
+	if p.TemplateID != 0 {
+		if _, err := r.templateRepo.Get(workspaceID, p.TemplateID); err != nil {
+			return ProjectResponse{}, err
+		}
+	}
+	prj, err := r.repository.CreateProject(workspaceID, p.Title, p.Description, p.Color, p.Mode)
 	if err != nil {
 		return ProjectResponse{}, err
 	}
+	if p.TemplateID != 0 {
+		if _, err := r.templateRepo.Apply(workspaceID, prj.ID, p.TemplateID); err != nil {
+			if err := r.repository.Delete(prj.ID); err != nil {
+				logger.Errorf("[PROJECT] - cannot delete project %d after failing to apply template %d. err %v", prj.ID, p.TemplateID, err)
+			}
+			return ProjectResponse{}, err
+		}
+	}
 	_, err = r.repository.CreatePermission(prj.ID, creator, project.Admin)
 	if err != nil {
 		logger.Errorf("error create admin permission for user %d to project %d workspace %d", creator, prj.ID, workspaceID)
@@ -113,16 +133,15 @@ func (r *repository) Create(workspaceID, creator uint64, p CreateProjectRequest)
 }
 
 func (r *repository) UpdateProject(id uint64, request UpdateProjectRequest) (ProjectResponse, error) {
+	if request.DuplicatePolicy != "" && !request.DuplicatePolicy.Valid() {
+		return ProjectResponse{}, errors.ProjectDuplicatePolicyInvalid.NewWithMessageF("duplicate policy %s is not one of allow, skip and reject", request.DuplicatePolicy)
+	}
 	var changes = make(map[string]interface{})
 	b, _ := json.Marshal(&request)
 	_ = json.Unmarshal(b, &changes)
 	project, err := r.repository.UpdateProject(id, changes)
 	if err != nil {
-		return ProjectResponse{}, nil
-	}
-	err = r.annotationService.UpdateProject(id)
-	if err != nil {
-		logger.Errorf("from project: push project to annotation server failed %v", err)
+		return ProjectResponse{}, err
 	}
 	return r.ConvertResponse(project), nil
 }
@@ -169,6 +188,8 @@ func (r *repository) ConvertResponse(p project.Project) ProjectResponse {
 		Workspace:       w,
 		Admin:           admin,
 		ProjectManagers: pm,
+		DuplicatePolicy: p.DuplicatePolicy,
+		Mode:            p.Mode,
 	}
 }
 
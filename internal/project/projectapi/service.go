@@ -3,6 +3,7 @@ package projectapi
 import (
 	"net/http"
 
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/internal/workspace/workspaceapi"
 
 	"github.com/nkhang/pluto/pkg/util/paging"
@@ -26,13 +27,15 @@ type service struct {
 	datasetRouter    pgin.Router
 	labelRouter      pgin.Router
 	statsRouter      pgin.Router
+	imageRouter      pgin.Router
+	authorizer       authz.Authorizer
 }
 
 const (
-	FieldProjectID = "projectId"
+	FieldProjectID = pgin.FieldProjectID
 )
 
-func NewService(r Repository, projectRepo project.Repository, permissionRouter, taskRouter, datasetRouter, labelRouter, statsRouter pgin.Router) *service {
+func NewService(r Repository, projectRepo project.Repository, permissionRouter, taskRouter, datasetRouter, labelRouter, statsRouter, imageRouter pgin.Router, authorizer authz.Authorizer) *service {
 	return &service{
 		repository:       r,
 		projectRepo:      projectRepo,
@@ -41,23 +44,26 @@ func NewService(r Repository, projectRepo project.Repository, permissionRouter,
 		taskRouter:       taskRouter,
 		labelRouter:      labelRouter,
 		statsRouter:      statsRouter,
+		imageRouter:      imageRouter,
+		authorizer:       authorizer,
 	}
 }
 
 func (s *service) Register(router gin.IRouter) {
 	router.POST("", ginwrapper.Wrap(s.create))
 	router.GET("", ginwrapper.Wrap(s.getForWorkspace))
-	detailRouter := router.Group("/:"+FieldProjectID, s.verifyProjectIDMdw())
+	detailRouter := router.Group("/:"+FieldProjectID, s.verifyProjectIDMdw(), s.authorizer.Require(authz.ProjectMember))
 	{
 		detailRouter.GET("", ginwrapper.Wrap(s.get))
-		detailRouter.PUT("", ginwrapper.Wrap(s.update))
-		detailRouter.DELETE("", ginwrapper.Wrap(s.delete))
+		detailRouter.PUT("", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.update))
+		detailRouter.DELETE("", s.authorizer.Require(authz.ProjectAdmin), ginwrapper.Wrap(s.delete))
 	}
 	s.permissionRouter.Register(detailRouter.Group("/perms"))
 	s.taskRouter.Register(detailRouter.Group("/tasks"))
 	s.datasetRouter.Register(detailRouter.Group("/datasets"))
 	s.labelRouter.Register(detailRouter.Group("/labels"))
 	s.statsRouter.Register(detailRouter.Group("/stats"))
+	s.imageRouter.Register(detailRouter)
 }
 
 func (s *service) RegisterStandalone(router gin.IRouter) {
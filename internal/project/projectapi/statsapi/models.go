@@ -8,9 +8,18 @@ type GetLabelStatsRequest struct {
 	LabelID uint64 `form:"label_id" json:"label_id"`
 }
 
+// GetLabelStatsResponse counts the objects of a label together with those
+// of the labels below it. Children breaks the counts down by direct child.
 type GetLabelStatsResponse struct {
-	TotalObjects int         `json:"total_objects"`
-	Donut        []DonutPart `json:"donut"`
+	TotalObjects int               `json:"total_objects"`
+	Donut        []DonutPart       `json:"donut"`
+	Children     []ChildLabelStats `json:"children,omitempty"`
+}
+
+type ChildLabelStats struct {
+	LabelID      uint64 `json:"label_id"`
+	Name         string `json:"name"`
+	TotalObjects int    `json:"total_objects"`
 }
 
 type DonutPart struct {
@@ -38,6 +47,42 @@ type AnnotatedStatusPair struct {
 	Value int    `json:"value"`
 }
 
+type GetConsensusStatsRequest struct {
+	DatasetID uint64 `form:"dataset_id" json:"dataset_id"`
+}
+
+type ConsensusStatsResponse struct {
+	Groups []GroupConsensus `json:"groups"`
+}
+
+type GroupConsensus struct {
+	GroupID       uint64           `json:"group_id"`
+	DatasetID     uint64           `json:"dataset_id"`
+	Overlap       int              `json:"overlap"`
+	Images        int              `json:"images"`
+	FullyCovered  int              `json:"fully_covered"`
+	Disagreements int              `json:"disagreements"`
+	Details       []ImageConsensus `json:"details"`
+}
+
+type ImageConsensus struct {
+	ImageID      uint64 `json:"image_id"`
+	Coverage     int    `json:"coverage"`
+	Annotated    int    `json:"annotated"`
+	Disagreement bool   `json:"disagreement"`
+}
+
+// DeadlineStatsResponse counts the tasks of a project against their
+// deadlines. Tasks without any deadline are only counted in NoDeadline.
+type DeadlineStatsResponse struct {
+	Total      int `json:"total"`
+	NoDeadline int `json:"no_deadline"`
+	OnTrack    int `json:"on_track"`
+	Overdue    int `json:"overdue"`
+	OnTime     int `json:"on_time"`
+	Late       int `json:"late"`
+}
+
 type TaskStatusPair struct {
 	Name  string `json:"name"`
 	Value int    `json:"value"`
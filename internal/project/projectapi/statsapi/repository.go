@@ -1,12 +1,17 @@
 package statsapi
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/nkhang/pluto/internal/dataset"
 	"github.com/nkhang/pluto/internal/image"
 	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/project"
 	"github.com/nkhang/pluto/internal/task"
 	"github.com/nkhang/pluto/pkg/annotation"
 	"github.com/nkhang/pluto/pkg/logger"
+	"github.com/nkhang/pluto/pkg/util/clock"
 )
 
 type repository struct {
@@ -14,24 +19,30 @@ type repository struct {
 	taskRepo          task.Repository
 	imageRepo         image.Repository
 	labelRepo         label.Repository
+	projectRepo       project.Repository
 	annotationService annotation.Service
+	clock             clock.Clock
 }
 
-func NewRepository(d dataset.Repository, t task.Repository, i image.Repository, s annotation.Service, l label.Repository) *repository {
+func NewRepository(d dataset.Repository, t task.Repository, i image.Repository, s annotation.Service, l label.Repository, p project.Repository, c clock.Clock) *repository {
 	return &repository{
 		datasetRepo:       d,
+		projectRepo:       p,
 		taskRepo:          t,
 		imageRepo:         i,
 		annotationService: s,
 		labelRepo:         l,
+		clock:             c,
 	}
 }
 
 type Repository interface {
 	BuildReport(projectID, datasetID uint64) (DatasetStatsResponse, error)
 	BuildTaskReport(projectID uint64) ([]TaskStatusPair, error)
+	BuildDeadlineReport(projectID uint64) (DeadlineStatsResponse, error)
 	BuildMemberReport(projectID uint64) (MemberStatsResponse, error)
 	BuildLabelReport(projectID, labelID uint64) (GetLabelStatsResponse, error)
+	BuildConsensusReport(projectID, datasetID uint64) (ConsensusStatsResponse, error)
 }
 
 func (r *repository) BuildReport(projectId, datasetID uint64) (DatasetStatsResponse, error) {
@@ -139,6 +150,40 @@ func (r *repository) BuildTaskReport(projectID uint64) ([]TaskStatusPair, error)
 	}, nil
 }
 
+// BuildDeadlineReport sorts the tasks of a project by how they do against
+// their deadlines: open tasks are on track or overdue, done tasks were
+// finished on time or late.
+func (r *repository) BuildDeadlineReport(projectID uint64) (DeadlineStatsResponse, error) {
+	tasks, _, err := r.taskRepo.GetTasksByProject(projectID, task.Any, 0, 0)
+	if err != nil {
+		return DeadlineStatsResponse{}, err
+	}
+	var (
+		now  = r.clock.Now()
+		resp = DeadlineStatsResponse{Total: len(tasks)}
+	)
+	for _, t := range tasks {
+		if !t.HasDeadline() {
+			resp.NoDeadline++
+			continue
+		}
+		if t.Status == task.Done {
+			if t.Late() {
+				resp.Late++
+			} else {
+				resp.OnTime++
+			}
+			continue
+		}
+		if _, overdue := t.Overdue(now); overdue {
+			resp.Overdue++
+		} else {
+			resp.OnTrack++
+		}
+	}
+	return resp, nil
+}
+
 func (r *repository) BuildMemberReport(projectID uint64) (resp MemberStatsResponse, err error) {
 	labelerMap := make(map[uint64]interface{})
 	reviewerMap := make(map[uint64]interface{})
@@ -168,34 +213,86 @@ func (r *repository) BuildLabelReport(projectID, labelID uint64) (resp GetLabelS
 		}
 		images = append(images, imgs...)
 	}
+	p, err := r.projectRepo.Get(projectID)
+	if err != nil {
+		return GetLabelStatsResponse{}, err
+	}
+	if p.Classifies() {
+		details, err := r.taskRepo.GetClassifiedDetails(projectID, 0)
+		if err != nil {
+			return GetLabelStatsResponse{}, err
+		}
+		return r.buildClassificationReport(projectID, labelID, task.ImageLabels(details), len(images))
+	}
 	if labelID == 0 {
 		return r.buildAllLabelReport(projectID, images)
 	}
 	return r.buildLabelReport(projectID, labelID, images)
 }
 
-func (r *repository) buildAllLabelReport(projectID uint64, images []image.Image) (resp GetLabelStatsResponse, err error) {
-	stats, err := r.annotationService.GetImageStats(projectID)
+// buildClassificationReport builds the label report of a classification
+// project from the labels pluto stores on the images, where every label set
+// on an image counts as one object.
+func (r *repository) buildClassificationReport(projectID, labelID uint64, imageLabels map[uint64][]uint64, total int) (GetLabelStatsResponse, error) {
+	var counts = make(map[uint64]annotation.LabelStatsObject)
+	for _, ids := range imageLabels {
+		for _, id := range ids {
+			c := counts[id]
+			c.TotalObject++
+			c.TotalImage++
+			counts[id] = c
+		}
+	}
+	if labelID == 0 {
+		var objects int
+		for _, c := range counts {
+			objects += c.TotalObject
+		}
+		return labelStats(objects, len(imageLabels), total), nil
+	}
+	labels, err := r.labelRepo.GetByProjectId(projectID)
 	if err != nil {
-		return
+		return GetLabelStatsResponse{}, err
 	}
+	subtree := label.Subtree(labels, labelID)
+	resp := labelStats(sumObjects(subtree, counts), imagesWithAny(imageLabels, subtree), total)
+	resp.Children = childStats(labels, labelID, counts)
+	return resp, nil
+}
+
+func labelStats(objects, labeled, total int) GetLabelStatsResponse {
 	return GetLabelStatsResponse{
-		TotalObjects: stats.TotalObject,
+		TotalObjects: objects,
 		Donut: []DonutPart{
 			{
 				Name:  "Have the label",
-				Value: stats.TotalImage,
+				Value: labeled,
 			},
 			{
 				Name:  "Don't have the label",
-				Value: len(images) - stats.TotalImage,
+				Value: total - labeled,
 			},
 		},
-	}, nil
+	}
 }
 
-func (r *repository) buildLabelReport(projectID, labelID uint64, images []image.Image) (resp GetLabelStatsResponse, err error) {
-	stats, err := r.annotationService.GetLabelCount(projectID, labelID)
+func childStats(labels []label.Label, labelID uint64, counts map[uint64]annotation.LabelStatsObject) []ChildLabelStats {
+	var children []ChildLabelStats
+	for _, l := range labels {
+		if l.ParentID != labelID {
+			continue
+		}
+		children = append(children, ChildLabelStats{
+			LabelID:      l.ID,
+			Name:         l.Name,
+			TotalObjects: sumObjects(label.Subtree(labels, l.ID), counts),
+		})
+	}
+	return children
+}
+
+func (r *repository) buildAllLabelReport(projectID uint64, images []image.Image) (resp GetLabelStatsResponse, err error) {
+	stats, err := r.annotationService.GetImageStats(projectID)
 	if err != nil {
 		return
 	}
@@ -213,3 +310,191 @@ func (r *repository) buildLabelReport(projectID, labelID uint64, images []image.
 		},
 	}, nil
 }
+
+// buildLabelReport counts the objects of the label and of every label below
+// it, so that a parent class covers its subclasses. Direct children are
+// broken down with their own rolled up counts. The counts of all labels are
+// read at once from the stats of the project.
+func (r *repository) buildLabelReport(projectID, labelID uint64, images []image.Image) (resp GetLabelStatsResponse, err error) {
+	labels, err := r.labelRepo.GetByProjectId(projectID)
+	if err != nil {
+		return
+	}
+	stats, err := r.annotationService.GetImageStats(projectID)
+	if err != nil {
+		return
+	}
+	var (
+		subtree = label.Subtree(labels, labelID)
+		counts  = make(map[uint64]annotation.LabelStatsObject, len(stats.Labels))
+	)
+	for _, c := range stats.Labels {
+		counts[c.LabelID] = annotation.LabelStatsObject{TotalObject: c.TotalObject, TotalImage: c.TotalImage}
+	}
+	totalImages := counts[labelID].TotalImage
+	if len(subtree) > 1 {
+		totalImages, err = r.countLabeledImages(projectID, subtree)
+		if err != nil {
+			return
+		}
+	}
+	resp = labelStats(sumObjects(subtree, counts), totalImages, len(images))
+	resp.Children = childStats(labels, labelID, counts)
+	return resp, nil
+}
+
+// countLabeledImages counts the images having any of the labels ids. An
+// image with several of them is counted once, which takes the labels of
+// each image from the tasks of the project.
+func (r *repository) countLabeledImages(projectID uint64, ids []uint64) (int, error) {
+	tasks, _, err := r.taskRepo.GetTasksByProject(projectID, task.Any, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+	if len(tasks) == 0 {
+		return 0, nil
+	}
+	var taskIDs = make([]uint64, len(tasks))
+	for i, t := range tasks {
+		taskIDs[i] = t.ID
+	}
+	objs, err := r.annotationService.GetTaskLabels(taskIDs)
+	if err != nil {
+		return 0, err
+	}
+	var imageLabels = make(map[uint64][]uint64)
+	for _, o := range objs {
+		imageLabels[o.ImageID] = append(imageLabels[o.ImageID], o.Labels...)
+	}
+	return imagesWithAny(imageLabels, ids), nil
+}
+
+// imagesWithAny counts the images having any of the labels ids.
+func imagesWithAny(imageLabels map[uint64][]uint64, ids []uint64) int {
+	var wanted = make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var count int
+	for _, labels := range imageLabels {
+		for _, l := range labels {
+			if wanted[l] {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+func sumObjects(ids []uint64, counts map[uint64]annotation.LabelStatsObject) int {
+	var sum int
+	for _, id := range ids {
+		sum += counts[id].TotalObject
+	}
+	return sum
+}
+
+func (r *repository) BuildConsensusReport(projectID, datasetID uint64) (ConsensusStatsResponse, error) {
+	groups, err := r.taskRepo.GetGroupsByProject(projectID, datasetID)
+	if err != nil {
+		return ConsensusStatsResponse{}, err
+	}
+	p, err := r.projectRepo.Get(projectID)
+	if err != nil {
+		return ConsensusStatsResponse{}, err
+	}
+	var resp = ConsensusStatsResponse{
+		Groups: make([]GroupConsensus, 0, len(groups)),
+	}
+	for _, g := range groups {
+		report, err := r.buildGroupConsensus(g, p.Classifies())
+		if err != nil {
+			return ConsensusStatsResponse{}, err
+		}
+		resp.Groups = append(resp.Groups, report)
+	}
+	return resp, nil
+}
+
+// buildGroupConsensus compares the labels given to each image by the tasks of
+// a group. Those come from the annotation server, or from the details
+// themselves in classification projects.
+func (r *repository) buildGroupConsensus(g task.Group, classifies bool) (GroupConsensus, error) {
+	tasks, err := r.taskRepo.GetTasksByGroup(g.ID)
+	if err != nil {
+		return GroupConsensus{}, err
+	}
+	var (
+		taskIDs  = make([]uint64, len(tasks))
+		imageIDs = make([]uint64, 0)
+		images   = make(map[uint64]*ImageConsensus)
+		labels   = make(map[uint64][]string)
+	)
+	for i, t := range tasks {
+		taskIDs[i] = t.ID
+		details, _, err := r.taskRepo.GetTaskDetails(t.ID, task.AnyStatus, 0, 0)
+		if err != nil {
+			return GroupConsensus{}, err
+		}
+		for _, d := range details {
+			img, ok := images[d.ImageID]
+			if !ok {
+				img = &ImageConsensus{ImageID: d.ImageID}
+				images[d.ImageID] = img
+				imageIDs = append(imageIDs, d.ImageID)
+			}
+			img.Coverage++
+			if d.Status >= task.Labeled {
+				img.Annotated++
+				if classifies {
+					labels[d.ImageID] = append(labels[d.ImageID], labelSetKey(d.LabelIDs()))
+				}
+			}
+		}
+	}
+	if !classifies && len(taskIDs) != 0 {
+		objs, err := r.annotationService.GetTaskLabels(taskIDs)
+		if err != nil {
+			return GroupConsensus{}, err
+		}
+		for _, o := range objs {
+			labels[o.ImageID] = append(labels[o.ImageID], labelSetKey(o.Labels))
+		}
+	}
+	var resp = GroupConsensus{
+		GroupID:   g.ID,
+		DatasetID: g.DatasetID,
+		Overlap:   g.Overlap,
+		Images:    len(imageIDs),
+		Details:   make([]ImageConsensus, len(imageIDs)),
+	}
+	for i, id := range imageIDs {
+		img := images[id]
+		img.Disagreement = disagree(labels[id])
+		if img.Coverage >= g.Overlap {
+			resp.FullyCovered++
+		}
+		if img.Disagreement {
+			resp.Disagreements++
+		}
+		resp.Details[i] = *img
+	}
+	return resp, nil
+}
+
+func labelSetKey(labels []uint64) string {
+	sorted := make([]uint64, len(labels))
+	copy(sorted, labels)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return fmt.Sprint(sorted)
+}
+
+func disagree(keys []string) bool {
+	for i := 1; i < len(keys); i++ {
+		if keys[i] != keys[0] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package statsapi
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/task"
+	"github.com/nkhang/pluto/pkg/annotation"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/gorm"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Initlialize(false)
+	os.Exit(m.Run())
+}
+
+type fakeLabelRepo struct {
+	label.Repository
+}
+
+// GetByProjectId returns vehicle 1 with car 2 and truck 3 below it, and car
+// with sedan 4 below it.
+func (fakeLabelRepo) GetByProjectId(pID uint64) ([]label.Label, error) {
+	return []label.Label{
+		{Model: gorm.Model{ID: 1}, Name: "vehicle"},
+		{Model: gorm.Model{ID: 2}, Name: "car", ParentID: 1},
+		{Model: gorm.Model{ID: 3}, Name: "truck", ParentID: 1},
+		{Model: gorm.Model{ID: 4}, Name: "sedan", ParentID: 2},
+	}, nil
+}
+
+type fakeTaskRepo struct {
+	task.Repository
+}
+
+func (fakeTaskRepo) GetTasksByProject(projectID uint64, status task.Status, offset, limit int) ([]task.Task, int, error) {
+	return []task.Task{{Model: gorm.Model{ID: 7}}}, 1, nil
+}
+
+type fakeAnnotationService struct {
+	annotation.Service
+	stats   int
+	failing bool
+	taskIDs []uint64
+}
+
+func (s *fakeAnnotationService) GetImageStats(projectID uint64) (annotation.LabelStatsObject, error) {
+	s.stats++
+	return annotation.LabelStatsObject{
+		TotalObject: 9,
+		TotalImage:  4,
+		Labels: []annotation.LabelCountObject{
+			{LabelID: 2, TotalObject: 3, TotalImage: 2},
+			{LabelID: 3, TotalObject: 2, TotalImage: 2},
+			{LabelID: 4, TotalObject: 4, TotalImage: 1},
+		},
+	}, nil
+}
+
+func (s *fakeAnnotationService) GetTaskLabels(taskIDs []uint64) ([]annotation.ImageLabelsObject, error) {
+	if s.failing {
+		return nil, errors.AnnotationCannotGetFromServer.NewWithMessage("annotation server is down")
+	}
+	s.taskIDs = taskIDs
+	return []annotation.ImageLabelsObject{
+		{ImageID: 1, Labels: []uint64{2, 4}},
+		{ImageID: 2, Labels: []uint64{2}},
+		{ImageID: 3, Labels: []uint64{3}},
+		{ImageID: 4, Labels: []uint64{3}},
+	}, nil
+}
+
+func newTestRepository(s annotation.Service) *repository {
+	return NewRepository(nil, fakeTaskRepo{}, nil, s, fakeLabelRepo{}, nil, nil)
+}
+
+func TestBuildLabelReport(t *testing.T) {
+	s := &fakeAnnotationService{}
+	images := make([]image.Image, 6)
+	tests := []struct {
+		labelID  uint64
+		objects  int
+		labeled  int
+		children []ChildLabelStats
+	}{
+		{1, 9, 4, []ChildLabelStats{{LabelID: 2, Name: "car", TotalObjects: 7}, {LabelID: 3, Name: "truck", TotalObjects: 2}}},
+		{2, 7, 2, []ChildLabelStats{{LabelID: 4, Name: "sedan", TotalObjects: 4}}},
+		{4, 4, 1, nil},
+	}
+	for _, tt := range tests {
+		resp, err := newTestRepository(s).buildLabelReport(5, tt.labelID, images)
+		require.NoError(t, err)
+		assert.Equal(t, tt.objects, resp.TotalObjects, "label %d", tt.labelID)
+		assert.Equal(t, tt.labeled, resp.Donut[0].Value, "label %d", tt.labelID)
+		assert.Equal(t, len(images)-tt.labeled, resp.Donut[1].Value, "label %d", tt.labelID)
+		assert.Equal(t, tt.children, resp.Children, "label %d", tt.labelID)
+	}
+	assert.Equal(t, len(tests), s.stats, "the counts of all labels are read in one call per report")
+	assert.Equal(t, []uint64{7}, s.taskIDs)
+}
+
+func TestBuildLabelReportFails(t *testing.T) {
+	r := newTestRepository(&fakeAnnotationService{failing: true})
+	_, err := r.buildLabelReport(5, 1, make([]image.Image, 6))
+	assert.Equal(t, errors.AnnotationCannotGetFromServer, errors.Type(err), "images are not counted twice when their labels cannot be read")
+}
+
+func (fakeTaskRepo) GetTasksByGroup(groupID uint64) ([]task.Task, error) {
+	return []task.Task{{Model: gorm.Model{ID: 7}}, {Model: gorm.Model{ID: 8}}}, nil
+}
+
+func (fakeTaskRepo) GetTaskDetails(taskID uint64, status task.DetailStatus, currentID uint64, limit int) ([]task.Detail, int, error) {
+	return []task.Detail{{TaskID: taskID, ImageID: 1, Status: task.Labeled}}, 1, nil
+}
+
+func TestBuildGroupConsensus(t *testing.T) {
+	r := newTestRepository(&fakeAnnotationService{failing: true})
+	_, err := r.buildGroupConsensus(task.Group{Model: gorm.Model{ID: 3}, Overlap: 2}, false)
+	assert.Equal(t, errors.AnnotationCannotGetFromServer, errors.Type(err), "no consensus is reported when the labels cannot be read")
+
+	r = newTestRepository(&fakeAnnotationService{})
+	report, err := r.buildGroupConsensus(task.Group{Model: gorm.Model{ID: 3}, Overlap: 2}, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Images)
+}
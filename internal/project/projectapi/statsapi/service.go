@@ -19,8 +19,10 @@ func NewService(r Repository) *service {
 func (s *service) Register(router gin.IRouter) {
 	router.GET("/images", ginwrapper.Wrap(s.getImageStats))
 	router.GET("/overall", ginwrapper.Wrap(s.getTaskStats))
+	router.GET("/deadlines", ginwrapper.Wrap(s.getDeadlineStats))
 	router.GET("/members", ginwrapper.Wrap(s.getMemberStats))
 	router.GET("/labels", ginwrapper.Wrap(s.getStatsLabel))
+	router.GET("/consensus", ginwrapper.Wrap(s.getConsensusStats))
 }
 
 func (s *service) getImageStats(c *gin.Context) ginwrapper.Response {
@@ -60,6 +62,20 @@ func (s *service) getTaskStats(c *gin.Context) ginwrapper.Response {
 	}
 }
 
+func (s *service) getDeadlineStats(c *gin.Context) ginwrapper.Response {
+	projectID := uint64(c.GetInt64(projectapi.FieldProjectID))
+	stats, err := s.repository.BuildDeadlineReport(projectID)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  stats,
+	}
+}
+
 func (s *service) getMemberStats(c *gin.Context) ginwrapper.Response {
 	projectID := uint64(c.GetInt64(projectapi.FieldProjectID))
 	resp, err := s.repository.BuildMemberReport(projectID)
@@ -91,3 +107,21 @@ func (s *service) getStatsLabel(c *gin.Context) ginwrapper.Response {
 		Data:  stats,
 	}
 }
+
+func (s *service) getConsensusStats(c *gin.Context) ginwrapper.Response {
+	projectID := uint64(c.GetInt64(projectapi.FieldProjectID))
+	var req GetConsensusStatsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("error binding params"),
+		}
+	}
+	stats, err := s.repository.BuildConsensusReport(projectID, req.DatasetID)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  stats,
+	}
+}
@@ -1,6 +1,8 @@
 package project
 
 import (
+	"github.com/jinzhu/gorm"
+
 	"github.com/nkhang/pluto/internal/dataset"
 	"github.com/nkhang/pluto/internal/image"
 	"github.com/nkhang/pluto/internal/rediskey"
@@ -13,16 +15,18 @@ import (
 
 type Repository interface {
 	Get(pID uint64) (Project, error)
-	CreateProject(wID uint64, title, desc, color string) (Project, error)
+	CreateProject(wID uint64, title, desc, color string, mode Mode) (Project, error)
 	GetByWorkspaceID(id uint64) ([]Project, error)
 	GetUserPermissions(userID uint64, role Role, offset, limit int) ([]Permission, int, error)
 	GetProjectPermissions(pID uint64, role Role, offset, limit int) ([]Permission, int, error)
 	GetPermission(userID, projectID uint64) (Permission, error)
 	CreatePermission(projectID, userID uint64, role Role) (Permission, error)
+	CreatePermissions(projectID uint64, perms []Permission) ([]Permission, error)
 	UpdatePermission(projectID, userID uint64, role Role) (Permission, error)
 	UpdateProject(projectID uint64, changes map[string]interface{}) (Project, error)
 	Delete(id uint64) error
 	DeletePermission(userID, projectID uint64) error
+	DeletePermissionAndReassign(userID, projectID, successor uint64) error
 	DeleteByWorkspace(workspaceID uint64) error
 	PickThumbnail(projectID uint64) (err error)
 }
@@ -127,10 +131,10 @@ func (r *repository) GetUserPermissions(userID uint64, role Role, offset, limit
 	return
 }
 
-func (r *repository) CreateProject(wID uint64, title, desc, color string) (Project, error) {
+func (r *repository) CreateProject(wID uint64, title, desc, color string, mode Mode) (Project, error) {
 	r.invalidateProjectsByWorkspaceID(wID)
 	uid := uuid.NewV4().String()
-	return r.disk.CreateProject(wID, title, desc, color, uid)
+	return r.disk.CreateProject(wID, title, desc, color, uid, mode)
 }
 
 func (r *repository) GetProjectPermissions(pID uint64, role Role, offset, limit int) ([]Permission, int, error) {
@@ -177,6 +181,18 @@ func (r *repository) CreatePermission(projectID, userID uint64, role Role) (Perm
 	return r.disk.CreatePermission(projectID, userID, role)
 }
 
+func (r *repository) CreatePermissions(projectID uint64, perms []Permission) ([]Permission, error) {
+	r.invalidatePermissionForProject(projectID)
+	for _, p := range perms {
+		r.invalidatePermissionForUser(p.UserID)
+	}
+	_, err := r.Get(projectID)
+	if errors.Type(err) == errors.ProjectNotFound {
+		return nil, errors.ProjectNotFound.NewWithMessageF("project %d not existed", projectID)
+	}
+	return r.disk.CreatePermissions(projectID, perms)
+}
+
 func (r *repository) UpdatePermission(projectID, userID uint64, role Role) (Permission, error) {
 	perm, err := r.disk.UpdatePermission(projectID, userID, role)
 	if err != nil {
@@ -300,6 +316,27 @@ func (r *repository) DeletePermission(userID, projectID uint64) error {
 	return nil
 }
 
+// DeletePermissionAndReassign removes the user from the project and hands
+// their labeling and reviewing tasks over to successor instead of deleting
+// them. The user keeps both when either fails.
+func (r *repository) DeletePermissionAndReassign(userID, projectID, successor uint64) error {
+	if successor == userID {
+		return errors.ProjectPermissionCannotDelete.NewWithMessage("successor must be another member of the project")
+	}
+	if _, err := r.GetPermission(successor, projectID); err != nil {
+		return err
+	}
+	err := r.taskRepo.ReassignUser(projectID, userID, successor, func(tx *gorm.DB) error {
+		return deletePermission(tx, userID, projectID)
+	})
+	if err != nil {
+		return err
+	}
+	r.invalidatePermissionForUser(userID)
+	r.invalidatePermissionForProject(projectID)
+	return nil
+}
+
 func (r *repository) triggerDeleteTask(projectID, userID uint64) {
 	var tasks = make([]task.Task, 0)
 	t1, _, err := r.taskRepo.GetByProjectAndUser(projectID, userID, task.Labeler, 0, 0)
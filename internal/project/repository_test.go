@@ -0,0 +1,81 @@
+package project
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nkhang/pluto/internal/task"
+	"github.com/nkhang/pluto/pkg/cache"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Initlialize(false)
+	os.Exit(m.Run())
+}
+
+type fakeCache struct {
+	cache.Cache
+}
+
+func (c *fakeCache) Keys(pattern string) ([]string, error) { return nil, nil }
+
+func (c *fakeCache) Del(keys ...string) error { return nil }
+
+// fakeDBRepo knows the permissions of users 10 and 11 in project 6.
+type fakeDBRepo struct {
+	DBRepository
+}
+
+func (r *fakeDBRepo) GetPermission(userID, projectID uint64) (Permission, error) {
+	if projectID == 6 && (userID == 10 || userID == 11) {
+		return Permission{UserID: userID, ProjectID: projectID}, nil
+	}
+	return Permission{}, errors.ProjectPermissionNotFound.NewWithMessage("permission not found")
+}
+
+type fakeTaskRepo struct {
+	task.Repository
+	err        error
+	reassigned int
+}
+
+func (r *fakeTaskRepo) ReassignUser(projectID, userID, successor uint64, within func(tx *gorm.DB) error) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.reassigned++
+	return nil
+}
+
+func TestDeletePermissionAndReassign(t *testing.T) {
+	tests := []struct {
+		name      string
+		successor uint64
+		reassign  error
+		err       errors.ErrorType
+	}{
+		{"member", 11, nil, 0},
+		{"same user", 10, nil, errors.ProjectPermissionCannotDelete},
+		{"not a member", 12, nil, errors.ProjectPermissionNotFound},
+		{"successor cannot take the tasks", 11, errors.TaskCannotUpdate.NewWithMessage("conflict"), errors.TaskCannotUpdate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taskRepo := &fakeTaskRepo{err: tt.reassign}
+			r := NewRepository(&fakeDBRepo{}, &fakeCache{}, taskRepo, nil, nil)
+			err := r.DeletePermissionAndReassign(10, 6, tt.successor)
+			if tt.err == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, 1, taskRepo.reassigned)
+			} else {
+				assert.Equal(t, tt.err, errors.Type(err))
+				assert.Equal(t, 0, taskRepo.reassigned)
+			}
+		})
+	}
+}
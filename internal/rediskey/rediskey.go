@@ -20,6 +20,10 @@ func LabelsByProject(pID uint64) string {
 	return fmt.Sprintf("pluto:labels:project:id:%d", pID)
 }
 
+func LabelsByProjectAllKeys() string {
+	return "pluto:labels:project:id:*"
+}
+
 func ImageByID(id uint64) string {
 	return fmt.Sprintf("pluto:image:id:%d", id)
 }
@@ -87,6 +91,10 @@ func TaskByProject(projectID uint64, status uint32, offset, limit int) (specific
 	return
 }
 
+func DetailLease(taskID, detailID uint64) string {
+	return fmt.Sprintf("pluto:lease:task:%d:detail:%d", taskID, detailID)
+}
+
 func WorkspaceByID(id uint64) string {
 	return fmt.Sprintf("pluto:workspace:id:%d", id)
 }
@@ -0,0 +1,142 @@
+package storagegc
+
+import (
+	"time"
+
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/pkg/logger"
+	"github.com/nkhang/pluto/pkg/objectstorage"
+)
+
+// ImageStore is the part of the image repository the collector needs to
+// tell which stored objects are still in use.
+type ImageStore interface {
+	DeleteByDatasets(dIDs []uint64) ([]image.Image, error)
+	CountReferences(urls []string) (int, error)
+	GetAllURLs() ([]string, error)
+}
+
+// Collector deletes the stored originals and thumbnails that no image
+// refers to anymore. A cloned dataset shares the URLs of its source, so an
+// object is only deleted once its last image is gone.
+type Collector struct {
+	images  ImageStore
+	storage objectstorage.ObjectStorage
+}
+
+func NewCollector(images ImageStore, storage objectstorage.ObjectStorage) *Collector {
+	return &Collector{
+		images:  images,
+		storage: storage,
+	}
+}
+
+// ReleaseDatasets deletes the images of deleted datasets together with the
+// objects only they were using.
+func (c *Collector) ReleaseDatasets(dIDs []uint64) error {
+	images, err := c.images.DeleteByDatasets(dIDs)
+	if err != nil {
+		return err
+	}
+	logger.Infof("[STORAGE-GC] - releasing %d images of datasets %v", len(images), dIDs)
+	return c.Release(images)
+}
+
+// Release deletes the objects of images that were already deleted, unless
+// another image still refers to them. It goes on past failures and returns
+// the last one.
+func (c *Collector) Release(images []image.Image) error {
+	var (
+		seen    = make(map[string]bool)
+		lastErr error
+	)
+	for _, img := range images {
+		for _, u := range []string{img.URL, img.Thumbnail} {
+			if u == "" || seen[u] {
+				continue
+			}
+			seen[u] = true
+			if err := c.release(u); err != nil {
+				logger.Errorf("[STORAGE-GC] - cannot release object %s. err %v", u, err)
+				lastErr = err
+			}
+		}
+	}
+	return lastErr
+}
+
+func (c *Collector) release(u string) error {
+	collection, key, ok := c.storage.Locate(u)
+	if !ok {
+		logger.Infof("[STORAGE-GC] - object %s is not in our storage, skipping", u)
+		return nil
+	}
+	count, err := c.images.CountReferences(objectstorage.URLForms(c.storage, collection, key))
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return c.storage.Delete(collection, key)
+}
+
+// Orphan is a stored object that no image refers to.
+type Orphan struct {
+	Collection string
+	objectstorage.ObjectInfo
+}
+
+// location is where an object is stored. URLs are compared by location
+// since the same object may be stored under differently escaped URLs.
+type location struct {
+	collection string
+	key        string
+}
+
+// FindOrphans lists the objects of collections that no image refers to.
+// Objects modified after before are left out, they may belong to an upload
+// that is still running.
+func (c *Collector) FindOrphans(collections []string, before time.Time) ([]Orphan, error) {
+	urls, err := c.images.GetAllURLs()
+	if err != nil {
+		return nil, err
+	}
+	var used = make(map[location]bool, len(urls))
+	for _, u := range urls {
+		if collection, key, ok := c.storage.Locate(u); ok {
+			used[location{collection: collection, key: key}] = true
+		}
+	}
+	var orphans = make([]Orphan, 0)
+	for _, collection := range collections {
+		objects, err := c.storage.List(collection, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range objects {
+			if o.LastModified.After(before) || used[location{collection: collection, key: o.Key}] {
+				continue
+			}
+			orphans = append(orphans, Orphan{Collection: collection, ObjectInfo: o})
+		}
+	}
+	return orphans, nil
+}
+
+// Remove deletes orphans and returns how many of them are gone.
+func (c *Collector) Remove(orphans []Orphan) (int, error) {
+	var (
+		removed int
+		lastErr error
+	)
+	for _, o := range orphans {
+		if err := c.storage.Delete(o.Collection, o.Key); err != nil {
+			logger.Errorf("[STORAGE-GC] - cannot remove orphan %s/%s. err %v", o.Collection, o.Key, err)
+			lastErr = err
+			continue
+		}
+		removed++
+	}
+	return removed, lastErr
+}
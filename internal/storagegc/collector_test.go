@@ -0,0 +1,157 @@
+package storagegc
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/pkg/logger"
+	"github.com/nkhang/pluto/pkg/objectstorage"
+)
+
+func TestMain(m *testing.M) {
+	logger.Initlialize(false)
+	os.Exit(m.Run())
+}
+
+type fakeImageStore struct {
+	images []image.Image
+}
+
+func (f *fakeImageStore) DeleteByDatasets(dIDs []uint64) ([]image.Image, error) {
+	var deleted, kept []image.Image
+	for _, img := range f.images {
+		if containsID(dIDs, img.DatasetID) {
+			deleted = append(deleted, img)
+		} else {
+			kept = append(kept, img)
+		}
+	}
+	f.images = kept
+	return deleted, nil
+}
+
+func (f *fakeImageStore) CountReferences(urls []string) (int, error) {
+	var count int
+	for _, img := range f.images {
+		for _, u := range urls {
+			if img.URL == u || img.Thumbnail == u {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+func (f *fakeImageStore) GetAllURLs() ([]string, error) {
+	var urls []string
+	for _, img := range f.images {
+		urls = append(urls, img.URL, img.Thumbnail)
+	}
+	return urls, nil
+}
+
+func containsID(ids []uint64, id uint64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func put(t *testing.T, s objectstorage.ObjectStorage, collection, key string) string {
+	_, err := s.Put(collection, key, strings.NewReader(key), int64(len(key)), "image/jpeg")
+	require.NoError(t, err)
+	return s.URL(collection, key)
+}
+
+func exists(s objectstorage.ObjectStorage, collection, key string) bool {
+	_, err := s.Stat(collection, key)
+	return err == nil
+}
+
+func TestReleaseDatasets(t *testing.T) {
+	s := objectstorage.NewMemory()
+	shared := image.Image{DatasetID: 1, URL: put(t, s, "images", "shared.jpg"), Thumbnail: put(t, s, "thumbnails", "shared.jpg")}
+	own := image.Image{DatasetID: 1, URL: put(t, s, "images", "own.jpg"), Thumbnail: put(t, s, "thumbnails", "own.jpg")}
+	clone := shared
+	clone.DatasetID = 2
+	store := &fakeImageStore{images: []image.Image{shared, own, clone}}
+	c := NewCollector(store, s)
+
+	require.NoError(t, c.ReleaseDatasets([]uint64{1}))
+	assert.False(t, exists(s, "images", "own.jpg"))
+	assert.False(t, exists(s, "thumbnails", "own.jpg"))
+	assert.True(t, exists(s, "images", "shared.jpg"), "objects of a clone are kept")
+	assert.True(t, exists(s, "thumbnails", "shared.jpg"))
+
+	require.NoError(t, c.ReleaseDatasets([]uint64{2}))
+	assert.False(t, exists(s, "images", "shared.jpg"))
+	assert.False(t, exists(s, "thumbnails", "shared.jpg"))
+}
+
+func TestRelease_ForeignURL(t *testing.T) {
+	s := objectstorage.NewMemory()
+	c := NewCollector(&fakeImageStore{}, s)
+	assert.NoError(t, c.Release([]image.Image{{URL: "https://example.com/a.jpg"}}))
+}
+
+func TestFindOrphans(t *testing.T) {
+	s := objectstorage.NewMemory()
+	used := image.Image{DatasetID: 1, URL: put(t, s, "images", "used.jpg"), Thumbnail: put(t, s, "thumbnails", "used.jpg")}
+	put(t, s, "images", "orphan.jpg")
+	put(t, s, "thumbnails", "orphan.jpg")
+	c := NewCollector(&fakeImageStore{images: []image.Image{used}}, s)
+
+	orphans, err := c.FindOrphans([]string{"images", "thumbnails"}, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, orphans, "objects within the grace period are kept")
+
+	orphans, err = c.FindOrphans([]string{"images", "thumbnails"}, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	require.Len(t, orphans, 2)
+	assert.Equal(t, "images", orphans[0].Collection)
+	assert.Equal(t, "orphan.jpg", orphans[0].Key)
+	assert.Equal(t, "thumbnails", orphans[1].Collection)
+
+	removed, err := c.Remove(orphans)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.False(t, exists(s, "images", "orphan.jpg"))
+	assert.True(t, exists(s, "images", "used.jpg"))
+}
+
+// legacyURL is the URL images were stored under before keys were escaped
+// per segment, with the slashes of the key escaped as well.
+func legacyURL(t *testing.T, s objectstorage.ObjectStorage, collection, key string) string {
+	put(t, s, collection, key)
+	return s.URL(collection, "") + url.PathEscape(key)
+}
+
+func TestLegacyURLsAreInUse(t *testing.T) {
+	s := objectstorage.NewMemory()
+	old := image.Image{DatasetID: 1, URL: legacyURL(t, s, "images", "dir/3/a.png"), Thumbnail: legacyURL(t, s, "thumbnails", "dir/3/a.png")}
+	require.Contains(t, old.URL, "dir%2F3%2Fa.png")
+	// the clone refers to the same objects with keys escaped per segment.
+	clone := image.Image{DatasetID: 2, URL: s.URL("images", "") + "dir/3/a.png", Thumbnail: s.URL("thumbnails", "") + "dir/3/a.png"}
+	store := &fakeImageStore{images: []image.Image{old, clone}}
+	c := NewCollector(store, s)
+
+	orphans, err := c.FindOrphans([]string{"images", "thumbnails"}, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Empty(t, orphans, "objects of images with escaped keys are in use")
+
+	require.NoError(t, c.ReleaseDatasets([]uint64{1}))
+	assert.True(t, exists(s, "images", "dir/3/a.png"), "the clone still refers to the object")
+	require.NoError(t, c.ReleaseDatasets([]uint64{2}))
+	assert.False(t, exists(s, "images", "dir/3/a.png"))
+	assert.False(t, exists(s, "thumbnails", "dir/3/a.png"))
+}
@@ -0,0 +1,89 @@
+package task
+
+import (
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// labelSeparator joins the label IDs of a classified detail.
+const labelSeparator = ","
+
+// LabelIDs returns the labels set on d, in the order they were given.
+func (d Detail) LabelIDs() []uint64 {
+	if d.Labels == "" {
+		return nil
+	}
+	parts := strings.Split(d.Labels, labelSeparator)
+	var ids = make([]uint64, 0, len(parts))
+	for _, p := range parts {
+		if id := cast.ToUint64(p); id != 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// JoinLabelIDs is the inverse of Detail.LabelIDs.
+func JoinLabelIDs(ids []uint64) string {
+	var parts = make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = cast.ToString(id)
+	}
+	return strings.Join(parts, labelSeparator)
+}
+
+// remapLabelIDs replaces from by to in ids, dropping to when it is already
+// there. It reports whether from was found.
+func remapLabelIDs(ids []uint64, from, to uint64) ([]uint64, bool) {
+	var (
+		found  bool
+		result = make([]uint64, 0, len(ids))
+		seen   = make(map[uint64]bool, len(ids))
+	)
+	for _, id := range ids {
+		if id == from {
+			found = true
+			id = to
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result, found
+}
+
+// classificationRank orders the statuses of details classifying the same
+// image: approved labels win over labeled ones, which win over drafts.
+var classificationRank = map[DetailStatus]int{
+	Approved: 3,
+	Labeled:  2,
+	Draft:    1,
+}
+
+// ImageLabels resolves the labels of each image from the classified details
+// of a project. An image may be classified in several tasks, in which case
+// the detail with the highest status wins, then the latest one.
+func ImageLabels(details []Detail) map[uint64][]uint64 {
+	var best = make(map[uint64]Detail, len(details))
+	for _, d := range details {
+		if d.Labels == "" {
+			continue
+		}
+		current, ok := best[d.ImageID]
+		if ok {
+			rank, currentRank := classificationRank[d.Status], classificationRank[current.Status]
+			if rank < currentRank || (rank == currentRank && !d.UpdatedAt.After(current.UpdatedAt)) {
+				continue
+			}
+		}
+		best[d.ImageID] = d
+	}
+	var labels = make(map[uint64][]uint64, len(best))
+	for imageID, d := range best {
+		labels[imageID] = d.LabelIDs()
+	}
+	return labels
+}
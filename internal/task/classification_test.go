@@ -0,0 +1,53 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetailLabelIDs(t *testing.T) {
+	assert.Empty(t, Detail{}.LabelIDs())
+	ids := []uint64{12, 3, 40}
+	assert.Equal(t, "12,3,40", JoinLabelIDs(ids))
+	assert.Equal(t, ids, Detail{Labels: JoinLabelIDs(ids)}.LabelIDs())
+	assert.Equal(t, "", JoinLabelIDs(nil))
+}
+
+func TestRemapLabelIDs(t *testing.T) {
+	ids, found := remapLabelIDs([]uint64{1, 2}, 1, 3)
+	assert.True(t, found)
+	assert.Equal(t, []uint64{3, 2}, ids)
+	ids, found = remapLabelIDs([]uint64{1, 2}, 1, 2)
+	assert.True(t, found)
+	assert.Equal(t, []uint64{2}, ids, "a label already set is not given twice")
+	_, found = remapLabelIDs([]uint64{2}, 1, 3)
+	assert.False(t, found)
+}
+
+func TestImageLabels(t *testing.T) {
+	var (
+		earlier = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+		later   = earlier.Add(time.Hour)
+	)
+	detail := func(imageID uint64, status DetailStatus, labels string, updatedAt time.Time) Detail {
+		d := Detail{ImageID: imageID, Status: status, Labels: labels}
+		d.UpdatedAt = updatedAt
+		return d
+	}
+	labels := ImageLabels([]Detail{
+		detail(1, Approved, "1", earlier),
+		detail(1, Labeled, "2", later),
+		detail(2, Labeled, "1", earlier),
+		detail(2, Rejected, "3", later),
+		detail(3, Labeled, "1", earlier),
+		detail(3, Labeled, "2,4", later),
+		detail(4, Pending, "", later),
+	})
+	assert.Equal(t, map[uint64][]uint64{
+		1: {1},
+		2: {1},
+		3: {2, 4},
+	}, labels)
+}
@@ -1,7 +1,10 @@
 package task
 
 import (
+	"time"
+
 	"github.com/jinzhu/gorm"
+	"github.com/nkhang/pluto/internal/outbox"
 	"github.com/nkhang/pluto/pkg/errors"
 	gormbulk "github.com/t-tiger/gorm-bulk-insert/v2"
 )
@@ -11,13 +14,34 @@ type DBRepository interface {
 	GetTasksByProject(projectID uint64, status Status, offset, limit int) (tasks []Task, total int, err error)
 	GetTasksByUser(userID uint64, role Role, status Status, offset, limit int) (tasks []Task, total int, err error)
 	GetByProjectAndUser(projectID, userID uint64, role Role, offset, limit int) (tasks []Task, total int, err error)
-	CreateTask(title, description string, assigner, labeler, reviewer, projectID, datasetID uint64) (Task, error)
+	CreateTask(title, description string, assigner, labeler, reviewer, projectID, datasetID, groupID uint64, priority int, deadline Deadline, images []uint64) (Task, error)
+	CreateGroup(projectID, datasetID uint64, overlap int) (Group, error)
+	GetGroupsByProject(projectID, datasetID uint64) ([]Group, error)
+	GetTasksByGroup(groupID uint64) ([]Task, error)
 	DeleteTask(id uint64) error
 	DeleteTaskByProject(projectID uint64) error
 	AddImages(id uint64, imageIDs []uint64) error
+	GetAssignedImages(datasetID uint64) ([]uint64, error)
+	RemoveImage(tx *gorm.DB, datasetID, imageID uint64) ([]uint64, error)
 	GetTaskDetails(taskID uint64, status DetailStatus, currentID uint64, limit int) (details []Detail, total int, err error)
+	GetQueue(labeler uint64) ([]Task, error)
+	GetOpenDetails(taskID uint64, statuses []DetailStatus, currentID uint64, limit int) ([]Detail, error)
 	UpdateTask(taskID uint64, changes map[string]interface{}) (Task, error)
-	UpdateTaskDetail(taskID, detailID uint64, changes map[string]interface{}) (Detail, error)
+	UpdateAssignees(taskID, labeler, reviewer uint64) (Task, error)
+	ReassignUser(projectID, userID, successor uint64, within func(tx *gorm.DB) error) ([]Task, error)
+	UpdateDeadline(taskID uint64, deadline Deadline) (Task, error)
+	GetOverdue(now time.Time, limit int) ([]Task, error)
+	GetOverdueByUser(userID uint64, now time.Time, offset, limit int) (tasks []Task, total int, err error)
+	MarkOverdueNotified(taskID uint64, at time.Time) error
+	SplitTask(t Task, labeler, reviewer uint64, quantity int) (Task, error)
+	MergeTasks(target, source Task) error
+	GetTaskDetail(taskID, detailID uint64) (Detail, error)
+	TransitDetail(detail Detail, userID uint64, role Role, to DetailStatus, comment string) (Detail, error)
+	ClassifyDetail(detail Detail, labels []uint64, userID uint64, role Role, to DetailStatus) (Detail, error)
+	GetClassifiedDetails(projectID, datasetID uint64) ([]Detail, error)
+	RemapLabel(tx *gorm.DB, projectID, from, to uint64) error
+	TransitTask(t Task, userID uint64, to Status) (Task, error)
+	GetHistory(taskID, detailID uint64, offset, limit int) (histories []History, total int, err error)
 }
 
 type dbRepository struct {
@@ -64,7 +88,7 @@ func (r *dbRepository) GetTasksByUser(userID uint64, role Role, status Status, o
 	if offset != 0 || limit != 0 {
 		db = db.Offset(offset).Limit(limit)
 	}
-	err = db.Find(&tasks).Error
+	err = db.Order("priority DESC").Order("id").Find(&tasks).Error
 	if err != nil {
 		return nil, 0, errors.TaskCannotGet.Wrap(err, "cannot get task")
 	}
@@ -113,7 +137,9 @@ func (r *dbRepository) GetTasksByProject(projectID uint64, status Status, offset
 	return
 }
 
-func (r *dbRepository) CreateTask(title, description string, assigner, labeler, reviewer, projectID, datasetID uint64) (Task, error) {
+// CreateTask creates the task with its images and queues the push to the
+// annotation server in a single transaction.
+func (r *dbRepository) CreateTask(title, description string, assigner, labeler, reviewer, projectID, datasetID, groupID uint64, priority int, deadline Deadline, images []uint64) (Task, error) {
 	t := Task{
 		Title:       title,
 		Description: description,
@@ -123,14 +149,62 @@ func (r *dbRepository) CreateTask(title, description string, assigner, labeler,
 		Labeler:     labeler,
 		Reviewer:    reviewer,
 		Status:      Labeling,
+		GroupID:     groupID,
+		Priority:    priority,
+		Deadline:    deadline,
 	}
-	err := r.db.Create(&t).Error
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&t).Error; err != nil {
+			return errors.TaskCannotCreate.Wrap(err, "cannot create task")
+		}
+		if err := addImages(tx, t.ID, images); err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, outbox.KindCreateTask, outbox.TaskPayload{
+			ProjectID: projectID,
+			DatasetID: datasetID,
+			TaskIDs:   []uint64{t.ID},
+		})
+	})
 	if err != nil {
-		return Task{}, errors.TaskCannotCreate.Wrap(err, "cannot create task")
+		return Task{}, err
 	}
 	return t, nil
 }
 
+func (r *dbRepository) CreateGroup(projectID, datasetID uint64, overlap int) (Group, error) {
+	g := Group{
+		ProjectID: projectID,
+		DatasetID: datasetID,
+		Overlap:   overlap,
+	}
+	err := r.db.Create(&g).Error
+	if err != nil {
+		return Group{}, errors.TaskCannotCreate.Wrap(err, "cannot create task group")
+	}
+	return g, nil
+}
+
+func (r *dbRepository) GetGroupsByProject(projectID, datasetID uint64) (groups []Group, err error) {
+	db := r.db.Where("project_id = ?", projectID)
+	if datasetID != 0 {
+		db = db.Where("dataset_id = ?", datasetID)
+	}
+	err = db.Find(&groups).Error
+	if err != nil {
+		return nil, errors.TaskCannotGet.Wrap(err, "cannot get task groups")
+	}
+	return
+}
+
+func (r *dbRepository) GetTasksByGroup(groupID uint64) (tasks []Task, err error) {
+	err = r.db.Where("group_id = ?", groupID).Find(&tasks).Error
+	if err != nil {
+		return nil, errors.TaskCannotGet.Wrap(err, "cannot get tasks of group")
+	}
+	return
+}
+
 func (r *dbRepository) DeleteTask(id uint64) error {
 	err := r.db.Transaction(func(tx *gorm.DB) error {
 		var t Task
@@ -157,6 +231,10 @@ func (r *dbRepository) DeleteTask(id uint64) error {
 }
 
 func (r *dbRepository) AddImages(id uint64, imageIDs []uint64) error {
+	return addImages(r.db, id, imageIDs)
+}
+
+func addImages(db *gorm.DB, id uint64, imageIDs []uint64) error {
 	records := make([]interface{}, len(imageIDs))
 	for i := range records {
 		var record = Detail{
@@ -166,13 +244,85 @@ func (r *dbRepository) AddImages(id uint64, imageIDs []uint64) error {
 		}
 		records[i] = record
 	}
-	err := gormbulk.BulkInsert(r.db, records, 1000)
+	err := gormbulk.BulkInsert(db, records, 1000)
 	if err != nil {
 		return errors.TaskCannotCreate.Wrap(err, "cannot create tasks")
 	}
 	return nil
 }
 
+func (r *dbRepository) GetAssignedImages(datasetID uint64) ([]uint64, error) {
+	var taskIDs = make([]uint64, 0)
+	err := r.db.Model(&Task{}).Where("dataset_id = ?", datasetID).Pluck("id", &taskIDs).Error
+	if err != nil {
+		return nil, errors.TaskCannotGet.Wrap(err, "cannot get tasks of dataset")
+	}
+	var shards = make(map[string][]uint64)
+	for _, id := range taskIDs {
+		tableName := Detail{TaskID: id}.TableName()
+		shards[tableName] = append(shards[tableName], id)
+	}
+	var imageIDs = make([]uint64, 0)
+	for tableName, ids := range shards {
+		var buffer = make([]uint64, 0)
+		err = r.db.Table(tableName).
+			Where("task_id IN (?) AND deleted_at IS NULL", ids).
+			Pluck("DISTINCT image_id", &buffer).Error
+		if err != nil {
+			return nil, errors.TaskDetailCannotGet.Wrap(err, "cannot get assigned images")
+		}
+		imageIDs = append(imageIDs, buffer...)
+	}
+	return imageIDs, nil
+}
+
+// RemoveImage deletes the details of the image from the tasks of its
+// dataset in tx and returns the IDs of those tasks. Nothing is deleted when
+// the image is already approved in one of them.
+func (r *dbRepository) RemoveImage(tx *gorm.DB, datasetID, imageID uint64) ([]uint64, error) {
+	var taskIDs = make([]uint64, 0)
+	err := tx.Model(&Task{}).Where("dataset_id = ?", datasetID).Pluck("id", &taskIDs).Error
+	if err != nil {
+		return nil, errors.TaskCannotGet.Wrap(err, "cannot get tasks of dataset")
+	}
+	var shards = make(map[string][]uint64)
+	for _, id := range taskIDs {
+		tableName := Detail{TaskID: id}.TableName()
+		shards[tableName] = append(shards[tableName], id)
+	}
+	var affected = make([]uint64, 0)
+	for tableName, ids := range shards {
+		var approved int
+		err := tx.Table(tableName).
+			Where("task_id IN (?) AND image_id = ? AND status = ? AND deleted_at IS NULL", ids, imageID, Approved).
+			Count(&approved).Error
+		if err != nil {
+			return nil, errors.TaskDetailCannotGet.Wrap(err, "cannot get details of image")
+		}
+		if approved != 0 {
+			return nil, errors.TaskImageApproved.NewWithMessageF("image %d is already approved", imageID)
+		}
+		var buffer = make([]uint64, 0)
+		err = tx.Table(tableName).
+			Where("task_id IN (?) AND image_id = ? AND deleted_at IS NULL", ids, imageID).
+			Pluck("DISTINCT task_id", &buffer).Error
+		if err != nil {
+			return nil, errors.TaskDetailCannotGet.Wrap(err, "cannot get details of image")
+		}
+		if len(buffer) == 0 {
+			continue
+		}
+		err = tx.Table(tableName).
+			Where("task_id IN (?) AND image_id = ?", buffer, imageID).
+			Delete(&Detail{}).Error
+		if err != nil {
+			return nil, errors.TaskDetailCannotDelete.Wrap(err, "cannot delete details of image")
+		}
+		affected = append(affected, buffer...)
+	}
+	return affected, nil
+}
+
 func (r *dbRepository) GetTaskDetails(taskID uint64, status DetailStatus, currentID uint64, limit int) (details []Detail, total int, err error) {
 	var tableName = Detail{TaskID: taskID}.TableName()
 	db := r.db.Table(tableName).
@@ -193,16 +343,239 @@ func (r *dbRepository) GetTaskDetails(taskID uint64, status DetailStatus, curren
 	return details, total, nil
 }
 
-func (r *dbRepository) UpdateTaskDetail(taskID, detailID uint64, changes map[string]interface{}) (Detail, error) {
-	var detail = Detail{TaskID: taskID}
-	detail.ID = detailID
-	err := r.db.Model(&detail).Update(changes).Preload("Image").First(&detail, detailID).Error
+// GetQueue returns the labeling tasks of labeler, highest priority first.
+func (r *dbRepository) GetQueue(labeler uint64) (tasks []Task, err error) {
+	err = r.db.Where("labeler = ? AND status = ?", labeler, Labeling).
+		Order("priority DESC").
+		Order("id").
+		Find(&tasks).Error
 	if err != nil {
-		return Detail{}, errors.TaskDetailCannotUpdate.Wrap(err, "cannot update task detail")
+		return nil, errors.TaskCannotGet.Wrap(err, "cannot get task queue")
+	}
+	return tasks, nil
+}
+
+// GetOpenDetails returns up to limit details of a task in one of statuses
+// with an ID above currentID, in ID order.
+func (r *dbRepository) GetOpenDetails(taskID uint64, statuses []DetailStatus, currentID uint64, limit int) (details []Detail, err error) {
+	err = r.db.Table(Detail{TaskID: taskID}.TableName()).
+		Preload("Image").
+		Where("task_id = ? AND status IN (?) AND id > ?", taskID, statuses, currentID).
+		Order("id").
+		Limit(limit).
+		Find(&details).Error
+	if err != nil {
+		return nil, errors.TaskDetailCannotGet.Wrap(err, "cannot get open task details")
+	}
+	return details, nil
+}
+
+func (r *dbRepository) GetTaskDetail(taskID, detailID uint64) (Detail, error) {
+	var detail = Detail{TaskID: taskID}
+	db := r.db.Table(detail.TableName()).
+		Preload("Image").
+		Where("task_id = ?", taskID).
+		First(&detail, detailID)
+	if db.RecordNotFound() {
+		return Detail{}, errors.TaskDetailCannotGet.NewWithMessageF("detail %d not found in task %d", detailID, taskID)
+	}
+	if err := db.Error; err != nil {
+		return Detail{}, errors.TaskDetailCannotGet.Wrap(err, "cannot get task detail")
 	}
 	return detail, nil
 }
 
+// TransitDetail moves detail to status to and records the change in the
+// history. The update only applies while the detail still has the status it
+// was read with, so a concurrent change makes the transition fail instead of
+// being overwritten. A rejection stores the comment and counts the rework.
+func (r *dbRepository) TransitDetail(detail Detail, userID uint64, role Role, to DetailStatus, comment string) (Detail, error) {
+	var changes = map[string]interface{}{
+		"status": to,
+	}
+	if to == Rejected {
+		changes["comment"] = comment
+		changes["rework_count"] = gorm.Expr("rework_count + ?", 1)
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		return transitDetail(tx, detail, userID, role, to, changes)
+	})
+	if err != nil {
+		return Detail{}, err
+	}
+	return r.GetTaskDetail(detail.TaskID, detail.ID)
+}
+
+// ClassifyDetail sets the labels of a detail in a classification project and
+// moves it to status to in one go, on the same terms as TransitDetail.
+func (r *dbRepository) ClassifyDetail(detail Detail, labels []uint64, userID uint64, role Role, to DetailStatus) (Detail, error) {
+	var changes = map[string]interface{}{
+		"status": to,
+		"labels": JoinLabelIDs(labels),
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		return transitDetail(tx, detail, userID, role, to, changes)
+	})
+	if err != nil {
+		return Detail{}, err
+	}
+	return r.GetTaskDetail(detail.TaskID, detail.ID)
+}
+
+// transitDetail applies changes to detail while it still has the status it
+// was read with and records the change of status, if any.
+func transitDetail(tx *gorm.DB, detail Detail, userID uint64, role Role, to DetailStatus, changes map[string]interface{}) error {
+	changes["updated_at"] = gorm.NowFunc()
+	db := tx.Table(detail.TableName()).
+		Where("id = ? AND task_id = ? AND status = ?", detail.ID, detail.TaskID, detail.Status).
+		Updates(changes)
+	if err := db.Error; err != nil {
+		return errors.TaskDetailCannotUpdate.Wrap(err, "cannot update task detail")
+	}
+	if db.RowsAffected == 0 {
+		return errors.TaskDetailInvalidTransition.NewWithMessageF("detail %d is no longer %s", detail.ID, detail.Status)
+	}
+	if detail.Status == to {
+		return nil
+	}
+	history := History{
+		TaskID:     detail.TaskID,
+		DetailID:   detail.ID,
+		UserID:     userID,
+		Role:       role,
+		FromStatus: int32(detail.Status),
+		ToStatus:   int32(to),
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		return errors.TaskDetailCannotUpdate.Wrap(err, "cannot record detail history")
+	}
+	return nil
+}
+
+// GetClassifiedDetails returns the details carrying labels in the tasks of a
+// project, only those of datasetID unless it is 0.
+func (r *dbRepository) GetClassifiedDetails(projectID, datasetID uint64) ([]Detail, error) {
+	return classifiedDetails(r.db, projectID, datasetID, false)
+}
+
+// classifiedDetails reads the classified details through tx, locking them
+// when lock is set.
+func classifiedDetails(tx *gorm.DB, projectID, datasetID uint64, lock bool) ([]Detail, error) {
+	var taskIDs = make([]uint64, 0)
+	db := tx.Model(&Task{}).Where("project_id = ?", projectID)
+	if datasetID != 0 {
+		db = db.Where("dataset_id = ?", datasetID)
+	}
+	if err := db.Pluck("id", &taskIDs).Error; err != nil {
+		return nil, errors.TaskCannotGet.Wrap(err, "cannot get tasks of project")
+	}
+	var shards = make(map[string][]uint64)
+	for _, id := range taskIDs {
+		tableName := Detail{TaskID: id}.TableName()
+		shards[tableName] = append(shards[tableName], id)
+	}
+	var details = make([]Detail, 0)
+	for tableName, ids := range shards {
+		var buffer = make([]Detail, 0)
+		db := tx.Table(tableName)
+		if lock {
+			db = db.Set("gorm:query_option", "FOR UPDATE")
+		}
+		err := db.Where("task_id IN (?) AND labels <> ''", ids).
+			Find(&buffer).Error
+		if err != nil {
+			return nil, errors.TaskDetailCannotGet.Wrap(err, "cannot get classified details")
+		}
+		details = append(details, buffer...)
+	}
+	return details, nil
+}
+
+// RemapLabel moves label from to label to on every classified detail of a
+// project, within tx. The details are locked while they are remapped so that
+// no classification made meanwhile is lost.
+func (r *dbRepository) RemapLabel(tx *gorm.DB, projectID, from, to uint64) error {
+	details, err := classifiedDetails(tx, projectID, 0, true)
+	if err != nil {
+		return err
+	}
+	for _, d := range details {
+		labels, found := remapLabelIDs(d.LabelIDs(), from, to)
+		if !found {
+			continue
+		}
+		db := tx.Table(d.TableName()).
+			Where("id = ? AND labels = ?", d.ID, d.Labels).
+			Update("labels", JoinLabelIDs(labels))
+		if err := db.Error; err != nil {
+			return errors.TaskDetailCannotUpdate.Wrap(err, "cannot remap labels of task detail")
+		}
+		if db.RowsAffected == 0 {
+			return errors.TaskDetailCannotUpdate.NewWithMessageF("labels of task detail %d changed while remapping", d.ID)
+		}
+	}
+	return nil
+}
+
+// TransitTask moves t to status to and records the change in the history
+// with a zero DetailID. Entering a new status rearms the overdue
+// notification, and DoneAt is kept while the task is done.
+func (r *dbRepository) TransitTask(t Task, userID uint64, to Status) (Task, error) {
+	var changes = map[string]interface{}{
+		"status":              to,
+		"done_at":             nil,
+		"overdue_notified_at": nil,
+	}
+	if to == Done {
+		changes["done_at"] = time.Now()
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		db := tx.Model(&Task{}).
+			Where("id = ? AND status = ?", t.ID, t.Status).
+			Updates(changes)
+		if err := db.Error; err != nil {
+			return errors.TaskCannotUpdate.Wrap(err, "cannot update task status")
+		}
+		if db.RowsAffected == 0 {
+			return errors.TaskInvalidTransition.NewWithMessageF("task %d is no longer %s", t.ID, t.Status)
+		}
+		history := History{
+			TaskID:     t.ID,
+			UserID:     userID,
+			Role:       System,
+			FromStatus: int32(t.Status),
+			ToStatus:   int32(to),
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return errors.TaskCannotUpdate.Wrap(err, "cannot record task history")
+		}
+		return nil
+	})
+	if err != nil {
+		return Task{}, err
+	}
+	return r.GetTask(t.ID)
+}
+
+// GetHistory returns the history of a task, oldest first. A non zero
+// detailID narrows it to a single detail.
+func (r *dbRepository) GetHistory(taskID, detailID uint64, offset, limit int) (histories []History, total int, err error) {
+	db := r.db.Model(&History{}).Where("task_id = ?", taskID)
+	if detailID != 0 {
+		db = db.Where("detail_id = ?", detailID)
+	}
+	db = db.Count(&total)
+	if offset != 0 || limit != 0 {
+		db = db.Offset(offset).Limit(limit)
+	}
+	err = db.Order("id").Find(&histories).Error
+	if err != nil {
+		err = errors.TaskHistoryCannotGet.Wrap(err, "cannot get task history")
+		return
+	}
+	return histories, total, nil
+}
+
 func (r *dbRepository) DeleteTaskByProject(projectID uint64) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
 		var tasks = make([]Task, 0)
@@ -229,3 +602,354 @@ func (r *dbRepository) UpdateTask(taskID uint64, changes map[string]interface{})
 	}
 	return task, nil
 }
+
+// UpdateAssignees replaces the labeler and/or reviewer of a task, a zero ID
+// keeps the current one. The task details are left untouched so the progress
+// is handed over as is.
+// UpdateAssignees changes the labeler and reviewer of a task. In a consensus
+// group every image goes to different labelers, so the new labeler must not
+// label another task of the group, and nobody reviews their own labels.
+func (r *dbRepository) UpdateAssignees(taskID, labeler, reviewer uint64) (Task, error) {
+	var task = Task{}
+	var changes = make(map[string]interface{})
+	if labeler != 0 {
+		changes["labeler"] = labeler
+	}
+	if reviewer != 0 {
+		changes["reviewer"] = reviewer
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&task, taskID).Error; err != nil {
+			return errors.TaskCannotGet.Wrap(err, "cannot get task to update assignees")
+		}
+		newLabeler, newReviewer := task.Labeler, task.Reviewer
+		if labeler != 0 {
+			newLabeler = labeler
+		}
+		if reviewer != 0 {
+			newReviewer = reviewer
+		}
+		if newLabeler == newReviewer {
+			return errors.TaskCannotUpdate.NewWithMessageF("user %d cannot both label and review task %d", newLabeler, task.ID)
+		}
+		if newLabeler != task.Labeler {
+			if err := checkGroupLabeler(tx, task, newLabeler, errors.TaskCannotUpdate); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&task).Update(changes).First(&task).Error; err != nil {
+			return errors.TaskCannotUpdate.Wrap(err, "cannot update task assignees")
+		}
+		return outbox.Enqueue(tx, outbox.KindUpdateTask, outbox.TaskPayload{
+			ProjectID: task.ProjectID,
+			DatasetID: task.DatasetID,
+			TaskIDs:   []uint64{task.ID},
+		})
+	})
+	if err != nil {
+		return Task{}, err
+	}
+	return task, nil
+}
+
+// checkGroupLabeler makes sure labeler does not already label another task
+// of the consensus group of t, failing with errType otherwise.
+func checkGroupLabeler(tx *gorm.DB, t Task, labeler uint64, errType errors.ErrorType) error {
+	if t.GroupID == 0 {
+		return nil
+	}
+	var count int
+	err := tx.Model(&Task{}).
+		Where("group_id = ? AND id <> ? AND labeler = ?", t.GroupID, t.ID, labeler).
+		Count(&count).Error
+	if err != nil {
+		return errors.TaskCannotGet.Wrap(err, "cannot get tasks of group")
+	}
+	if count != 0 {
+		return errType.NewWithMessageF("user %d already labels another task of group %d", labeler, t.GroupID)
+	}
+	return nil
+}
+
+// ReassignUser hands the tasks userID labels or reviews in a project over to
+// successor and returns them as they were. within runs in the same
+// transaction, so the change that requires the reassignment is only kept
+// along with it. successor cannot become both labeler and reviewer of a task,
+// nor label two tasks of a consensus group.
+func (r *dbRepository) ReassignUser(projectID, userID, successor uint64, within func(tx *gorm.DB) error) ([]Task, error) {
+	var tasks = make([]Task, 0)
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if within != nil {
+			if err := within(tx); err != nil {
+				return err
+			}
+		}
+		err := tx.Where("project_id = ? AND (labeler = ? OR reviewer = ?)", projectID, userID, userID).
+			Order("id").
+			Find(&tasks).Error
+		if err != nil {
+			return errors.TaskCannotGet.Wrap(err, "cannot get tasks to reassign")
+		}
+		if len(tasks) == 0 {
+			return nil
+		}
+		for _, t := range tasks {
+			if err := checkSuccessor(tx, t, userID, successor); err != nil {
+				return err
+			}
+		}
+		for _, role := range []string{"labeler", "reviewer"} {
+			err := tx.Model(&Task{}).
+				Where("project_id = ? AND "+role+" = ?", projectID, userID).
+				Update(role, successor).Error
+			if err != nil {
+				return errors.TaskCannotUpdate.Wrap(err, "cannot reassign tasks")
+			}
+		}
+		var byDataset = make(map[uint64][]uint64)
+		var datasets = make([]uint64, 0)
+		for _, t := range tasks {
+			if _, ok := byDataset[t.DatasetID]; !ok {
+				datasets = append(datasets, t.DatasetID)
+			}
+			byDataset[t.DatasetID] = append(byDataset[t.DatasetID], t.ID)
+		}
+		for _, datasetID := range datasets {
+			err := outbox.Enqueue(tx, outbox.KindUpdateTask, outbox.TaskPayload{
+				ProjectID: projectID,
+				DatasetID: datasetID,
+				TaskIDs:   byDataset[datasetID],
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// checkSuccessor makes sure successor can take the place of userID in t.
+func checkSuccessor(tx *gorm.DB, t Task, userID, successor uint64) error {
+	if t.Labeler == userID && t.Reviewer == successor || t.Reviewer == userID && t.Labeler == successor {
+		return errors.TaskCannotUpdate.NewWithMessageF("user %d would both label and review task %d", successor, t.ID)
+	}
+	if t.Labeler != userID {
+		return nil
+	}
+	return checkGroupLabeler(tx, t, successor, errors.TaskCannotUpdate)
+}
+
+// UpdateDeadline replaces the due dates of a task and rearms its overdue
+// notification.
+func (r *dbRepository) UpdateDeadline(taskID uint64, deadline Deadline) (Task, error) {
+	var task = Task{}
+	task.ID = taskID
+	err := r.db.Model(&task).Updates(map[string]interface{}{
+		"label_due_at":        deadline.LabelDueAt,
+		"review_due_at":       deadline.ReviewDueAt,
+		"overdue_notified_at": nil,
+	}).First(&task).Error
+	if err != nil {
+		return Task{}, errors.TaskCannotUpdate.Wrap(err, "cannot update task deadline")
+	}
+	return task, nil
+}
+
+// overdue narrows db to the tasks that missed a deadline at now, matching
+// Task.Overdue.
+func overdue(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("status <> ?", Done).
+		Where("(status = ? AND label_due_at < ?) OR review_due_at < ?", Labeling, now, now)
+}
+
+// GetOverdue returns up to limit overdue tasks that have not been notified
+// yet.
+func (r *dbRepository) GetOverdue(now time.Time, limit int) (tasks []Task, err error) {
+	err = overdue(r.db, now).
+		Where("overdue_notified_at IS NULL").
+		Order("id").
+		Limit(limit).
+		Find(&tasks).Error
+	if err != nil {
+		return nil, errors.TaskCannotGet.Wrap(err, "cannot get overdue tasks")
+	}
+	return tasks, nil
+}
+
+func (r *dbRepository) GetOverdueByUser(userID uint64, now time.Time, offset, limit int) (tasks []Task, total int, err error) {
+	db := overdue(r.db.Model(&Task{}), now).
+		Where("labeler = ? OR reviewer = ? OR assigner = ?", userID, userID, userID).
+		Count(&total)
+	if offset != 0 || limit != 0 {
+		db = db.Offset(offset).Limit(limit)
+	}
+	err = db.Find(&tasks).Error
+	if err != nil {
+		return nil, 0, errors.TaskCannotGet.Wrap(err, "cannot get overdue tasks")
+	}
+	return
+}
+
+func (r *dbRepository) MarkOverdueNotified(taskID uint64, at time.Time) error {
+	err := r.db.Model(&Task{}).
+		Where("id = ?", taskID).
+		Update("overdue_notified_at", at).Error
+	if err != nil {
+		return errors.TaskCannotUpdate.Wrap(err, "cannot mark task as notified")
+	}
+	return nil
+}
+
+// SplitTask moves up to quantity Pending and Draft details of t into a new
+// task for labeler, all of them when quantity is 0. The new task stays in the
+// consensus group of t, so labeler must not label another task of the group.
+func (r *dbRepository) SplitTask(t Task, labeler, reviewer uint64, quantity int) (Task, error) {
+	created := Task{
+		Title:       t.Title,
+		Description: t.Description,
+		ProjectID:   t.ProjectID,
+		DatasetID:   t.DatasetID,
+		Assigner:    t.Assigner,
+		Labeler:     labeler,
+		Reviewer:    reviewer,
+		Status:      Labeling,
+		GroupID:     t.GroupID,
+		Priority:    t.Priority,
+		Deadline:    t.Deadline,
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkGroupLabeler(tx, t, labeler, errors.TaskCannotSplit); err != nil {
+			return err
+		}
+		if err := tx.Create(&created).Error; err != nil {
+			return errors.TaskCannotSplit.Wrap(err, "cannot create split task")
+		}
+		moved, _, err := moveDetails(tx, t.ID, created.ID, []DetailStatus{Pending, Draft}, quantity)
+		if err != nil {
+			return err
+		}
+		if moved == 0 {
+			return errors.TaskCannotSplit.NewWithMessageF("task %d has no pending or draft images left to split", t.ID)
+		}
+		return outbox.Enqueue(tx, outbox.KindCreateTask, outbox.TaskPayload{
+			ProjectID: t.ProjectID,
+			DatasetID: t.DatasetID,
+			TaskIDs:   []uint64{created.ID},
+		})
+	})
+	if err != nil {
+		return Task{}, err
+	}
+	return created, nil
+}
+
+// MergeTasks moves every detail of source into target and deletes source.
+// An image both tasks hold keeps the detail of target.
+func (r *dbRepository) MergeTasks(target, source Task) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var imageIDs = make([]uint64, 0)
+		err := tx.Table(Detail{TaskID: target.ID}.TableName()).
+			Where("task_id = ? AND deleted_at IS NULL", target.ID).
+			Pluck("image_id", &imageIDs).Error
+		if err != nil {
+			return errors.TaskCannotMerge.Wrap(err, "cannot get images of target task")
+		}
+		if len(imageIDs) != 0 {
+			err = tx.Unscoped().Table(Detail{TaskID: source.ID}.TableName()).
+				Where("task_id = ? AND image_id IN (?)", source.ID, imageIDs).
+				Delete(&Detail{}).Error
+			if err != nil {
+				return errors.TaskCannotMerge.Wrap(err, "cannot drop duplicated images")
+			}
+		}
+		_, moved, err := moveDetails(tx, source.ID, target.ID, nil, 0)
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&Task{}, source.ID).Error; err != nil {
+			return errors.TaskCannotMerge.Wrap(err, "cannot delete merged task")
+		}
+		err = outbox.Enqueue(tx, outbox.KindUpdateTask, outbox.TaskPayload{
+			ProjectID: target.ProjectID,
+			DatasetID: target.DatasetID,
+			TaskIDs:   []uint64{target.ID},
+		})
+		if err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, outbox.KindDeleteTask, outbox.DeleteTaskPayload{
+			ProjectID:  source.ProjectID,
+			TaskIDs:    []uint64{source.ID},
+			MergedInto: target.ID,
+			DetailIDs:  moved,
+		})
+	})
+	return err
+}
+
+// moveDetails hands the details of task from with one of statuses (any status
+// when empty) over to task to, along with their histories. Details live in
+// the task_detail_N shard of their task, so rows are moved to another shard
+// when the two tasks use different ones and get new IDs there. The returned
+// map holds the new ID of every such detail.
+func moveDetails(tx *gorm.DB, from, to uint64, statuses []DetailStatus, limit int) (int, map[uint64]uint64, error) {
+	src := Detail{TaskID: from}.TableName()
+	dst := Detail{TaskID: to}.TableName()
+	db := tx.Table(src).Where("task_id = ? AND deleted_at IS NULL", from)
+	if len(statuses) != 0 {
+		db = db.Where("status IN (?)", statuses)
+	}
+	db = db.Order("id")
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	var details = make([]Detail, 0)
+	if err := db.Find(&details).Error; err != nil {
+		return 0, nil, errors.TaskDetailCannotGet.Wrap(err, "cannot get details to move")
+	}
+	if len(details) == 0 {
+		return 0, nil, nil
+	}
+	ids := make([]uint64, len(details))
+	for i := range details {
+		ids[i] = details[i].ID
+	}
+	if src == dst {
+		err := tx.Table(src).Where("id IN (?)", ids).Updates(map[string]interface{}{"task_id": to}).Error
+		if err != nil {
+			return 0, nil, errors.TaskDetailCannotUpdate.Wrap(err, "cannot move details")
+		}
+		err = tx.Model(&History{}).
+			Where("task_id = ? AND detail_id IN (?)", from, ids).
+			Updates(map[string]interface{}{"task_id": to}).Error
+		if err != nil {
+			return 0, nil, errors.TaskDetailCannotUpdate.Wrap(err, "cannot move histories of details")
+		}
+		return len(details), nil, nil
+	}
+	moved := make(map[uint64]uint64, len(details))
+	for _, d := range details {
+		old := d.ID
+		d.ID = 0
+		d.TaskID = to
+		if err := tx.Table(dst).Create(&d).Error; err != nil {
+			return 0, nil, errors.TaskDetailCannotUpdate.Wrap(err, "cannot move details")
+		}
+		moved[old] = d.ID
+		err := tx.Model(&History{}).
+			Where("task_id = ? AND detail_id = ?", from, old).
+			Updates(map[string]interface{}{"task_id": to, "detail_id": d.ID}).Error
+		if err != nil {
+			return 0, nil, errors.TaskDetailCannotUpdate.Wrap(err, "cannot move histories of details")
+		}
+	}
+	err := tx.Unscoped().Table(src).Where("id IN (?)", ids).Delete(&Detail{}).Error
+	if err != nil {
+		return 0, nil, errors.TaskDetailCannotDelete.Wrap(err, "cannot move details")
+	}
+	return len(details), moved, nil
+}
@@ -0,0 +1,199 @@
+package task
+
+import (
+	"database/sql"
+	"testing"
+
+	gomocket "github.com/Selvatico/go-mocket"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/pkg/errors"
+	pgorm "github.com/nkhang/pluto/pkg/gorm"
+)
+
+func newMockDB(t *testing.T) *gorm.DB {
+	gomocket.Catcher.Register()
+	conn, err := sql.Open(gomocket.DriverName, "connection_string")
+	require.NoError(t, err)
+	db, err := gorm.Open("mysql", conn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+		gomocket.Catcher.Reset()
+	})
+	return db
+}
+
+func TestRemapLabel(t *testing.T) {
+	db := newMockDB(t)
+	gomocket.Catcher.NewMock().
+		WithQuery("SELECT id FROM `tasks`").
+		WithReply([]map[string]interface{}{{"id": 3}})
+	locked := gomocket.Catcher.NewMock().
+		WithQuery("labels <> '')) FOR UPDATE").
+		WithReply([]map[string]interface{}{
+			{"id": 1, "task_id": 3, "labels": "4"},
+			{"id": 2, "task_id": 3, "labels": "7"},
+			{"id": 5, "task_id": 3, "labels": "9,4"},
+		})
+	remapped := gomocket.Catcher.NewMock().
+		WithQuery("UPDATE `task_detail_3`").
+		WithRowsNum(1)
+	err := NewDBRepository(db).RemapLabel(db, 6, 4, 9)
+	require.NoError(t, err)
+	assert.True(t, locked.Triggered, "the details are locked while remapped")
+	assert.True(t, remapped.Triggered)
+}
+
+func TestRemapLabelChanged(t *testing.T) {
+	db := newMockDB(t)
+	gomocket.Catcher.NewMock().
+		WithQuery("SELECT id FROM `tasks`").
+		WithReply([]map[string]interface{}{{"id": 3}})
+	gomocket.Catcher.NewMock().
+		WithQuery("FROM `task_detail_3`").
+		WithReply([]map[string]interface{}{{"id": 1, "task_id": 3, "labels": "4"}})
+	gomocket.Catcher.NewMock().
+		WithQuery("UPDATE `task_detail_3`").
+		WithRowsNum(0)
+	err := NewDBRepository(db).RemapLabel(db, 6, 4, 9)
+	assert.Equal(t, errors.TaskDetailCannotUpdate, errors.Type(err), "a detail classified meanwhile is not overwritten")
+}
+
+func TestUpdateAssigneesGroupLabeler(t *testing.T) {
+	db := newMockDB(t)
+	gomocket.Catcher.NewMock().
+		WithQuery("SELECT * FROM `tasks`").
+		WithReply([]map[string]interface{}{{"id": 3, "group_id": 2, "labeler": 10}})
+	gomocket.Catcher.NewMock().
+		WithQuery("group_id = 2 AND id <> 3 AND labeler = 11").
+		WithReply([]map[string]interface{}{{"count(*)": 1}})
+	updated := gomocket.Catcher.NewMock().
+		WithQuery("UPDATE `tasks`").
+		WithRowsNum(1)
+	_, err := NewDBRepository(db).UpdateAssignees(3, 11, 0)
+	assert.Equal(t, errors.TaskCannotUpdate, errors.Type(err), "a labeler cannot label two tasks of a consensus group")
+	assert.False(t, updated.Triggered)
+}
+
+func TestUpdateAssigneesOwnReviewer(t *testing.T) {
+	db := newMockDB(t)
+	gomocket.Catcher.NewMock().
+		WithQuery("SELECT * FROM `tasks`").
+		WithReply([]map[string]interface{}{{"id": 3, "labeler": 10, "reviewer": 12}})
+	updated := gomocket.Catcher.NewMock().
+		WithQuery("UPDATE `tasks`").
+		WithRowsNum(1)
+	_, err := NewDBRepository(db).UpdateAssignees(3, 0, 10)
+	assert.Equal(t, errors.TaskCannotUpdate, errors.Type(err), "a labeler cannot review their own task")
+	assert.False(t, updated.Triggered)
+}
+
+func TestReassignUser(t *testing.T) {
+	tests := []struct {
+		name  string
+		task  map[string]interface{}
+		count int
+		err   errors.ErrorType
+	}{
+		{"labeler", map[string]interface{}{"id": 3, "labeler": 10, "reviewer": 12}, 0, 0},
+		{"labeler in a group", map[string]interface{}{"id": 3, "group_id": 2, "labeler": 10, "reviewer": 12}, 0, 0},
+		{"successor labels the group", map[string]interface{}{"id": 3, "group_id": 2, "labeler": 10, "reviewer": 12}, 1, errors.TaskCannotUpdate},
+		{"successor reviews the task", map[string]interface{}{"id": 3, "labeler": 10, "reviewer": 11}, 0, errors.TaskCannotUpdate},
+		{"successor labels the task", map[string]interface{}{"id": 3, "labeler": 11, "reviewer": 10}, 0, errors.TaskCannotUpdate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newMockDB(t)
+			gomocket.Catcher.NewMock().
+				WithQuery("SELECT * FROM `tasks`").
+				WithReply([]map[string]interface{}{tt.task})
+			gomocket.Catcher.NewMock().
+				WithQuery("group_id = 2 AND id <> 3 AND labeler = 11").
+				WithReply([]map[string]interface{}{{"count(*)": tt.count}})
+			updated := gomocket.Catcher.NewMock().
+				WithQuery("UPDATE `tasks`").
+				WithRowsNum(1)
+			var within bool
+			_, err := NewDBRepository(db).ReassignUser(6, 10, 11, func(tx *gorm.DB) error {
+				within = true
+				return nil
+			})
+			assert.True(t, within)
+			if tt.err == 0 {
+				assert.NoError(t, err)
+				assert.True(t, updated.Triggered)
+			} else {
+				assert.Equal(t, tt.err, errors.Type(err))
+				assert.False(t, updated.Triggered, "nothing is reassigned")
+			}
+		})
+	}
+}
+
+func TestSplitTaskGroupLabeler(t *testing.T) {
+	db := newMockDB(t)
+	gomocket.Catcher.NewMock().
+		WithQuery("group_id = 2 AND id <> 3 AND labeler = 11").
+		WithReply([]map[string]interface{}{{"count(*)": 1}})
+	created := gomocket.Catcher.NewMock().
+		WithQuery("INSERT INTO `tasks`").
+		WithID(14)
+	source := Task{Model: pgorm.Model{ID: 3}, GroupID: 2, Labeler: 10, Reviewer: 12}
+	_, err := NewDBRepository(db).SplitTask(source, 11, 12, 0)
+	assert.Equal(t, errors.TaskCannotSplit, errors.Type(err), "a labeler cannot label two tasks of a consensus group")
+	assert.False(t, created.Triggered)
+}
+
+func TestSplitTaskMovesShard(t *testing.T) {
+	db := newMockDB(t)
+	gomocket.Catcher.NewMock().
+		WithQuery("INSERT INTO `tasks`").
+		WithID(14)
+	gomocket.Catcher.NewMock().
+		WithQuery("SELECT * FROM `task_detail_3`").
+		WithReply([]map[string]interface{}{{"id": 1, "task_id": 3, "image_id": 7, "status": int32(Draft)}})
+	inserted := gomocket.Catcher.NewMock().
+		WithQuery("INSERT INTO `task_detail_4`").
+		WithID(21)
+	histories := gomocket.Catcher.NewMock().
+		WithQuery("UPDATE `task_histories` SET `detail_id`").
+		WithRowsNum(1)
+	deleted := gomocket.Catcher.NewMock().
+		WithQuery("DELETE FROM `task_detail_3`  WHERE (id IN").
+		WithArgs(int64(1)).
+		WithRowsNum(1)
+	source := Task{Model: pgorm.Model{ID: 3}, Labeler: 10, Reviewer: 12}
+	created, err := NewDBRepository(db).SplitTask(source, 11, 12, 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(14), created.ID)
+	assert.True(t, inserted.Triggered, "details are copied to the shard of the new task")
+	assert.True(t, histories.Triggered, "histories follow the copied details")
+	assert.True(t, deleted.Triggered, "details are removed from the shard of the old task")
+}
+
+func TestMergeTasksSameImage(t *testing.T) {
+	db := newMockDB(t)
+	gomocket.Catcher.NewMock().
+		WithQuery("SELECT image_id FROM `task_detail_3`").
+		WithReply([]map[string]interface{}{{"image_id": 7}})
+	dropped := gomocket.Catcher.NewMock().
+		WithQuery("DELETE FROM `task_detail_3`  WHERE (task_id = ? AND image_id IN").
+		WithArgs(int64(13), int64(7)).
+		WithRowsNum(1)
+	gomocket.Catcher.NewMock().
+		WithQuery("SELECT * FROM `task_detail_3`").
+		WithReply([]map[string]interface{}{{"id": 2, "task_id": 13, "image_id": 8, "status": int32(Labeled)}})
+	moved := gomocket.Catcher.NewMock().
+		WithQuery("UPDATE `task_detail_3` SET `task_id`").
+		WithArgs(int64(3), int64(2)).
+		WithRowsNum(1)
+	target := Task{Model: pgorm.Model{ID: 3}, Labeler: 10}
+	source := Task{Model: pgorm.Model{ID: 13}, Labeler: 11}
+	err := NewDBRepository(db).MergeTasks(target, source)
+	require.NoError(t, err)
+	assert.True(t, dropped.Triggered, "an image of both tasks keeps the detail of the target")
+	assert.True(t, moved.Triggered, "details in the same shard are moved in place")
+}
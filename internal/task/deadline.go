@@ -0,0 +1,52 @@
+package task
+
+import "time"
+
+// Deadline holds the due dates of a task. Labeling is due by LabelDueAt and
+// the whole task, review included, by ReviewDueAt. A nil date sets no
+// deadline.
+type Deadline struct {
+	LabelDueAt  *time.Time
+	ReviewDueAt *time.Time
+}
+
+// Overdue reports whether t has missed a deadline at now and returns that
+// deadline. A done task is never overdue.
+func (t Task) Overdue(now time.Time) (time.Time, bool) {
+	if t.Status == Done {
+		return time.Time{}, false
+	}
+	if t.Status == Labeling && t.LabelDueAt != nil && now.After(*t.LabelDueAt) {
+		return *t.LabelDueAt, true
+	}
+	if t.ReviewDueAt != nil && now.After(*t.ReviewDueAt) {
+		return *t.ReviewDueAt, true
+	}
+	return time.Time{}, false
+}
+
+// Late reports whether a done task was finished after its final deadline,
+// which is ReviewDueAt or LabelDueAt when there is no review deadline.
+func (t Task) Late() bool {
+	if t.Status != Done || t.DoneAt == nil {
+		return false
+	}
+	due := t.ReviewDueAt
+	if due == nil {
+		due = t.LabelDueAt
+	}
+	return due != nil && t.DoneAt.After(*due)
+}
+
+// HasDeadline reports whether any due date is set on t.
+func (t Task) HasDeadline() bool {
+	return t.LabelDueAt != nil || t.ReviewDueAt != nil
+}
+
+// Age is how long t has been open at now, or took until it was done.
+func (t Task) Age(now time.Time) time.Duration {
+	if t.Status == Done && t.DoneAt != nil {
+		now = *t.DoneAt
+	}
+	return now.Sub(t.CreatedAt)
+}
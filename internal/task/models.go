@@ -1,6 +1,8 @@
 package task
 
 import (
+	"time"
+
 	"github.com/nkhang/pluto/internal/image"
 	"github.com/nkhang/pluto/pkg/gorm"
 	"github.com/spf13/cast"
@@ -15,6 +17,8 @@ const (
 	Assigner
 	Labeler
 	Reviewer
+	// System is pluto itself, deriving the status of a task from its details.
+	System
 )
 
 const (
@@ -33,20 +37,23 @@ const (
 	Done
 )
 
-var statusMap = map[Status][]DetailStatus{
-	Reviewing: {Labeled},
-	Done:      {Approved, Rejected},
-}
-
-var reverseStatusMap = map[DetailStatus]Status{
-	Labeled:  Reviewing,
-	Approved: Done,
-	Rejected: Done,
-}
-
-func relative(detailStatus DetailStatus) ([]DetailStatus, Status) {
-	status := reverseStatusMap[detailStatus]
-	return statusMap[status], status
+// statusOf derives the task status from the status of its details: the task
+// is labeling while any image is left to label or was rejected for rework,
+// reviewing while any labeled image waits for review and done otherwise.
+func statusOf(details []Detail) Status {
+	var labeled bool
+	for _, d := range details {
+		switch d.Status {
+		case Pending, Draft, Rejected:
+			return Labeling
+		case Labeled:
+			labeled = true
+		}
+	}
+	if labeled {
+		return Reviewing
+	}
+	return Done
 }
 
 type Task struct {
@@ -59,14 +66,39 @@ type Task struct {
 	Labeler     uint64
 	Reviewer    uint64
 	Status      Status
+	GroupID     uint64
+	Priority    int
+	Deadline
+	DoneAt            *time.Time
+	OverdueNotifiedAt *time.Time
+}
+
+// Group ties together the tasks created in consensus mode, where every image
+// is labeled independently by Overlap different labelers.
+type Group struct {
+	gorm.Model
+	ProjectID uint64
+	DatasetID uint64
+	Overlap   int
+}
+
+func (Group) TableName() string {
+	return "task_groups"
 }
 
+// Detail is an image of a task. A Rejected detail goes back to the labeler
+// for rework, Comment holds the last reason given by the reviewer and
+// ReworkCount the number of rejections so far. In classification projects
+// Labels holds the IDs of the labels set on the image, see LabelIDs.
 type Detail struct {
 	gorm.Model
-	Status  DetailStatus
-	TaskID  uint64
-	ImageID uint64
-	Image   image.Image
+	Status      DetailStatus
+	TaskID      uint64
+	ImageID     uint64
+	Image       image.Image
+	Comment     string `gorm:"type:text"`
+	ReworkCount int
+	Labels      string `gorm:"type:varchar(1024)"`
 }
 
 func (d Detail) TableName() string {
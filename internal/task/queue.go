@@ -0,0 +1,116 @@
+package task
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/nkhang/pluto/internal/rediskey"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+// leaseScanSize is how many details are tried per query when looking for one
+// that is not leased yet.
+const leaseScanSize = 50
+
+// openStatuses are the statuses of the details a labeler still has to work on.
+var openStatuses = []DetailStatus{Pending, Draft, Rejected}
+
+// Lease reserves a detail for one client of a user until ExpiresAt so that
+// no other client, even of the same user, is handed the same image. Token
+// tells the clients apart. Leases live in the cache only and vanish when
+// they expire.
+type Lease struct {
+	TaskID    uint64    `json:"task_id"`
+	DetailID  uint64    `json:"detail_id"`
+	UserID    uint64    `json:"user_id"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NextDetail leases the first open detail of the labeling tasks of userID,
+// taking the tasks by priority. Details already leased are skipped, whoever
+// holds them.
+func (r *repository) NextDetail(userID uint64, ttl time.Duration) (Task, Detail, Lease, error) {
+	token := uuid.NewV4().String()
+	tasks, err := r.dbRepo.GetQueue(userID)
+	if err != nil {
+		return Task{}, Detail{}, Lease{}, err
+	}
+	for _, t := range tasks {
+		var currentID uint64
+		for {
+			details, err := r.dbRepo.GetOpenDetails(t.ID, openStatuses, currentID, leaseScanSize)
+			if err != nil {
+				return Task{}, Detail{}, Lease{}, err
+			}
+			for _, d := range details {
+				lease, ok, err := r.acquireLease(t.ID, d.ID, userID, token, ttl)
+				if err != nil {
+					return Task{}, Detail{}, Lease{}, err
+				}
+				if ok {
+					return t, d, lease, nil
+				}
+			}
+			if len(details) < leaseScanSize {
+				break
+			}
+			currentID = details[len(details)-1].ID
+		}
+	}
+	return Task{}, Detail{}, Lease{}, errors.TaskQueueEmpty.NewWithMessageF("no image left to label for user %d", userID)
+}
+
+func (r *repository) acquireLease(taskID, detailID, userID uint64, token string, ttl time.Duration) (Lease, bool, error) {
+	lease := Lease{
+		TaskID:    taskID,
+		DetailID:  detailID,
+		UserID:    userID,
+		Token:     token,
+		ExpiresAt: r.clock.Now().Add(ttl),
+	}
+	ok, err := r.cache.SetNX(rediskey.DetailLease(taskID, detailID), &lease, ttl)
+	if err != nil {
+		return Lease{}, false, errors.TaskLeaseCannotAcquire.Wrap(err, "cannot lease task detail")
+	}
+	if !ok {
+		return Lease{}, false, nil
+	}
+	logger.Infof("[TASK] - detail %d of task %d leased to user %d", detailID, taskID, userID)
+	return lease, true, nil
+}
+
+// ReleaseLease gives a leased detail back before its lease expires on behalf
+// of userID, who must hold the lease under token or be the assigner of the
+// task.
+func (r *repository) ReleaseLease(taskID, detailID, userID uint64, token string) error {
+	if userID == 0 {
+		return errors.BadRequest.NewWithMessage("the user releasing a detail is missing")
+	}
+	var held Lease
+	if err := r.cache.Get(rediskey.DetailLease(taskID, detailID), &held); err != nil {
+		return errors.TaskLeaseNotHeld.Wrap(err, "task detail is not leased")
+	}
+	if held.UserID != userID || held.Token != token {
+		t, err := r.GetTask(taskID)
+		if err != nil {
+			return err
+		}
+		if t.Assigner != userID {
+			return errors.TaskLeaseNotHeld.NewWithMessage("task detail is leased to another user")
+		}
+	}
+	return r.dropLease(taskID, detailID)
+}
+
+// dropLease removes the lease on a detail, whoever holds it. It is called
+// once a detail leaves draft, since nobody has to work on it anymore.
+func (r *repository) dropLease(taskID, detailID uint64) error {
+	if err := r.cache.Del(rediskey.DetailLease(taskID, detailID)); err != nil {
+		return err
+	}
+	logger.Infof("[TASK] - lease on detail %d of task %d released", detailID, taskID)
+	return nil
+}
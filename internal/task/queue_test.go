@@ -0,0 +1,201 @@
+package task
+
+import (
+	"encoding/json"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/rediskey"
+	"github.com/nkhang/pluto/pkg/cache"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/gorm"
+	"github.com/nkhang/pluto/pkg/util/clock"
+)
+
+// fakeCache keeps values as JSON in memory. Expirations are ignored.
+type fakeCache struct {
+	cache.Cache
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{values: make(map[string][]byte)}
+}
+
+func (c *fakeCache) Get(key string, target interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.values[key]
+	if !ok {
+		return errors.CacheNotFound.NewWithMessage("key not found")
+	}
+	return json.Unmarshal(b, target)
+}
+
+func (c *fakeCache) Set(key string, target interface{}) error {
+	b, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = b
+	return nil
+}
+
+func (c *fakeCache) SetNX(key string, target interface{}, exp time.Duration) (bool, error) {
+	c.mu.Lock()
+	_, ok := c.values[key]
+	c.mu.Unlock()
+	if ok {
+		return false, nil
+	}
+	return true, c.Set(key, target)
+}
+
+func (c *fakeCache) Keys(pattern string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var keys []string
+	for key := range c.values {
+		if ok, _ := path.Match(pattern, key); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (c *fakeCache) Del(keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.values, key)
+	}
+	return nil
+}
+
+// fakeQueueRepo serves the tasks of labeler 10 by priority, each with open
+// details 1 and 2.
+type fakeQueueRepo struct {
+	DBRepository
+	tasks []Task
+}
+
+func (r *fakeQueueRepo) GetQueue(labeler uint64) ([]Task, error) {
+	var tasks []Task
+	for _, t := range r.tasks {
+		if t.Labeler == labeler {
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks, nil
+}
+
+func (r *fakeQueueRepo) GetOpenDetails(taskID uint64, statuses []DetailStatus, currentID uint64, limit int) ([]Detail, error) {
+	var details []Detail
+	for id := currentID + 1; id <= 2 && len(details) < limit; id++ {
+		details = append(details, Detail{Model: gorm.Model{ID: id}, TaskID: taskID, Status: Pending})
+	}
+	return details, nil
+}
+
+func (r *fakeQueueRepo) GetTask(taskID uint64) (Task, error) {
+	for _, t := range r.tasks {
+		if t.ID == taskID {
+			return t, nil
+		}
+	}
+	return Task{}, errors.TaskNotFound.NewWithMessage("task not found")
+}
+
+var leaseStart = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newQueueRepository() (*repository, *fakeCache) {
+	c := newFakeCache()
+	return NewRepository(&fakeQueueRepo{tasks: []Task{
+		{Model: gorm.Model{ID: 4}, Assigner: 1, Labeler: 10, Priority: 2},
+		{Model: gorm.Model{ID: 3}, Assigner: 1, Labeler: 10, Priority: 1},
+	}}, c, clock.NewMock(leaseStart)), c
+}
+
+func TestNextDetail(t *testing.T) {
+	r, _ := newQueueRepository()
+	tests := []struct {
+		userID   uint64
+		taskID   uint64
+		detailID uint64
+	}{
+		{10, 4, 1},
+		{10, 4, 2},
+		{10, 3, 1},
+		{10, 3, 2},
+		{10, 0, 0},
+		{11, 0, 0},
+	}
+	var tokens = make(map[string]bool)
+	for _, tt := range tests {
+		_, d, lease, err := r.NextDetail(tt.userID, time.Minute)
+		if tt.taskID == 0 {
+			assert.Equal(t, errors.TaskQueueEmpty, errors.Type(err), "user %d", tt.userID)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tt.detailID, d.ID, "each client of a user is handed another detail")
+		assert.Equal(t, tt.taskID, lease.TaskID)
+		assert.Equal(t, tt.userID, lease.UserID)
+		assert.Equal(t, leaseStart.Add(time.Minute), lease.ExpiresAt)
+		assert.False(t, tokens[lease.Token], "every lease has its own token")
+		tokens[lease.Token] = true
+	}
+}
+
+func TestNextDetailSkipsLeased(t *testing.T) {
+	r, c := newQueueRepository()
+	for _, id := range []uint64{1, 2} {
+		require.NoError(t, c.Set(rediskey.DetailLease(4, id), &Lease{TaskID: 4, DetailID: id, UserID: 11}))
+	}
+	tk, d, _, err := r.NextDetail(10, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), tk.ID, "a task whose details are all leased to others is skipped")
+	assert.Equal(t, uint64(1), d.ID)
+}
+
+func TestReleaseLease(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID uint64
+		token  string
+		leased bool
+		err    errors.ErrorType
+	}{
+		{"holder", 10, "a", true, 0},
+		{"assigner", 1, "", true, 0},
+		{"another client of the holder", 10, "b", true, errors.TaskLeaseNotHeld},
+		{"another user", 11, "a", true, errors.TaskLeaseNotHeld},
+		{"no user", 0, "a", true, errors.BadRequest},
+		{"not leased", 10, "a", false, errors.TaskLeaseNotHeld},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, c := newQueueRepository()
+			key := rediskey.DetailLease(4, 1)
+			if tt.leased {
+				require.NoError(t, c.Set(key, &Lease{TaskID: 4, DetailID: 1, UserID: 10, Token: "a"}))
+			}
+			err := r.ReleaseLease(4, 1, tt.userID, tt.token)
+			if tt.err == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, tt.err, errors.Type(err))
+			}
+			var held Lease
+			assert.Equal(t, tt.leased && tt.err != 0, c.Get(key, &held) == nil, "only a lease released by its holder or an assigner is dropped")
+		})
+	}
+}
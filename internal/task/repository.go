@@ -1,35 +1,60 @@
 package task
 
 import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
 	"github.com/nkhang/pluto/internal/rediskey"
 	"github.com/nkhang/pluto/pkg/cache"
 	"github.com/nkhang/pluto/pkg/errors"
 	"github.com/nkhang/pluto/pkg/logger"
+	"github.com/nkhang/pluto/pkg/util/clock"
 )
 
 type Repository interface {
 	GetTask(taskID uint64) (Task, error)
-	CreateTask(title, description string, assigner, labeler, reviewer, projectID, datasetID uint64, images []uint64) (Task, error)
+	CreateTask(title, description string, assigner, labeler, reviewer, projectID, datasetID, groupID uint64, priority int, deadline Deadline, images []uint64) (Task, error)
+	CreateGroup(projectID, datasetID uint64, overlap int) (Group, error)
+	GetGroupsByProject(projectID, datasetID uint64) ([]Group, error)
+	GetTasksByGroup(groupID uint64) ([]Task, error)
 	GetTasksByUser(userID uint64, role Role, status Status, offset, limit int) (tasks []Task, total int, err error)
 	GetTasksByProject(projectID uint64, status Status, offset, limit int) (tasks []Task, total int, err error)
 	GetByProjectAndUser(projectID, userID uint64, role Role, offset, limit int) (tasks []Task, total int, err error)
 	DeleteTask(taskID uint64) error
 	DeleteTaskByProject(projectID uint64) error
 	GetTaskDetails(taskID uint64, status DetailStatus, currentID uint64, limit int) ([]Detail, int, error)
+	GetAssignedImages(datasetID uint64) ([]uint64, error)
+	RemoveImage(tx *gorm.DB, datasetID, imageID uint64) ([]uint64, error)
+	RefreshTasks(taskIDs []uint64)
 	UpdateTask(taskID uint64, changes map[string]interface{}) (Task, error)
-	UpdateTaskDetail(taskID, detailID uint64, changes map[string]interface{}) (Detail, error)
-	CheckTaskStatus(taskID uint64, detailStatus DetailStatus) error
+	UpdateAssignees(taskID, labeler, reviewer uint64) (Task, error)
+	ReassignUser(projectID, userID, successor uint64, within func(tx *gorm.DB) error) error
+	UpdateDeadline(taskID uint64, deadline Deadline) (Task, error)
+	GetOverdueByUser(userID uint64, now time.Time, offset, limit int) (tasks []Task, total int, err error)
+	TransitDetail(taskID, detailID, userID uint64, to DetailStatus, comment string) (detail Detail, from DetailStatus, err error)
+	ClassifyDetail(taskID, detailID, userID uint64, labels []uint64, to DetailStatus) (detail Detail, from DetailStatus, err error)
+	GetClassifiedDetails(projectID, datasetID uint64) ([]Detail, error)
+	RemapLabel(tx *gorm.DB, projectID, from, to uint64) error
+	CheckTaskStatus(taskID uint64) error
+	GetHistory(taskID, detailID uint64, offset, limit int) (histories []History, total int, err error)
+	NextDetail(userID uint64, ttl time.Duration) (Task, Detail, Lease, error)
+	ReleaseLease(taskID, detailID, userID uint64, token string) error
+	SplitTask(taskID, labeler, reviewer uint64, quantity int) (Task, error)
+	MergeTasks(targetID, sourceID uint64) (Task, error)
 }
 
 type repository struct {
 	dbRepo DBRepository
 	cache  cache.Cache
+	clock  clock.Clock
 }
 
-func NewRepository(dbRepo DBRepository, cache cache.Cache) *repository {
+func NewRepository(dbRepo DBRepository, cache cache.Cache, c clock.Clock) *repository {
 	return &repository{
 		dbRepo: dbRepo,
 		cache:  cache,
+		clock:  c,
 	}
 }
 
@@ -49,8 +74,8 @@ func (r *repository) GetTask(taskID uint64) (task Task, err error) {
 	return
 }
 
-func (r *repository) CreateTask(title, description string, assigner, labeler, reviewer, projectID, datasetID uint64, images []uint64) (Task, error) {
-	task, err := r.dbRepo.CreateTask(title, description, assigner, labeler, reviewer, projectID, datasetID)
+func (r *repository) CreateTask(title, description string, assigner, labeler, reviewer, projectID, datasetID, groupID uint64, priority int, deadline Deadline, images []uint64) (Task, error) {
+	task, err := r.dbRepo.CreateTask(title, description, assigner, labeler, reviewer, projectID, datasetID, groupID, priority, deadline, images)
 	if err != nil {
 		return Task{}, err
 	}
@@ -67,12 +92,21 @@ func (r *repository) CreateTask(title, description string, assigner, labeler, re
 			logger.Error(err)
 		}
 	}()
-	if err := r.dbRepo.AddImages(task.ID, images); err != nil {
-		return Task{}, errors.TaskCannotCreate.NewWithMessage("task created, but images not add properly")
-	}
 	return task, nil
 }
 
+func (r *repository) CreateGroup(projectID, datasetID uint64, overlap int) (Group, error) {
+	return r.dbRepo.CreateGroup(projectID, datasetID, overlap)
+}
+
+func (r *repository) GetGroupsByProject(projectID, datasetID uint64) ([]Group, error) {
+	return r.dbRepo.GetGroupsByProject(projectID, datasetID)
+}
+
+func (r *repository) GetTasksByGroup(groupID uint64) ([]Task, error) {
+	return r.dbRepo.GetTasksByGroup(groupID)
+}
+
 func (r *repository) GetTasksByUser(userID uint64, role Role, status Status, offset, limit int) (tasks []Task, total int, err error) {
 	tasks, total, err = r.dbRepo.GetTasksByUser(userID, role, status, offset, limit)
 	return
@@ -129,9 +163,119 @@ func (r *repository) GetTaskDetails(taskID uint64, status DetailStatus, currentI
 	return details, total, nil
 }
 
-func (r *repository) UpdateTaskDetail(taskID, detailID uint64, changes map[string]interface{}) (Detail, error) {
+func (r *repository) GetAssignedImages(datasetID uint64) ([]uint64, error) {
+	return r.dbRepo.GetAssignedImages(datasetID)
+}
+
+// RemoveImage takes the image out of the tasks of its dataset in tx, unless
+// it is already approved, and returns the tasks left without it. They are
+// given to RefreshTasks once tx is committed.
+func (r *repository) RemoveImage(tx *gorm.DB, datasetID, imageID uint64) ([]uint64, error) {
+	return r.dbRepo.RemoveImage(tx, datasetID, imageID)
+}
+
+// RefreshTasks drops the cached tasks whose details were taken out and
+// updates their status.
+func (r *repository) RefreshTasks(taskIDs []uint64) {
+	for _, id := range taskIDs {
+		r.invalidateTask(id)
+		if err := r.checkTaskStatus(id, true); err != nil {
+			logger.Errorf("[TASK] - cannot update status of task %d after its details changed. err %v", id, err)
+		}
+	}
+}
+
+// TransitDetail moves a detail to status to on behalf of userID, who must
+// hold a role on the task allowing the transition, and returns the detail
+// along with its previous status. Moving a detail to its current status is a
+// no-op and is not recorded.
+func (r *repository) TransitDetail(taskID, detailID, userID uint64, to DetailStatus, comment string) (detail Detail, from DetailStatus, err error) {
+	if userID == 0 {
+		return Detail{}, AnyStatus, errors.BadRequest.NewWithMessage("the user changing a detail is missing")
+	}
+	t, err := r.GetTask(taskID)
+	if err != nil {
+		return Detail{}, AnyStatus, err
+	}
+	detail, err = r.dbRepo.GetTaskDetail(taskID, detailID)
+	if err != nil {
+		return Detail{}, AnyStatus, err
+	}
+	from = detail.Status
+	if from == to {
+		return detail, from, nil
+	}
+	role, ok := CanTransitDetail(RolesOf(t, userID), from, to)
+	if !ok {
+		return Detail{}, from, errors.TaskDetailInvalidTransition.NewWithMessageF("user %d cannot move detail %d from %s to %s", userID, detailID, from, to)
+	}
+	detail, err = r.dbRepo.TransitDetail(detail, userID, role, to, comment)
+	if err != nil {
+		return Detail{}, from, err
+	}
 	r.invalidateTask(taskID)
-	return r.dbRepo.UpdateTaskDetail(taskID, detailID, changes)
+	r.afterTransit(taskID, detailID, to)
+	logger.Infof("[TASK] - detail %d of task %d moved from %s to %s by %s %d", detailID, taskID, from, to, role, userID)
+	return detail, from, nil
+}
+
+// ClassifyDetail sets the labels of a detail on behalf of userID and moves it
+// to status to, Draft to save the labels or Labeled to submit them for
+// review. userID must hold a role allowing the transition, which keeps the
+// labels of a detail under review or approved from changing.
+func (r *repository) ClassifyDetail(taskID, detailID, userID uint64, labels []uint64, to DetailStatus) (detail Detail, from DetailStatus, err error) {
+	if userID == 0 {
+		return Detail{}, AnyStatus, errors.BadRequest.NewWithMessage("the user classifying a detail is missing")
+	}
+	t, err := r.GetTask(taskID)
+	if err != nil {
+		return Detail{}, AnyStatus, err
+	}
+	detail, err = r.dbRepo.GetTaskDetail(taskID, detailID)
+	if err != nil {
+		return Detail{}, AnyStatus, err
+	}
+	from = detail.Status
+	role, ok := CanTransitDetail(RolesOf(t, userID), from, to)
+	if !ok {
+		return Detail{}, from, errors.TaskDetailInvalidTransition.NewWithMessageF("user %d cannot classify detail %d while it is %s", userID, detailID, from)
+	}
+	detail, err = r.dbRepo.ClassifyDetail(detail, labels, userID, role, to)
+	if err != nil {
+		return Detail{}, from, err
+	}
+	r.invalidateTask(taskID)
+	r.afterTransit(taskID, detailID, to)
+	logger.Infof("[TASK] - detail %d of task %d classified and moved from %s to %s by %s %d", detailID, taskID, from, to, role, userID)
+	return detail, from, nil
+}
+
+// afterTransit drops the lease on a detail leaving draft.
+func (r *repository) afterTransit(taskID, detailID uint64, to DetailStatus) {
+	if to == Draft {
+		return
+	}
+	if err := r.dropLease(taskID, detailID); err != nil {
+		logger.Errorf("[TASK] - cannot release lease on detail %d of task %d. err %v", detailID, taskID, err)
+	}
+}
+
+func (r *repository) GetClassifiedDetails(projectID, datasetID uint64) ([]Detail, error) {
+	return r.dbRepo.GetClassifiedDetails(projectID, datasetID)
+}
+
+// RemapLabel moves label from to label to on the classified details of a
+// project within tx, the transaction deleting label from.
+func (r *repository) RemapLabel(tx *gorm.DB, projectID, from, to uint64) error {
+	if err := r.dbRepo.RemapLabel(tx, projectID, from, to); err != nil {
+		return err
+	}
+	logger.Infof("[TASK] - labels %d of project %d remapped to %d", from, projectID, to)
+	return nil
+}
+
+func (r *repository) GetHistory(taskID, detailID uint64, offset, limit int) (histories []History, total int, err error) {
+	return r.dbRepo.GetHistory(taskID, detailID, offset, limit)
 }
 
 func (r *repository) invalidateForUser(userID uint64) {
@@ -181,28 +325,156 @@ func (r *repository) UpdateTask(taskID uint64, changes map[string]interface{}) (
 	return task, nil
 }
 
-func (r *repository) CheckTaskStatus(taskID uint64, detailStatus DetailStatus) (err error) {
-	rl, s := relative(detailStatus)
-	var (
-		buffer []Detail
-		images = make([]Detail, 0)
-		total  int
-	)
-	for i := range rl {
-		buffer, total, err = r.dbRepo.GetTaskDetails(taskID, rl[i], 0, 0)
-		if err != nil {
-			return err
-		}
-		images = append(images, buffer...)
+func (r *repository) UpdateAssignees(taskID, labeler, reviewer uint64) (Task, error) {
+	old, err := r.GetTask(taskID)
+	if err != nil {
+		return Task{}, err
+	}
+	task, err := r.dbRepo.UpdateAssignees(taskID, labeler, reviewer)
+	if err != nil {
+		return Task{}, err
+	}
+	r.invalidateTask(taskID)
+	r.invalidateForProject(task.ProjectID)
+	for _, userID := range []uint64{old.Labeler, old.Reviewer, task.Labeler, task.Reviewer} {
+		r.invalidateForUser(userID)
+	}
+	logger.Infof("[TASK] - task %d reassigned. labeler %d -> %d, reviewer %d -> %d", taskID, old.Labeler, task.Labeler, old.Reviewer, task.Reviewer)
+	return task, nil
+}
+
+// ReassignUser hands the tasks userID labels or reviews in a project over to
+// successor, together with the change made by within.
+func (r *repository) ReassignUser(projectID, userID, successor uint64, within func(tx *gorm.DB) error) error {
+	tasks, err := r.dbRepo.ReassignUser(projectID, userID, successor, within)
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		r.invalidateTask(t.ID)
 	}
-	if len(images) != total {
+	r.invalidateForProject(projectID)
+	r.invalidateForUser(userID)
+	r.invalidateForUser(successor)
+	logger.Infof("[TASK] - %d tasks of project %d reassigned from user %d to %d", len(tasks), projectID, userID, successor)
+	return nil
+}
+
+func (r *repository) UpdateDeadline(taskID uint64, deadline Deadline) (Task, error) {
+	task, err := r.dbRepo.UpdateDeadline(taskID, deadline)
+	if err != nil {
+		return Task{}, err
+	}
+	r.invalidateTask(taskID)
+	r.invalidateForProject(task.ProjectID)
+	return task, nil
+}
+
+func (r *repository) GetOverdueByUser(userID uint64, now time.Time, offset, limit int) (tasks []Task, total int, err error) {
+	return r.dbRepo.GetOverdueByUser(userID, now, offset, limit)
+}
+
+// CheckTaskStatus recomputes the status of a task from its details. It is
+// called whenever details change status. The task is read from the database
+// since the transition only applies to its current status.
+func (r *repository) CheckTaskStatus(taskID uint64) error {
+	return r.checkTaskStatus(taskID, false)
+}
+
+// checkTaskStatus is CheckTaskStatus, reshaped when details moved in or out
+// of the task.
+func (r *repository) checkTaskStatus(taskID uint64, reshaped bool) error {
+	t, err := r.dbRepo.GetTask(taskID)
+	if err != nil {
+		return err
+	}
+	details, _, err := r.dbRepo.GetTaskDetails(taskID, AnyStatus, 0, 0)
+	if err != nil {
+		return err
+	}
+	if len(details) == 0 {
 		return nil
 	}
-	logger.Infof("[TASK] no more images of status %v of task %d - increasing task status...", rl, taskID)
-	_, err = r.UpdateTask(taskID, map[string]interface{}{
-		"status": s,
-	})
-	return
+	s := statusOf(details)
+	if s == t.Status {
+		return nil
+	}
+	if !canTransitTask(t.Status, s, reshaped) {
+		return errors.TaskInvalidTransition.NewWithMessageF("task %d cannot move from %s to %s", taskID, t.Status, s)
+	}
+	logger.Infof("[TASK] - task %d status changes from %s to %s", taskID, t.Status, s)
+	task, err := r.dbRepo.TransitTask(t, 0, s)
+	if err != nil {
+		return err
+	}
+	r.invalidateTask(taskID)
+	r.invalidateForProject(task.ProjectID)
+	return nil
+}
+
+func (r *repository) SplitTask(taskID, labeler, reviewer uint64, quantity int) (Task, error) {
+	t, err := r.GetTask(taskID)
+	if err != nil {
+		return Task{}, err
+	}
+	if labeler == t.Labeler {
+		return Task{}, errors.TaskCannotSplit.NewWithMessage("split task must go to a different labeler")
+	}
+	if reviewer == 0 {
+		reviewer = t.Reviewer
+	}
+	if labeler == reviewer {
+		return Task{}, errors.TaskCannotSplit.NewWithMessage("split task cannot be reviewed by its labeler")
+	}
+	created, err := r.dbRepo.SplitTask(t, labeler, reviewer, quantity)
+	if err != nil {
+		return Task{}, err
+	}
+	r.invalidateTask(taskID)
+	r.invalidateForProject(t.ProjectID)
+	for _, userID := range []uint64{labeler, reviewer} {
+		r.invalidateForUser(userID)
+	}
+	if err := r.checkTaskStatus(taskID, true); err != nil {
+		logger.Errorf("[TASK] - error checking status of task %d after split. err %v", taskID, err)
+	}
+	if err := r.checkTaskStatus(created.ID, true); err != nil {
+		logger.Errorf("[TASK] - error checking status of task %d after split. err %v", created.ID, err)
+	}
+	return created, nil
+}
+
+func (r *repository) MergeTasks(targetID, sourceID uint64) (Task, error) {
+	if targetID == sourceID {
+		return Task{}, errors.TaskCannotMerge.NewWithMessage("cannot merge a task into itself")
+	}
+	target, err := r.GetTask(targetID)
+	if err != nil {
+		return Task{}, err
+	}
+	source, err := r.GetTask(sourceID)
+	if err != nil {
+		return Task{}, err
+	}
+	if target.ProjectID != source.ProjectID || target.DatasetID != source.DatasetID {
+		return Task{}, errors.TaskCannotMerge.NewWithMessage("tasks must belong to the same project and dataset")
+	}
+	if target.GroupID != 0 && target.GroupID == source.GroupID {
+		return Task{}, errors.TaskCannotMerge.NewWithMessageF("tasks belong to the same consensus group %d", target.GroupID)
+	}
+	if err := r.dbRepo.MergeTasks(target, source); err != nil {
+		return Task{}, err
+	}
+	r.invalidateTask(targetID)
+	r.invalidateTask(sourceID)
+	r.invalidateForProject(target.ProjectID)
+	for _, userID := range []uint64{target.Labeler, target.Reviewer, source.Labeler, source.Reviewer, source.Assigner} {
+		r.invalidateForUser(userID)
+	}
+	if err := r.checkTaskStatus(targetID, true); err != nil {
+		logger.Errorf("[TASK] - error checking status of task %d after merge. err %v", targetID, err)
+	}
+	return r.GetTask(targetID)
 }
 
 func (r *repository) invalidateTask(id uint64) {
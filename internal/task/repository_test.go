@@ -0,0 +1,107 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/gorm"
+	"github.com/nkhang/pluto/pkg/util/clock"
+)
+
+// fakeReshapeRepo keeps tasks and their details in memory and records the
+// status changes.
+type fakeReshapeRepo struct {
+	DBRepository
+	tasks   map[uint64]Task
+	details map[uint64][]Detail
+	transit map[uint64]Status
+}
+
+func (r *fakeReshapeRepo) GetTask(taskID uint64) (Task, error) {
+	t, ok := r.tasks[taskID]
+	if !ok {
+		return Task{}, errors.TaskNotFound.NewWithMessage("task not found")
+	}
+	return t, nil
+}
+
+func (r *fakeReshapeRepo) GetTaskDetails(taskID uint64, status DetailStatus, currentID uint64, limit int) ([]Detail, int, error) {
+	return r.details[taskID], len(r.details[taskID]), nil
+}
+
+func (r *fakeReshapeRepo) TransitTask(t Task, userID uint64, to Status) (Task, error) {
+	r.transit[t.ID] = to
+	t.Status = to
+	r.tasks[t.ID] = t
+	return t, nil
+}
+
+// SplitTask moves the first quantity details of t into task 14.
+func (r *fakeReshapeRepo) SplitTask(t Task, labeler, reviewer uint64, quantity int) (Task, error) {
+	created := Task{Model: gorm.Model{ID: 14}, Labeler: labeler, Reviewer: reviewer, Status: Labeling}
+	r.tasks[created.ID] = created
+	r.details[created.ID] = r.details[t.ID][:quantity]
+	r.details[t.ID] = r.details[t.ID][quantity:]
+	return created, nil
+}
+
+func (r *fakeReshapeRepo) MergeTasks(target, source Task) error {
+	r.details[target.ID] = append(r.details[target.ID], r.details[source.ID]...)
+	delete(r.details, source.ID)
+	delete(r.tasks, source.ID)
+	return nil
+}
+
+func newReshapeRepository() (*repository, *fakeReshapeRepo) {
+	db := &fakeReshapeRepo{
+		tasks: map[uint64]Task{
+			3:  {Model: gorm.Model{ID: 3}, ProjectID: 6, Labeler: 10, Reviewer: 12, Status: Labeling},
+			13: {Model: gorm.Model{ID: 13}, ProjectID: 6, Labeler: 11, Reviewer: 12, Status: Reviewing},
+		},
+		details: map[uint64][]Detail{
+			3:  {{Model: gorm.Model{ID: 1}, Status: Pending}, {Model: gorm.Model{ID: 2}, Status: Labeled}},
+			13: {{Model: gorm.Model{ID: 1}, Status: Labeled}},
+		},
+		transit: make(map[uint64]Status),
+	}
+	return NewRepository(db, newFakeCache(), clock.NewMock(leaseStart)), db
+}
+
+func TestSplitTask(t *testing.T) {
+	tests := []struct {
+		name     string
+		labeler  uint64
+		reviewer uint64
+		err      errors.ErrorType
+	}{
+		{"another labeler", 11, 0, 0},
+		{"same labeler", 10, 0, errors.TaskCannotSplit},
+		{"labeler reviews", 12, 0, errors.TaskCannotSplit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, db := newReshapeRepository()
+			created, err := r.SplitTask(3, tt.labeler, tt.reviewer, 1)
+			if tt.err != 0 {
+				assert.Equal(t, tt.err, errors.Type(err))
+				assert.Len(t, db.details[3], 2, "nothing is split")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, uint64(12), created.Reviewer, "the split task keeps the reviewer by default")
+			assert.Equal(t, map[uint64]Status{3: Reviewing}, db.transit, "the task left with labeled images only moves on to review")
+		})
+	}
+}
+
+func TestMergeTasksRecomputesStatus(t *testing.T) {
+	r, db := newReshapeRepository()
+	db.details[3] = []Detail{{Model: gorm.Model{ID: 2}, Status: Approved}}
+	db.tasks[3] = Task{Model: gorm.Model{ID: 3}, ProjectID: 6, Labeler: 10, Reviewer: 12, Status: Done}
+	_, err := r.MergeTasks(3, 13)
+	require.NoError(t, err)
+	assert.Equal(t, map[uint64]Status{3: Reviewing}, db.transit, "a done task gets back to review with the merged labeled images")
+}
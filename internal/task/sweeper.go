@@ -0,0 +1,118 @@
+package task
+
+import (
+	"context"
+	"time"
+
+	"github.com/nkhang/pluto/pkg/logger"
+	"github.com/nkhang/pluto/pkg/util/clock"
+)
+
+// Publisher sends a message on a subject, *nats.EncodedConn satisfies it.
+type Publisher interface {
+	Publish(subject string, v interface{}) error
+}
+
+// OverdueEvent is published once a task misses a deadline. Times are in
+// milliseconds.
+type OverdueEvent struct {
+	TaskID    uint64 `json:"task_id"`
+	ProjectID uint64 `json:"project_id"`
+	DatasetID uint64 `json:"dataset_id"`
+	Assigner  uint64 `json:"assigner"`
+	Labeler   uint64 `json:"labeler"`
+	Reviewer  uint64 `json:"reviewer"`
+	Status    Status `json:"status"`
+	DueAt     int64  `json:"due_at"`
+	OverdueAt int64  `json:"overdue_at"`
+}
+
+type SweeperConfig struct {
+	Interval  time.Duration
+	BatchSize int
+	Subject   string
+}
+
+// Sweeper looks for tasks that missed a deadline and publishes an
+// OverdueEvent for each of them. A task is notified once until it changes
+// status or its deadline is edited. A failed publish is retried on the next
+// sweep.
+type Sweeper struct {
+	repo      DBRepository
+	publisher Publisher
+	clock     clock.Clock
+	conf      SweeperConfig
+}
+
+func NewSweeper(r DBRepository, p Publisher, c clock.Clock, conf SweeperConfig) *Sweeper {
+	if conf.Interval <= 0 {
+		conf.Interval = time.Minute
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = 100
+	}
+	if conf.Subject == "" {
+		conf.Subject = "task.overdue"
+	}
+	return &Sweeper{
+		repo:      r,
+		publisher: p,
+		clock:     c,
+		conf:      conf,
+	}
+}
+
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.conf.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.SweepOnce()
+		}
+	}
+}
+
+// SweepOnce publishes the tasks overdue at the current time and returns how
+// many of them were notified.
+func (s *Sweeper) SweepOnce() int {
+	now := s.clock.Now()
+	tasks, err := s.repo.GetOverdue(now, s.conf.BatchSize)
+	if err != nil {
+		logger.Errorf("[TASK] - cannot get overdue tasks. err %v", err)
+		return 0
+	}
+	var notified int
+	for _, t := range tasks {
+		due, ok := t.Overdue(now)
+		if !ok {
+			continue
+		}
+		event := OverdueEvent{
+			TaskID:    t.ID,
+			ProjectID: t.ProjectID,
+			DatasetID: t.DatasetID,
+			Assigner:  t.Assigner,
+			Labeler:   t.Labeler,
+			Reviewer:  t.Reviewer,
+			Status:    t.Status,
+			DueAt:     clock.UnixMillisecondFromTime(due),
+			OverdueAt: clock.UnixMillisecondFromTime(now),
+		}
+		if err := s.publisher.Publish(s.conf.Subject, &event); err != nil {
+			logger.Errorf("[TASK] - cannot publish overdue task %d. err %v", t.ID, err)
+			continue
+		}
+		if err := s.repo.MarkOverdueNotified(t.ID, now); err != nil {
+			logger.Errorf("[TASK] - cannot mark overdue task %d as notified. err %v", t.ID, err)
+			continue
+		}
+		notified++
+	}
+	if notified > 0 {
+		logger.Infof("[TASK] - notified %d overdue tasks", notified)
+	}
+	return notified
+}
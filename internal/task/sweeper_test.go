@@ -0,0 +1,146 @@
+package task
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nkhang/pluto/pkg/logger"
+	"github.com/nkhang/pluto/pkg/util/clock"
+)
+
+func TestMain(m *testing.M) {
+	logger.Initlialize(false)
+	os.Exit(m.Run())
+}
+
+// fakeDBRepo serves the overdue queries from memory, following the same rule
+// as Task.Overdue.
+type fakeDBRepo struct {
+	DBRepository
+	tasks    []Task
+	notified map[uint64]time.Time
+}
+
+func (r *fakeDBRepo) GetOverdue(now time.Time, limit int) ([]Task, error) {
+	var tasks []Task
+	for _, t := range r.tasks {
+		if _, ok := r.notified[t.ID]; ok {
+			continue
+		}
+		if _, ok := t.Overdue(now); ok {
+			tasks = append(tasks, t)
+		}
+	}
+	if len(tasks) > limit {
+		tasks = tasks[:limit]
+	}
+	return tasks, nil
+}
+
+func (r *fakeDBRepo) MarkOverdueNotified(taskID uint64, at time.Time) error {
+	r.notified[taskID] = at
+	return nil
+}
+
+type fakePublisher struct {
+	events []OverdueEvent
+	err    error
+}
+
+func (p *fakePublisher) Publish(subject string, v interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.events = append(p.events, *v.(*OverdueEvent))
+	return nil
+}
+
+func newTask(id uint64, status Status, labelDueAt, reviewDueAt *time.Time) Task {
+	t := Task{Status: status, Deadline: Deadline{LabelDueAt: labelDueAt, ReviewDueAt: reviewDueAt}}
+	t.ID = id
+	return t
+}
+
+func at(t time.Time) *time.Time {
+	return &t
+}
+
+func TestTaskOverdue(t *testing.T) {
+	var (
+		now    = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+		past   = now.Add(-time.Hour)
+		future = now.Add(time.Hour)
+	)
+	tests := []struct {
+		name    string
+		task    Task
+		overdue bool
+		due     time.Time
+	}{
+		{"no deadline", newTask(1, Labeling, nil, nil), false, time.Time{}},
+		{"labeling late", newTask(1, Labeling, at(past), at(future)), true, past},
+		{"labeling in time", newTask(1, Labeling, at(future), nil), false, time.Time{}},
+		{"labeling past review deadline", newTask(1, Labeling, nil, at(past)), true, past},
+		{"reviewing ignores labeling deadline", newTask(1, Reviewing, at(past), at(future)), false, time.Time{}},
+		{"reviewing late", newTask(1, Reviewing, at(past), at(past)), true, past},
+		{"done is never overdue", newTask(1, Done, at(past), at(past)), false, time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			due, overdue := test.task.Overdue(now)
+			assert.Equal(t, test.overdue, overdue)
+			assert.Equal(t, test.due, due)
+		})
+	}
+}
+
+func TestSweeperNotifiesOnce(t *testing.T) {
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	repo := &fakeDBRepo{
+		tasks: []Task{
+			newTask(1, Labeling, at(start.Add(time.Hour)), nil),
+			newTask(2, Reviewing, nil, at(start.Add(2*time.Hour))),
+			newTask(3, Done, nil, at(start.Add(time.Hour))),
+		},
+		notified: make(map[uint64]time.Time),
+	}
+	publisher := &fakePublisher{}
+	c := clock.NewMock(start)
+	sweeper := NewSweeper(repo, publisher, c, SweeperConfig{})
+
+	assert.Equal(t, 0, sweeper.SweepOnce())
+
+	c.Add(90 * time.Minute)
+	assert.Equal(t, 1, sweeper.SweepOnce())
+	assert.Equal(t, uint64(1), publisher.events[0].TaskID)
+	assert.Equal(t, clock.UnixMillisecondFromTime(start.Add(time.Hour)), publisher.events[0].DueAt)
+	assert.Equal(t, clock.UnixMillisecondFromTime(c.Now()), publisher.events[0].OverdueAt)
+
+	assert.Equal(t, 0, sweeper.SweepOnce())
+
+	c.Add(time.Hour)
+	assert.Equal(t, 1, sweeper.SweepOnce())
+	assert.Equal(t, uint64(2), publisher.events[1].TaskID)
+	assert.Len(t, repo.notified, 2)
+}
+
+func TestSweeperRetriesFailedPublish(t *testing.T) {
+	start := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	repo := &fakeDBRepo{
+		tasks:    []Task{newTask(1, Labeling, at(start.Add(-time.Minute)), nil)},
+		notified: make(map[uint64]time.Time),
+	}
+	publisher := &fakePublisher{err: errors.New("nats is down")}
+	sweeper := NewSweeper(repo, publisher, clock.NewMock(start), SweeperConfig{})
+
+	assert.Equal(t, 0, sweeper.SweepOnce())
+	assert.Empty(t, repo.notified)
+
+	publisher.err = nil
+	assert.Equal(t, 1, sweeper.SweepOnce())
+	assert.Contains(t, repo.notified, uint64(1))
+}
@@ -0,0 +1,138 @@
+package taskapi
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+type Strategy string
+
+const (
+	// StrategySequential hands each assignee the next Quantity images,
+	// wrapping around the dataset when it runs out.
+	StrategySequential Strategy = "sequential"
+	// StrategyUnassigned works like StrategySequential but skips images that
+	// already belong to a live task of the dataset, and does not wrap around.
+	StrategyUnassigned Strategy = "unassigned"
+	// StrategyRoundRobin deals the images one by one to the assignees.
+	StrategyRoundRobin Strategy = "round_robin"
+	// StrategyRandom shuffles the images with Seed before splitting them.
+	StrategyRandom Strategy = "random"
+	// StrategyLeastAnnotated hands out the images with the lowest status first.
+	StrategyLeastAnnotated Strategy = "least_annotated"
+)
+
+// allocate splits imgs between the assignees of the request. The returned
+// slice has one entry per assignee, in the same order.
+func allocate(imgs []image.Image, assigned []uint64, request CreateTaskRequest) ([][]image.Image, error) {
+	n := len(request.Assignees)
+	ordered, err := order(imgs, assigned, request)
+	if err != nil {
+		return nil, err
+	}
+	if request.Overlap > 1 {
+		return spread(ordered, n, request.Quantity, request.Overlap), nil
+	}
+	switch request.Strategy {
+	case "", StrategySequential:
+		var cursor = 0
+		allocations := make([][]image.Image, n)
+		for i := range allocations {
+			allocations[i] = truncate(ordered, &cursor, request.Quantity)
+		}
+		return allocations, nil
+	case StrategyRoundRobin:
+		allocations := make([][]image.Image, n)
+		for i := range ordered {
+			k := i % n
+			if len(allocations[k]) >= request.Quantity {
+				break
+			}
+			allocations[k] = append(allocations[k], ordered[i])
+		}
+		return allocations, nil
+	default:
+		return split(ordered, n, request.Quantity), nil
+	}
+}
+
+// order returns the images in the order the strategy hands them out.
+func order(imgs []image.Image, assigned []uint64, request CreateTaskRequest) ([]image.Image, error) {
+	switch request.Strategy {
+	case "", StrategySequential, StrategyRoundRobin:
+		return imgs, nil
+	case StrategyUnassigned:
+		return exclude(imgs, assigned), nil
+	case StrategyRandom:
+		shuffled := make([]image.Image, len(imgs))
+		copy(shuffled, imgs)
+		rnd := rand.New(rand.NewSource(request.Seed))
+		rnd.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		return shuffled, nil
+	case StrategyLeastAnnotated:
+		sorted := make([]image.Image, len(imgs))
+		copy(sorted, imgs)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].Status < sorted[j].Status
+		})
+		return sorted, nil
+	default:
+		return nil, errors.TaskCannotCreate.NewWithMessageF("allocation strategy %s is not supported", request.Strategy)
+	}
+}
+
+// spread hands every image to overlap consecutive assignees, cycling through
+// them, until each assignee holds quantity images. As long as overlap does
+// not exceed n, the copies of an image always go to different assignees.
+func spread(imgs []image.Image, n, quantity, overlap int) [][]image.Image {
+	allocations := make([][]image.Image, n)
+	var cursor = 0
+loop:
+	for i := range imgs {
+		for k := 0; k < overlap; k++ {
+			if len(allocations[(cursor+k)%n]) >= quantity {
+				break loop
+			}
+		}
+		for k := 0; k < overlap; k++ {
+			allocations[cursor] = append(allocations[cursor], imgs[i])
+			cursor = (cursor + 1) % n
+		}
+	}
+	return allocations
+}
+
+// split gives each of the n assignees up to quantity consecutive images
+// without handing out the same image twice.
+func split(imgs []image.Image, n, quantity int) [][]image.Image {
+	allocations := make([][]image.Image, n)
+	var cursor = 0
+	for i := range allocations {
+		end := cursor + quantity
+		if end > len(imgs) {
+			end = len(imgs)
+		}
+		allocations[i] = imgs[cursor:end]
+		cursor = end
+	}
+	return allocations
+}
+
+func exclude(imgs []image.Image, ids []uint64) []image.Image {
+	var excluded = make(map[uint64]struct{}, len(ids))
+	for _, id := range ids {
+		excluded[id] = struct{}{}
+	}
+	res := make([]image.Image, 0, len(imgs))
+	for i := range imgs {
+		if _, ok := excluded[imgs[i].ID]; !ok {
+			res = append(res, imgs[i])
+		}
+	}
+	return res
+}
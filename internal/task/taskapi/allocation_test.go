@@ -0,0 +1,181 @@
+package taskapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/gorm"
+)
+
+// newImages returns images 1 to n, the status of each is given by statuses
+// when set.
+func newImages(n int, statuses ...uint32) []image.Image {
+	imgs := make([]image.Image, n)
+	for i := range imgs {
+		imgs[i] = image.Image{Model: gorm.Model{ID: uint64(i + 1)}}
+		if i < len(statuses) {
+			imgs[i].Status = statuses[i]
+		}
+	}
+	return imgs
+}
+
+func imageIDs(allocations [][]image.Image) [][]uint64 {
+	ids := make([][]uint64, len(allocations))
+	for i, imgs := range allocations {
+		ids[i] = []uint64{}
+		for _, img := range imgs {
+			ids[i] = append(ids[i], img.ID)
+		}
+	}
+	return ids
+}
+
+func assignees(n int) []AssigneePair {
+	pairs := make([]AssigneePair, n)
+	for i := range pairs {
+		pairs[i] = AssigneePair{Labeler: uint64(10 + i), Reviewer: 99}
+	}
+	return pairs
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name      string
+		images    []image.Image
+		assigned  []uint64
+		strategy  Strategy
+		assignees int
+		quantity  int
+		want      [][]uint64
+	}{
+		{"sequential", newImages(6), nil, StrategySequential, 2, 3, [][]uint64{{1, 2, 3}, {4, 5, 6}}},
+		{"default is sequential", newImages(4), nil, "", 2, 2, [][]uint64{{1, 2}, {3, 4}}},
+		{"sequential wraps around", newImages(5), nil, StrategySequential, 2, 3, [][]uint64{{1, 2, 3}, {4, 5, 1}}},
+		{"sequential with fewer images than quantity", newImages(2), nil, StrategySequential, 2, 3, [][]uint64{{1, 2}, {1, 2}}},
+		{"unassigned skips assigned images", newImages(6), []uint64{2, 3}, StrategyUnassigned, 2, 2, [][]uint64{{1, 4}, {5, 6}}},
+		{"unassigned does not wrap around", newImages(6), []uint64{1, 2, 3}, StrategyUnassigned, 2, 2, [][]uint64{{4, 5}, {6}}},
+		{"round robin", newImages(6), nil, StrategyRoundRobin, 2, 3, [][]uint64{{1, 3, 5}, {2, 4, 6}}},
+		{"round robin uneven", newImages(5), nil, StrategyRoundRobin, 3, 2, [][]uint64{{1, 4}, {2, 5}, {3}}},
+		{"round robin stops at quantity", newImages(9), nil, StrategyRoundRobin, 2, 2, [][]uint64{{1, 3}, {2, 4}}},
+		{"least annotated", newImages(4, 2, 0, 1, 0), nil, StrategyLeastAnnotated, 2, 2, [][]uint64{{2, 4}, {3, 1}}},
+		{"least annotated uneven", newImages(3, 1, 0, 0), nil, StrategyLeastAnnotated, 2, 2, [][]uint64{{2, 3}, {1}}},
+		{"zero images", newImages(0), nil, StrategySequential, 2, 2, [][]uint64{{}, {}}},
+		{"zero images round robin", newImages(0), nil, StrategyRoundRobin, 2, 2, [][]uint64{{}, {}}},
+		{"zero images least annotated", newImages(0), nil, StrategyLeastAnnotated, 2, 2, [][]uint64{{}, {}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocations, err := allocate(tt.images, tt.assigned, CreateTaskRequest{
+				Strategy:  tt.strategy,
+				Assignees: assignees(tt.assignees),
+				Quantity:  tt.quantity,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, imageIDs(allocations))
+		})
+	}
+}
+
+func TestAllocateRandom(t *testing.T) {
+	request := CreateTaskRequest{Strategy: StrategyRandom, Seed: 42, Assignees: assignees(3), Quantity: 3}
+	first, err := allocate(newImages(10), nil, request)
+	require.NoError(t, err)
+	again, err := allocate(newImages(10), nil, request)
+	require.NoError(t, err)
+	assert.Equal(t, imageIDs(first), imageIDs(again), "the same seed gives the same allocation")
+
+	var seen = make(map[uint64]bool)
+	for _, ids := range imageIDs(first) {
+		assert.Len(t, ids, 3)
+		for _, id := range ids {
+			assert.False(t, seen[id], "image %d is handed out twice", id)
+			seen[id] = true
+		}
+	}
+
+	uneven, err := allocate(newImages(4), nil, request)
+	require.NoError(t, err)
+	var lengths []int
+	for _, ids := range imageIDs(uneven) {
+		lengths = append(lengths, len(ids))
+	}
+	assert.Equal(t, []int{3, 1, 0}, lengths)
+}
+
+func TestAllocateUnknownStrategy(t *testing.T) {
+	_, err := allocate(newImages(3), nil, CreateTaskRequest{Strategy: "by_size", Assignees: assignees(1), Quantity: 1})
+	assert.Equal(t, errors.TaskCannotCreate, errors.Type(err))
+}
+
+func TestSpread(t *testing.T) {
+	tests := []struct {
+		name     string
+		images   int
+		n        int
+		quantity int
+		overlap  int
+		want     [][]uint64
+	}{
+		{"even", 6, 3, 4, 2, [][]uint64{{1, 2, 4, 5}, {1, 3, 4, 6}, {2, 3, 5, 6}}},
+		{"uneven", 5, 3, 4, 2, [][]uint64{{1, 2, 4, 5}, {1, 3, 4}, {2, 3, 5}}},
+		{"overlap equal to assignees", 3, 3, 2, 3, [][]uint64{{1, 2}, {1, 2}, {1, 2}}},
+		{"stops at quantity", 10, 2, 2, 2, [][]uint64{{1, 2}, {1, 2}}},
+		{"zero images", 0, 2, 2, 2, [][]uint64{{}, {}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocations := spread(newImages(tt.images), tt.n, tt.quantity, tt.overlap)
+			assert.Equal(t, tt.want, imageIDs(allocations))
+			for i, ids := range imageIDs(allocations) {
+				var seen = make(map[uint64]bool)
+				for _, id := range ids {
+					assert.False(t, seen[id], "image %d is handed twice to assignee %d", id, i)
+					seen[id] = true
+				}
+			}
+		})
+	}
+}
+
+func TestAllocateOverlap(t *testing.T) {
+	allocations, err := allocate(newImages(4), nil, CreateTaskRequest{
+		Strategy:  StrategyRoundRobin,
+		Assignees: assignees(2),
+		Quantity:  3,
+		Overlap:   2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]uint64{{1, 2, 3}, {1, 2, 3}}, imageIDs(allocations), "overlap spreads the images whatever the strategy")
+}
+
+func TestVerifyOverlap(t *testing.T) {
+	twice := []AssigneePair{{Labeler: 10, Reviewer: 99}, {Labeler: 10, Reviewer: 98}, {Labeler: 11, Reviewer: 99}}
+	tests := []struct {
+		name      string
+		assignees []AssigneePair
+		overlap   int
+		ok        bool
+	}{
+		{"no overlap", assignees(2), 0, true},
+		{"overlap of one", twice, 1, true},
+		{"overlap below assignees", assignees(3), 2, true},
+		{"overlap equal to assignees", assignees(3), 3, true},
+		{"overlap above assignees", assignees(2), 3, false},
+		{"labeler assigned twice", twice, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyOverlap(CreateTaskRequest{Assignees: tt.assignees, Overlap: tt.overlap})
+			if tt.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, errors.TaskCannotCreate, errors.Type(err))
+			}
+		})
+	}
+}
@@ -15,6 +15,29 @@ type CreateTaskRequest struct {
 	DatasetID   uint64         `json:"dataset_id" form:"dataset_id" binding:"required"`
 	Quantity    int            `json:"quantity" form:"quantity" binding:"required"`
 	Assignees   []AssigneePair `json:"assignees" form:"assignees" binding:"required"`
+	Strategy    Strategy       `json:"strategy" form:"strategy"`
+	Seed        int64          `json:"seed" form:"seed"`
+	DryRun      bool           `json:"dry_run" form:"dry_run"`
+	Overlap     int            `json:"overlap" form:"overlap"`
+	Priority    int            `json:"priority" form:"priority"`
+	LabelDueAt  int64          `json:"label_due_at" form:"label_due_at"`
+	ReviewDueAt int64          `json:"review_due_at" form:"review_due_at"`
+}
+
+type CreateTaskResponse struct {
+	DryRun      bool                 `json:"dry_run"`
+	Strategy    Strategy             `json:"strategy"`
+	Overlap     int                  `json:"overlap,omitempty"`
+	GroupID     uint64               `json:"group_id,omitempty"`
+	Allocations []AllocationResponse `json:"allocations"`
+}
+
+type AllocationResponse struct {
+	TaskID     uint64   `json:"task_id,omitempty"`
+	Labeler    uint64   `json:"labeler"`
+	Reviewer   uint64   `json:"reviewer"`
+	ImageCount int      `json:"image_count"`
+	ImageIDs   []uint64 `json:"image_ids"`
 }
 
 const (
@@ -22,6 +45,7 @@ const (
 	SrcAssignerTasks
 	SrcLabelingTasks
 	SrcReviewingTasks
+	SrcOverdueTasks
 )
 
 type GetTasksRequest struct {
@@ -40,27 +64,128 @@ type AssigneePair struct {
 	Reviewer uint64 `json:"reviewer" form:"reviewer" binding:"required"`
 }
 
+type UpdateAssigneesRequest struct {
+	Labeler  uint64 `json:"labeler" form:"labeler"`
+	Reviewer uint64 `json:"reviewer" form:"reviewer"`
+}
+
+// UpdatePriorityRequest sets the priority of a task, higher priorities are
+// handed out first by the work queue.
+type UpdatePriorityRequest struct {
+	Priority int `json:"priority" form:"priority"`
+}
+
+// UpdateDeadlineRequest replaces the due dates of a task, in milliseconds.
+// A zero date removes that deadline.
+type UpdateDeadlineRequest struct {
+	LabelDueAt  int64 `json:"label_due_at" form:"label_due_at"`
+	ReviewDueAt int64 `json:"review_due_at" form:"review_due_at"`
+}
+
+type SplitTaskRequest struct {
+	Labeler  uint64 `json:"labeler" form:"labeler" binding:"required"`
+	Reviewer uint64 `json:"reviewer" form:"reviewer"`
+	Quantity int    `json:"quantity" form:"quantity"`
+}
+
+type SplitTaskResponse struct {
+	Original TaskResponse `json:"original"`
+	Created  TaskResponse `json:"created"`
+}
+
+type MergeTaskRequest struct {
+	TaskID uint64 `json:"task_id" form:"task_id" binding:"required"`
+}
+
 type GetTaskDetailsRequest struct {
-	CurrentID uint64            `form:"current_id" json:"current_id"`
-	PageSize  int               `form:"page_size" json:"page_size"`
-	Status    task.DetailStatus `form:"status" json:"status"`
+	CurrentID   uint64            `form:"current_id" json:"current_id"`
+	PageSize    int               `form:"page_size" json:"page_size"`
+	Status      task.DetailStatus `form:"status" json:"status"`
+	NeedsRework bool              `form:"needs_rework" json:"needs_rework"`
 }
 
+// UpdateTaskDetailRequest changes the status of a detail on behalf of
+// UserID, the transition must be allowed for one of the user's roles on the
+// task. Rejecting a detail requires a Comment telling the labeler what to
+// rework.
 type UpdateTaskDetailRequest struct {
-	Status task.DetailStatus `form:"status" json:"status"`
+	UserID  uint64            `form:"user_id" json:"user_id"`
+	Status  task.DetailStatus `form:"status" json:"status"`
+	Comment string            `form:"comment" json:"comment,omitempty"`
+}
+
+// ClassifyDetailRequest sets the labels of an image in a classification
+// project. The labels are saved as a draft unless Submit sends them for
+// review, in which case at least one label is required.
+type ClassifyDetailRequest struct {
+	LabelIDs []uint64 `form:"label_ids" json:"label_ids"`
+	Submit   bool     `form:"submit" json:"submit"`
+}
+
+// ReviewDetailRequest approves or rejects the labels of an image in a
+// classification project.
+type ReviewDetailRequest struct {
+	Status  task.DetailStatus `form:"status" json:"status" binding:"required"`
+	Comment string            `form:"comment" json:"comment"`
 }
 
 type NATSUpdateDetailRequest struct {
 	TaskID   uint64            `json:"task"`
 	DetailID uint64            `json:"task_detail"`
+	UserID   uint64            `json:"user_id"`
 	Status   task.DetailStatus `form:"status" json:"status"`
+	Comment  string            `json:"comment"`
+}
+
+type GetHistoryRequest struct {
+	DetailID uint64 `json:"detail_id" form:"detail_id"`
+	Page     int    `json:"page" form:"page"`
+	PageSize int    `json:"page_size" form:"page_size"`
+}
+
+type GetHistoryResponse struct {
+	Total     int               `json:"total"`
+	Histories []HistoryResponse `json:"histories"`
+}
+
+// HistoryResponse is a status change of a task, or of one of its details
+// when DetailID is set. From and To are detail statuses for the latter and
+// task statuses otherwise.
+type HistoryResponse struct {
+	ID        uint64 `json:"id"`
+	TaskID    uint64 `json:"task_id"`
+	DetailID  uint64 `json:"detail_id,omitempty"`
+	UserID    uint64 `json:"user_id"`
+	Role      string `json:"role"`
+	From      int32  `json:"from"`
+	To        int32  `json:"to"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// WorkItemResponse is the next image for a labeler to work on, reserved for
+// the client until LeaseExpiresAt, in milliseconds. The client gives
+// LeaseToken back to release it.
+type WorkItemResponse struct {
+	Task           TaskResponse       `json:"task"`
+	Detail         TaskDetailResponse `json:"detail"`
+	LeaseToken     string             `json:"lease_token"`
+	LeaseExpiresAt int64              `json:"lease_expires_at"`
+}
+
+// ReleaseWorkItemRequest holds the token of the lease to release. An
+// assigner releases without it.
+type ReleaseWorkItemRequest struct {
+	Token string `form:"token"`
 }
 
 type TaskDetailResponse struct {
-	ID     uint64                 `json:"id"`
-	Status int32                  `json:"status"`
-	TaskID uint64                 `json:"task_id"`
-	Image  imageapi.ImageResponse `json:"image"`
+	ID          uint64                 `json:"id"`
+	Status      int32                  `json:"status"`
+	TaskID      uint64                 `json:"task_id"`
+	Comment     string                 `json:"comment"`
+	ReworkCount int                    `json:"rework_count"`
+	Labels      []uint64               `json:"labels,omitempty"`
+	Image       imageapi.ImageResponse `json:"image"`
 }
 
 type TaskResponse struct {
@@ -73,9 +198,15 @@ type TaskResponse struct {
 	Labeler     uint64                     `json:"labeler"`
 	Reviewer    uint64                     `json:"reviewer"`
 	Status      uint32                     `json:"status"`
+	Priority    int                        `json:"priority"`
 	ImageCount  int                        `json:"image_count"`
 	CreatedAt   int64                      `json:"created_at"`
 	Dataset     datasetapi.DatasetResponse `json:"dataset"`
+	LabelDueAt  int64                      `json:"label_due_at,omitempty"`
+	ReviewDueAt int64                      `json:"review_due_at,omitempty"`
+	DoneAt      int64                      `json:"done_at,omitempty"`
+	Overdue     bool                       `json:"overdue"`
+	Age         int64                      `json:"age"`
 }
 
 type ProjectObject struct {
@@ -98,9 +229,12 @@ type PushTaskMessage struct {
 
 func ToTaskDetailResponse(detail task.Detail) TaskDetailResponse {
 	return TaskDetailResponse{
-		ID:     detail.ID,
-		Status: int32(detail.Status),
-		TaskID: detail.TaskID,
-		Image:  imageapi.ToImageResponse(detail.Image),
+		ID:          detail.ID,
+		Status:      int32(detail.Status),
+		TaskID:      detail.TaskID,
+		Comment:     detail.Comment,
+		ReworkCount: detail.ReworkCount,
+		Labels:      detail.LabelIDs(),
+		Image:       imageapi.ToImageResponse(detail.Image),
 	}
 }
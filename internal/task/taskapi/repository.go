@@ -1,14 +1,12 @@
 package taskapi
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/nkhang/pluto/internal/workspace/workspaceapi"
 
-	"github.com/nkhang/pluto/pkg/annotation"
-
 	"github.com/nkhang/pluto/pkg/logger"
 
 	"github.com/nkhang/pluto/pkg/util/clock"
@@ -17,6 +15,8 @@ import (
 
 	"github.com/nkhang/pluto/internal/dataset/datasetapi"
 	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/project"
 	"github.com/nkhang/pluto/internal/project/projectapi"
 	"github.com/nkhang/pluto/internal/task"
 	"github.com/nkhang/pluto/pkg/util/paging"
@@ -26,31 +26,50 @@ type Repository interface {
 	GetTasks(userID uint64, request GetTasksRequest) (response GetTaskResponse, err error)
 	GetTaskForProject(projectID, userID uint64, request GetTasksRequest) (response GetTaskResponse, err error)
 	GetTask(taskID uint64) (TaskResponse, error)
-	CreateTask(projectID, assigner uint64, request CreateTaskRequest) error
+	CreateTask(projectID, assigner uint64, request CreateTaskRequest) (CreateTaskResponse, error)
 	DeleteTask(taskID uint64) error
+	UpdateAssignees(taskID uint64, request UpdateAssigneesRequest) (TaskResponse, error)
+	UpdateDeadline(taskID uint64, request UpdateDeadlineRequest) (TaskResponse, error)
+	UpdatePriority(taskID uint64, request UpdatePriorityRequest) (TaskResponse, error)
+	NextWorkItem(userID uint64) (WorkItemResponse, error)
+	ReleaseWorkItem(taskID, detailID, userID uint64, token string) error
+	SplitTask(taskID uint64, request SplitTaskRequest) (SplitTaskResponse, error)
+	MergeTask(taskID uint64, request MergeTaskRequest) (TaskResponse, error)
 	GetTaskDetails(taskID uint64, request GetTaskDetailsRequest) ([]TaskDetailResponse, error)
 	UpdateTaskDetail(taskID, detailID uint64, request UpdateTaskDetailRequest) (TaskDetailResponse, error)
+	ClassifyDetail(taskID, detailID, userID uint64, request ClassifyDetailRequest) (TaskDetailResponse, error)
+	ReviewDetail(taskID, detailID, userID uint64, request ReviewDetailRequest) (TaskDetailResponse, error)
+	GetHistory(taskID uint64, request GetHistoryRequest) (GetHistoryResponse, error)
 }
 
 type repository struct {
-	repository        task.Repository
-	imgRepo           image.Repository
-	datasetRepo       datasetapi.Repository
-	projectRepo       projectapi.Repository
-	annotationService annotation.Service
+	repository  task.Repository
+	imgRepo     image.Repository
+	datasetRepo datasetapi.Repository
+	projectRepo projectapi.Repository
+	labelRepo   label.Repository
+	clock       clock.Clock
+	leaseTTL    time.Duration
 }
 
 func NewRepository(r task.Repository,
 	ir image.Repository,
 	datasetRepo datasetapi.Repository,
 	projectRepo projectapi.Repository,
-	annotationService annotation.Service) *repository {
+	labelRepo label.Repository,
+	c clock.Clock,
+	leaseTTL time.Duration) *repository {
+	if leaseTTL <= 0 {
+		leaseTTL = 5 * time.Minute
+	}
 	return &repository{
-		repository:        r,
-		imgRepo:           ir,
-		datasetRepo:       datasetRepo,
-		projectRepo:       projectRepo,
-		annotationService: annotationService,
+		repository:  r,
+		imgRepo:     ir,
+		datasetRepo: datasetRepo,
+		projectRepo: projectRepo,
+		labelRepo:   labelRepo,
+		clock:       c,
+		leaseTTL:    leaseTTL,
 	}
 }
 
@@ -89,6 +108,11 @@ func (r *repository) GetTasks(userID uint64, request GetTasksRequest) (response
 		if err != nil {
 			return GetTaskResponse{}, err
 		}
+	case SrcOverdueTasks:
+		tasks, total, err = r.repository.GetOverdueByUser(userID, r.clock.Now(), offset, limit)
+		if err != nil {
+			return GetTaskResponse{}, err
+		}
 	default:
 		return GetTaskResponse{}, errors.TaskCannotGet.NewWithMessage("role is not supported")
 	}
@@ -102,51 +126,221 @@ func (r *repository) GetTasks(userID uint64, request GetTasksRequest) (response
 	}, nil
 }
 
-func (r *repository) CreateTask(projectID, assigner uint64, request CreateTaskRequest) error {
+func (r *repository) CreateTask(projectID, assigner uint64, request CreateTaskRequest) (CreateTaskResponse, error) {
+	if len(request.Assignees) == 0 || request.Quantity <= 0 {
+		return CreateTaskResponse{}, errors.TaskCannotCreate.NewWithMessage("assignees and quantity must not be empty")
+	}
+	if err := verifyOverlap(request); err != nil {
+		return CreateTaskResponse{}, err
+	}
+	deadline, err := toDeadline(request.LabelDueAt, request.ReviewDueAt, errors.TaskCannotCreate)
+	if err != nil {
+		return CreateTaskResponse{}, err
+	}
 	imgs, err := r.imgRepo.GetAllImageByDataset(request.DatasetID)
 	if err != nil {
-		return err
+		return CreateTaskResponse{}, err
 	}
 	if len(imgs) == 0 {
-		return errors.TaskCannotCreate.NewWithMessageF("dataset %d has no images, abort", request.DatasetID)
+		return CreateTaskResponse{}, errors.TaskCannotCreate.NewWithMessageF("dataset %d has no images, abort", request.DatasetID)
 	}
-	var errs = make([]error, 0)
-	var cursor = 0
-	var tasks = make([]task.Task, 0)
-	for _, pair := range request.Assignees {
-		truncated := truncate(imgs, &cursor, request.Quantity)
-		ids := make([]uint64, len(truncated))
-		for j := range truncated {
-			ids[j] = truncated[j].ID
+	var assigned []uint64
+	if request.Strategy == StrategyUnassigned {
+		assigned, err = r.repository.GetAssignedImages(request.DatasetID)
+		if err != nil {
+			return CreateTaskResponse{}, err
+		}
+	}
+	allocations, err := allocate(imgs, assigned, request)
+	if err != nil {
+		return CreateTaskResponse{}, err
+	}
+	var response = CreateTaskResponse{
+		DryRun:      request.DryRun,
+		Strategy:    request.Strategy,
+		Overlap:     request.Overlap,
+		Allocations: make([]AllocationResponse, len(allocations)),
+	}
+	if response.Strategy == "" {
+		response.Strategy = StrategySequential
+	}
+	for i, pair := range request.Assignees {
+		ids := make([]uint64, len(allocations[i]))
+		for j := range allocations[i] {
+			ids[j] = allocations[i][j].ID
+		}
+		response.Allocations[i] = AllocationResponse{
+			Labeler:    pair.Labeler,
+			Reviewer:   pair.Reviewer,
+			ImageCount: len(ids),
+			ImageIDs:   ids,
+		}
+	}
+	if request.DryRun {
+		return response, nil
+	}
+	for i := range response.Allocations {
+		if response.Allocations[i].ImageCount == 0 {
+			return CreateTaskResponse{}, errors.TaskCannotCreate.NewWithMessageF("not enough images in dataset %d to allocate for every assignee", request.DatasetID)
 		}
-		task, err := r.repository.CreateTask(request.Title, request.Description, assigner, pair.Labeler, pair.Reviewer, projectID, request.DatasetID, ids)
+	}
+	if request.Overlap > 1 {
+		group, err := r.repository.CreateGroup(projectID, request.DatasetID, request.Overlap)
 		if err != nil {
-			errs = append(errs, err)
+			return CreateTaskResponse{}, err
 		}
-		tasks = append(tasks, task)
+		response.GroupID = group.ID
 	}
-	if len(tasks) != 0 {
-		err := r.annotationService.CreateTask(projectID, request.DatasetID, tasks)
+	var errs = make([]error, 0)
+	for i, pair := range request.Assignees {
+		task, err := r.repository.CreateTask(request.Title, request.Description, assigner, pair.Labeler, pair.Reviewer, projectID, request.DatasetID, response.GroupID, request.Priority, deadline, response.Allocations[i].ImageIDs)
 		if err != nil {
-			logger.Errorf("create task to annotation server error. err %v", err)
+			errs = append(errs, err)
+			continue
 		}
+		response.Allocations[i].TaskID = task.ID
 	}
 	if len(errs) == 0 {
-		return nil
+		return response, nil
 	}
 	msg := fmt.Sprintf("failed to create %d tasks", len(errs))
 	for i := range errs {
 		logger.Infof("error creating task %v", errs[i])
 	}
-	return errors.TaskCannotCreate.NewWithMessage(msg)
+	return CreateTaskResponse{}, errors.TaskCannotCreate.NewWithMessage(msg)
+}
+
+// verifyOverlap makes sure every copy of an image can go to a different
+// labeler when the request asks for consensus mode.
+func verifyOverlap(request CreateTaskRequest) error {
+	if request.Overlap <= 1 {
+		return nil
+	}
+	if request.Overlap > len(request.Assignees) {
+		return errors.TaskCannotCreate.NewWithMessageF("overlap %d exceeds the number of assignees %d", request.Overlap, len(request.Assignees))
+	}
+	var labelers = make(map[uint64]struct{})
+	for _, pair := range request.Assignees {
+		if _, ok := labelers[pair.Labeler]; ok {
+			return errors.TaskCannotCreate.NewWithMessageF("labeler %d is assigned twice, overlap requires different labelers", pair.Labeler)
+		}
+		labelers[pair.Labeler] = struct{}{}
+	}
+	return nil
 }
 
 func (r *repository) DeleteTask(taskID uint64) error {
 	return r.repository.DeleteTask(taskID)
 }
 
+func (r *repository) UpdateAssignees(taskID uint64, request UpdateAssigneesRequest) (TaskResponse, error) {
+	if request.Labeler == 0 && request.Reviewer == 0 {
+		return TaskResponse{}, errors.TaskCannotUpdate.NewWithMessage("labeler or reviewer must be provided")
+	}
+	t, err := r.repository.UpdateAssignees(taskID, request.Labeler, request.Reviewer)
+	if err != nil {
+		return TaskResponse{}, err
+	}
+	return r.ToTaskResponse(t), nil
+}
+
+func (r *repository) UpdateDeadline(taskID uint64, request UpdateDeadlineRequest) (TaskResponse, error) {
+	deadline, err := toDeadline(request.LabelDueAt, request.ReviewDueAt, errors.TaskCannotUpdate)
+	if err != nil {
+		return TaskResponse{}, err
+	}
+	t, err := r.repository.UpdateDeadline(taskID, deadline)
+	if err != nil {
+		return TaskResponse{}, err
+	}
+	return r.ToTaskResponse(t), nil
+}
+
+func (r *repository) UpdatePriority(taskID uint64, request UpdatePriorityRequest) (TaskResponse, error) {
+	t, err := r.repository.UpdateTask(taskID, map[string]interface{}{
+		"priority": request.Priority,
+	})
+	if err != nil {
+		return TaskResponse{}, err
+	}
+	return r.ToTaskResponse(t), nil
+}
+
+func (r *repository) NextWorkItem(userID uint64) (WorkItemResponse, error) {
+	t, detail, lease, err := r.repository.NextDetail(userID, r.leaseTTL)
+	if err != nil {
+		return WorkItemResponse{}, err
+	}
+	return WorkItemResponse{
+		Task:           r.ToTaskResponse(t),
+		Detail:         ToTaskDetailResponse(detail),
+		LeaseToken:     lease.Token,
+		LeaseExpiresAt: clock.UnixMillisecondFromTime(lease.ExpiresAt),
+	}, nil
+}
+
+func (r *repository) ReleaseWorkItem(taskID, detailID, userID uint64, token string) error {
+	return r.repository.ReleaseLease(taskID, detailID, userID, token)
+}
+
+// toDeadline builds a deadline from dates in milliseconds, zero meaning no
+// date. Labeling cannot be due after the review, errType is reported
+// otherwise.
+func toDeadline(labelDueAt, reviewDueAt int64, errType errors.ErrorType) (task.Deadline, error) {
+	if labelDueAt < 0 || reviewDueAt < 0 {
+		return task.Deadline{}, errType.NewWithMessage("due dates must not be negative")
+	}
+	if labelDueAt != 0 && reviewDueAt != 0 && labelDueAt > reviewDueAt {
+		return task.Deadline{}, errType.NewWithMessageF("labeling is due at %d, after the review at %d", labelDueAt, reviewDueAt)
+	}
+	var deadline task.Deadline
+	if labelDueAt != 0 {
+		t := clock.TimeFromUnixMillisecond(labelDueAt)
+		deadline.LabelDueAt = &t
+	}
+	if reviewDueAt != 0 {
+		t := clock.TimeFromUnixMillisecond(reviewDueAt)
+		deadline.ReviewDueAt = &t
+	}
+	return deadline, nil
+}
+
+func millisecondsOrZero(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return clock.UnixMillisecondFromTime(*t)
+}
+
+func (r *repository) SplitTask(taskID uint64, request SplitTaskRequest) (SplitTaskResponse, error) {
+	created, err := r.repository.SplitTask(taskID, request.Labeler, request.Reviewer, request.Quantity)
+	if err != nil {
+		return SplitTaskResponse{}, err
+	}
+	original, err := r.repository.GetTask(taskID)
+	if err != nil {
+		return SplitTaskResponse{}, err
+	}
+	return SplitTaskResponse{
+		Original: r.ToTaskResponse(original),
+		Created:  r.ToTaskResponse(created),
+	}, nil
+}
+
+func (r *repository) MergeTask(taskID uint64, request MergeTaskRequest) (TaskResponse, error) {
+	t, err := r.repository.MergeTasks(taskID, request.TaskID)
+	if err != nil {
+		return TaskResponse{}, err
+	}
+	return r.ToTaskResponse(t), nil
+}
+
 func (r *repository) GetTaskDetails(taskID uint64, request GetTaskDetailsRequest) ([]TaskDetailResponse, error) {
-	details, _, err := r.repository.GetTaskDetails(taskID, request.Status, request.CurrentID, request.PageSize)
+	status := request.Status
+	if request.NeedsRework {
+		status = task.Rejected
+	}
+	details, _, err := r.repository.GetTaskDetails(taskID, status, request.CurrentID, request.PageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -158,27 +352,152 @@ func (r *repository) GetTaskDetails(taskID uint64, request GetTaskDetailsRequest
 }
 
 func (r *repository) UpdateTaskDetail(taskID, detailID uint64, request UpdateTaskDetailRequest) (TaskDetailResponse, error) {
-	var changes = make(map[string]interface{})
-	b, _ := json.Marshal(&request)
-	_ = json.Unmarshal(b, &changes)
-	detail, err := r.repository.UpdateTaskDetail(taskID, detailID, changes)
+	if request.Status == task.Rejected && request.Comment == "" {
+		return TaskDetailResponse{}, errors.TaskDetailCannotUpdate.NewWithMessage("rejection requires a comment for the labeler")
+	}
+	detail, from, err := r.repository.TransitDetail(taskID, detailID, request.UserID, request.Status, request.Comment)
 	if err != nil {
 		return TaskDetailResponse{}, err
 	}
-	err = r.repository.CheckTaskStatus(taskID, request.Status)
+	r.afterTransit(taskID, detailID, detail, from)
+	return ToTaskDetailResponse(detail), nil
+}
+
+// ClassifyDetail sets the labels of an image of a classification project,
+// which pluto stores itself instead of the annotation server.
+func (r *repository) ClassifyDetail(taskID, detailID, userID uint64, request ClassifyDetailRequest) (TaskDetailResponse, error) {
+	t, err := r.classificationTask(taskID)
 	if err != nil {
-		logger.Error("error check update task status for task %d, detail status %d", taskID, request.Status)
+		return TaskDetailResponse{}, err
+	}
+	labels, err := r.labelRepo.GetByProjectId(t.ProjectID)
+	if err != nil {
+		return TaskDetailResponse{}, err
+	}
+	if err := checkClassification(labels, request.LabelIDs, request.Submit); err != nil {
+		return TaskDetailResponse{}, err
+	}
+	var to = task.Draft
+	if request.Submit {
+		to = task.Labeled
+	}
+	detail, from, err := r.repository.ClassifyDetail(taskID, detailID, userID, request.LabelIDs, to)
+	if err != nil {
+		return TaskDetailResponse{}, err
+	}
+	r.afterTransit(taskID, detailID, detail, from)
+	return ToTaskDetailResponse(detail), nil
+}
+
+// ReviewDetail approves or rejects the labels of an image of a
+// classification project.
+func (r *repository) ReviewDetail(taskID, detailID, userID uint64, request ReviewDetailRequest) (TaskDetailResponse, error) {
+	if request.Status != task.Approved && request.Status != task.Rejected {
+		return TaskDetailResponse{}, errors.BadRequest.NewWithMessageF("a review either approves or rejects, not %s", request.Status)
 	}
-	if _, ok := changes["status"]; ok && request.Status == 2 {
+	if _, err := r.classificationTask(taskID); err != nil {
+		return TaskDetailResponse{}, err
+	}
+	return r.UpdateTaskDetail(taskID, detailID, UpdateTaskDetailRequest{
+		UserID:  userID,
+		Status:  request.Status,
+		Comment: request.Comment,
+	})
+}
+
+func (r *repository) classificationTask(taskID uint64) (task.Task, error) {
+	t, err := r.repository.GetTask(taskID)
+	if err != nil {
+		return task.Task{}, err
+	}
+	p, err := r.projectRepo.GetByID(t.ProjectID)
+	if err != nil {
+		return task.Task{}, err
+	}
+	if p.Mode != project.ModeClassification {
+		return task.Task{}, errors.TaskNotClassification.NewWithMessageF("project %d of task %d is not in classification mode", t.ProjectID, taskID)
+	}
+	return t, nil
+}
+
+// checkClassification makes sure ids are distinct labels among labels, and
+// that an image takes at most one label of each single tag tool. Submitted
+// images need at least one label.
+func checkClassification(labels []label.Label, ids []uint64, submit bool) error {
+	if submit && len(ids) == 0 {
+		return errors.TaskClassificationInvalid.NewWithMessage("an image needs a label to be submitted")
+	}
+	var byID = make(map[uint64]label.Label, len(labels))
+	for _, l := range labels {
+		byID[l.ID] = l
+	}
+	var (
+		seen  = make(map[uint64]bool, len(ids))
+		tools = make(map[uint64]uint64)
+	)
+	for _, id := range ids {
+		l, ok := byID[id]
+		if !ok {
+			return errors.TaskClassificationInvalid.NewWithMessageF("label %d does not belong to the project", id)
+		}
+		if seen[id] {
+			return errors.TaskClassificationInvalid.NewWithMessageF("label %d is given twice", id)
+		}
+		seen[id] = true
+		if !l.Tool.SingleTag() {
+			continue
+		}
+		if other, ok := tools[l.ToolID]; ok {
+			return errors.TaskClassificationInvalid.NewWithMessageF("labels %d and %d both use tool %s, which allows one tag per image", other, id, l.Tool.Name)
+		}
+		tools[l.ToolID] = id
+	}
+	return nil
+}
+
+// afterTransit updates the status of the task of a detail and counts the
+// image as annotated once labeled.
+func (r *repository) afterTransit(taskID, detailID uint64, detail task.Detail, from task.DetailStatus) {
+	err := r.repository.CheckTaskStatus(taskID)
+	if err != nil {
+		logger.Errorf("error check update task status for task %d, detail status %d", taskID, detail.Status)
+	}
+	if detail.Status == task.Labeled && from != task.Labeled {
 		err := r.imgRepo.Incr(detail.ImageID)
 		if err != nil {
 			logger.Errorf("error increasing image status %v, id %d", err, detail.ImageID)
 		}
 	}
-	return ToTaskDetailResponse(detail), nil
+}
+
+func (r *repository) GetHistory(taskID uint64, request GetHistoryRequest) (GetHistoryResponse, error) {
+	offset, limit := paging.Parse(request.Page, request.PageSize)
+	histories, total, err := r.repository.GetHistory(taskID, request.DetailID, offset, limit)
+	if err != nil {
+		return GetHistoryResponse{}, err
+	}
+	var responses = make([]HistoryResponse, len(histories))
+	for i, h := range histories {
+		responses[i] = HistoryResponse{
+			ID:        h.ID,
+			TaskID:    h.TaskID,
+			DetailID:  h.DetailID,
+			UserID:    h.UserID,
+			Role:      h.Role.String(),
+			From:      h.FromStatus,
+			To:        h.ToStatus,
+			CreatedAt: clock.UnixMillisecondFromTime(h.CreatedAt),
+		}
+	}
+	return GetHistoryResponse{
+		Total:     total,
+		Histories: responses,
+	}, nil
 }
 
 func (r *repository) ToTaskResponse(t task.Task) TaskResponse {
+	now := r.clock.Now()
+	_, overdue := t.Overdue(now)
 	_, total, err := r.repository.GetTaskDetails(t.ID, task.AnyStatus, 0, 0)
 	dataset, err := r.datasetRepo.GetByID(t.DatasetID)
 	if err != nil {
@@ -214,12 +533,18 @@ func (r *repository) ToTaskResponse(t task.Task) TaskResponse {
 			},
 			Admin: project.Workspace.Admin,
 		},
-		Assigner:   t.Assigner,
-		Labeler:    t.Labeler,
-		Reviewer:   t.Reviewer,
-		Status:     uint32(t.Status),
-		ImageCount: total,
-		CreatedAt:  clock.UnixMillisecondFromTime(t.CreatedAt),
+		Assigner:    t.Assigner,
+		Labeler:     t.Labeler,
+		Reviewer:    t.Reviewer,
+		Status:      uint32(t.Status),
+		Priority:    t.Priority,
+		ImageCount:  total,
+		CreatedAt:   clock.UnixMillisecondFromTime(t.CreatedAt),
+		LabelDueAt:  millisecondsOrZero(t.LabelDueAt),
+		ReviewDueAt: millisecondsOrZero(t.ReviewDueAt),
+		DoneAt:      millisecondsOrZero(t.DoneAt),
+		Overdue:     overdue,
+		Age:         int64(t.Age(now) / time.Millisecond),
 	}
 }
 
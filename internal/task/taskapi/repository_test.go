@@ -0,0 +1,185 @@
+package taskapi
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/image"
+	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/project/projectapi"
+	"github.com/nkhang/pluto/internal/task"
+	"github.com/nkhang/pluto/internal/tool"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/gorm"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Initlialize(false)
+	os.Exit(m.Run())
+}
+
+type transit struct {
+	userID uint64
+	labels []uint64
+	to     task.DetailStatus
+}
+
+// fakeTaskRepo holds a single detail of image 7 and records what is done to
+// it.
+type fakeTaskRepo struct {
+	task.Repository
+	tasks    map[uint64]task.Task
+	status   task.DetailStatus
+	transits []transit
+}
+
+func (r *fakeTaskRepo) GetTask(id uint64) (task.Task, error) {
+	t, ok := r.tasks[id]
+	if !ok {
+		return task.Task{}, errors.TaskNotFound.NewWithMessage("task not found")
+	}
+	return t, nil
+}
+
+func (r *fakeTaskRepo) detail(taskID, detailID uint64, to task.DetailStatus) task.Detail {
+	return task.Detail{Model: gorm.Model{ID: detailID}, TaskID: taskID, ImageID: 7, Status: to}
+}
+
+func (r *fakeTaskRepo) ClassifyDetail(taskID, detailID, userID uint64, labels []uint64, to task.DetailStatus) (task.Detail, task.DetailStatus, error) {
+	from := r.status
+	r.status = to
+	r.transits = append(r.transits, transit{userID: userID, labels: labels, to: to})
+	return r.detail(taskID, detailID, to), from, nil
+}
+
+func (r *fakeTaskRepo) TransitDetail(taskID, detailID, userID uint64, to task.DetailStatus, comment string) (task.Detail, task.DetailStatus, error) {
+	from := r.status
+	r.status = to
+	r.transits = append(r.transits, transit{userID: userID, to: to})
+	return r.detail(taskID, detailID, to), from, nil
+}
+
+func (r *fakeTaskRepo) CheckTaskStatus(taskID uint64) error {
+	return nil
+}
+
+type fakeProjectAPIRepo struct {
+	projectapi.Repository
+}
+
+func (fakeProjectAPIRepo) GetByID(id uint64) (projectapi.ProjectResponse, error) {
+	var mode = project.ModeAnnotation
+	if id == 2 {
+		mode = project.ModeClassification
+	}
+	return projectapi.ProjectResponse{Mode: mode}, nil
+}
+
+type fakeLabelRepo struct {
+	label.Repository
+}
+
+func (fakeLabelRepo) GetByProjectId(pID uint64) ([]label.Label, error) {
+	tag := tool.Tool{Model: gorm.Model{ID: 9}, Name: "TAG", Kind: tool.KindTag, Config: `{"multiple":false}`}
+	return []label.Label{
+		{Model: gorm.Model{ID: 1}, ProjectID: pID, ToolID: 9, Tool: tag},
+		{Model: gorm.Model{ID: 2}, ProjectID: pID, ToolID: 9, Tool: tag},
+		{Model: gorm.Model{ID: 3}, ProjectID: pID, ToolID: 1, Tool: tool.Tool{Model: gorm.Model{ID: 1}, Kind: tool.KindRectangle}},
+	}, nil
+}
+
+type fakeImageRepo struct {
+	image.Repository
+	labeled []uint64
+}
+
+func (r *fakeImageRepo) Incr(id uint64) error {
+	r.labeled = append(r.labeled, id)
+	return nil
+}
+
+// newClassifyRepository serves task 1 of an annotation project and task 2 of
+// a classification project, both labeled by user 10 and reviewed by user 20.
+func newClassifyRepository(status task.DetailStatus) (*repository, *fakeTaskRepo, *fakeImageRepo) {
+	tasks := &fakeTaskRepo{
+		tasks: map[uint64]task.Task{
+			1: {Model: gorm.Model{ID: 1}, ProjectID: 1, Labeler: 10, Reviewer: 20},
+			2: {Model: gorm.Model{ID: 2}, ProjectID: 2, Labeler: 10, Reviewer: 20},
+		},
+		status: status,
+	}
+	images := &fakeImageRepo{}
+	return NewRepository(tasks, images, nil, fakeProjectAPIRepo{}, fakeLabelRepo{}, nil, 0), tasks, images
+}
+
+func TestClassifyDetail(t *testing.T) {
+	r, tasks, images := newClassifyRepository(task.Pending)
+	resp, err := r.ClassifyDetail(2, 5, 10, ClassifyDetailRequest{LabelIDs: []uint64{1, 3}})
+	require.NoError(t, err)
+	assert.Equal(t, int32(task.Draft), resp.Status)
+	assert.Equal(t, []transit{{userID: 10, labels: []uint64{1, 3}, to: task.Draft}}, tasks.transits)
+	assert.Empty(t, images.labeled, "a draft does not count the image as labeled")
+
+	resp, err = r.ClassifyDetail(2, 5, 10, ClassifyDetailRequest{LabelIDs: []uint64{2}, Submit: true})
+	require.NoError(t, err)
+	assert.Equal(t, int32(task.Labeled), resp.Status)
+	assert.Equal(t, []uint64{7}, images.labeled)
+}
+
+func TestClassifyDetailRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		taskID  uint64
+		request ClassifyDetailRequest
+		err     errors.ErrorType
+	}{
+		{"annotation project", 1, ClassifyDetailRequest{LabelIDs: []uint64{1}}, errors.TaskNotClassification},
+		{"unknown task", 3, ClassifyDetailRequest{LabelIDs: []uint64{1}}, errors.TaskNotFound},
+		{"submit without label", 2, ClassifyDetailRequest{Submit: true}, errors.TaskClassificationInvalid},
+		{"label of another project", 2, ClassifyDetailRequest{LabelIDs: []uint64{4}}, errors.TaskClassificationInvalid},
+		{"label given twice", 2, ClassifyDetailRequest{LabelIDs: []uint64{3, 3}}, errors.TaskClassificationInvalid},
+		{"two labels of a single tag tool", 2, ClassifyDetailRequest{LabelIDs: []uint64{1, 2}}, errors.TaskClassificationInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, tasks, _ := newClassifyRepository(task.Pending)
+			_, err := r.ClassifyDetail(tt.taskID, 5, 10, tt.request)
+			assert.Equal(t, tt.err, errors.Type(err))
+			assert.Empty(t, tasks.transits)
+		})
+	}
+}
+
+func TestReviewDetail(t *testing.T) {
+	r, tasks, _ := newClassifyRepository(task.Labeled)
+	resp, err := r.ReviewDetail(2, 5, 20, ReviewDetailRequest{Status: task.Approved})
+	require.NoError(t, err)
+	assert.Equal(t, int32(task.Approved), resp.Status)
+	assert.Equal(t, []transit{{userID: 20, to: task.Approved}}, tasks.transits)
+}
+
+func TestReviewDetailRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		taskID  uint64
+		request ReviewDetailRequest
+		err     errors.ErrorType
+	}{
+		{"annotation project", 1, ReviewDetailRequest{Status: task.Approved}, errors.TaskNotClassification},
+		{"not a review", 2, ReviewDetailRequest{Status: task.Labeled}, errors.BadRequest},
+		{"rejection without comment", 2, ReviewDetailRequest{Status: task.Rejected}, errors.TaskDetailCannotUpdate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, tasks, _ := newClassifyRepository(task.Labeled)
+			_, err := r.ReviewDetail(tt.taskID, 5, 20, tt.request)
+			assert.Equal(t, tt.err, errors.Type(err))
+			assert.Empty(t, tasks.transits)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"net/http"
 
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/pkg/pgin"
 
 	"github.com/nats-io/nats.go"
@@ -28,24 +29,34 @@ type Service struct {
 	repository  Repository
 	taskRepo    task.Repository
 	statsRouter pgin.Router
+	authorizer  authz.Authorizer
 }
 
-func NewService(r Repository, tr task.Repository, statsRouter pgin.Router) *Service {
+func NewService(r Repository, tr task.Repository, statsRouter pgin.Router, authorizer authz.Authorizer) *Service {
 	return &Service{
 		repository:  r,
 		taskRepo:    tr,
 		statsRouter: statsRouter,
+		authorizer:  authorizer,
 	}
 }
 
 func (s *Service) Register(router gin.IRouter) {
-	router.POST("", ginwrapper.Wrap(s.createTask))
+	router.POST("", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.createTask))
 	router.GET("", ginwrapper.Wrap(s.getListForProject))
 	detailRouter := router.Group("/:"+FieldTaskID, s.verifyTask())
 	{
-		detailRouter.DELETE("", ginwrapper.Wrap(s.delete))
+		detailRouter.DELETE("", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.delete))
+		detailRouter.PUT("/assignees", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.updateAssignees))
+		detailRouter.PUT("/deadline", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.updateDeadline))
+		detailRouter.PUT("/priority", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.updatePriority))
+		detailRouter.POST("/split", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.split))
+		detailRouter.POST("/merge", s.authorizer.Require(authz.ProjectManager), ginwrapper.Wrap(s.merge))
 		detailRouter.GET("", ginwrapper.Wrap(s.get))
 		detailRouter.GET("/details", ginwrapper.Wrap(s.getTaskDetails))
+		detailRouter.PUT("/details/:"+fieldTaskDetailID+"/labels", s.authorizer.Require(authz.ProjectMember), ginwrapper.Wrap(s.classifyDetail))
+		detailRouter.PUT("/details/:"+fieldTaskDetailID+"/review", s.authorizer.Require(authz.ProjectMember), ginwrapper.Wrap(s.reviewDetail))
+		detailRouter.GET("/history", ginwrapper.Wrap(s.getHistory))
 	}
 	s.statsRouter.Register(detailRouter)
 }
@@ -62,6 +73,8 @@ func (s *Service) RegisterNATS(ec *nats.EncodedConn) error {
 
 func (s *Service) RegisterStandalone(router gin.IRouter) {
 	router.GET("", ginwrapper.Wrap(s.getListForUser))
+	router.GET("/next", ginwrapper.Wrap(s.next))
+	router.DELETE("/leases/:"+FieldTaskID+"/:"+fieldTaskDetailID, ginwrapper.Wrap(s.release))
 }
 
 func (s *Service) RegisterInternal(router gin.IRouter) {
@@ -80,7 +93,7 @@ func (s *Service) handleUpdateTask(msg *nats.Msg) {
 		logger.Errorf("error unmarshal message from nats. error %v. msg %s", err, msg.Data)
 		return
 	}
-	_, err = s.repository.UpdateTaskDetail(req.TaskID, req.DetailID, UpdateTaskDetailRequest{Status: req.Status})
+	_, err = s.repository.UpdateTaskDetail(req.TaskID, req.DetailID, UpdateTaskDetailRequest{UserID: req.UserID, Status: req.Status, Comment: req.Comment})
 	if err != nil {
 		logger.Infof("error updating task detail. task %d, detail %d, status %d, err %v", req.TaskID, req.DetailID, req.Status, err)
 		return
@@ -97,7 +110,7 @@ func (s *Service) createTask(c *gin.Context) ginwrapper.Response {
 			Error: errors.BadRequest.NewWithMessage("error binding create task request"),
 		}
 	}
-	err := s.repository.CreateTask(projectID, assigner, req)
+	resp, err := s.repository.CreateTask(projectID, assigner, req)
 	if err != nil {
 		return ginwrapper.Response{
 			Error: err,
@@ -105,6 +118,7 @@ func (s *Service) createTask(c *gin.Context) ginwrapper.Response {
 	}
 	return ginwrapper.Response{
 		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
 	}
 }
 
@@ -143,6 +157,46 @@ func (s *Service) getListForUser(c *gin.Context) ginwrapper.Response {
 	}
 }
 
+func (s *Service) next(c *gin.Context) ginwrapper.Response {
+	userID := pgin.ExtractUserIDFromContext(c)
+	resp, err := s.repository.NextWorkItem(userID)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+func (s *Service) release(c *gin.Context) ginwrapper.Response {
+	userID := pgin.ExtractUserIDFromContext(c)
+	taskID, err := idextractor.ExtractUint64Param(c, FieldTaskID)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	detailID, err := idextractor.ExtractUint64Param(c, fieldTaskDetailID)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	var req ReleaseWorkItemRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("error binding release request"),
+		}
+	}
+	if err := s.repository.ReleaseWorkItem(taskID, detailID, userID, req.Token); err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+	}
+}
+
 func (s *Service) getListForProject(c *gin.Context) ginwrapper.Response {
 	projectID := uint64(c.GetInt64(projectapi.FieldProjectID))
 	userID := pgin.ExtractUserIDFromContext(c)
@@ -178,6 +232,114 @@ func (s *Service) delete(c *gin.Context) ginwrapper.Response {
 	}
 }
 
+func (s *Service) updateAssignees(c *gin.Context) ginwrapper.Response {
+	taskID := uint64(c.GetInt64(FieldTaskID))
+	projectID := uint64(c.GetInt64(projectapi.FieldProjectID))
+	var req UpdateAssigneesRequest
+	if err := c.ShouldBind(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("error binding update assignees request"),
+		}
+	}
+	if err := s.verifyMembers(projectID, req.Labeler, req.Reviewer); err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	resp, err := s.repository.UpdateAssignees(taskID, req)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+func (s *Service) updatePriority(c *gin.Context) ginwrapper.Response {
+	taskID := uint64(c.GetInt64(FieldTaskID))
+	var req UpdatePriorityRequest
+	if err := c.ShouldBind(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("error binding update priority request"),
+		}
+	}
+	resp, err := s.repository.UpdatePriority(taskID, req)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+func (s *Service) updateDeadline(c *gin.Context) ginwrapper.Response {
+	taskID := uint64(c.GetInt64(FieldTaskID))
+	var req UpdateDeadlineRequest
+	if err := c.ShouldBind(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("error binding update deadline request"),
+		}
+	}
+	resp, err := s.repository.UpdateDeadline(taskID, req)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+func (s *Service) split(c *gin.Context) ginwrapper.Response {
+	taskID := uint64(c.GetInt64(FieldTaskID))
+	projectID := uint64(c.GetInt64(projectapi.FieldProjectID))
+	var req SplitTaskRequest
+	if err := c.ShouldBind(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("error binding split task request"),
+		}
+	}
+	if err := s.verifyMembers(projectID, req.Labeler, req.Reviewer); err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	resp, err := s.repository.SplitTask(taskID, req)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+func (s *Service) merge(c *gin.Context) ginwrapper.Response {
+	taskID := uint64(c.GetInt64(FieldTaskID))
+	var req MergeTaskRequest
+	if err := c.ShouldBind(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("error binding merge task request"),
+		}
+	}
+	resp, err := s.repository.MergeTask(taskID, req)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
 func (s *Service) getTaskDetails(c *gin.Context) ginwrapper.Response {
 	taskID := uint64(c.GetInt64(FieldTaskID))
 	var req GetTaskDetailsRequest
@@ -196,6 +358,24 @@ func (s *Service) getTaskDetails(c *gin.Context) ginwrapper.Response {
 	}
 }
 
+func (s *Service) getHistory(c *gin.Context) ginwrapper.Response {
+	taskID := uint64(c.GetInt64(FieldTaskID))
+	var req GetHistoryRequest
+	if err := c.ShouldBind(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("cannot bind request params"),
+		}
+	}
+	resp, err := s.repository.GetHistory(taskID, req)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
 func (s *Service) updateTaskDetail(c *gin.Context) ginwrapper.Response {
 	b, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil || len(b) == 0 {
@@ -228,6 +408,70 @@ func (s *Service) updateTaskDetail(c *gin.Context) ginwrapper.Response {
 	}
 }
 
+func (s *Service) classifyDetail(c *gin.Context) ginwrapper.Response {
+	taskID := uint64(c.GetInt64(FieldTaskID))
+	userID := pgin.ExtractUserIDFromContext(c)
+	detailID, err := idextractor.ExtractUint64Param(c, fieldTaskDetailID)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	var req ClassifyDetailRequest
+	if err := c.ShouldBind(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("error binding classify detail request"),
+		}
+	}
+	resp, err := s.repository.ClassifyDetail(taskID, detailID, userID, req)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+func (s *Service) reviewDetail(c *gin.Context) ginwrapper.Response {
+	taskID := uint64(c.GetInt64(FieldTaskID))
+	userID := pgin.ExtractUserIDFromContext(c)
+	detailID, err := idextractor.ExtractUint64Param(c, fieldTaskDetailID)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	var req ReviewDetailRequest
+	if err := c.ShouldBind(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessage("error binding review detail request"),
+		}
+	}
+	resp, err := s.repository.ReviewDetail(taskID, detailID, userID, req)
+	if err != nil {
+		return ginwrapper.Response{
+			Error: err,
+		}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  resp,
+	}
+}
+
+// verifyMembers makes sure tasks are only handed to members of the project.
+// Zero IDs are skipped.
+func (s *Service) verifyMembers(projectID uint64, userIDs ...uint64) error {
+	for _, userID := range userIDs {
+		if userID == 0 {
+			continue
+		}
+		if err := s.authorizer.Authorize(userID, 0, projectID, authz.ProjectMember); err != nil {
+			return errors.BadRequest.NewWithMessageF("user %d is not a member of project %d", userID, projectID)
+		}
+	}
+	return nil
+}
+
 func (s *Service) verifyTask() gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
 		taskID, err := idextractor.ExtractInt64Param(c, FieldTaskID)
@@ -241,11 +485,16 @@ func (s *Service) verifyTask() gin.HandlerFunc {
 			ginwrapper.Report(c, http.StatusOK, err, nil)
 			return
 		}
-		_, err = s.taskRepo.GetTask(uint64(taskID))
+		t, err := s.taskRepo.GetTask(uint64(taskID))
 		if err != nil {
 			ginwrapper.Report(c, http.StatusOK, err, nil)
 			return
 		}
+		if projectID := uint64(c.GetInt64(projectapi.FieldProjectID)); projectID != 0 && t.ProjectID != projectID {
+			err = errors.TaskNotFound.NewWithMessageF("task %d does not belong to project %d", taskID, projectID)
+			ginwrapper.Report(c, http.StatusOK, err, nil)
+			return
+		}
 		c.Set(FieldTaskID, taskID)
 		c.Next()
 	})
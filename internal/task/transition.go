@@ -0,0 +1,147 @@
+package task
+
+import "github.com/nkhang/pluto/pkg/gorm"
+
+var detailStatusNames = map[DetailStatus]string{
+	Pending:  "pending",
+	Draft:    "draft",
+	Labeled:  "labeled",
+	Approved: "approved",
+	Rejected: "rejected",
+}
+
+func (s DetailStatus) String() string {
+	if name, ok := detailStatusNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+var statusNames = map[Status]string{
+	Labeling:  "labeling",
+	Reviewing: "reviewing",
+	Done:      "done",
+}
+
+func (s Status) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+var roleNames = map[Role]string{
+	Assigner: "assigner",
+	Labeler:  "labeler",
+	Reviewer: "reviewer",
+	System:   "system",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// detailTransitions lists, per role, the statuses a detail may move to from
+// each status. Labelers work on pending, draft and rejected images, reviewers
+// judge labeled ones and assigners may also reopen an approved image.
+var detailTransitions = map[Role]map[DetailStatus][]DetailStatus{
+	Labeler: {
+		Pending:  {Draft, Labeled},
+		Draft:    {Draft, Labeled},
+		Rejected: {Draft, Labeled},
+	},
+	Reviewer: {
+		Labeled: {Approved, Rejected},
+	},
+	Assigner: {
+		Labeled:  {Approved, Rejected},
+		Approved: {Rejected},
+	},
+}
+
+// taskTransitions lists the statuses a task may move to from each status as
+// its details change status. Task statuses are derived from the details: a
+// task is reviewed once its images are labeled, goes back to labeling when an
+// image is rejected and is done once every image is approved. Tasks without
+// a status, written before statuses were tracked, may take any.
+var taskTransitions = map[Status][]Status{
+	Any:       {Labeling, Reviewing, Done},
+	Labeling:  {Reviewing},
+	Reviewing: {Labeling, Done},
+	Done:      {Labeling},
+}
+
+// reshapeTransitions are the further transitions allowed when images move in
+// or out of a task: a split or a removal may leave a labeling task with only
+// approved images, and merging labeled images into a done task sends it back
+// to review.
+var reshapeTransitions = map[Status][]Status{
+	Labeling: {Done},
+	Done:     {Reviewing},
+}
+
+// History records a status change. DetailID is 0 for a change of the task
+// status itself, in which case the statuses are task statuses.
+type History struct {
+	gorm.Model
+	TaskID     uint64 `sql:"index"`
+	DetailID   uint64
+	UserID     uint64
+	Role       Role
+	FromStatus int32
+	ToStatus   int32
+}
+
+func (History) TableName() string {
+	return "task_histories"
+}
+
+// RolesOf returns the roles userID holds on t. A missing user (0) holds
+// none.
+func RolesOf(t Task, userID uint64) []Role {
+	var roles = make([]Role, 0)
+	if userID == 0 {
+		return roles
+	}
+	if t.Labeler == userID {
+		roles = append(roles, Labeler)
+	}
+	if t.Reviewer == userID {
+		roles = append(roles, Reviewer)
+	}
+	if t.Assigner == userID {
+		roles = append(roles, Assigner)
+	}
+	return roles
+}
+
+// CanTransitDetail returns the first of roles allowed to move a detail from
+// one status to another.
+func CanTransitDetail(roles []Role, from, to DetailStatus) (Role, bool) {
+	for _, role := range roles {
+		for _, s := range detailTransitions[role][from] {
+			if s == to {
+				return role, true
+			}
+		}
+	}
+	return AnyRole, false
+}
+
+// canTransitTask tells whether a task may move from one status to another,
+// reshaped when images moved in or out of it.
+func canTransitTask(from, to Status, reshaped bool) bool {
+	allowed := taskTransitions[from]
+	if reshaped {
+		allowed = append(allowed[:len(allowed):len(allowed)], reshapeTransitions[from]...)
+	}
+	for _, s := range allowed {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
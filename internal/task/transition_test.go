@@ -0,0 +1,73 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransitTask(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		strict   bool
+		reshaped bool
+	}{
+		{Any, Labeling, true, true},
+		{Any, Reviewing, true, true},
+		{Any, Done, true, true},
+		{Labeling, Reviewing, true, true},
+		{Labeling, Done, false, true},
+		{Labeling, Labeling, false, false},
+		{Reviewing, Labeling, true, true},
+		{Reviewing, Done, true, true},
+		{Reviewing, Reviewing, false, false},
+		{Done, Labeling, true, true},
+		{Done, Reviewing, false, true},
+		{Done, Done, false, false},
+		{Labeling, Any, false, false},
+		{Done, Any, false, false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.strict, canTransitTask(test.from, test.to, false), "%s to %s", test.from, test.to)
+		assert.Equal(t, test.reshaped, canTransitTask(test.from, test.to, true), "%s to %s reshaped", test.from, test.to)
+	}
+}
+
+func TestCanTransitDetail(t *testing.T) {
+	tests := []struct {
+		roles []Role
+		from  DetailStatus
+		to    DetailStatus
+		role  Role
+		ok    bool
+	}{
+		{[]Role{Labeler}, Pending, Draft, Labeler, true},
+		{[]Role{Labeler}, Draft, Labeled, Labeler, true},
+		{[]Role{Labeler}, Rejected, Labeled, Labeler, true},
+		{[]Role{Labeler}, Labeled, Approved, AnyRole, false},
+		{[]Role{Labeler}, Approved, Draft, AnyRole, false},
+		{[]Role{Reviewer}, Labeled, Approved, Reviewer, true},
+		{[]Role{Reviewer}, Labeled, Rejected, Reviewer, true},
+		{[]Role{Reviewer}, Approved, Rejected, AnyRole, false},
+		{[]Role{Reviewer}, Pending, Labeled, AnyRole, false},
+		{[]Role{Assigner}, Approved, Rejected, Assigner, true},
+		{[]Role{Assigner}, Pending, Draft, AnyRole, false},
+		{[]Role{Labeler, Reviewer}, Labeled, Approved, Reviewer, true},
+		{[]Role{System}, Labeled, Approved, AnyRole, false},
+		{nil, Pending, Draft, AnyRole, false},
+	}
+	for _, test := range tests {
+		role, ok := CanTransitDetail(test.roles, test.from, test.to)
+		assert.Equal(t, test.ok, ok, "%v from %s to %s", test.roles, test.from, test.to)
+		assert.Equal(t, test.role, role, "%v from %s to %s", test.roles, test.from, test.to)
+	}
+}
+
+func TestRolesOf(t *testing.T) {
+	task := Task{Assigner: 1, Labeler: 2, Reviewer: 2}
+	assert.Equal(t, []Role{Assigner}, RolesOf(task, 1))
+	assert.Equal(t, []Role{Labeler, Reviewer}, RolesOf(task, 2))
+	assert.Empty(t, RolesOf(task, 3))
+	assert.Empty(t, RolesOf(Task{}, 0), "a missing user holds no role")
+	assert.Equal(t, "system", System.String())
+}
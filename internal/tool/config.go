@@ -0,0 +1,191 @@
+package tool
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+type Kind string
+
+const (
+	KindRectangle Kind = "rectangle"
+	KindPoint     Kind = "point"
+	KindPolyline  Kind = "polyline"
+	KindPolygon   Kind = "polygon"
+	KindEllipse   Kind = "ellipse"
+	KindCuboid    Kind = "cuboid"
+	KindSkeleton  Kind = "skeleton"
+	KindBrush     Kind = "brush"
+	KindTag       Kind = "tag"
+)
+
+// Config is the config schema of a kind of tool.
+type Config interface {
+	Validate() error
+}
+
+// configs makes the empty config of each kind. Kinds that take no config
+// are not listed.
+var configs = map[Kind]func() Config{
+	KindPolyline: func() Config { return &PointsConfig{} },
+	KindPolygon:  func() Config { return &PointsConfig{} },
+	KindSkeleton: func() Config { return &SkeletonConfig{} },
+	KindBrush:    func() Config { return &BrushConfig{} },
+	KindTag:      func() Config { return &TagConfig{} },
+}
+
+var kinds = []Kind{
+	KindRectangle, KindPoint, KindPolyline, KindPolygon, KindEllipse,
+	KindCuboid, KindSkeleton, KindBrush, KindTag,
+}
+
+func (k Kind) Valid() bool {
+	for _, kind := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// PointsConfig bounds the number of points of a polyline or a polygon, 0
+// leaves it unbounded.
+type PointsConfig struct {
+	MinPoints int `json:"min_points,omitempty"`
+	MaxPoints int `json:"max_points,omitempty"`
+}
+
+func (c *PointsConfig) Validate() error {
+	if c.MinPoints < 0 || c.MaxPoints < 0 {
+		return fmt.Errorf("point counts must not be negative")
+	}
+	if c.MaxPoints != 0 && c.MaxPoints < c.MinPoints {
+		return fmt.Errorf("max_points %d is below min_points %d", c.MaxPoints, c.MinPoints)
+	}
+	return nil
+}
+
+// SkeletonConfig names the keypoints of a skeleton. Each edge joins two
+// keypoints by their index.
+type SkeletonConfig struct {
+	Keypoints []string `json:"keypoints"`
+	Edges     [][2]int `json:"edges"`
+}
+
+func (c *SkeletonConfig) Validate() error {
+	if len(c.Keypoints) == 0 {
+		return fmt.Errorf("a skeleton needs keypoints")
+	}
+	var names = make(map[string]bool, len(c.Keypoints))
+	for _, k := range c.Keypoints {
+		if strings.TrimSpace(k) == "" {
+			return fmt.Errorf("keypoint names must not be empty")
+		}
+		if names[k] {
+			return fmt.Errorf("keypoint %s is given twice", k)
+		}
+		names[k] = true
+	}
+	var edges = make(map[[2]int]bool, len(c.Edges))
+	for _, e := range c.Edges {
+		for _, i := range e {
+			if i < 0 || i >= len(c.Keypoints) {
+				return fmt.Errorf("edge %v joins keypoint %d, which does not exist", e, i)
+			}
+		}
+		if e[0] == e[1] {
+			return fmt.Errorf("edge %v joins a keypoint to itself", e)
+		}
+		if edges[e] || edges[[2]int{e[1], e[0]}] {
+			return fmt.Errorf("edge %v is given twice", e)
+		}
+		edges[e] = true
+	}
+	return nil
+}
+
+// BrushConfig bounds the size of the semantic brush in pixels.
+type BrushConfig struct {
+	MinSize int `json:"min_size"`
+	MaxSize int `json:"max_size"`
+}
+
+func (c *BrushConfig) Validate() error {
+	if c.MinSize <= 0 || c.MaxSize < c.MinSize {
+		return fmt.Errorf("brush sizes must satisfy 0 < min_size <= max_size")
+	}
+	return nil
+}
+
+// TagConfig tells whether an image may carry several tags of the tool, or
+// exactly one.
+type TagConfig struct {
+	Multiple bool `json:"multiple"`
+}
+
+func (c *TagConfig) Validate() error {
+	return nil
+}
+
+// ParseConfig checks that raw is a valid config for kind and returns it in
+// its canonical form, empty for kinds that take no config.
+func ParseConfig(kind Kind, raw json.RawMessage) (string, error) {
+	if !kind.Valid() {
+		return "", errors.ToolConfigInvalid.NewWithMessageF("kind %q is not supported", kind)
+	}
+	newConfig, ok := configs[kind]
+	if !ok {
+		if len(bytes.TrimSpace(raw)) != 0 && string(bytes.TrimSpace(raw)) != "null" {
+			return "", errors.ToolConfigInvalid.NewWithMessageF("tools of kind %s take no config", kind)
+		}
+		return "", nil
+	}
+	config := newConfig()
+	if len(bytes.TrimSpace(raw)) != 0 {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return "", errors.ToolConfigInvalid.WrapF(err, "invalid config for kind %s", kind)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return "", errors.ToolConfigInvalid.WrapF(err, "invalid config for kind %s", kind)
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		return "", errors.ToolConfigInvalid.Wrap(err, "cannot write config")
+	}
+	return string(b), nil
+}
+
+// Builtins are the tools every deployment starts with. Skeletons have no
+// builtin since their keypoints depend on what is annotated.
+func Builtins() []Tool {
+	return []Tool{
+		{Name: "RECTANGLE", Kind: KindRectangle},
+		{Name: "POINT", Kind: KindPoint},
+		{Name: "POLYLINE", Kind: KindPolyline, Config: `{}`},
+		{Name: "POLYGON", Kind: KindPolygon, Config: `{"min_points":3}`},
+		{Name: "ELLIPSE", Kind: KindEllipse},
+		{Name: "CUBOID", Kind: KindCuboid},
+		{Name: "BRUSH", Kind: KindBrush, Config: `{"min_size":1,"max_size":100}`},
+		{Name: "TAG", Kind: KindTag, Config: `{"multiple":false}`},
+	}
+}
+
+// SingleTag tells whether t is a tag tool of which an image takes at most one
+// label.
+func (t Tool) SingleTag() bool {
+	if t.Kind != KindTag {
+		return false
+	}
+	var c TagConfig
+	if err := json.Unmarshal([]byte(t.Config), &c); err != nil {
+		return true
+	}
+	return !c.Multiple
+}
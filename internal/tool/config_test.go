@@ -0,0 +1,51 @@
+package tool
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig(KindSkeleton, json.RawMessage(`{"keypoints":["head","neck","hip"],"edges":[[0,1],[1,2]]}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"keypoints":["head","neck","hip"],"edges":[[0,1],[1,2]]}`, config)
+
+	config, err = ParseConfig(KindRectangle, nil)
+	require.NoError(t, err)
+	assert.Empty(t, config)
+	config, err = ParseConfig(KindTag, nil)
+	require.NoError(t, err)
+	assert.Equal(t, `{"multiple":false}`, config)
+
+	for _, invalid := range []struct {
+		kind Kind
+		raw  string
+	}{
+		{"hexagon", ``},
+		{KindRectangle, `{"min_points":3}`},
+		{KindSkeleton, ``},
+		{KindSkeleton, `{"keypoints":["head","head"]}`},
+		{KindSkeleton, `{"keypoints":["head","neck"],"edges":[[0,2]]}`},
+		{KindSkeleton, `{"keypoints":["head","neck"],"edges":[[1,1]]}`},
+		{KindSkeleton, `{"keypoints":["head","neck"],"edges":[[0,1],[1,0]]}`},
+		{KindPolygon, `{"min_points":5,"max_points":4}`},
+		{KindPolygon, `{"sides":4}`},
+		{KindBrush, `{"min_size":0,"max_size":10}`},
+	} {
+		_, err := ParseConfig(invalid.kind, json.RawMessage(invalid.raw))
+		assert.Equal(t, errors.ToolConfigInvalid, errors.Type(err), "%s %s", invalid.kind, invalid.raw)
+	}
+}
+
+func TestBuiltinsAreCanonical(t *testing.T) {
+	for _, b := range Builtins() {
+		config, err := ParseConfig(b.Kind, json.RawMessage(b.Config))
+		require.NoError(t, err, b.Name)
+		assert.Equal(t, b.Config, config, b.Name)
+	}
+}
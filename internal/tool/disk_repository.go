@@ -2,11 +2,20 @@ package tool
 
 import (
 	"github.com/jinzhu/gorm"
+
+	"github.com/nkhang/pluto/internal/outbox"
 	"github.com/nkhang/pluto/pkg/errors"
 )
 
+// tablesUsingTools are the tables whose rows point to a tool by tool_id.
+var tablesUsingTools = []string{"labels", "label_template_labels"}
+
 type DbRepository interface {
 	GetAll() ([]Tool, error)
+	Get(id uint64) (Tool, error)
+	Create(name string, kind Kind, config string) (Tool, error)
+	Update(id uint64, changes map[string]interface{}) (Tool, error)
+	Delete(id uint64) error
 }
 
 type dbRepository struct {
@@ -27,3 +36,75 @@ func (d *dbRepository) GetAll() ([]Tool, error) {
 	}
 	return t, nil
 }
+
+func (d *dbRepository) Get(id uint64) (Tool, error) {
+	var t Tool
+	result := d.db.First(&t, id)
+	if result.RecordNotFound() {
+		return Tool{}, errors.ToolNotFound.NewWithMessageF("tool %d not found", id)
+	}
+	if err := result.Error; err != nil {
+		return Tool{}, errors.ToolQueryError.Wrap(err, "cannot query tool")
+	}
+	return t, nil
+}
+
+func (d *dbRepository) Create(name string, kind Kind, config string) (Tool, error) {
+	t := Tool{
+		Name:   name,
+		Kind:   kind,
+		Config: config,
+	}
+	if err := d.db.Create(&t).Error; err != nil {
+		return Tool{}, errors.ToolCannotSave.Wrap(err, "cannot create tool")
+	}
+	return t, nil
+}
+
+// Update changes a tool and has the labels of the projects using it pushed
+// again, so the annotation server gets the new config.
+func (d *dbRepository) Update(id uint64, changes map[string]interface{}) (Tool, error) {
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Tool{}).Where("id = ?", id).Updates(changes).Error; err != nil {
+			return err
+		}
+		var projectIDs []uint64
+		err := tx.Table("labels").
+			Where("tool_id = ? AND deleted_at IS NULL", id).
+			Pluck("DISTINCT project_id", &projectIDs).Error
+		if err != nil {
+			return err
+		}
+		for _, projectID := range projectIDs {
+			err := outbox.Enqueue(tx, outbox.KindUpdateLabels, outbox.ProjectPayload{ProjectID: projectID})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Tool{}, errors.ToolCannotSave.WrapF(err, "cannot update tool %d", id)
+	}
+	return d.Get(id)
+}
+
+// Delete deletes a tool that no label nor label template uses.
+func (d *dbRepository) Delete(id uint64) error {
+	for _, table := range tablesUsingTools {
+		var count int
+		err := d.db.Table(table).
+			Where("tool_id = ? AND deleted_at IS NULL", id).
+			Count(&count).Error
+		if err != nil {
+			return errors.ToolQueryError.Wrap(err, "cannot count tool usage")
+		}
+		if count != 0 {
+			return errors.ToolInUse.NewWithMessageF("tool %d is used by %d rows of %s", id, count, table)
+		}
+	}
+	if err := d.db.Delete(&Tool{}, id).Error; err != nil {
+		return errors.ToolCannotSave.WrapF(err, "cannot delete tool %d", id)
+	}
+	return nil
+}
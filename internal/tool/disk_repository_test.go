@@ -56,8 +56,16 @@ func (r *diskRepoTest) TestDiskRepository_GetAll() {
 		WithQuery("SELECT * FROM `tools`  WHERE `tools`.`deleted_at` IS NULL").
 		WithReply([]map[string]interface{}{
 			{
-				"id":   1,
-				"name": "rectangle",
+				"id":     1,
+				"name":   "rectangle",
+				"kind":   "rectangle",
+				"config": "",
+			},
+			{
+				"id":     2,
+				"name":   "human pose",
+				"kind":   "skeleton",
+				"config": `{"keypoints":["head","neck"],"edges":[[0,1]]}`,
 			},
 		})
 	repo := NewDiskRepository(r.gormDB)
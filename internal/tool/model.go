@@ -1,8 +1,24 @@
 package tool
 
-import "github.com/nkhang/pluto/pkg/gorm"
+import (
+	"encoding/json"
 
+	"github.com/nkhang/pluto/pkg/gorm"
+)
+
+// Tool is a way of drawing objects. Its Config is the JSON of the config
+// schema of its Kind, such as the keypoints of a skeleton.
 type Tool struct {
 	gorm.Model
-	Name string
+	Name   string
+	Kind   Kind
+	Config string `gorm:"type:text"`
+}
+
+// ConfigJSON returns the config of t, nil when its kind takes none.
+func (t Tool) ConfigJSON() json.RawMessage {
+	if t.Config == "" {
+		return nil
+	}
+	return json.RawMessage(t.Config)
 }
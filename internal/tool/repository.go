@@ -9,6 +9,10 @@ import (
 
 type Repository interface {
 	GetAll() ([]Tool, error)
+	Get(id uint64) (Tool, error)
+	Create(name string, kind Kind, config string) (Tool, error)
+	Update(id uint64, changes map[string]interface{}) (Tool, error)
+	Delete(id uint64) error
 }
 
 type repository struct {
@@ -51,3 +55,46 @@ func (r *repository) GetAll() ([]Tool, error) {
 	}()
 	return tools, nil
 }
+
+func (r *repository) Get(id uint64) (Tool, error) {
+	return r.dbRepo.Get(id)
+}
+
+func (r *repository) Create(name string, kind Kind, config string) (Tool, error) {
+	t, err := r.dbRepo.Create(name, kind, config)
+	if err != nil {
+		return Tool{}, err
+	}
+	r.invalidate()
+	return t, nil
+}
+
+func (r *repository) Update(id uint64, changes map[string]interface{}) (Tool, error) {
+	t, err := r.dbRepo.Update(id, changes)
+	if err != nil {
+		return Tool{}, err
+	}
+	r.invalidate()
+	return t, nil
+}
+
+func (r *repository) Delete(id uint64) error {
+	if err := r.dbRepo.Delete(id); err != nil {
+		return err
+	}
+	r.invalidate()
+	return nil
+}
+
+// invalidate drops the cached tools, and the cached labels of every project
+// since they hold their tool.
+func (r *repository) invalidate() {
+	keys, err := r.cacheRepo.Keys(rediskey.LabelsByProjectAllKeys())
+	if err != nil {
+		logger.Errorf("[TOOL] - cannot get label keys. err %v", err)
+	}
+	keys = append(keys, rediskey.AllTools())
+	if err := r.cacheRepo.Del(keys...); err != nil {
+		logger.Errorf("[TOOL] - cannot invalidate tools. err %v", err)
+	}
+}
@@ -1,15 +1,36 @@
 package toolapi
 
-import "github.com/nkhang/pluto/internal/tool"
+import (
+	"encoding/json"
+
+	"github.com/nkhang/pluto/internal/tool"
+)
 
 type ToolResponse struct {
-	ID   uint64 `json:"id"`
-	Name string `json:"name"`
+	ID     uint64          `json:"id"`
+	Name   string          `json:"name"`
+	Kind   tool.Kind       `json:"kind"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+type CreateToolRequest struct {
+	Name   string          `json:"name" binding:"required"`
+	Kind   tool.Kind       `json:"kind" binding:"required"`
+	Config json.RawMessage `json:"config"`
+}
+
+// UpdateToolRequest changes the fields that are set. The kind of a tool
+// cannot change, Config must fit it.
+type UpdateToolRequest struct {
+	Name   *string          `json:"name"`
+	Config *json.RawMessage `json:"config"`
 }
 
 func ToToolResponse(t tool.Tool) ToolResponse {
 	return ToolResponse{
-		ID:   t.ID,
-		Name: t.Name,
+		ID:     t.ID,
+		Name:   t.Name,
+		Kind:   t.Kind,
+		Config: t.ConfigJSON(),
 	}
 }
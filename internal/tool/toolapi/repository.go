@@ -1,9 +1,18 @@
 package toolapi
 
-import "github.com/nkhang/pluto/internal/tool"
+import (
+	"strings"
+
+	"github.com/nkhang/pluto/internal/tool"
+	"github.com/nkhang/pluto/pkg/errors"
+)
 
 type Repository interface {
 	GetAll() ([]ToolResponse, error)
+	Get(id uint64) (ToolResponse, error)
+	Create(r CreateToolRequest) (ToolResponse, error)
+	Update(id uint64, r UpdateToolRequest) (ToolResponse, error)
+	Delete(id uint64) error
 }
 
 type repository struct {
@@ -27,3 +36,83 @@ func (r *repository) GetAll() ([]ToolResponse, error) {
 	}
 	return responses, nil
 }
+
+func (r *repository) Get(id uint64) (ToolResponse, error) {
+	t, err := r.toolRepo.Get(id)
+	if err != nil {
+		return ToolResponse{}, err
+	}
+	return ToToolResponse(t), nil
+}
+
+func (r *repository) Create(request CreateToolRequest) (ToolResponse, error) {
+	name, err := r.checkName(0, request.Name)
+	if err != nil {
+		return ToolResponse{}, err
+	}
+	config, err := tool.ParseConfig(request.Kind, request.Config)
+	if err != nil {
+		return ToolResponse{}, err
+	}
+	t, err := r.toolRepo.Create(name, request.Kind, config)
+	if err != nil {
+		return ToolResponse{}, err
+	}
+	return ToToolResponse(t), nil
+}
+
+func (r *repository) Update(id uint64, request UpdateToolRequest) (ToolResponse, error) {
+	t, err := r.toolRepo.Get(id)
+	if err != nil {
+		return ToolResponse{}, err
+	}
+	var changes = make(map[string]interface{})
+	if request.Name != nil {
+		name, err := r.checkName(id, *request.Name)
+		if err != nil {
+			return ToolResponse{}, err
+		}
+		changes["name"] = name
+	}
+	if request.Config != nil {
+		config, err := tool.ParseConfig(t.Kind, *request.Config)
+		if err != nil {
+			return ToolResponse{}, err
+		}
+		changes["config"] = config
+	}
+	if len(changes) == 0 {
+		return ToToolResponse(t), nil
+	}
+	t, err = r.toolRepo.Update(id, changes)
+	if err != nil {
+		return ToolResponse{}, err
+	}
+	return ToToolResponse(t), nil
+}
+
+func (r *repository) Delete(id uint64) error {
+	if _, err := r.toolRepo.Get(id); err != nil {
+		return err
+	}
+	return r.toolRepo.Delete(id)
+}
+
+// checkName checks that name is not empty nor used by a tool other than id.
+// Tools are matched by name ignoring case when templates are imported.
+func (r *repository) checkName(id uint64, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.BadRequest.NewWithMessage("tool name must not be empty")
+	}
+	tools, err := r.toolRepo.GetAll()
+	if err != nil {
+		return "", err
+	}
+	for _, t := range tools {
+		if t.ID != id && strings.EqualFold(t.Name, name) {
+			return "", errors.ToolCannotSave.NewWithMessageF("tool %s already exists", t.Name)
+		}
+	}
+	return name, nil
+}
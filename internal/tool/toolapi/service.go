@@ -2,23 +2,38 @@ package toolapi
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
 
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/pkg/errors"
 	"github.com/nkhang/pluto/pkg/ginwrapper"
 )
 
+const fieldToolID = "toolId"
+
 type service struct {
-	repo Repository
+	repo       Repository
+	authorizer authz.Authorizer
 }
 
-func NewService(r Repository) *service {
+func NewService(r Repository, authorizer authz.Authorizer) *service {
 	return &service{
-		repo: r,
+		repo:       r,
+		authorizer: authorizer,
 	}
 }
 
+// RegisterStandalone serves the tools to every user, only system
+// administrators manage them.
 func (s *service) RegisterStandalone(router gin.IRouter) {
 	router.GET("/", ginwrapper.Wrap(s.allTools))
+	router.GET("/:"+fieldToolID, ginwrapper.Wrap(s.get))
+	adminRouter := router.Group("", s.authorizer.RequireSystemAdmin())
+	{
+		adminRouter.POST("", ginwrapper.Wrap(s.create))
+		adminRouter.PUT("/:"+fieldToolID, ginwrapper.Wrap(s.update))
+		adminRouter.DELETE("/:"+fieldToolID, ginwrapper.Wrap(s.delete))
+	}
 }
 
 func (s *service) allTools(c *gin.Context) ginwrapper.Response {
@@ -33,3 +48,77 @@ func (s *service) allTools(c *gin.Context) ginwrapper.Response {
 		Data:  tools,
 	}
 }
+
+func (s *service) get(c *gin.Context) ginwrapper.Response {
+	id, err := toolIDOf(c)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	t, err := s.repo.Get(id)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  t,
+	}
+}
+
+func (s *service) create(c *gin.Context) ginwrapper.Response {
+	var req CreateToolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessageF("error binding request. error %v", err),
+		}
+	}
+	t, err := s.repo.Create(req)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  t,
+	}
+}
+
+func (s *service) update(c *gin.Context) ginwrapper.Response {
+	id, err := toolIDOf(c)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	var req UpdateToolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return ginwrapper.Response{
+			Error: errors.BadRequest.NewWithMessageF("error binding request. error %v", err),
+		}
+	}
+	t, err := s.repo.Update(id, req)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+		Data:  t,
+	}
+}
+
+func (s *service) delete(c *gin.Context) ginwrapper.Response {
+	id, err := toolIDOf(c)
+	if err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return ginwrapper.Response{Error: err}
+	}
+	return ginwrapper.Response{
+		Error: errors.Success.NewWithMessage("success"),
+	}
+}
+
+func toolIDOf(c *gin.Context) (uint64, error) {
+	id, err := cast.ToUint64E(c.Param(fieldToolID))
+	if err != nil || id == 0 {
+		return 0, errors.BadRequest.NewWithMessageF("invalid tool id %s", c.Param(fieldToolID))
+	}
+	return id, nil
+}
@@ -12,6 +12,7 @@ type Repository interface {
 	Get(id uint64) (Workspace, error)
 	GetByUserID(userID uint64, role Role, offset, limit int) ([]Workspace, int, error)
 	GetPermission(workspaceID uint64, role Role, offset, limit int) ([]Permission, int, error)
+	GetUserPermission(workspaceID, userID uint64) (Permission, error)
 	CreatePermission(workspaceID uint64, userIDs []uint64, role Role) error
 	Create(userID uint64, title, description, color string) (Workspace, error)
 	UpdateWorkspace(workspaceID uint64, changes map[string]interface{}) (Workspace, error)
@@ -125,6 +126,10 @@ func (r *repository) GetPermission(workspaceID uint64, role Role, offset, limit
 	return perms, total, nil
 }
 
+func (r *repository) GetUserPermission(workspaceID, userID uint64) (Permission, error) {
+	return r.dbRepo.GetPermission(workspaceID, userID)
+}
+
 func (r *repository) Create(userID uint64, title, description, color string) (Workspace, error) {
 	w, err := r.dbRepo.Create(userID, title, description, color)
 	if err != nil {
@@ -2,6 +2,7 @@ package permissionapi
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/internal/workspace/workspaceapi"
 	"github.com/nkhang/pluto/pkg/errors"
 	"github.com/nkhang/pluto/pkg/ginwrapper"
@@ -11,18 +12,22 @@ import (
 
 type service struct {
 	repository Repository
+	authorizer authz.Authorizer
 }
 
-func NewService(r Repository) *service {
-	return &service{repository: r}
+func NewService(r Repository, authorizer authz.Authorizer) *service {
+	return &service{
+		repository: r,
+		authorizer: authorizer,
+	}
 }
 
 const FieldUserID = "userId"
 
 func (s *service) Register(router gin.IRouter) {
 	router.GET("", ginwrapper.Wrap(s.get))
-	router.POST("", ginwrapper.Wrap(s.create))
-	router.DELETE("/:"+FieldUserID, ginwrapper.Wrap(s.delete))
+	router.POST("", s.authorizer.Require(authz.WorkspaceAdmin), ginwrapper.Wrap(s.create))
+	router.DELETE("/:"+FieldUserID, s.authorizer.Require(authz.WorkspaceAdmin), ginwrapper.Wrap(s.delete))
 }
 
 func (s *service) get(c *gin.Context) ginwrapper.Response {
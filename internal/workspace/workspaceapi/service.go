@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nkhang/pluto/internal/authz"
 	"github.com/nkhang/pluto/internal/workspace"
 	"github.com/nkhang/pluto/pkg/pgin"
 	"github.com/nkhang/pluto/pkg/util/idextractor"
@@ -14,37 +15,42 @@ import (
 )
 
 const (
-	FieldWorkspaceID = "workspaceId"
+	FieldWorkspaceID = pgin.FieldWorkspaceID
 )
 
 type service struct {
-	repository    Repository
-	workspaceRepo workspace.Repository
-	permRouter    pgin.Router
-	projectRouter pgin.Router
+	repository     Repository
+	workspaceRepo  workspace.Repository
+	permRouter     pgin.Router
+	projectRouter  pgin.Router
+	templateRouter pgin.Router
+	authorizer     authz.Authorizer
 }
 
 func NewService(r Repository, workspaceRepo workspace.Repository,
-	pr pgin.Router, permRouter pgin.Router) *service {
+	pr pgin.Router, permRouter pgin.Router, templateRouter pgin.Router, authorizer authz.Authorizer) *service {
 	return &service{
-		repository:    r,
-		workspaceRepo: workspaceRepo,
-		permRouter:    permRouter,
-		projectRouter: pr,
+		repository:     r,
+		workspaceRepo:  workspaceRepo,
+		permRouter:     permRouter,
+		projectRouter:  pr,
+		templateRouter: templateRouter,
+		authorizer:     authorizer,
 	}
 }
 
 func (s *service) RegisterStandalone(router gin.IRouter) {
 	router.GET("", ginwrapper.Wrap(s.getByUserID))
 	router.POST("", ginwrapper.Wrap(s.create))
-	detailRouter := router.Group("/:"+FieldWorkspaceID, s.verifyWorkspace())
+	detailRouter := router.Group("/:"+FieldWorkspaceID, s.verifyWorkspace(), s.authorizer.Require(authz.WorkspaceMember))
 	{
 		detailRouter.GET("", ginwrapper.Wrap(s.get))
-		detailRouter.PUT("", ginwrapper.Wrap(s.update))
-		detailRouter.DELETE("", ginwrapper.Wrap(s.delete))
+		detailRouter.PUT("", s.authorizer.Require(authz.WorkspaceAdmin), ginwrapper.Wrap(s.update))
+		detailRouter.DELETE("", s.authorizer.Require(authz.WorkspaceAdmin), ginwrapper.Wrap(s.delete))
 	}
 	s.permRouter.Register(detailRouter.Group("/perms"))
 	s.projectRouter.Register(detailRouter.Group("/projects"))
+	s.templateRouter.Register(detailRouter.Group("/templates"))
 }
 
 func (s *service) get(c *gin.Context) ginwrapper.Response {
@@ -0,0 +1,261 @@
+package annotation
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/nkhang/pluto/internal/dataset"
+	"github.com/nkhang/pluto/internal/outbox"
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/task"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+type DispatcherConfig struct {
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Lease       time.Duration
+}
+
+// Dispatcher delivers the outbox events to the annotation server. Events are
+// claimed for Lease before being delivered so that several replicas can run
+// a dispatcher, the lease must outlast the delivery of a batch. A failed
+// delivery is retried with exponential backoff until MaxAttempts is reached,
+// then the event is marked dead and waits for a manual replay. Projects in
+// classification mode are not known to the annotation server, their events
+// are handled locally.
+type Dispatcher struct {
+	service     Service
+	repo        outbox.DBRepository
+	taskRepo    task.Repository
+	projectRepo project.Repository
+	datasetRepo dataset.Repository
+	conf        DispatcherConfig
+}
+
+func NewDispatcher(s Service, r outbox.DBRepository, t task.Repository, p project.Repository, d dataset.Repository, conf DispatcherConfig) *Dispatcher {
+	if conf.Interval <= 0 {
+		conf.Interval = 5 * time.Second
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = 50
+	}
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = 8
+	}
+	if conf.BaseBackoff <= 0 {
+		conf.BaseBackoff = 10 * time.Second
+	}
+	if conf.MaxBackoff < conf.BaseBackoff {
+		conf.MaxBackoff = conf.BaseBackoff
+	}
+	if conf.Lease <= 0 {
+		conf.Lease = time.Minute
+	}
+	return &Dispatcher{
+		service:     s,
+		repo:        r,
+		taskRepo:    t,
+		projectRepo: p,
+		datasetRepo: d,
+		conf:        conf,
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.conf.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.DispatchOnce(time.Now())
+		}
+	}
+}
+
+// DispatchOnce claims the events that are due at now, delivers them in the
+// order they were written and returns how many of them were delivered.
+func (d *Dispatcher) DispatchOnce(now time.Time) int {
+	events, err := d.repo.Claim(now, d.conf.Lease, d.conf.BatchSize)
+	if err != nil {
+		logger.Errorf("[OUTBOX] - cannot claim due events. err %v", err)
+		return 0
+	}
+	var delivered int
+	for _, e := range events {
+		err := d.deliver(e)
+		if err == nil {
+			delivered++
+			d.update(e.ID, map[string]interface{}{
+				"status":     outbox.Delivered,
+				"attempts":   e.Attempts + 1,
+				"last_error": "",
+			})
+			continue
+		}
+		attempts := e.Attempts + 1
+		changes := map[string]interface{}{
+			"attempts":   attempts,
+			"last_error": err.Error(),
+		}
+		if attempts >= d.conf.MaxAttempts {
+			logger.Errorf("[OUTBOX] - event %d (%s) is dead after %d attempts. err %v", e.ID, e.Kind, attempts, err)
+			changes["status"] = outbox.Dead
+		} else {
+			logger.Infof("[OUTBOX] - event %d (%s) failed, attempt %d. err %v", e.ID, e.Kind, attempts, err)
+			changes["next_attempt_at"] = now.Add(d.backoff(attempts))
+		}
+		d.update(e.ID, changes)
+	}
+	return delivered
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.conf.BaseBackoff
+	for i := 1; i < attempts && wait < d.conf.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.conf.MaxBackoff {
+		wait = d.conf.MaxBackoff
+	}
+	return wait
+}
+
+func (d *Dispatcher) update(id uint64, changes map[string]interface{}) {
+	if _, err := d.repo.Update(id, changes); err != nil {
+		logger.Errorf("[OUTBOX] - cannot update event %d. err %v", id, err)
+	}
+}
+
+func (d *Dispatcher) deliver(e outbox.Event) error {
+	switch e.Kind {
+	case outbox.KindCreateTask:
+		var p outbox.TaskPayload
+		if err := json.Unmarshal([]byte(e.Payload), &p); err != nil {
+			return errors.OutboxCannotDeliver.Wrap(err, "cannot parse task payload")
+		}
+		if skip, err := d.skip(e, p.ProjectID); skip || err != nil {
+			return err
+		}
+		tasks := make([]task.Task, len(p.TaskIDs))
+		for i, id := range p.TaskIDs {
+			t, err := d.taskRepo.GetTask(id)
+			if err != nil {
+				return err
+			}
+			tasks[i] = t
+		}
+		return d.service.CreateTask(p.ProjectID, p.DatasetID, tasks)
+	case outbox.KindUpdateTask:
+		var p outbox.TaskPayload
+		if err := json.Unmarshal([]byte(e.Payload), &p); err != nil {
+			return errors.OutboxCannotDeliver.Wrap(err, "cannot parse task payload")
+		}
+		if skip, err := d.skip(e, p.ProjectID); skip || err != nil {
+			return err
+		}
+		for _, id := range p.TaskIDs {
+			t, err := d.taskRepo.GetTask(id)
+			if err != nil {
+				return err
+			}
+			if err := d.service.UpdateTask(t); err != nil {
+				return err
+			}
+		}
+		return nil
+	case outbox.KindDeleteTask:
+		var p outbox.DeleteTaskPayload
+		if err := json.Unmarshal([]byte(e.Payload), &p); err != nil {
+			return errors.OutboxCannotDeliver.Wrap(err, "cannot parse delete task payload")
+		}
+		if skip, err := d.skip(e, p.ProjectID); skip || err != nil {
+			return err
+		}
+		return d.service.DeleteTask(DeleteTaskMessage{
+			ProjectID:  p.ProjectID,
+			TaskIDs:    p.TaskIDs,
+			MergedInto: p.MergedInto,
+			DetailIDs:  p.DetailIDs,
+		})
+	case outbox.KindUpdateProject:
+		var p outbox.ProjectPayload
+		if err := json.Unmarshal([]byte(e.Payload), &p); err != nil {
+			return errors.OutboxCannotDeliver.Wrap(err, "cannot parse project payload")
+		}
+		if skip, err := d.skip(e, p.ProjectID); skip || err != nil {
+			return err
+		}
+		return d.service.UpdateProject(p.ProjectID)
+	case outbox.KindUpdateDataset:
+		var p outbox.DatasetPayload
+		if err := json.Unmarshal([]byte(e.Payload), &p); err != nil {
+			return errors.OutboxCannotDeliver.Wrap(err, "cannot parse dataset payload")
+		}
+		ds, err := d.datasetRepo.Get(p.DatasetID)
+		if errors.Type(err) == errors.DatasetNotFound {
+			logger.Infof("[OUTBOX] - event %d (%s) dropped, dataset %d is gone", e.ID, e.Kind, p.DatasetID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if skip, err := d.skip(e, ds.ProjectID); skip || err != nil {
+			return err
+		}
+		return d.service.UpdateDataset(p.DatasetID)
+	case outbox.KindUpdateLabels:
+		var p outbox.ProjectPayload
+		if err := json.Unmarshal([]byte(e.Payload), &p); err != nil {
+			return errors.OutboxCannotDeliver.Wrap(err, "cannot parse project payload")
+		}
+		if skip, err := d.skip(e, p.ProjectID); skip || err != nil {
+			return err
+		}
+		return d.service.UpdateLabels(p.ProjectID)
+	case outbox.KindRemapLabel:
+		var p outbox.RemapLabelPayload
+		if err := json.Unmarshal([]byte(e.Payload), &p); err != nil {
+			return errors.OutboxCannotDeliver.Wrap(err, "cannot parse remap label payload")
+		}
+		if skip, err := d.skip(e, p.ProjectID); skip || err != nil {
+			return err
+		}
+		return d.service.RemapLabel(p.ProjectID, p.From, p.To)
+	default:
+		return errors.OutboxCannotDeliver.NewWithMessageF("event kind %s is not supported", e.Kind)
+	}
+}
+
+// skip tells whether event e of a project is left out of the annotation
+// server because the project is in classification mode.
+func (d *Dispatcher) skip(e outbox.Event, projectID uint64) (bool, error) {
+	classifies, err := d.classifies(projectID)
+	if err != nil || !classifies {
+		return false, err
+	}
+	logger.Infof("[OUTBOX] - event %d (%s) skipped, project %d is in classification mode", e.ID, e.Kind, projectID)
+	return true, nil
+}
+
+// classifies tells whether a project is in classification mode. A project
+// that is gone is taken for an annotation one, so that the events written
+// before it was deleted still reach the annotation server instead of being
+// retried until they are dead.
+func (d *Dispatcher) classifies(projectID uint64) (bool, error) {
+	p, err := d.projectRepo.Get(projectID)
+	if errors.Type(err) == errors.ProjectNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return p.Classifies(), nil
+}
@@ -0,0 +1,223 @@
+package annotation
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/internal/outbox"
+	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/gorm"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Initlialize(false)
+	os.Exit(m.Run())
+}
+
+// fakeOutbox keeps the events in memory and claims them the way the
+// database does.
+type fakeOutbox struct {
+	outbox.DBRepository
+	events map[uint64]*outbox.Event
+}
+
+func newFakeOutbox() *fakeOutbox {
+	return &fakeOutbox{events: make(map[uint64]*outbox.Event)}
+}
+
+func (r *fakeOutbox) add(t *testing.T, kind outbox.Kind, payload interface{}, at time.Time) uint64 {
+	b, err := json.Marshal(payload)
+	require.NoError(t, err)
+	id := uint64(len(r.events) + 1)
+	r.events[id] = &outbox.Event{
+		Model:         gorm.Model{ID: id},
+		Kind:          kind,
+		Payload:       string(b),
+		NextAttemptAt: at,
+	}
+	return id
+}
+
+func (r *fakeOutbox) Claim(now time.Time, lease time.Duration, limit int) ([]outbox.Event, error) {
+	var events []outbox.Event
+	for _, e := range r.events {
+		if e.Status == outbox.Pending && !e.NextAttemptAt.After(now) {
+			events = append(events, *e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	for _, e := range events {
+		r.events[e.ID].NextAttemptAt = now.Add(lease)
+	}
+	return events, nil
+}
+
+func (r *fakeOutbox) Update(id uint64, changes map[string]interface{}) (outbox.Event, error) {
+	e := r.events[id]
+	for k, v := range changes {
+		switch k {
+		case "status":
+			e.Status = v.(outbox.Status)
+		case "attempts":
+			e.Attempts = v.(int)
+		case "last_error":
+			e.LastError = v.(string)
+		case "next_attempt_at":
+			e.NextAttemptAt = v.(time.Time)
+		}
+	}
+	return *e, nil
+}
+
+type fakeService struct {
+	Service
+	updated  []uint64
+	deleted  []uint64
+	remapped []uint64
+	failing  map[uint64]bool
+}
+
+func (s *fakeService) RemapLabel(projectID, from, to uint64) error {
+	s.remapped = append(s.remapped, from)
+	return nil
+}
+
+func (s *fakeService) DeleteTask(message DeleteTaskMessage) error {
+	s.deleted = append(s.deleted, message.TaskIDs...)
+	return nil
+}
+
+func (s *fakeService) UpdateProject(projectID uint64) error {
+	if s.failing[projectID] {
+		return errors.AnnotationCannotGetFromServer.NewWithMessage("annotation server is down")
+	}
+	s.updated = append(s.updated, projectID)
+	return nil
+}
+
+type fakeProjectRepo struct {
+	project.Repository
+	projects map[uint64]project.Project
+}
+
+func (r fakeProjectRepo) Get(id uint64) (project.Project, error) {
+	p, ok := r.projects[id]
+	if !ok {
+		return project.Project{}, errors.ProjectNotFound.NewWithMessage("project not found")
+	}
+	return p, nil
+}
+
+var (
+	dispatchStart = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	dispatchConf  = DispatcherConfig{
+		BatchSize:   10,
+		MaxAttempts: 3,
+		BaseBackoff: time.Second,
+		MaxBackoff:  3 * time.Second,
+		Lease:       time.Minute,
+	}
+)
+
+func newTestDispatcher(r outbox.DBRepository, s Service) *Dispatcher {
+	projects := fakeProjectRepo{projects: map[uint64]project.Project{
+		1: {Model: gorm.Model{ID: 1}, Mode: project.ModeAnnotation},
+		2: {Model: gorm.Model{ID: 2}, Mode: project.ModeAnnotation},
+		3: {Model: gorm.Model{ID: 3}, Mode: project.ModeAnnotation},
+		4: {Model: gorm.Model{ID: 4}, Mode: project.ModeClassification},
+	}}
+	return NewDispatcher(s, r, nil, projects, nil, dispatchConf)
+}
+
+func TestDispatchOrder(t *testing.T) {
+	r := newFakeOutbox()
+	for _, id := range []uint64{3, 1, 2} {
+		r.add(t, outbox.KindUpdateProject, outbox.ProjectPayload{ProjectID: id}, dispatchStart)
+	}
+	r.add(t, outbox.KindUpdateProject, outbox.ProjectPayload{ProjectID: 1}, dispatchStart.Add(time.Hour))
+	s := &fakeService{}
+	d := newTestDispatcher(r, s)
+	assert.Equal(t, 3, d.DispatchOnce(dispatchStart))
+	assert.Equal(t, []uint64{3, 1, 2}, s.updated, "events are delivered in the order they were written")
+	assert.Equal(t, outbox.Delivered, r.events[1].Status)
+	assert.Equal(t, outbox.Pending, r.events[4].Status, "events are not delivered before they are due")
+}
+
+func TestDispatchBackoffAndDeadLetter(t *testing.T) {
+	r := newFakeOutbox()
+	id := r.add(t, outbox.KindUpdateProject, outbox.ProjectPayload{ProjectID: 1}, dispatchStart)
+	s := &fakeService{failing: map[uint64]bool{1: true}}
+	d := newTestDispatcher(r, s)
+	now := dispatchStart
+	for attempt, wait := range []time.Duration{time.Second, 2 * time.Second} {
+		assert.Equal(t, 0, d.DispatchOnce(now))
+		e := r.events[id]
+		assert.Equal(t, outbox.Pending, e.Status)
+		assert.Equal(t, attempt+1, e.Attempts)
+		assert.Equal(t, now.Add(wait), e.NextAttemptAt)
+		assert.NotEmpty(t, e.LastError)
+		assert.Equal(t, 0, d.DispatchOnce(now.Add(wait-time.Millisecond)), "a failed event waits for its backoff")
+		now = now.Add(wait)
+	}
+	assert.Equal(t, 0, d.DispatchOnce(now))
+	assert.Equal(t, outbox.Dead, r.events[id].Status, "an event is dead after MaxAttempts")
+	assert.Equal(t, 3, r.events[id].Attempts)
+	assert.Equal(t, 0, d.DispatchOnce(now.Add(time.Hour)), "a dead event is not delivered again")
+	assert.Empty(t, s.updated)
+}
+
+func TestDispatchBackoff(t *testing.T) {
+	d := newTestDispatcher(newFakeOutbox(), &fakeService{})
+	tests := []struct {
+		attempts int
+		wait     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 3 * time.Second},
+		{10, 3 * time.Second},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.wait, d.backoff(tt.attempts), "attempt %d", tt.attempts)
+	}
+}
+
+func TestDispatchClaims(t *testing.T) {
+	r := newFakeOutbox()
+	r.add(t, outbox.KindUpdateProject, outbox.ProjectPayload{ProjectID: 1}, dispatchStart)
+	claimed, err := r.Claim(dispatchStart, dispatchConf.Lease, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	s := &fakeService{}
+	d := newTestDispatcher(r, s)
+	assert.Equal(t, 0, d.DispatchOnce(dispatchStart), "an event claimed by another dispatcher is left to it")
+	assert.Equal(t, 1, d.DispatchOnce(dispatchStart.Add(dispatchConf.Lease)), "an event is claimed again once its lease is over")
+	assert.Equal(t, []uint64{1}, s.updated)
+}
+
+func TestDispatchSkip(t *testing.T) {
+	r := newFakeOutbox()
+	classified := r.add(t, outbox.KindUpdateProject, outbox.ProjectPayload{ProjectID: 4}, dispatchStart)
+	remapped := r.add(t, outbox.KindRemapLabel, outbox.RemapLabelPayload{ProjectID: 4, From: 1, To: 2}, dispatchStart)
+	gone := r.add(t, outbox.KindDeleteTask, outbox.DeleteTaskPayload{ProjectID: 9, TaskIDs: []uint64{5}}, dispatchStart)
+	s := &fakeService{}
+	d := newTestDispatcher(r, s)
+	assert.Equal(t, 3, d.DispatchOnce(dispatchStart))
+	assert.Empty(t, s.updated, "events of classification projects are not sent")
+	assert.Equal(t, outbox.Delivered, r.events[classified].Status)
+	assert.Empty(t, s.remapped, "pluto remaps the labels of classification projects itself")
+	assert.Equal(t, outbox.Delivered, r.events[remapped].Status)
+	assert.Equal(t, []uint64{5}, s.deleted, "events of deleted projects are still sent")
+	assert.Equal(t, outbox.Delivered, r.events[gone].Status)
+}
@@ -1,5 +1,7 @@
 package annotation
 
+import "encoding/json"
+
 type PushTaskMessage struct {
 	Workspace WorkspaceObject `json:"workspace"`
 	Project   ProjectObject   `json:"project"`
@@ -30,24 +32,114 @@ type TaskObject struct {
 	ID        uint64 `json:"id"`
 	Labeler   uint64 `json:"labeler"`
 	Reviewer  uint64 `json:"reviewer"`
+	GroupID   uint64 `json:"group_id,omitempty"`
 	CreatedAt int64  `json:"created_at"`
 }
 
+// LabelObject is a label of the project. ParentID is the label it is a
+// subclass of, 0 for the labels at the top of the hierarchy.
 type LabelObject struct {
-	ID    uint64     `json:"id"`
-	Name  string     `json:"name"`
-	Color string     `json:"color"`
-	Tool  ToolObject `json:"tool"`
+	ID         uint64            `json:"id"`
+	Name       string            `json:"name"`
+	Color      string            `json:"color"`
+	ParentID   uint64            `json:"parent_id"`
+	Tool       ToolObject        `json:"tool"`
+	Position   int               `json:"position"`
+	Attributes []AttributeObject `json:"attributes"`
+}
+
+type AttributeObject struct {
+	ID       uint64   `json:"id"`
+	Name     string   `json:"name"`
+	Kind     string   `json:"kind"`
+	Options  []string `json:"options"`
+	Required bool     `json:"required"`
 }
 
+// LabelsMessage holds every label of a project, in their order.
+type LabelsMessage struct {
+	ProjectID uint64        `json:"project_id"`
+	Labels    []LabelObject `json:"labels"`
+}
+
+// RemapLabelMessage asks to move the objects of label From to label To.
+type RemapLabelMessage struct {
+	ProjectID uint64 `json:"project_id"`
+	From      uint64 `json:"from"`
+	To        uint64 `json:"to"`
+}
+
+// DeleteTaskMessage asks to drop tasks. Tasks merged into task MergedInto
+// keep their objects under it, with the details in DetailIDs renamed.
+type DeleteTaskMessage struct {
+	ProjectID  uint64            `json:"project_id"`
+	TaskIDs    []uint64          `json:"task_ids"`
+	MergedInto uint64            `json:"merged_into,omitempty"`
+	DetailIDs  map[uint64]uint64 `json:"detail_ids,omitempty"`
+}
+
+// ToolObject is the tool of a label. Config follows the schema of its Kind,
+// such as the keypoints and edges of a skeleton.
 type ToolObject struct {
-	ID   uint64 `json:"id"`
-	Name string `json:"name"`
+	ID     uint64          `json:"id"`
+	Name   string          `json:"name"`
+	Kind   string          `json:"kind"`
+	Config json.RawMessage `json:"config,omitempty"`
 }
 
 type LabelStatsObject struct {
-	TotalObject int `json:"total_objects"`
-	TotalImage  int `json:"total_images"`
+	TotalObject int                `json:"total_objects"`
+	TotalImage  int                `json:"total_images"`
+	Labels      []LabelCountObject `json:"labels,omitempty"`
+}
+
+// LabelCountObject counts the objects of one label of a project and the
+// images they are drawn on.
+type LabelCountObject struct {
+	LabelID     uint64 `json:"label_id"`
+	TotalObject int    `json:"total_objects"`
+	TotalImage  int    `json:"total_images"`
+}
+
+type ImageLabelsObject struct {
+	TaskID  uint64   `json:"task_id"`
+	ImageID uint64   `json:"image_id"`
+	Labels  []uint64 `json:"labels"`
+}
+
+type ImageLabelsResponse struct {
+	Status  int32               `json:"status"`
+	Message string              `json:"msg"`
+	Data    []ImageLabelsObject `json:"data"`
+}
+
+// ImageAnnotationsObject holds the shapes drawn on an image, their points
+// are in pixels.
+type ImageAnnotationsObject struct {
+	ImageID     uint64        `json:"image_id"`
+	Annotations []ShapeObject `json:"annotations"`
+}
+
+type ShapeObject struct {
+	LabelID uint64        `json:"label_id"`
+	Points  []PointObject `json:"points"`
+}
+
+type PointObject struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type ImportAnnotationsMessage struct {
+	ProjectID uint64                   `json:"project_id"`
+	DatasetID uint64                   `json:"dataset_id"`
+	Images    []ImageAnnotationsObject `json:"images"`
+}
+
+type ImageAnnotationsResponse struct {
+	Status  int32                    `json:"status"`
+	Message string                   `json:"msg"`
+	Data    []ImageAnnotationsObject `json:"data"`
 }
 
 type LabelStatsResponse struct {
@@ -30,8 +30,20 @@ type Service interface {
 	GetLabelCount(projectID, labelID uint64) (LabelStatsObject, error)
 	CreateTaskWithNATS(projectID, datasetID uint64, tasks []task.Task) error
 	GetImageStats(projectID uint64) (obj LabelStatsObject, err error)
+	GetTaskLabels(taskIDs []uint64) ([]ImageLabelsObject, error)
+	GetDatasetAnnotations(projectID, datasetID uint64) ([]ImageAnnotationsObject, error)
+	ImportAnnotations(projectID, datasetID uint64, images []ImageAnnotationsObject) error
+	UpdateTask(t task.Task) error
+	DeleteTask(message DeleteTaskMessage) error
+	UpdateLabels(projectID uint64) error
+	RemapLabel(projectID, from, to uint64) error
 }
 
+const (
+	TransportHTTP = "http"
+	TransportNATS = "nats"
+)
+
 type service struct {
 	workspaceRepo      workspace.Repository
 	projectRepo        project.Repository
@@ -39,21 +51,31 @@ type service struct {
 	labelRepo          label.Repository
 	client             http.Client
 	nc                 *nats.EncodedConn
+	transport          string
 	annotationBasePath string
 }
 
+// NewService creates the annotation service. nc may be nil, in which case
+// every push goes over HTTP regardless of annotation.transport.
 func NewService(workspaceRepo workspace.Repository,
 	projectRepo project.Repository,
 	datasetRepo dataset.Repository,
-	labelRepo label.Repository) *service {
+	labelRepo label.Repository,
+	nc *nats.EncodedConn) *service {
 	client := http.Client{}
 	annotationBase := viper.GetString("annotation.baseurl")
+	transport := viper.GetString("annotation.transport")
+	if transport != TransportNATS || nc == nil {
+		transport = TransportHTTP
+	}
 	return &service{
 		workspaceRepo:      workspaceRepo,
 		projectRepo:        projectRepo,
 		datasetRepo:        datasetRepo,
 		labelRepo:          labelRepo,
 		client:             client,
+		nc:                 nc,
+		transport:          transport,
 		annotationBasePath: annotationBase,
 	}
 }
@@ -89,6 +111,7 @@ func (s *service) GetLabelCount(projectID, labelID uint64) (obj LabelStatsObject
 	}
 	if respObj.Status != 1 {
 		err = errors.AnnotationCannotGetFromServer.NewWithMessageF("error getting from annotation server. msg: %s", respObj.Message)
+		return
 	}
 	return respObj.Data, nil
 }
@@ -123,10 +146,104 @@ func (s *service) GetImageStats(projectID uint64) (obj LabelStatsObject, err err
 	}
 	if respObj.Status != 1 {
 		err = errors.AnnotationCannotGetFromServer.NewWithMessageF("error getting from annotation server. msg: %s", respObj.Message)
+		return
+	}
+	return respObj.Data, nil
+}
+
+// GetTaskLabels returns the labels put on each image of the given tasks.
+func (s *service) GetTaskLabels(taskIDs []uint64) (objs []ImageLabelsObject, err error) {
+	path := s.annotationBasePath + "/stats/tasks"
+	u, err := url.Parse(path)
+	if err != nil {
+		err = errors.AnnotationCannotParseURL.WrapF(err, "cannot parse url %s", path)
+		return
+	}
+	q := u.Query()
+	for _, id := range taskIDs {
+		q.Add("task_id", cast.ToString(id))
+	}
+	u.RawQuery = q.Encode()
+	logger.Infof("[ANNOTATION] - request URL: %s", u.String())
+	resp, err := s.client.Get(u.String())
+	if err != nil {
+		err = errors.AnnotationCannotGetFromServer.WrapF(err, "cannot get task labels from server")
+		return
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		err = errors.AnnotationCannotReadBody.Wrap(err, "cannot get response body")
+		return
+	}
+	var respObj ImageLabelsResponse
+	err = json.Unmarshal(b, &respObj)
+	if err != nil {
+		err = errors.AnnotationCannotReadBody.Wrap(err, "cannot parse json body of response")
+		return
+	}
+	if respObj.Status != 1 {
+		err = errors.AnnotationCannotGetFromServer.NewWithMessageF("error getting from annotation server. msg: %s", respObj.Message)
+		return
 	}
 	return respObj.Data, nil
 }
 
+// GetDatasetAnnotations returns the shapes drawn on every image of a
+// dataset.
+func (s *service) GetDatasetAnnotations(projectID, datasetID uint64) (objs []ImageAnnotationsObject, err error) {
+	path := s.annotationBasePath + "/annotations"
+	u, err := url.Parse(path)
+	if err != nil {
+		err = errors.AnnotationCannotParseURL.WrapF(err, "cannot parse url %s", path)
+		return
+	}
+	q := u.Query()
+	q.Set("project_id", cast.ToString(projectID))
+	q.Set("dataset_id", cast.ToString(datasetID))
+	u.RawQuery = q.Encode()
+	logger.Infof("[ANNOTATION] - request URL: %s", u.String())
+	resp, err := s.client.Get(u.String())
+	if err != nil {
+		err = errors.AnnotationCannotGetFromServer.WrapF(err, "cannot get dataset annotations from server")
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		err = errors.AnnotationCannotGetFromServer.NewWithMessageF("annotation server responded %d", resp.StatusCode)
+		return
+	}
+	var respObj ImageAnnotationsResponse
+	err = json.NewDecoder(resp.Body).Decode(&respObj)
+	if err != nil {
+		err = errors.AnnotationCannotReadBody.Wrap(err, "cannot parse json body of response")
+		return
+	}
+	if respObj.Status != 1 {
+		err = errors.AnnotationCannotGetFromServer.NewWithMessageF("error getting from annotation server. msg: %s", respObj.Message)
+		return
+	}
+	return respObj.Data, nil
+}
+
+// ImportAnnotations sends the shapes of an imported dataset to the
+// annotation server. It always goes over HTTP since the message may be too
+// large for NATS.
+func (s *service) ImportAnnotations(projectID, datasetID uint64, images []ImageAnnotationsObject) error {
+	message := ImportAnnotationsMessage{
+		ProjectID: projectID,
+		DatasetID: datasetID,
+		Images:    images,
+	}
+	b, err := json.Marshal(&message)
+	if err != nil {
+		return errors.AnnotationCannotReadBody.NewWithMessage("error marshalling object")
+	}
+	path := s.annotationBasePath + "/annotations/import"
+	logger.Infof("[ANNOTATION] - importing annotations of %d images of dataset %d", len(images), datasetID)
+	return s.post(path, b)
+}
+
 func (s *service) CreateTaskWithNATS(projectID, datasetID uint64, tasks []task.Task) error {
 	p, err := s.projectRepo.Get(projectID)
 	if err != nil {
@@ -168,6 +285,9 @@ func (s *service) CreateTask(projectID, datasetID uint64, tasks []task.Task) err
 	if err != nil {
 		return err
 	}
+	if s.transport == TransportNATS {
+		return s.pushWithNATS(message)
+	}
 	return s.push(message)
 }
 
@@ -183,13 +303,24 @@ func (s *service) push(message PushTaskMessage) error {
 		return err
 	}
 	logger.Infof("msg: %s", b)
-	resp, err := s.client.Post(path, "application/json", bytes.NewReader(b))
+	return s.post(path, b)
+}
+
+// post sends body to the annotation server and fails on any non-2xx status,
+// so the outbox dispatcher knows when to retry.
+func (s *service) post(path string, body []byte) error {
+	resp, err := s.client.Post(path, "application/json", bytes.NewReader(body))
 	if err != nil {
-		return err
+		return errors.AnnotationCannotGetFromServer.NewWithMessageF("error requesting to annotation server. err %v", err)
 	}
-	bb, err := ioutil.ReadAll(resp.Body)
-	if err == nil {
-		logger.Info(string(bb))
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.AnnotationCannotReadBody.NewWithMessageF("cannot read body from annotation server. err %v", err)
+	}
+	logger.Infof("[ANNOTATION] - response from %s: %s", path, b)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.AnnotationCannotGetFromServer.NewWithMessageF("annotation server responded %d", resp.StatusCode)
 	}
 	return nil
 }
@@ -291,6 +422,7 @@ func (b *builder) WithTasks(tasks []task.Task) *builder {
 			ID:        task.ID,
 			Labeler:   task.Labeler,
 			Reviewer:  task.Reviewer,
+			GroupID:   task.GroupID,
 			CreatedAt: clock.UnixMillisecondFromTime(task.CreatedAt),
 		}
 	}
@@ -305,20 +437,39 @@ func (b *builder) WithLabels(projectID uint64) *builder {
 		b.errs = append(b.errs, err)
 		return b
 	}
-	var responses = make([]LabelObject, len(labels))
-	for i, label := range labels {
-		responses[i] = LabelObject{
-			ID:    label.ID,
-			Name:  label.Name,
-			Color: label.Color,
+	b.labels = toLabelObjects(labels)
+	return b
+}
+
+func toLabelObjects(labels []label.Label) []LabelObject {
+	var objects = make([]LabelObject, len(labels))
+	for i, l := range labels {
+		attributes := make([]AttributeObject, len(l.Attributes))
+		for j, a := range l.Attributes {
+			attributes[j] = AttributeObject{
+				ID:       a.ID,
+				Name:     a.Name,
+				Kind:     string(a.Kind),
+				Options:  a.OptionList(),
+				Required: a.Required,
+			}
+		}
+		objects[i] = LabelObject{
+			ID:       l.ID,
+			Name:     l.Name,
+			Color:    l.Color,
+			ParentID: l.ParentID,
 			Tool: ToolObject{
-				ID:   label.Tool.ID,
-				Name: label.Tool.Name,
+				ID:     l.Tool.ID,
+				Name:   l.Tool.Name,
+				Kind:   string(l.Tool.Kind),
+				Config: l.Tool.ConfigJSON(),
 			},
+			Position:   l.Position,
+			Attributes: attributes,
 		}
 	}
-	b.labels = responses
-	return b
+	return objects
 }
 func (b *builder) Build() (PushTaskMessage, error) {
 	if len(b.errs) != 0 {
@@ -355,22 +506,53 @@ func (s *service) UpdateProject(projectID uint64) error {
 		Title:          p.Title,
 		ProjectManager: managers,
 	}
+	if s.transport == TransportNATS {
+		return s.nc.Publish(viper.GetString("annotation.updateproject"), &object)
+	}
 	b, err := json.Marshal(object)
 	if err != nil {
 		return errors.AnnotationCannotReadBody.NewWithMessage("error marshalling object")
 	}
 	path := s.annotationBasePath + "/annotation/project/update"
 	logger.Infof("publishing project to annotation server. path: %s. body %s", path, b)
-	resp, err := s.client.Post(path, "application/json", bytes.NewReader(b))
+	return s.post(path, b)
+}
+
+// UpdateTask tells the annotation server about the current assignees of a
+// task.
+func (s *service) UpdateTask(t task.Task) error {
+	object := TaskObject{
+		ID:        t.ID,
+		Labeler:   t.Labeler,
+		Reviewer:  t.Reviewer,
+		GroupID:   t.GroupID,
+		CreatedAt: clock.UnixMillisecondFromTime(t.CreatedAt),
+	}
+	if s.transport == TransportNATS {
+		return s.nc.Publish(viper.GetString("annotation.updatetaskassignees"), &object)
+	}
+	b, err := json.Marshal(object)
 	if err != nil {
-		return errors.AnnotationCannotGetFromServer.NewWithMessageF("error requesting to annotation server. err %v", err)
+		return errors.AnnotationCannotReadBody.NewWithMessage("error marshalling object")
 	}
-	body, err := ioutil.ReadAll(resp.Body)
+	path := s.annotationBasePath + "/annotation/task/update"
+	logger.Infof("[ANNOTATION] - publishing task to annotation server. path: %s. body %s", path, b)
+	return s.post(path, b)
+}
+
+// DeleteTask tells the annotation server that tasks are gone, either deleted
+// or merged into another task.
+func (s *service) DeleteTask(message DeleteTaskMessage) error {
+	if s.transport == TransportNATS {
+		return s.nc.Publish(viper.GetString("annotation.deletetask"), &message)
+	}
+	b, err := json.Marshal(message)
 	if err != nil {
-		return errors.AnnotationCannotReadBody.NewWithMessageF("cannot read body from annotation server. err", err)
+		return errors.AnnotationCannotReadBody.NewWithMessage("error marshalling object")
 	}
-	logger.Infof("update to annotation server resp %s", body)
-	return nil
+	path := s.annotationBasePath + "/annotation/task/delete"
+	logger.Infof("[ANNOTATION] - deleting tasks %v of project %d. path: %s", message.TaskIDs, message.ProjectID, path)
+	return s.post(path, b)
 }
 
 func (s *service) UpdateDataset(datasetID uint64) error {
@@ -384,20 +566,58 @@ func (s *service) UpdateDataset(datasetID uint64) error {
 		Title:     d.Title,
 		ProjectID: d.ProjectID,
 	}
+	if s.transport == TransportNATS {
+		return s.nc.Publish(viper.GetString("annotation.updatedataset"), &object)
+	}
 	b, err := json.Marshal(object)
 	if err != nil {
 		return errors.AnnotationCannotReadBody.NewWithMessage("error marshalling object")
 	}
 	path := s.annotationBasePath + "/annotation/dataset/update"
 	logger.Infof("[ANNOTATION] - publishing project to annotation server. path: %s. body %s", path, b)
-	resp, err := s.client.Post(path, "application/json", bytes.NewReader(b))
+	return s.post(path, b)
+}
+
+// UpdateLabels sends every label of a project, with their attributes, to
+// the annotation server.
+func (s *service) UpdateLabels(projectID uint64) error {
+	labels, err := s.labelRepo.GetByProjectId(projectID)
 	if err != nil {
-		return errors.AnnotationCannotGetFromServer.NewWithMessageF("error requesting to annotation server. err %v", err)
+		logger.Errorf("[ANNOTATION] - error getting labels of project %d. err %v", projectID, err)
+		return err
 	}
-	body, err := ioutil.ReadAll(resp.Body)
+	message := LabelsMessage{
+		ProjectID: projectID,
+		Labels:    toLabelObjects(labels),
+	}
+	if s.transport == TransportNATS {
+		return s.nc.Publish(viper.GetString("annotation.updatelabels"), &message)
+	}
+	b, err := json.Marshal(message)
 	if err != nil {
-		return errors.AnnotationCannotReadBody.NewWithMessageF("cannot read body from annotation server. err", err)
+		return errors.AnnotationCannotReadBody.NewWithMessage("error marshalling object")
 	}
-	logger.Infof("[ANNOTATION] - update to annotation server resp %s", body)
-	return nil
+	path := s.annotationBasePath + "/annotation/labels/update"
+	logger.Infof("[ANNOTATION] - publishing labels of project %d to annotation server. path: %s", projectID, path)
+	return s.post(path, b)
+}
+
+// RemapLabel asks the annotation server to move the objects of a deleted
+// label to another label.
+func (s *service) RemapLabel(projectID, from, to uint64) error {
+	message := RemapLabelMessage{
+		ProjectID: projectID,
+		From:      from,
+		To:        to,
+	}
+	if s.transport == TransportNATS {
+		return s.nc.Publish(viper.GetString("annotation.remaplabel"), &message)
+	}
+	b, err := json.Marshal(message)
+	if err != nil {
+		return errors.AnnotationCannotReadBody.NewWithMessage("error marshalling object")
+	}
+	path := s.annotationBasePath + "/annotation/labels/remap"
+	logger.Infof("[ANNOTATION] - remapping label %d to %d in project %d. path: %s", from, to, projectID, path)
+	return s.post(path, b)
 }
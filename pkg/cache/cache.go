@@ -14,6 +14,7 @@ type Cache interface {
 	Set(key string, target interface{}) error
 	Del(key ...string) error
 	Keys(pattern string) ([]string, error)
+	SetNX(key string, target interface{}, exp time.Duration) (bool, error)
 }
 
 type client struct {
@@ -73,3 +74,17 @@ func (c *client) Keys(pattern string) ([]string, error) {
 	}
 	return s, nil
 }
+
+// SetNX sets key only if it does not exist yet, with its own expiration, and
+// reports whether it was set.
+func (c *client) SetNX(key string, data interface{}, exp time.Duration) (bool, error) {
+	s, err := json.Serialize(data)
+	if err != nil {
+		return false, errors.CacheSetError.Wrap(err, "cannot serialize data")
+	}
+	ok, err := c.cmd.SetNX(key, s, exp).Result()
+	if err != nil {
+		return false, errors.CacheSetError.Wrap(err, "cannot set cache if not exists")
+	}
+	return ok, nil
+}
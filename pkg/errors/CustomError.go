@@ -16,3 +16,11 @@ func (ce CustomError) Error() string {
 func (ce CustomError) BareError() string {
 	return ce.Message
 }
+
+// Message is what a client is told about err, the root cause is left out.
+func Message(err error) string {
+	if ce, ok := err.(CustomError); ok {
+		return ce.BareError()
+	}
+	return err.Error()
+}
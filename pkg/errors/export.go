@@ -0,0 +1,9 @@
+package errors
+
+const (
+	ExportCannotBuild ErrorType = -(2000 + iota)
+	ExportCannotWrite
+	ExportFormatNotSupported
+	ExportJobNotFound
+	ExportJobNotReady
+)
@@ -5,4 +5,5 @@ const (
 	Unknown
 	BadRequest
 	Unauthorized
+	Forbidden
 )
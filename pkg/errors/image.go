@@ -9,4 +9,14 @@ const (
 	ImageIncrError
 	ImageCannotUpdate
 	ImageCannotDecode
+	ImageArchiveCannotRead
+	ImageArchiveTooLarge
+	ImageUploadJobNotFound
+	ImageUploadJobCannotCreate
+	ImageUploadJobCannotUpdate
+	ImageDuplicate
+	ImageDuplicateSkipped
+	ImageUploadKeyInvalid
+	ImageCannotDelete
+	ImageCannotTransfer
 )
@@ -0,0 +1,9 @@
+package errors
+
+const (
+	ImportCannotRead ErrorType = -(2100 + iota)
+	ImportFormatNotSupported
+	ImportAnnotationsNotFound
+	ImportToolNotFound
+	ImportCannotForward
+)
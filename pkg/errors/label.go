@@ -4,4 +4,13 @@ const (
 	LabelRecordNotFound ErrorType = -(1100 + iota)
 	LabelQueryError
 	LabelCannotCreate
+	LabelCannotUpdate
+	LabelCannotDelete
+	LabelAttributeInvalid
+	LabelHasAnnotations
+	LabelOrderInvalid
+	LabelParentInvalid
+	LabelTemplateNotFound
+	LabelTemplateInvalid
+	LabelTemplateCannotSave
 )
@@ -0,0 +1,9 @@
+package errors
+
+const (
+	OutboxCannotCreate ErrorType = -(1900 + iota)
+	OutboxCannotGet
+	OutboxCannotUpdate
+	OutboxEventNotFound
+	OutboxCannotDeliver
+)
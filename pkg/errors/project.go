@@ -13,4 +13,6 @@ const (
 	ProjectCannotUpdate
 	ProjectCannotDelete
 	ProjectRoleInvalid
+	ProjectDuplicatePolicyInvalid
+	ProjectModeInvalid
 )
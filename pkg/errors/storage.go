@@ -0,0 +1,13 @@
+package errors
+
+const (
+	StorageObjectNotFound ErrorType = -(2200 + iota)
+	StorageCannotGet
+	StorageCannotPresign
+	StorageCannotPut
+	StorageCannotDelete
+	StorageCannotList
+	StorageInvalidKey
+	StorageBackendNotSupported
+	StorageConfigInvalid
+)
@@ -9,4 +9,15 @@ const (
 	TaskDetailCannotGet
 	TaskDetailCannotUpdate
 	TaskDetailCannotDelete
+	TaskCannotSplit
+	TaskCannotMerge
+	TaskDetailInvalidTransition
+	TaskInvalidTransition
+	TaskHistoryCannotGet
+	TaskQueueEmpty
+	TaskLeaseCannotAcquire
+	TaskLeaseNotHeld
+	TaskImageApproved
+	TaskNotClassification
+	TaskClassificationInvalid
 )
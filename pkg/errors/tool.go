@@ -4,4 +4,7 @@ const (
 	ToolNotFound ErrorType = -(1000 + iota)
 	ToolNoRecord
 	ToolQueryError
+	ToolConfigInvalid
+	ToolCannotSave
+	ToolInUse
 )
@@ -1,16 +1,62 @@
 package annotationfx
 
 import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+
 	"github.com/nkhang/pluto/internal/dataset"
 	"github.com/nkhang/pluto/internal/label"
+	"github.com/nkhang/pluto/internal/outbox"
 	"github.com/nkhang/pluto/internal/project"
+	"github.com/nkhang/pluto/internal/task"
 	"github.com/nkhang/pluto/internal/workspace"
 	"github.com/nkhang/pluto/pkg/annotation"
+	"github.com/nkhang/pluto/pkg/logger"
 )
 
-func provideAnnotationService(workspaceRepo workspace.Repository,
-	projectRepo project.Repository,
-	datasetRepo dataset.Repository,
-	labelRepo label.Repository) annotation.Service {
-	return annotation.NewService(workspaceRepo, projectRepo, datasetRepo, labelRepo)
+type params struct {
+	fx.In
+
+	WorkspaceRepo workspace.Repository
+	ProjectRepo   project.Repository
+	DatasetRepo   dataset.Repository
+	LabelRepo     label.Repository
+	NATSConn      *nats.EncodedConn `optional:"true"`
+}
+
+func provideAnnotationService(p params) annotation.Service {
+	return annotation.NewService(p.WorkspaceRepo, p.ProjectRepo, p.DatasetRepo, p.LabelRepo, p.NATSConn)
+}
+
+func provideDispatcher(s annotation.Service, r outbox.DBRepository, t task.Repository, p project.Repository, d dataset.Repository) *annotation.Dispatcher {
+	conf := annotation.DispatcherConfig{
+		Interval:    viper.GetDuration("outbox.interval"),
+		BatchSize:   viper.GetInt("outbox.batchsize"),
+		MaxAttempts: viper.GetInt("outbox.maxattempts"),
+		BaseBackoff: viper.GetDuration("outbox.basebackoff"),
+		MaxBackoff:  viper.GetDuration("outbox.maxbackoff"),
+		Lease:       viper.GetDuration("outbox.lease"),
+	}
+	return annotation.NewDispatcher(s, r, t, p, d, conf)
+}
+
+func runDispatcher(l fx.Lifecycle, d *annotation.Dispatcher) {
+	if !viper.GetBool("outbox.enabled") {
+		logger.Info("[OUTBOX] - dispatcher is disabled")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	l.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go d.Run(ctx)
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
 }
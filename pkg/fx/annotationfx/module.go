@@ -2,4 +2,7 @@ package annotationfx
 
 import "go.uber.org/fx"
 
-var Module = fx.Provide(provideAnnotationService)
+var Module = fx.Options(
+	fx.Provide(provideAnnotationService, provideDispatcher),
+	fx.Invoke(runDispatcher),
+)
@@ -10,7 +10,13 @@ import (
 	"go.uber.org/fx"
 )
 
+// provideNATSClient connects to NATS when nats.enabled is set, the
+// connection is nil otherwise and its users fall back or stay idle.
 func provideNATSClient(lc fx.Lifecycle) *nats.EncodedConn {
+	if !viper.GetBool("nats.enabled") {
+		logger.Info("NATS is disabled")
+		return nil
+	}
 	url := viper.GetString("nats.url")
 	logger.Infof("Opening connection to NATS server at %s", url)
 	nc, err := nats.Connect(url, nats.Timeout(30*time.Second))
@@ -1,14 +1,51 @@
 package storagefx
 
 import (
-	"github.com/nkhang/pluto/pkg/objectstorage"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
+
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/logger"
+	"github.com/nkhang/pluto/pkg/objectstorage"
+	"github.com/nkhang/pluto/pkg/pgin"
 )
 
+// provideObjectStorage builds the backend set by storage.backend, which is
+// minio unless set to local or memory.
 func provideObjectStorage() (objectstorage.ObjectStorage, error) {
-	endpoint := viper.GetString("minio.endpoint")
-	accessKey := viper.GetString("minio.accesskey")
-	secretKey := viper.GetString("minio.secretkey")
-	useSSL := viper.GetBool("minio.usessl")
-	return objectstorage.NewMinioClient(endpoint, accessKey, secretKey, useSSL)
-}
\ No newline at end of file
+	backend := viper.GetString("storage.backend")
+	logger.Infof("[STORAGE] - using %s object storage", backend)
+	switch backend {
+	case "", "minio":
+		endpoint := viper.GetString("minio.endpoint")
+		accessKey := viper.GetString("minio.accesskey")
+		secretKey := viper.GetString("minio.secretkey")
+		useSSL := viper.GetBool("minio.usessl")
+		baseURL := fmt.Sprintf("%s://%s", viper.GetString("minio.scheme"), viper.GetString("minio.basepath"))
+		return objectstorage.NewMinioClient(endpoint, accessKey, secretKey, useSSL, baseURL)
+	case "local":
+		root := viper.GetString("storage.local.root")
+		baseURL := viper.GetString("storage.local.baseurl")
+		secret := viper.GetString("storage.local.secret")
+		maxSize := viper.GetInt64("storage.local.maxsize")
+		return objectstorage.NewLocal(root, baseURL, secret, maxSize)
+	case "memory":
+		return objectstorage.NewMemory(), nil
+	}
+	return nil, errors.StorageBackendNotSupported.NewWithMessageF("storage backend %s is not supported", backend)
+}
+
+type noopRouter struct{}
+
+func (noopRouter) RegisterStandalone(gin.IRouter) {}
+
+// provideStorageService serves the objects of backends that have no server
+// of their own.
+func provideStorageService(s objectstorage.ObjectStorage) pgin.StandaloneRouter {
+	if r, ok := s.(pgin.StandaloneRouter); ok {
+		return r
+	}
+	return noopRouter{}
+}
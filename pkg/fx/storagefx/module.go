@@ -2,4 +2,10 @@ package storagefx
 
 import "go.uber.org/fx"
 
-var Module = fx.Provide(provideObjectStorage)
\ No newline at end of file
+var Module = fx.Provide(
+	provideObjectStorage,
+	fx.Annotated{
+		Name:   "StorageService",
+		Target: provideStorageService,
+	},
+)
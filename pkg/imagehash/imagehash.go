@@ -0,0 +1,91 @@
+// Package imagehash computes the hashes used to find duplicate images.
+package imagehash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"math/bits"
+	"sort"
+
+	"github.com/nfnt/resize"
+)
+
+// MaxDistance is the largest Hamming distance between two perceptual hashes.
+const MaxDistance = 64
+
+// Content returns the hex encoded SHA-256 of b, which is the same for
+// images with the same bytes.
+func Content(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// DHash returns the difference hash of img. The image is shrunk to 9x8 gray
+// pixels and each bit tells whether a pixel is brighter than the one on its
+// right, so resized or recompressed copies of an image get close hashes.
+func DHash(img image.Image) uint64 {
+	small := resize.Resize(9, 8, img, resize.Bilinear)
+	b := small.Bounds()
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := color.GrayModel.Convert(small.At(b.Min.X+x, b.Min.Y+y)).(color.Gray)
+			right := color.GrayModel.Convert(small.At(b.Min.X+x+1, b.Min.Y+y)).(color.Gray)
+			hash <<= 1
+			if left.Y > right.Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance is the number of bits that differ between two perceptual hashes.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Cluster groups the indexes of hashes that are within threshold of each
+// other, directly or through other hashes of the group. Hashes with no
+// neighbour are left out. Each group is sorted, and the groups are sorted
+// by their first index.
+func Cluster(hashes []uint64, threshold int) [][]int {
+	parent := make([]int, len(hashes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range hashes {
+		for j := i + 1; j < len(hashes); j++ {
+			if Distance(hashes[i], hashes[j]) > threshold {
+				continue
+			}
+			if a, b := find(i), find(j); a != b {
+				parent[b] = a
+			}
+		}
+	}
+	var groups = make(map[int][]int)
+	for i := range hashes {
+		root := find(i)
+		groups[root] = append(groups[root], i)
+	}
+	var clusters = make([][]int, 0)
+	for _, g := range groups {
+		if len(g) > 1 {
+			clusters = append(clusters, g)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i][0] < clusters[j][0]
+	})
+	return clusters
+}
@@ -0,0 +1,42 @@
+package imagehash
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/nfnt/resize"
+	"github.com/stretchr/testify/assert"
+)
+
+func gradient(width, height int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(math.Abs(math.Sin(float64(x)/40+float64(y)/90)) * 255)})
+		}
+	}
+	return img
+}
+
+func TestDHash(t *testing.T) {
+	img := gradient(300, 200)
+	assert.NotZero(t, DHash(img))
+	smaller := resize.Resize(150, 100, img, resize.Lanczos3)
+	assert.LessOrEqual(t, Distance(DHash(img), DHash(smaller)), 4, "resized copies are near duplicates")
+
+	flat := image.NewGray(image.Rect(0, 0, 300, 200))
+	assert.Equal(t, uint64(0), DHash(flat))
+}
+
+func TestCluster(t *testing.T) {
+	hashes := []uint64{0x0, 0xff00, 0x1, 0xff01, 0xffffffff, 0x3}
+	assert.Equal(t, [][]int{{0, 2, 5}, {1, 3}}, Cluster(hashes, 1))
+	assert.Empty(t, Cluster(hashes[:2], 1))
+}
+
+func TestContent(t *testing.T) {
+	assert.Equal(t, Content([]byte("a")), Content([]byte("a")))
+	assert.NotEqual(t, Content([]byte("a")), Content([]byte("b")))
+}
@@ -0,0 +1,109 @@
+package labelformat
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+// csvLabelSeparator joins the labels of an image within a CSV cell.
+const csvLabelSeparator = ";"
+
+type classificationFile struct {
+	Dataset    string                `json:"dataset"`
+	Categories []string              `json:"categories"`
+	Images     []classificationImage `json:"images"`
+}
+
+type classificationImage struct {
+	ID       uint64   `json:"id"`
+	FileName string   `json:"file_name"`
+	URL      string   `json:"url"`
+	Labels   []string `json:"labels"`
+}
+
+// writeCSV writes labels.csv with a row per image holding the names of its
+// labels, joined with csvLabelSeparator.
+func writeCSV(z *zip.Writer, d Dataset) error {
+	f, err := createFile(z, "labels.csv")
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"image_id", "file_name", "url", "labels"}); err != nil {
+		return errors.ExportCannotWrite.Wrap(err, "cannot write csv header")
+	}
+	names := categoryNames(d)
+	for _, img := range d.Images {
+		row := []string{
+			strconv.FormatUint(img.ID, 10),
+			fileName(img),
+			img.URL,
+			strings.Join(labelNames(img, names), csvLabelSeparator),
+		}
+		if err := w.Write(row); err != nil {
+			return errors.ExportCannotWrite.WrapF(err, "cannot write csv row of image %d", img.ID)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return errors.ExportCannotWrite.Wrap(err, "cannot write csv")
+	}
+	return nil
+}
+
+// writeJSON writes labels.json with the categories of the dataset and the
+// names of the labels of every image.
+func writeJSON(z *zip.Writer, d Dataset) error {
+	names := categoryNames(d)
+	file := classificationFile{
+		Dataset:    d.Name,
+		Categories: make([]string, len(d.Categories)),
+		Images:     make([]classificationImage, len(d.Images)),
+	}
+	for i, c := range d.Categories {
+		file.Categories[i] = c.Name
+	}
+	for i, img := range d.Images {
+		file.Images[i] = classificationImage{
+			ID:       img.ID,
+			FileName: fileName(img),
+			URL:      img.URL,
+			Labels:   labelNames(img, names),
+		}
+	}
+	w, err := createFile(z, "labels.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(&file); err != nil {
+		return errors.ExportCannotWrite.Wrap(err, "cannot write labels json")
+	}
+	return nil
+}
+
+func categoryNames(d Dataset) map[uint64]string {
+	var names = make(map[uint64]string, len(d.Categories))
+	for _, c := range d.Categories {
+		names[c.ID] = c.Name
+	}
+	return names
+}
+
+// labelNames returns the names of the labels of img, leaving out those that
+// are not categories of the dataset.
+func labelNames(img Image, names map[uint64]string) []string {
+	var result = make([]string, 0, len(img.Labels))
+	for _, id := range img.Labels {
+		if name, ok := names[id]; ok {
+			result = append(result, name)
+		}
+	}
+	return result
+}
@@ -0,0 +1,221 @@
+package labelformat
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+type cocoFile struct {
+	Info        cocoInfo         `json:"info"`
+	Images      []cocoImage      `json:"images"`
+	Categories  []cocoCategory   `json:"categories"`
+	Annotations []cocoAnnotation `json:"annotations"`
+}
+
+type cocoInfo struct {
+	Description string `json:"description"`
+}
+
+type cocoImage struct {
+	ID       uint64 `json:"id"`
+	FileName string `json:"file_name"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	URL      string `json:"coco_url"`
+}
+
+type cocoCategory struct {
+	ID            uint64 `json:"id"`
+	Name          string `json:"name"`
+	Supercategory string `json:"supercategory"`
+}
+
+type cocoAnnotation struct {
+	ID           int         `json:"id"`
+	ImageID      uint64      `json:"image_id"`
+	CategoryID   uint64      `json:"category_id"`
+	BBox         [4]float64  `json:"bbox"`
+	Area         float64     `json:"area"`
+	Segmentation [][]float64 `json:"segmentation"`
+	Keypoints    []float64   `json:"keypoints,omitempty"`
+	NumKeypoints int         `json:"num_keypoints,omitempty"`
+	IsCrowd      int         `json:"iscrowd"`
+}
+
+// writeCOCO writes annotations/instances.json. Polygons get a segmentation,
+// points are written as single keypoints and every shape gets its bounding
+// box.
+func writeCOCO(z *zip.Writer, d Dataset) error {
+	var tools = make(map[uint64]string, len(d.Categories))
+	file := cocoFile{
+		Info:        cocoInfo{Description: d.Name},
+		Images:      make([]cocoImage, 0, len(d.Images)),
+		Categories:  make([]cocoCategory, 0, len(d.Categories)),
+		Annotations: make([]cocoAnnotation, 0),
+	}
+	for _, c := range d.Categories {
+		tools[c.ID] = c.Tool
+		file.Categories = append(file.Categories, cocoCategory{
+			ID:            c.ID,
+			Name:          c.Name,
+			Supercategory: c.Tool,
+		})
+	}
+	for _, img := range d.Images {
+		file.Images = append(file.Images, cocoImage{
+			ID:       img.ID,
+			FileName: fileName(img),
+			Width:    img.Width,
+			Height:   img.Height,
+			URL:      img.URL,
+		})
+		for _, s := range img.Shapes {
+			b := s.Bounds()
+			a := cocoAnnotation{
+				ID:           len(file.Annotations) + 1,
+				ImageID:      img.ID,
+				CategoryID:   s.CategoryID,
+				BBox:         [4]float64{b.XMin, b.YMin, b.Width(), b.Height()},
+				Area:         b.Width() * b.Height(),
+				Segmentation: make([][]float64, 0),
+			}
+			switch tools[s.CategoryID] {
+			case ToolPolygon:
+				a.Segmentation = append(a.Segmentation, flatten(s.Points))
+				a.Area = polygonArea(s.Points)
+			case ToolPoint:
+				for _, p := range s.Points {
+					a.Keypoints = append(a.Keypoints, p.X, p.Y, 2)
+				}
+				a.NumKeypoints = len(s.Points)
+			}
+			file.Annotations = append(file.Annotations, a)
+		}
+	}
+	w, err := createFile(z, "annotations/instances.json")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(&file); err != nil {
+		return errors.ExportCannotWrite.Wrap(err, "cannot write coco annotations")
+	}
+	return nil
+}
+
+func flatten(points []Point) []float64 {
+	var coords = make([]float64, 0, 2*len(points))
+	for _, p := range points {
+		coords = append(coords, p.X, p.Y)
+	}
+	return coords
+}
+
+// polygonArea computes the area of a simple polygon with the shoelace
+// formula.
+func polygonArea(points []Point) float64 {
+	var sum float64
+	for i := range points {
+		j := (i + 1) % len(points)
+		sum += points[i].X*points[j].Y - points[j].X*points[i].Y
+	}
+	return math.Abs(sum) / 2
+}
+
+// cocoInput is an annotation as read. The segmentation is kept raw since it
+// may also be an RLE mask, which is not supported.
+type cocoInput struct {
+	Images      []cocoImage    `json:"images"`
+	Categories  []cocoCategory `json:"categories"`
+	Annotations []struct {
+		ImageID      uint64          `json:"image_id"`
+		CategoryID   uint64          `json:"category_id"`
+		BBox         []float64       `json:"bbox"`
+		Segmentation json.RawMessage `json:"segmentation"`
+	} `json:"annotations"`
+}
+
+// readCOCO reads every JSON file of the archive as COCO annotations, so the
+// splits of a dataset, such as instances_train.json and instances_val.json,
+// are merged. Categories are matched by name across the files. Polygon
+// segmentations make their category a polygon one, other annotations are
+// read from their bounding box.
+func readCOCO(a archive) (Dataset, []Problem, error) {
+	files := a.withExt(".json")
+	if len(files) == 0 {
+		return Dataset{}, nil, errors.ImportAnnotationsNotFound.NewWithMessage("no coco json file in archive")
+	}
+	b := newBuilder(a)
+	for _, f := range files {
+		content, err := readFile(f)
+		if err != nil {
+			b.skip(f.Name, "cannot read file")
+			continue
+		}
+		var in cocoInput
+		if err := json.Unmarshal(content, &in); err != nil {
+			b.skip(f.Name, "not a coco annotation file")
+			continue
+		}
+		var categories = make(map[uint64]int, len(in.Categories))
+		for _, c := range in.Categories {
+			categories[c.ID] = b.category(c.Name)
+		}
+		var images = make(map[uint64]int, len(in.Images))
+		for _, img := range in.Images {
+			zf, ok := a.find(img.FileName)
+			if !ok {
+				b.skip(img.FileName, "image not found in archive")
+				continue
+			}
+			i := b.images[zf.Name]
+			images[img.ID] = i
+			b.d.Images[i].Width = img.Width
+			b.d.Images[i].Height = img.Height
+		}
+		for n, ann := range in.Annotations {
+			i, ok := images[ann.ImageID]
+			if !ok {
+				continue
+			}
+			c, ok := categories[ann.CategoryID]
+			if !ok {
+				b.skip(f.Name, fmt.Sprintf("annotation %d has unknown category %d", n, ann.CategoryID))
+				continue
+			}
+			var polygons [][]float64
+			if len(ann.Segmentation) != 0 && json.Unmarshal(ann.Segmentation, &polygons) != nil {
+				b.skip(f.Name, fmt.Sprintf("annotation %d has an rle segmentation, its bbox is used", n))
+			}
+			var drawn bool
+			for _, coords := range polygons {
+				if len(coords) < 6 || len(coords)%2 != 0 {
+					continue
+				}
+				points := make([]Point, len(coords)/2)
+				for k := range points {
+					points[k] = Point{X: coords[2*k], Y: coords[2*k+1]}
+				}
+				b.addPolygon(i, c, points)
+				drawn = true
+			}
+			if drawn {
+				continue
+			}
+			if len(ann.BBox) != 4 || ann.BBox[2] <= 0 || ann.BBox[3] <= 0 {
+				b.skip(f.Name, fmt.Sprintf("annotation %d has no shape", n))
+				continue
+			}
+			b.addBox(i, c, Box{
+				XMin: ann.BBox[0],
+				YMin: ann.BBox[1],
+				XMax: ann.BBox[0] + ann.BBox[2],
+				YMax: ann.BBox[1] + ann.BBox[3],
+			})
+		}
+	}
+	return b.build()
+}
@@ -0,0 +1,183 @@
+// Package labelformat writes annotated datasets in the usual exchange formats
+// of labeling tools: COCO, Pascal VOC and YOLO, along with CSV and JSON for
+// classified images. Every format is written as a zip archive.
+package labelformat
+
+import (
+	"archive/zip"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+type Format string
+
+const (
+	COCO Format = "coco"
+	VOC  Format = "voc"
+	YOLO Format = "yolo"
+	CSV  Format = "csv"
+	JSON Format = "json"
+)
+
+// Tool names as stored by the tool package.
+const (
+	ToolRectangle = "RECTANGLE"
+	ToolPoint     = "POINT"
+	ToolPolyline  = "POLYLINE"
+	ToolPolygon   = "POLYGON"
+)
+
+func (f Format) Valid() bool {
+	switch f {
+	case COCO, VOC, YOLO, CSV, JSON:
+		return true
+	}
+	return false
+}
+
+// Readable tells whether datasets can be read back from format f.
+func (f Format) Readable() bool {
+	switch f {
+	case COCO, VOC, YOLO:
+		return true
+	}
+	return false
+}
+
+type Dataset struct {
+	Name       string
+	Categories []Category
+	Images     []Image
+}
+
+// Category is a label of the project, Tool tells how its shapes are drawn.
+type Category struct {
+	ID   uint64
+	Name string
+	Tool string
+}
+
+// Image is an image of the dataset with its shapes. Width and Height are in
+// pixels, as are the points of the shapes. Labels are the categories of a
+// classified image.
+type Image struct {
+	ID       uint64
+	FileName string
+	URL      string
+	Width    int
+	Height   int
+	Shapes   []Shape
+	Labels   []uint64
+}
+
+type Shape struct {
+	CategoryID uint64
+	Points     []Point
+}
+
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Box is the bounding box of a shape.
+type Box struct {
+	XMin float64
+	YMin float64
+	XMax float64
+	YMax float64
+}
+
+func (b Box) Width() float64 {
+	return b.XMax - b.XMin
+}
+
+func (b Box) Height() float64 {
+	return b.YMax - b.YMin
+}
+
+// Bounds returns the bounding box of the points of s.
+func (s Shape) Bounds() Box {
+	if len(s.Points) == 0 {
+		return Box{}
+	}
+	b := Box{
+		XMin: math.Inf(1),
+		YMin: math.Inf(1),
+		XMax: math.Inf(-1),
+		YMax: math.Inf(-1),
+	}
+	for _, p := range s.Points {
+		b.XMin = math.Min(b.XMin, p.X)
+		b.YMin = math.Min(b.YMin, p.Y)
+		b.XMax = math.Max(b.XMax, p.X)
+		b.YMax = math.Max(b.YMax, p.Y)
+	}
+	return b
+}
+
+// Write writes d to w as a zip archive in format f.
+func Write(w io.Writer, f Format, d Dataset) error {
+	var write func(z *zip.Writer, d Dataset) error
+	switch f {
+	case COCO:
+		write = writeCOCO
+	case VOC:
+		write = writeVOC
+	case YOLO:
+		write = writeYOLO
+	case CSV:
+		write = writeCSV
+	case JSON:
+		write = writeJSON
+	default:
+		return errors.ExportFormatNotSupported.NewWithMessageF("format %s is not supported", f)
+	}
+	z := zip.NewWriter(w)
+	if err := write(z, d); err != nil {
+		return err
+	}
+	if err := z.Close(); err != nil {
+		return errors.ExportCannotWrite.Wrap(err, "cannot close archive")
+	}
+	return nil
+}
+
+func createFile(z *zip.Writer, name string) (io.Writer, error) {
+	w, err := z.Create(name)
+	if err != nil {
+		return nil, errors.ExportCannotWrite.WrapF(err, "cannot create %s in archive", name)
+	}
+	return w, nil
+}
+
+// baseName is the file name of an image without its extension, made unique
+// with the image ID since titles may repeat within a dataset.
+func baseName(img Image) string {
+	name := strings.TrimSuffix(path.Base(img.FileName), path.Ext(img.FileName))
+	if name == "" || name == "." || name == "/" {
+		name = "image"
+	}
+	return strconv.FormatUint(img.ID, 10) + "_" + name
+}
+
+func fileName(img Image) string {
+	ext := path.Ext(img.FileName)
+	if ext == "" {
+		ext = path.Ext(img.URL)
+	}
+	return baseName(img) + ext
+}
+
+func categoryIndex(d Dataset) map[uint64]int {
+	var index = make(map[uint64]int, len(d.Categories))
+	for i, c := range d.Categories {
+		index[c.ID] = i
+	}
+	return index
+}
@@ -0,0 +1,210 @@
+package labelformat
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+// Problem is an entry of an archive that was skipped while reading it.
+type Problem struct {
+	File   string
+	Reason string
+}
+
+// Read reads a zip archive of images annotated in format f. The FileName of
+// every image is its path within the archive, every image of the archive is
+// part of the result, annotated or not, and the IDs of the categories only
+// mean something within the result. Entries that cannot be read are reported
+// as problems rather than failing the whole archive.
+func Read(z *zip.Reader, f Format) (Dataset, []Problem, error) {
+	a := newArchive(z)
+	switch f {
+	case COCO:
+		return readCOCO(a)
+	case VOC:
+		return readVOC(a)
+	case YOLO:
+		return readYOLO(a)
+	}
+	return Dataset{}, nil, errors.ImportFormatNotSupported.NewWithMessageF("format %s is not supported", f)
+}
+
+var imageExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".bmp":  true,
+	".webp": true,
+}
+
+// IsImage reports whether name has the extension of an image format that
+// can be decoded.
+func IsImage(name string) bool {
+	return imageExts[strings.ToLower(path.Ext(name))]
+}
+
+type archive struct {
+	files  []*zip.File
+	byPath map[string]*zip.File
+	byBase map[string][]*zip.File
+}
+
+// newArchive indexes the regular files of z, leaving out directories and
+// the metadata that archivers add, such as __MACOSX and dot files.
+func newArchive(z *zip.Reader) archive {
+	a := archive{
+		byPath: make(map[string]*zip.File),
+		byBase: make(map[string][]*zip.File),
+	}
+	for _, f := range z.File {
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") || strings.HasPrefix(path.Base(f.Name), ".") {
+			continue
+		}
+		a.files = append(a.files, f)
+		a.byPath[f.Name] = f
+		base := path.Base(f.Name)
+		a.byBase[base] = append(a.byBase[base], f)
+	}
+	return a
+}
+
+// find looks name up by its path, then by its base name when only one file
+// of the archive has it.
+func (a archive) find(name string) (*zip.File, bool) {
+	name = strings.TrimPrefix(path.Clean(strings.ReplaceAll(name, "\\", "/")), "/")
+	if f, ok := a.byPath[name]; ok {
+		return f, true
+	}
+	if files := a.byBase[path.Base(name)]; len(files) == 1 {
+		return files[0], true
+	}
+	return nil, false
+}
+
+// withExt returns the files with one of the given extensions, sorted by
+// path.
+func (a archive) withExt(exts ...string) []*zip.File {
+	var files []*zip.File
+	for _, f := range a.files {
+		ext := strings.ToLower(path.Ext(f.Name))
+		for _, e := range exts {
+			if ext == e {
+				files = append(files, f)
+				break
+			}
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files
+}
+
+func (a archive) images() []*zip.File {
+	var files []*zip.File
+	for _, f := range a.files {
+		if IsImage(f.Name) {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files
+}
+
+func readFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// builder collects the images and categories of an archive being read.
+// Shapes drawn from boxes are kept apart until every category is known,
+// since a box of a category that also has polygons is written as one.
+type builder struct {
+	d          Dataset
+	images     map[string]int
+	categories map[string]int
+	boxes      map[int][]boxShape
+	problems   []Problem
+}
+
+type boxShape struct {
+	image int
+	box   Box
+}
+
+func newBuilder(a archive) *builder {
+	b := &builder{
+		images:     make(map[string]int),
+		categories: make(map[string]int),
+		boxes:      make(map[int][]boxShape),
+	}
+	for _, f := range a.images() {
+		b.images[f.Name] = len(b.d.Images)
+		b.d.Images = append(b.d.Images, Image{
+			ID:       uint64(len(b.d.Images) + 1),
+			FileName: f.Name,
+		})
+	}
+	return b
+}
+
+func (b *builder) skip(file, reason string) {
+	b.problems = append(b.problems, Problem{File: file, Reason: reason})
+}
+
+// category returns the index of the category called name, adding it as a
+// rectangle category when it is new.
+func (b *builder) category(name string) int {
+	if i, ok := b.categories[name]; ok {
+		return i
+	}
+	i := len(b.d.Categories)
+	b.categories[name] = i
+	b.d.Categories = append(b.d.Categories, Category{
+		ID:   uint64(i + 1),
+		Name: name,
+		Tool: ToolRectangle,
+	})
+	return i
+}
+
+func (b *builder) addBox(image, category int, box Box) {
+	b.boxes[category] = append(b.boxes[category], boxShape{image: image, box: box})
+}
+
+func (b *builder) addPolygon(image, category int, points []Point) {
+	b.d.Categories[category].Tool = ToolPolygon
+	b.d.Images[image].Shapes = append(b.d.Images[image].Shapes, Shape{
+		CategoryID: b.d.Categories[category].ID,
+		Points:     points,
+	})
+}
+
+func (b *builder) build() (Dataset, []Problem, error) {
+	for category, c := range b.d.Categories {
+		for _, s := range b.boxes[category] {
+			points := []Point{{X: s.box.XMin, Y: s.box.YMin}, {X: s.box.XMax, Y: s.box.YMax}}
+			if c.Tool == ToolPolygon {
+				points = []Point{
+					{X: s.box.XMin, Y: s.box.YMin},
+					{X: s.box.XMax, Y: s.box.YMin},
+					{X: s.box.XMax, Y: s.box.YMax},
+					{X: s.box.XMin, Y: s.box.YMax},
+				}
+			}
+			b.d.Images[s.image].Shapes = append(b.d.Images[s.image].Shapes, Shape{
+				CategoryID: c.ID,
+				Points:     points,
+			})
+		}
+	}
+	return b.d, b.problems, nil
+}
@@ -0,0 +1,117 @@
+package labelformat
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newZip(t *testing.T, files map[string][]byte) *zip.Reader {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := z.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, z.Close())
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	return r
+}
+
+func newPNG(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+func TestReadCOCO(t *testing.T) {
+	z := newZip(t, map[string][]byte{
+		"images/a.png": newPNG(t, 1, 1),
+		"images/b.png": newPNG(t, 1, 1),
+		"annotations/instances.json": []byte(`{
+			"images": [{"id": 4, "file_name": "a.png", "width": 200, "height": 100},
+				{"id": 5, "file_name": "missing.png"}],
+			"categories": [{"id": 1, "name": "car"}, {"id": 2, "name": "road"}],
+			"annotations": [
+				{"image_id": 4, "category_id": 1, "bbox": [10, 20, 40, 40]},
+				{"image_id": 4, "category_id": 2, "bbox": [0, 0, 10, 10], "segmentation": [[0, 0, 10, 0, 10, 10]]},
+				{"image_id": 4, "category_id": 2, "bbox": [5, 5, 10, 10], "segmentation": {"counts": [1], "size": [1, 1]}},
+				{"image_id": 4, "category_id": 9, "bbox": [0, 0, 1, 1]}
+			]}`),
+	})
+	d, problems, err := Read(z, COCO)
+	require.NoError(t, err)
+	require.Len(t, d.Categories, 2)
+	assert.Equal(t, ToolRectangle, d.Categories[0].Tool)
+	assert.Equal(t, ToolPolygon, d.Categories[1].Tool)
+	require.Len(t, d.Images, 2)
+	assert.Equal(t, "images/a.png", d.Images[0].FileName)
+	assert.Equal(t, 200, d.Images[0].Width)
+	assert.Empty(t, d.Images[1].Shapes, "images left out of the json are imported without shapes")
+	require.Len(t, d.Images[0].Shapes, 3)
+	assert.Equal(t, []Point{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}}, d.Images[0].Shapes[0].Points)
+	assert.Equal(t, []Point{{X: 10, Y: 20}, {X: 50, Y: 60}}, d.Images[0].Shapes[1].Points)
+	assert.Len(t, d.Images[0].Shapes[2].Points, 4, "boxes of polygon categories become polygons")
+	assert.Len(t, problems, 3)
+}
+
+func TestReadVOC(t *testing.T) {
+	z := newZip(t, map[string][]byte{
+		"JPEGImages/a.png": newPNG(t, 1, 1),
+		"Annotations/a.xml": []byte(`<annotation><filename>a.png</filename>
+			<size><width>200</width><height>100</height></size>
+			<object><name>car</name><bndbox><xmin>10.5</xmin><ymin>20</ymin><xmax>50</xmax><ymax>60</ymax></bndbox></object>
+			<object><name>car</name><bndbox><xmin>10</xmin><ymin>20</ymin><xmax>10</xmax><ymax>60</ymax></bndbox></object>
+			</annotation>`),
+		"Annotations/b.xml": []byte(`<annotation><filename>b.png</filename></annotation>`),
+	})
+	d, problems, err := Read(z, VOC)
+	require.NoError(t, err)
+	require.Len(t, d.Categories, 1)
+	require.Len(t, d.Images, 1)
+	assert.Equal(t, []Shape{{CategoryID: 1, Points: []Point{{X: 10.5, Y: 20}, {X: 50, Y: 60}}}}, d.Images[0].Shapes)
+	assert.Equal(t, []Problem{
+		{File: "Annotations/a.xml", Reason: "object 1 has no name or no area"},
+		{File: "Annotations/b.xml", Reason: "image b.png not found in archive"},
+	}, problems)
+}
+
+func TestReadYOLO(t *testing.T) {
+	z := newZip(t, map[string][]byte{
+		"classes.txt":     []byte("car\nroad\n"),
+		"images/a.png":    newPNG(t, 200, 100),
+		"labels/a.txt":    []byte("0 0.15 0.4 0.2 0.4\n1 0 0 0.5 0 0.5 0.5\n7 0.5 0.5 0.1 0.1\n"),
+		"labels/nope.txt": []byte("0 0.5 0.5 0.1 0.1\n"),
+	})
+	d, problems, err := Read(z, YOLO)
+	require.NoError(t, err)
+	require.Len(t, d.Categories, 2)
+	assert.Equal(t, ToolPolygon, d.Categories[1].Tool)
+	require.Len(t, d.Images, 1)
+	assert.Equal(t, 200, d.Images[0].Width)
+	require.Len(t, d.Images[0].Shapes, 2)
+	assert.Equal(t, []Point{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 50}}, d.Images[0].Shapes[0].Points)
+	b := d.Images[0].Shapes[1].Bounds()
+	assert.InDelta(t, 10, b.XMin, 1e-9)
+	assert.InDelta(t, 60, b.YMax, 1e-9)
+	assert.Equal(t, []Problem{
+		{File: "labels/a.txt", Reason: "line 3 has unknown class 7"},
+		{File: "labels/nope.txt", Reason: "no image with the same name in archive"},
+	}, problems)
+}
+
+func TestReadWithoutAnnotations(t *testing.T) {
+	z := newZip(t, map[string][]byte{"a.png": newPNG(t, 1, 1)})
+	for _, f := range []Format{COCO, VOC, YOLO} {
+		_, _, err := Read(z, f)
+		assert.Error(t, err, f)
+	}
+}
@@ -0,0 +1,147 @@
+package labelformat
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"path"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+type vocAnnotation struct {
+	XMLName   xml.Name    `xml:"annotation"`
+	Folder    string      `xml:"folder"`
+	FileName  string      `xml:"filename"`
+	Path      string      `xml:"path"`
+	Source    vocSource   `xml:"source"`
+	Size      vocSize     `xml:"size"`
+	Segmented int         `xml:"segmented"`
+	Objects   []vocObject `xml:"object"`
+}
+
+type vocSource struct {
+	Database string `xml:"database"`
+}
+
+type vocSize struct {
+	Width  int `xml:"width"`
+	Height int `xml:"height"`
+	Depth  int `xml:"depth"`
+}
+
+type vocObject struct {
+	Name      string `xml:"name"`
+	Pose      string `xml:"pose"`
+	Truncated int    `xml:"truncated"`
+	Difficult int    `xml:"difficult"`
+	BndBox    vocBox `xml:"bndbox"`
+}
+
+// vocBox is in float since some tools write fractional pixels, the boxes
+// written by pluto are rounded.
+type vocBox struct {
+	XMin float64 `xml:"xmin"`
+	YMin float64 `xml:"ymin"`
+	XMax float64 `xml:"xmax"`
+	YMax float64 `xml:"ymax"`
+}
+
+// writeVOC writes one Annotations/<image>.xml file per image along with the
+// ImageSets/Main/default.txt image list. VOC only knows boxes, so every shape
+// is written as its bounding box and shapes without an area are left out.
+func writeVOC(z *zip.Writer, d Dataset) error {
+	var names = make(map[uint64]string, len(d.Categories))
+	for _, c := range d.Categories {
+		names[c.ID] = c.Name
+	}
+	// a zip entry is closed once the next one is created, so the image list
+	// is written after the annotations.
+	var list bytes.Buffer
+	for _, img := range d.Images {
+		fmt.Fprintln(&list, baseName(img))
+		a := vocAnnotation{
+			Folder:   d.Name,
+			FileName: fileName(img),
+			Path:     img.URL,
+			Source:   vocSource{Database: "pluto"},
+			Size:     vocSize{Width: img.Width, Height: img.Height, Depth: 3},
+			Objects:  make([]vocObject, 0, len(img.Shapes)),
+		}
+		for _, s := range img.Shapes {
+			b := s.Bounds()
+			if b.Width() <= 0 || b.Height() <= 0 {
+				continue
+			}
+			a.Objects = append(a.Objects, vocObject{
+				Name: names[s.CategoryID],
+				Pose: "Unspecified",
+				BndBox: vocBox{
+					XMin: math.Round(b.XMin),
+					YMin: math.Round(b.YMin),
+					XMax: math.Round(b.XMax),
+					YMax: math.Round(b.YMax),
+				},
+			})
+		}
+		w, err := createFile(z, "Annotations/"+baseName(img)+".xml")
+		if err != nil {
+			return err
+		}
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		if err := enc.Encode(&a); err != nil {
+			return errors.ExportCannotWrite.WrapF(err, "cannot write voc annotation of image %d", img.ID)
+		}
+	}
+	w, err := createFile(z, "ImageSets/Main/default.txt")
+	if err != nil {
+		return err
+	}
+	if _, err := list.WriteTo(w); err != nil {
+		return errors.ExportCannotWrite.Wrap(err, "cannot write voc image list")
+	}
+	return nil
+}
+
+// readVOC reads every XML file of the archive as the VOC annotation of the
+// image its filename names. VOC only has boxes, so every category is a
+// rectangle one.
+func readVOC(a archive) (Dataset, []Problem, error) {
+	files := a.withExt(".xml")
+	if len(files) == 0 {
+		return Dataset{}, nil, errors.ImportAnnotationsNotFound.NewWithMessage("no voc xml file in archive")
+	}
+	b := newBuilder(a)
+	for _, f := range files {
+		content, err := readFile(f)
+		if err != nil {
+			b.skip(f.Name, "cannot read file")
+			continue
+		}
+		var ann vocAnnotation
+		if err := xml.Unmarshal(content, &ann); err != nil {
+			b.skip(f.Name, "not a voc annotation file")
+			continue
+		}
+		zf, ok := a.find(path.Join(path.Dir(f.Name), ann.FileName))
+		if !ok {
+			b.skip(f.Name, fmt.Sprintf("image %s not found in archive", ann.FileName))
+			continue
+		}
+		i := b.images[zf.Name]
+		b.d.Images[i].Width = ann.Size.Width
+		b.d.Images[i].Height = ann.Size.Height
+		for n, o := range ann.Objects {
+			box := Box{XMin: o.BndBox.XMin, YMin: o.BndBox.YMin, XMax: o.BndBox.XMax, YMax: o.BndBox.YMax}
+			if o.Name == "" || box.Width() <= 0 || box.Height() <= 0 {
+				b.skip(f.Name, fmt.Sprintf("object %d has no name or no area", n))
+				continue
+			}
+			b.addBox(i, b.category(o.Name), box)
+		}
+	}
+	return b.build()
+}
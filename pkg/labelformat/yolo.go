@@ -0,0 +1,213 @@
+package labelformat
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+// writeYOLO writes classes.txt with a category per line, a labels/<image>.txt
+// file per image and images.txt with the URL of each image. Every shape is
+// written as its bounding box normalized to the image size, so shapes without
+// an area and images of unknown size are left without boxes.
+func writeYOLO(z *zip.Writer, d Dataset) error {
+	index := categoryIndex(d)
+	classes, err := createFile(z, "classes.txt")
+	if err != nil {
+		return err
+	}
+	for _, c := range d.Categories {
+		if _, err := fmt.Fprintln(classes, c.Name); err != nil {
+			return errors.ExportCannotWrite.Wrap(err, "cannot write yolo classes")
+		}
+	}
+	// a zip entry is closed once the next one is created, so the image list
+	// is written after the labels.
+	var list bytes.Buffer
+	for _, img := range d.Images {
+		fmt.Fprintf(&list, "%s %s\n", fileName(img), img.URL)
+		w, err := createFile(z, "labels/"+baseName(img)+".txt")
+		if err != nil {
+			return err
+		}
+		if img.Width <= 0 || img.Height <= 0 {
+			continue
+		}
+		width, height := float64(img.Width), float64(img.Height)
+		for _, s := range img.Shapes {
+			class, ok := index[s.CategoryID]
+			b := s.Bounds()
+			if !ok || b.Width() <= 0 || b.Height() <= 0 {
+				continue
+			}
+			_, err := fmt.Fprintf(w, "%d %.6f %.6f %.6f %.6f\n", class,
+				(b.XMin+b.XMax)/2/width,
+				(b.YMin+b.YMax)/2/height,
+				b.Width()/width,
+				b.Height()/height)
+			if err != nil {
+				return errors.ExportCannotWrite.WrapF(err, "cannot write yolo labels of image %d", img.ID)
+			}
+		}
+	}
+	w, err := createFile(z, "images.txt")
+	if err != nil {
+		return err
+	}
+	if _, err := list.WriteTo(w); err != nil {
+		return errors.ExportCannotWrite.Wrap(err, "cannot write yolo image list")
+	}
+	return nil
+}
+
+// yoloClassFiles are the names under which the class list is looked for.
+var yoloClassFiles = []string{"classes.txt", "obj.names"}
+
+// readYOLO reads the class list of the archive and, for every image, the
+// text file of the same base name, preferably one under a labels directory.
+// Lines of five values are boxes, longer ones are polygons as written by
+// segmentation models. Coordinates are normalized, so the size of every
+// image is read from its header.
+func readYOLO(a archive) (Dataset, []Problem, error) {
+	classFile, ok := findYOLOClasses(a)
+	if !ok {
+		return Dataset{}, nil, errors.ImportAnnotationsNotFound.NewWithMessage("no yolo classes.txt or obj.names in archive")
+	}
+	content, err := readFile(classFile)
+	if err != nil {
+		return Dataset{}, nil, errors.ImportCannotRead.Wrap(err, "cannot read yolo classes")
+	}
+	b := newBuilder(a)
+	var classes []int
+	for _, line := range strings.Split(string(content), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			classes = append(classes, b.category(name))
+		}
+	}
+	var labels = make(map[string]*zip.File)
+	for _, f := range a.withExt(".txt") {
+		if f == classFile || path.Base(f.Name) == "images.txt" {
+			continue
+		}
+		key := strings.TrimSuffix(path.Base(f.Name), path.Ext(f.Name))
+		if prev, ok := labels[key]; ok && isYOLOLabelDir(prev.Name) {
+			continue
+		}
+		labels[key] = f
+	}
+	for i, img := range b.d.Images {
+		key := strings.TrimSuffix(path.Base(img.FileName), path.Ext(img.FileName))
+		f, ok := labels[key]
+		if !ok {
+			continue
+		}
+		delete(labels, key)
+		width, height, err := imageSize(a.byPath[img.FileName])
+		if err != nil {
+			b.skip(img.FileName, "cannot read image size, its labels are skipped")
+			continue
+		}
+		b.d.Images[i].Width = width
+		b.d.Images[i].Height = height
+		content, err := readFile(f)
+		if err != nil {
+			b.skip(f.Name, "cannot read file")
+			continue
+		}
+		for n, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			values, err := parseFloats(fields)
+			if err != nil || (len(values) != 5 && (len(values) < 7 || len(values)%2 == 0)) {
+				b.skip(f.Name, fmt.Sprintf("line %d is not a box or a polygon", n+1))
+				continue
+			}
+			class := int(values[0])
+			if float64(class) != values[0] || class < 0 || class >= len(classes) {
+				b.skip(f.Name, fmt.Sprintf("line %d has unknown class %s", n+1, fields[0]))
+				continue
+			}
+			w, h := float64(width), float64(height)
+			if len(values) == 5 {
+				cx, cy, bw, bh := values[1]*w, values[2]*h, values[3]*w, values[4]*h
+				if bw <= 0 || bh <= 0 {
+					b.skip(f.Name, fmt.Sprintf("line %d has no area", n+1))
+					continue
+				}
+				b.addBox(i, classes[class], Box{XMin: cx - bw/2, YMin: cy - bh/2, XMax: cx + bw/2, YMax: cy + bh/2})
+				continue
+			}
+			points := make([]Point, (len(values)-1)/2)
+			for k := range points {
+				points[k] = Point{X: values[1+2*k] * w, Y: values[2+2*k] * h}
+			}
+			b.addPolygon(i, classes[class], points)
+		}
+	}
+	var orphans []string
+	for _, f := range labels {
+		orphans = append(orphans, f.Name)
+	}
+	sort.Strings(orphans)
+	for _, name := range orphans {
+		b.skip(name, "no image with the same name in archive")
+	}
+	return b.build()
+}
+
+func findYOLOClasses(a archive) (*zip.File, bool) {
+	for _, name := range yoloClassFiles {
+		if files := a.byBase[name]; len(files) != 0 {
+			return files[0], true
+		}
+	}
+	if files := a.withExt(".names"); len(files) != 0 {
+		return files[0], true
+	}
+	return nil, false
+}
+
+func isYOLOLabelDir(name string) bool {
+	return path.Base(path.Dir(name)) == "labels"
+}
+
+func parseFloats(fields []string) ([]float64, error) {
+	var values = make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// imageSize decodes the header of an image to get its size.
+func imageSize(f *zip.File) (int, int, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rc.Close()
+	c, _, err := image.DecodeConfig(rc)
+	if err != nil {
+		return 0, 0, err
+	}
+	return c.Width, c.Height, nil
+}
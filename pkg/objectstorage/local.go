@@ -0,0 +1,286 @@
+package objectstorage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/nkhang/pluto/pkg/errors"
+	"github.com/nkhang/pluto/pkg/logger"
+)
+
+const (
+	fieldCollection = "collection"
+	fieldKey        = "key"
+	queryExpires    = "expires"
+	querySignature  = "signature"
+)
+
+// DefaultSecret is the placeholder secret of the sample config, which the
+// local backend refuses to sign with.
+const DefaultSecret = "change-me"
+
+// defaultMaxSize bounds presigned uploads when no limit is set.
+const defaultMaxSize = 100 << 20
+
+// local keeps objects as files under root, one directory per collection,
+// and serves them through RegisterStandalone at baseURL. Presigned PUT URLs
+// are signed with secret and take up to maxSize bytes.
+type local struct {
+	root    string
+	baseURL string
+	secret  []byte
+	maxSize int64
+}
+
+func NewLocal(root, baseURL, secret string, maxSize int64) (*local, error) {
+	if secret == "" || secret == DefaultSecret {
+		return nil, errors.StorageConfigInvalid.NewWithMessage("the local storage needs a secret to sign uploads, set storage.local.secret")
+	}
+	if maxSize <= 0 {
+		maxSize = defaultMaxSize
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &local{
+		root:    root,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  []byte(secret),
+		maxSize: maxSize,
+	}, nil
+}
+
+func (l *local) Put(collection, filename string, reader io.Reader, size int64, contentType string) (int64, error) {
+	p, err := l.filePath(collection, filename)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return 0, errors.StorageCannotPut.Wrap(err, "cannot create directory")
+	}
+	// The object is written aside and renamed, so that readers never see a
+	// partial file.
+	tmp, err := ioutil.TempFile(filepath.Dir(p), ".put-")
+	if err != nil {
+		return 0, errors.StorageCannotPut.Wrap(err, "cannot create file")
+	}
+	n, err := io.Copy(tmp, reader)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), p)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, errors.StorageCannotPut.Wrap(err, "cannot write file")
+	}
+	return n, nil
+}
+
+func (l *local) PutImage(collection, filename string, reader io.Reader, size int64) (int64, error) {
+	contentType, r, err := sniff(reader)
+	if err != nil {
+		return 0, err
+	}
+	return l.Put(collection, filename, r, size, contentType)
+}
+
+func (l *local) Get(collection, filename string) (io.ReadCloser, error) {
+	p, err := l.filePath(collection, filename)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, errors.StorageObjectNotFound.NewWithMessageF("object %s not found", filename)
+	}
+	if err != nil {
+		return nil, errors.StorageCannotGet.Wrap(err, "cannot open file")
+	}
+	return f, nil
+}
+
+func (l *local) Stat(collection, filename string) (ObjectInfo, error) {
+	p, err := l.filePath(collection, filename)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) || (err == nil && fi.IsDir()) {
+		return ObjectInfo{}, errors.StorageObjectNotFound.NewWithMessageF("object %s not found", filename)
+	}
+	if err != nil {
+		return ObjectInfo{}, errors.StorageCannotGet.Wrap(err, "cannot stat file")
+	}
+	return fileInfo(filename, fi), nil
+}
+
+func (l *local) Delete(collection, filename string) error {
+	p, err := l.filePath(collection, filename)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return errors.StorageCannotDelete.Wrap(err, "cannot delete file")
+	}
+	return nil
+}
+
+func (l *local) List(collection, prefix string) ([]ObjectInfo, error) {
+	dir, err := l.filePath(collection, "")
+	if err != nil {
+		return nil, err
+	}
+	var objects = make([]ObjectInfo, 0)
+	err = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".put-") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, fileInfo(key, fi))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.StorageCannotList.Wrap(err, "cannot list files")
+	}
+	return objects, nil
+}
+
+func (l *local) URL(collection, filename string) string {
+	return l.baseURL + "/" + collection + "/" + escapeKey(filename)
+}
+
+func (l *local) Locate(url string) (string, string, bool) {
+	return locate(l.baseURL, url)
+}
+
+func (l *local) PresignPut(collection, filename string, expiry time.Duration) (string, error) {
+	if _, err := l.filePath(collection, filename); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	return l.URL(collection, filename) + "?" + queryExpires + "=" + expires +
+		"&" + querySignature + "=" + l.sign(collection, filename, expires), nil
+}
+
+// RegisterStandalone serves the objects with GET and takes the uploads made
+// to presigned URLs with PUT.
+func (l *local) RegisterStandalone(router gin.IRouter) {
+	router.GET("/:"+fieldCollection+"/*"+fieldKey, l.serve)
+	router.PUT("/:"+fieldCollection+"/*"+fieldKey, l.upload)
+}
+
+func (l *local) serve(c *gin.Context) {
+	collection, key := c.Param(fieldCollection), strings.TrimPrefix(c.Param(fieldKey), "/")
+	p, err := l.filePath(collection, key)
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if fi, err := os.Stat(p); err != nil || fi.IsDir() {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	c.File(p)
+}
+
+func (l *local) upload(c *gin.Context) {
+	collection, key := c.Param(fieldCollection), strings.TrimPrefix(c.Param(fieldKey), "/")
+	expires := c.Query(queryExpires)
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix ||
+		!hmac.Equal([]byte(c.Query(querySignature)), []byte(l.sign(collection, key, expires))) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	if c.Request.ContentLength > l.maxSize {
+		c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+		return
+	}
+	body := &cappedReader{reader: http.MaxBytesReader(c.Writer, c.Request.Body, l.maxSize), max: l.maxSize}
+	if _, err := l.Put(collection, key, body, c.Request.ContentLength, c.ContentType()); err != nil {
+		if body.exceeded() {
+			c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+			return
+		}
+		logger.Errorf("[STORAGE] - cannot put presigned object %s/%s. err %v", collection, key, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// cappedReader counts what is read from a body limited to max bytes, to
+// tell an oversized body from other read failures.
+type cappedReader struct {
+	reader io.Reader
+	max    int64
+	read   int64
+}
+
+func (r *cappedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	if err != nil && err != io.EOF && r.read >= r.max {
+		r.read = r.max + 1
+	}
+	return n, err
+}
+
+func (r *cappedReader) exceeded() bool {
+	return r.read > r.max
+}
+
+func (l *local) sign(collection, filename, expires string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(collection + "/" + filename + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// filePath maps an object to its file, refusing keys that would leave the
+// directory of the collection.
+func (l *local) filePath(collection, filename string) (string, error) {
+	if collection == "" || strings.ContainsAny(collection, `/\`) || collection == "." || collection == ".." {
+		return "", errors.StorageInvalidKey.NewWithMessageF("invalid collection %q", collection)
+	}
+	cleaned := path.Clean("/" + filename)
+	if filename != "" && strings.TrimPrefix(cleaned, "/") != strings.TrimPrefix(filename, "/") {
+		return "", errors.StorageInvalidKey.NewWithMessageF("invalid key %q", filename)
+	}
+	return filepath.Join(l.root, collection, filepath.FromSlash(cleaned)), nil
+}
+
+func fileInfo(key string, fi os.FileInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:          key,
+		Size:         fi.Size(),
+		ContentType:  mime.TypeByExtension(path.Ext(key)),
+		LastModified: fi.ModTime(),
+	}
+}
@@ -0,0 +1,119 @@
+package objectstorage
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+func newTestLocal(t *testing.T, baseURL string) *local {
+	root, err := ioutil.TempDir("", "storage")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(root) })
+	l, err := NewLocal(root, baseURL, "secret", 16)
+	require.NoError(t, err)
+	return l
+}
+
+func TestLocal(t *testing.T) {
+	l := newTestLocal(t, "http://pluto/storage")
+	_, err := l.Put("images", "prj/1/a b.jpg", strings.NewReader("a"), 1, "")
+	require.NoError(t, err)
+	_, err = l.Put("images", "prj/2/c.jpg", strings.NewReader("cc"), 2, "")
+	require.NoError(t, err)
+
+	info, err := l.Stat("images", "prj/2/c.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), info.Size)
+	assert.Equal(t, "image/jpeg", info.ContentType)
+
+	objects, err := l.List("images", "prj/1/")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, "prj/1/a b.jpg", objects[0].Key)
+	assert.Equal(t, "http://pluto/storage/images/prj%2F1%2Fa%20b.jpg", l.URL("images", objects[0].Key))
+
+	require.NoError(t, l.Delete("images", "prj/1/a b.jpg"))
+	require.NoError(t, l.Delete("images", "prj/1/a b.jpg"), "deleting a missing object")
+	_, err = l.Stat("images", "prj/1/a b.jpg")
+	assert.Equal(t, errors.StorageObjectNotFound, errors.Type(err))
+
+	_, err = l.Put("images", "../escape.jpg", strings.NewReader("x"), 1, "")
+	assert.Equal(t, errors.StorageInvalidKey, errors.Type(err))
+	_, err = l.Get("..", "escape.jpg")
+	assert.Equal(t, errors.StorageInvalidKey, errors.Type(err))
+}
+
+func TestLocalServesPresignedUploads(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+	l := newTestLocal(t, srv.URL+"/storage")
+	l.RegisterStandalone(engine.Group("/storage"))
+
+	putBody := func(url, body string) int {
+		req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	put := func(url string) int {
+		return putBody(url, "image")
+	}
+	u, err := l.PresignPut("images", "prj/a.jpg", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, put(strings.Replace(u, "a.jpg", "b.jpg", 1)), "signature is for another key")
+	expired, err := l.PresignPut("images", "prj/a.jpg", -time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, put(expired))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, putBody(u, strings.Repeat("x", 17)), "uploads are capped")
+	assert.Equal(t, http.StatusOK, put(u))
+
+	resp, err := http.Get(l.URL("images", "prj/a.jpg"))
+	require.NoError(t, err)
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "image", string(b))
+
+	resp, err = http.Get(srv.URL + "/storage/images/prj")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "directories are not listed")
+}
+
+func TestLocalNeedsSecret(t *testing.T) {
+	for _, secret := range []string{"", DefaultSecret} {
+		_, err := NewLocal(t.TempDir(), "http://pluto/storage", secret, 0)
+		assert.Equal(t, errors.StorageConfigInvalid, errors.Type(err), "secret %q", secret)
+	}
+}
+
+func TestLocate(t *testing.T) {
+	l := newTestLocal(t, "http://pluto/storage")
+	collection, key, ok := l.Locate(l.URL("images", "prj/1/a b.jpg"))
+	require.True(t, ok)
+	assert.Equal(t, "images", collection)
+	assert.Equal(t, "prj/1/a b.jpg", key)
+
+	collection, key, ok = l.Locate("http://pluto/storage/images/prj%2F1%2Fa.jpg")
+	require.True(t, ok, "keys escaped as a whole")
+	assert.Equal(t, "prj/1/a.jpg", key)
+
+	_, _, ok = l.Locate("http://elsewhere/images/a.jpg")
+	assert.False(t, ok)
+	_, _, ok = NewMemory().Locate(l.URL("images", "a.jpg"))
+	assert.False(t, ok)
+}
@@ -0,0 +1,117 @@
+package objectstorage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nkhang/pluto/pkg/errors"
+)
+
+type memoryObject struct {
+	data         []byte
+	contentType  string
+	lastModified time.Time
+}
+
+// memory keeps objects in memory, for tests and local runs that need no
+// server. Its URLs use the memory scheme and cannot be fetched.
+type memory struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+func NewMemory() *memory {
+	return &memory{objects: make(map[string]memoryObject)}
+}
+
+func (m *memory) Put(collection, filename string, reader io.Reader, size int64, contentType string) (int64, error) {
+	b, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return 0, errors.StorageCannotPut.Wrap(err, "cannot read object")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[collection+"/"+filename] = memoryObject{
+		data:         b,
+		contentType:  contentType,
+		lastModified: time.Now(),
+	}
+	return int64(len(b)), nil
+}
+
+func (m *memory) PutImage(collection, filename string, reader io.Reader, size int64) (int64, error) {
+	contentType, r, err := sniff(reader)
+	if err != nil {
+		return 0, err
+	}
+	return m.Put(collection, filename, r, size, contentType)
+}
+
+func (m *memory) Get(collection, filename string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	o, ok := m.objects[collection+"/"+filename]
+	if !ok {
+		return nil, errors.StorageObjectNotFound.NewWithMessageF("object %s not found", filename)
+	}
+	return ioutil.NopCloser(bytes.NewReader(o.data)), nil
+}
+
+func (m *memory) Stat(collection, filename string) (ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	o, ok := m.objects[collection+"/"+filename]
+	if !ok {
+		return ObjectInfo{}, errors.StorageObjectNotFound.NewWithMessageF("object %s not found", filename)
+	}
+	return o.info(filename), nil
+}
+
+func (m *memory) Delete(collection, filename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, collection+"/"+filename)
+	return nil
+}
+
+func (m *memory) List(collection, prefix string) ([]ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var objects = make([]ObjectInfo, 0)
+	for k, o := range m.objects {
+		key := strings.TrimPrefix(k, collection+"/")
+		if key != k && strings.HasPrefix(key, prefix) {
+			objects = append(objects, o.info(key))
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
+
+func (m *memory) URL(collection, filename string) string {
+	return "memory://" + collection + "/" + escapeKey(filename)
+}
+
+func (m *memory) Locate(url string) (string, string, bool) {
+	return locate("memory:/", url)
+}
+
+func (m *memory) PresignPut(collection, filename string, expiry time.Duration) (string, error) {
+	return m.URL(collection, filename), nil
+}
+
+func (o memoryObject) info(key string) ObjectInfo {
+	return ObjectInfo{
+		Key:          key,
+		Size:         int64(len(o.data)),
+		ContentType:  o.contentType,
+		LastModified: o.lastModified,
+	}
+}
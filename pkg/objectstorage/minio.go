@@ -1,25 +1,31 @@
 package objectstorage
 
 import (
-	"bytes"
 	"io"
-	"net/http"
+	"strings"
+	"time"
 
 	"github.com/minio/minio-go"
+
+	"github.com/nkhang/pluto/pkg/errors"
 	"github.com/nkhang/pluto/pkg/logger"
 )
 
 type minioClient struct {
-	client *minio.Client
+	client  *minio.Client
+	baseURL string
 }
 
-func NewMinioClient(endpoint, accessKey, secretKey string, ssl bool) (*minioClient, error) {
+// NewMinioClient connects to MinIO at endpoint. baseURL is where clients
+// reach the buckets, which may differ from endpoint behind a proxy.
+func NewMinioClient(endpoint, accessKey, secretKey string, ssl bool, baseURL string) (*minioClient, error) {
 	client, err := minio.New(endpoint, accessKey, secretKey, ssl)
 	if err != nil {
 		return nil, err
 	}
 	return &minioClient{
-		client: client,
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
 	}, nil
 }
 
@@ -29,19 +35,95 @@ func (c *minioClient) Put(collection, filename string, reader io.Reader, size in
 	})
 }
 
+// PutImage streams an image to collection, its content type is detected
+// from the first bytes.
 func (c *minioClient) PutImage(collection, filename string, reader io.Reader, size int64) (int64, error) {
-	if ok, _ := c.client.BucketExists(collection); !ok {
-		logger.Infof("making bucket %s", collection)
-		err := c.client.MakeBucket(collection, "ap-southeast-1")
-		if err != nil {
-			panic(err)
+	if err := c.ensureBucket(collection); err != nil {
+		return 0, err
+	}
+	contentType, r, err := sniff(reader)
+	if err != nil {
+		return 0, err
+	}
+	return c.Put(collection, filename, r, size, contentType)
+}
+
+func (c *minioClient) Get(collection, filename string) (io.ReadCloser, error) {
+	o, err := c.client.GetObject(collection, filename, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, errors.StorageCannotGet.Wrap(err, "cannot get object")
+	}
+	return o, nil
+}
+
+func (c *minioClient) Stat(collection, filename string) (ObjectInfo, error) {
+	info, err := c.client.StatObject(collection, filename, minio.StatObjectOptions{})
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ObjectInfo{}, errors.StorageObjectNotFound.NewWithMessageF("object %s not found", filename)
+	}
+	if err != nil {
+		return ObjectInfo{}, errors.StorageCannotGet.Wrap(err, "cannot stat object")
+	}
+	return ObjectInfo{
+		Key:          info.Key,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+func (c *minioClient) Delete(collection, filename string) error {
+	err := c.client.RemoveObject(collection, filename)
+	if err != nil && minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return errors.StorageCannotDelete.Wrap(err, "cannot delete object")
+	}
+	return nil
+}
+
+func (c *minioClient) List(collection, prefix string) ([]ObjectInfo, error) {
+	done := make(chan struct{})
+	defer close(done)
+	var objects = make([]ObjectInfo, 0)
+	for o := range c.client.ListObjectsV2(collection, prefix, true, done) {
+		if o.Err != nil {
+			if minio.ToErrorResponse(o.Err).Code == "NoSuchBucket" {
+				return objects, nil
+			}
+			return nil, errors.StorageCannotList.Wrap(o.Err, "cannot list objects")
 		}
+		objects = append(objects, ObjectInfo{
+			Key:          o.Key,
+			Size:         o.Size,
+			ContentType:  o.ContentType,
+			LastModified: o.LastModified,
+		})
+	}
+	return objects, nil
+}
+
+func (c *minioClient) URL(collection, filename string) string {
+	return c.baseURL + "/" + collection + "/" + escapeKey(filename)
+}
+
+func (c *minioClient) Locate(url string) (string, string, bool) {
+	return locate(c.baseURL, url)
+}
+
+func (c *minioClient) PresignPut(collection, filename string, expiry time.Duration) (string, error) {
+	if err := c.ensureBucket(collection); err != nil {
+		return "", errors.StorageCannotPresign.Wrap(err, "cannot create bucket")
+	}
+	u, err := c.client.PresignedPutObject(collection, filename, expiry)
+	if err != nil {
+		return "", errors.StorageCannotPresign.Wrap(err, "cannot presign object")
+	}
+	return u.String(), nil
+}
+
+func (c *minioClient) ensureBucket(collection string) error {
+	if ok, _ := c.client.BucketExists(collection); ok {
+		return nil
 	}
-	b := make([]byte, size)
-	n, err := reader.Read(b)
-	if err != nil || n == 0 {
-		return int64(n), err
-	} 
-	contentType := http.DetectContentType(b)
-	return c.Put(collection, filename, bytes.NewReader(b), size, contentType)
+	logger.Infof("making bucket %s", collection)
+	return c.client.MakeBucket(collection, "ap-southeast-1")
 }
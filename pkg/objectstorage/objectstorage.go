@@ -1,8 +1,102 @@
 package objectstorage
 
-import "io"
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
 
 type ObjectStorage interface {
 	Put(collection, filename string, reader io.Reader, size int64, contentType string) (int64, error)
 	PutImage(collection, filename string, reader io.Reader, size int64) (int64, error)
+	// Get opens an object, the caller closes it.
+	Get(collection, filename string) (io.ReadCloser, error)
+	// Stat fails with errors.StorageObjectNotFound when there is no object.
+	Stat(collection, filename string) (ObjectInfo, error)
+	// Delete removes an object, deleting a missing object is not an error.
+	Delete(collection, filename string) error
+	// List returns the objects of collection whose key starts with prefix.
+	List(collection, prefix string) ([]ObjectInfo, error)
+	// URL is where clients get an object from.
+	URL(collection, filename string) string
+	// Locate is the reverse of URL, it fails for URLs of other storages.
+	Locate(url string) (collection, filename string, ok bool)
+	// PresignPut returns a URL that anyone can PUT the object to until
+	// expiry has passed.
+	PresignPut(collection, filename string, expiry time.Duration) (string, error)
+}
+
+// sniffLen is the number of bytes http.DetectContentType looks at.
+const sniffLen = 512
+
+// sniff detects the content type of reader from its first bytes and returns
+// a reader of the whole content.
+func sniff(reader io.Reader) (string, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	if n == 0 {
+		return "", nil, io.ErrUnexpectedEOF
+	}
+	return http.DetectContentType(head[:n]), io.MultiReader(bytes.NewReader(head[:n]), reader), nil
+}
+
+// escapeKey escapes a key as a whole, slashes included, which is how image
+// URLs have always been stored.
+func escapeKey(key string) string {
+	return url.PathEscape(key)
+}
+
+// escapePath escapes each segment of a slash separated key.
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// URLForms returns every URL under which images may refer to an object of
+// s: its key escaped as a whole, as URL does, or segment by segment, as some
+// versions stored it.
+func URLForms(s ObjectStorage, collection, key string) []string {
+	prefix := s.URL(collection, "")
+	var forms []string
+	for _, escaped := range []string{escapeKey(key), escapePath(key)} {
+		if u := prefix + escaped; len(forms) == 0 || forms[0] != u {
+			forms = append(forms, u)
+		}
+	}
+	return forms
+}
+
+// locate splits a URL made by prefixing an escaped key with baseURL and its
+// collection.
+func locate(baseURL, u string) (string, string, bool) {
+	rest := strings.TrimPrefix(u, baseURL+"/")
+	if rest == u {
+		return "", "", false
+	}
+	rest, err := url.PathUnescape(rest)
+	if err != nil {
+		return "", "", false
+	}
+	i := strings.Index(rest, "/")
+	if i <= 0 || i == len(rest)-1 {
+		return "", "", false
+	}
+	return rest[:i], rest[i+1:], true
 }
@@ -21,7 +21,9 @@ type payload struct {
 }
 
 const (
-	FieldUserID = "userId"
+	FieldUserID      = "userId"
+	FieldWorkspaceID = "workspaceId"
+	FieldProjectID   = "projectId"
 )
 
 func ApplyVerifyToken() gin.HandlerFunc {
@@ -1,7 +1,59 @@
 package clock
 
-import "time"
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the time source of the code that depends on the current time, so
+// that tests can control it.
+type Clock interface {
+	Now() time.Time
+}
 
 func UnixMillisecondFromTime(t time.Time) int64 {
 	return t.UnixNano() / int64(time.Millisecond)
 }
+
+func TimeFromUnixMillisecond(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+type realClock struct{}
+
+// New returns the Clock backed by the system time.
+func New() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Mock is a Clock that only moves when told to.
+type Mock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewMock(now time.Time) *Mock {
+	return &Mock{now: now}
+}
+
+func (m *Mock) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+func (m *Mock) Set(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+func (m *Mock) Add(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+}
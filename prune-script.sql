@@ -39,3 +39,6 @@ ALTER TABLE projects MODIFY description TEXT;
 ALTER TABLE datasets MODIFY description TEXT;
 ALTER TABLE tasks MODIFY description TEXT;
 
+
+-- The stored originals and thumbnails of the truncated images are left in
+-- the buckets, run `go run ./cmd/storagegc -grace 0 -delete` to remove them.